@@ -0,0 +1,34 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+const (
+	backendPrefix = "/api/backend"
+)
+
+func GetBackendCmd(ctx *Context) *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:   "backend [command]",
+		Short: "",
+	}
+	rootCmd.AddCommand(GetBackendWatchCmd(ctx))
+	return rootCmd
+}
+
+func GetBackendWatchCmd(ctx *Context) *cobra.Command {
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "tail the backend health events",
+	}
+	watchCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return doStreamRequest(cmd.Context(), ctx, backendPrefix+"/events", func(data string) {
+			cmd.Println(data)
+		})
+	}
+	return watchCmd
+}
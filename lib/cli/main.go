@@ -87,5 +87,7 @@ func GetRootCmd(tlsConfig *tls.Config) *cobra.Command {
 	rootCmd.AddCommand(GetConfigCmd(ctx))
 	rootCmd.AddCommand(GetHealthCmd(ctx))
 	rootCmd.AddCommand(GetTrafficCmd(ctx))
+	rootCmd.AddCommand(GetBackendCmd(ctx))
+	rootCmd.AddCommand(GetSQLCmd(ctx))
 	return rootCmd
 }
@@ -0,0 +1,49 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cli
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	sqlPrefix = "/api/sql"
+)
+
+func GetSQLCmd(ctx *Context) *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:   "sql [command]",
+		Short: "",
+	}
+	rootCmd.AddCommand(GetSQLTopCmd(ctx))
+	return rootCmd
+}
+
+func GetSQLTopCmd(ctx *Context) *cobra.Command {
+	topCmd := &cobra.Command{
+		Use:   "top [flags]",
+		Short: "show the statistics of the top SQL digests",
+	}
+	groupBy := topCmd.PersistentFlags().String("group-by", "all", "the dimensions to aggregate by, one of digest, user, backend and all")
+	orderBy := topCmd.PersistentFlags().String("order-by", "total_latency", "the field to order by, one of total_latency, avg_latency, max_latency, count, errors and bytes")
+	limit := topCmd.PersistentFlags().Int("limit", 10, "the number of records to show, 0 means no limit")
+	topCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		params := url.Values{}
+		params.Set("group-by", *groupBy)
+		params.Set("order-by", *orderBy)
+		params.Set("limit", strconv.Itoa(*limit))
+		resp, err := doRequest(cmd.Context(), ctx, http.MethodGet, sqlPrefix+"/stats?"+params.Encode(), nil)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(resp)
+		return nil
+	}
+	return topCmd
+}
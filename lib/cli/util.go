@@ -4,6 +4,7 @@
 package cli
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"io"
@@ -24,7 +25,7 @@ type Context struct {
 	SSL    bool
 }
 
-func doRequest(ctx context.Context, bctx *Context, method string, url string, rd io.Reader) (string, error) {
+func sendRequest(ctx context.Context, bctx *Context, method string, url string, rd io.Reader) (*http.Response, error) {
 	var sep string
 	if len(url) > 0 && url[0] != '/' {
 		sep = "/"
@@ -37,7 +38,7 @@ func doRequest(ctx context.Context, bctx *Context, method string, url string, rd
 
 	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s://localhost%s%s", schema, sep, url), rd)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if method == http.MethodPost {
@@ -57,9 +58,17 @@ func doRequest(ctx context.Context, bctx *Context, method string, url string, rd
 			res, err = bctx.Client.Do(req)
 		}
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
+	return res, nil
+}
+
+func doRequest(ctx context.Context, bctx *Context, method string, url string, rd io.Reader) (string, error) {
+	res, err := sendRequest(ctx, bctx, method, url, rd)
+	if err != nil {
+		return "", err
+	}
 	resb, _ := io.ReadAll(res.Body)
 	res.Body.Close()
 
@@ -71,6 +80,32 @@ func doRequest(ctx context.Context, bctx *Context, method string, url string, rd
 	}
 }
 
+// doStreamRequest reads Server-Sent Events from the URL and calls `f` with the data of each event.
+// It returns when the server closes the stream or the context is canceled.
+func doStreamRequest(ctx context.Context, bctx *Context, url string, f func(data string)) error {
+	res, err := sendRequest(ctx, bctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		resb, _ := io.ReadAll(res.Body)
+		return errors.Errorf("%s: %s", res.Status, string(resb))
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			f(strings.TrimSpace(data))
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
 func GetFormReader(m map[string]string) io.Reader {
 	form := url.Values{}
 	for key, value := range m {
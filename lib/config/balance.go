@@ -3,7 +3,11 @@
 
 package config
 
-import "github.com/pingcap/tiproxy/lib/util/errors"
+import (
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
 
 const (
 	BalancePolicyResource   = "resource"
@@ -18,6 +22,8 @@ const (
 	MatchClientCIDRStr = "client_cidr"
 	// MatchProxyCIDRStr is used for MatchProxyCIDR.
 	MatchProxyCIDRStr = "proxy_cidr"
+	// MatchSNIStr is used for MatchSNI.
+	MatchSNIStr = "sni"
 )
 
 type Balance struct {
@@ -31,6 +37,7 @@ type Balance struct {
 	CPU           Factor          `yaml:"cpu,omitempty" toml:"cpu,omitempty" json:"cpu,omitempty" reloadable:"true"`
 	Location      Factor          `yaml:"location,omitempty" toml:"location,omitempty" json:"location,omitempty" reloadable:"true"`
 	ConnCount     ConnCountFactor `yaml:"conn-count,omitempty" toml:"conn-count,omitempty" json:"conn-count,omitempty" reloadable:"true"`
+	RTT           RTTFactor       `yaml:"rtt,omitempty" toml:"rtt,omitempty" json:"rtt,omitempty" reloadable:"true"`
 }
 
 type ConnCountFactor struct {
@@ -38,6 +45,14 @@ type ConnCountFactor struct {
 	CountRatioThreshold float64 `yaml:"count-ratio-threshold,omitempty" toml:"count-ratio-threshold,omitempty" json:"count-ratio-threshold,omitempty" reloadable:"true"`
 }
 
+// RTTFactor prefers the backends with lower network latency. It only works for the resource and location policies.
+type RTTFactor struct {
+	Factor `yaml:",inline" toml:",inline" json:",inline"`
+	Enable bool `yaml:"enable,omitempty" toml:"enable,omitempty" json:"enable,omitempty" reloadable:"true"`
+	// Backends whose RTT differences are within the tolerance are treated as equal.
+	Tolerance time.Duration `yaml:"tolerance,omitempty" toml:"tolerance,omitempty" json:"tolerance,omitempty" reloadable:"true"`
+}
+
 type Factor struct {
 	MigrationsPerSecond float64 `yaml:"migrations-per-second,omitempty" toml:"migrations-per-second,omitempty" json:"migrations-per-second,omitempty" reloadable:"true"`
 }
@@ -52,7 +67,7 @@ func (b *Balance) Check() error {
 	}
 
 	switch b.RoutingRule {
-	case MatchClientCIDRStr, MatchProxyCIDRStr, "":
+	case MatchClientCIDRStr, MatchProxyCIDRStr, MatchSNIStr, "":
 	default:
 		return errors.Wrapf(ErrInvalidConfigValue, "invalid balance.routing-rule")
 	}
@@ -83,6 +98,12 @@ func (b *Balance) Check() error {
 	if b.ConnCount.MigrationsPerSecond < 0 {
 		return errors.Wrapf(ErrInvalidConfigValue, "invalid balance.conn-count.migrations-per-second")
 	}
+	if b.RTT.MigrationsPerSecond < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid balance.rtt.migrations-per-second")
+	}
+	if b.RTT.Tolerance < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid balance.rtt.tolerance")
+	}
 	if b.ConnCount.CountRatioThreshold != 0 && b.ConnCount.CountRatioThreshold <= 1 {
 		return errors.Wrapf(ErrInvalidConfigValue, "invalid balance.conn-count.count-ratio-threshold")
 	}
@@ -5,6 +5,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -38,6 +39,12 @@ func TestCheckBalance(t *testing.T) {
 		{
 			ConnCount: ConnCountFactor{CountRatioThreshold: -1},
 		},
+		{
+			RTT: RTTFactor{Factor: Factor{MigrationsPerSecond: -1}},
+		},
+		{
+			RTT: RTTFactor{Tolerance: -1},
+		},
 	}
 
 	for i, balance := range balances {
@@ -46,6 +53,8 @@ func TestCheckBalance(t *testing.T) {
 
 	balance := Balance{}
 	require.NoError(t, (&balance).Check())
+	balance = Balance{RTT: RTTFactor{Enable: true, Tolerance: time.Millisecond}}
+	require.NoError(t, (&balance).Check())
 	balance = DefaultBalance()
 	require.NoError(t, (&balance).Check())
 }
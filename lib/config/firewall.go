@@ -0,0 +1,165 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+const (
+	FirewallActionAllow = "allow"
+	FirewallActionDeny  = "deny"
+	FirewallActionLog   = "log"
+)
+
+// Firewall checks COM_QUERY and COM_STMT_PREPARE before they are sent to the backends.
+// The rules are evaluated in order and the first matched rule decides the action. Statements matching no rule are allowed.
+type Firewall struct {
+	Enable bool           `yaml:"enable,omitempty" toml:"enable,omitempty" json:"enable,omitempty" reloadable:"true"`
+	Rules  []FirewallRule `yaml:"rules,omitempty" toml:"rules,omitempty" json:"rules,omitempty" reloadable:"true"`
+	// TimeZone is the IANA time zone of the maintenance windows. It's the local time zone if it's empty.
+	TimeZone string `yaml:"time-zone,omitempty" toml:"time-zone,omitempty" json:"time-zone,omitempty" reloadable:"true"`
+}
+
+// FirewallRule matches a statement if all the non-empty conditions match.
+type FirewallRule struct {
+	// Name is used in logs and metrics.
+	Name string `yaml:"name,omitempty" toml:"name,omitempty" json:"name,omitempty"`
+	// Action is one of allow, deny and log.
+	Action string `yaml:"action,omitempty" toml:"action,omitempty" json:"action,omitempty"`
+	// Message is returned to the client when the statement is denied.
+	Message string   `yaml:"message,omitempty" toml:"message,omitempty" json:"message,omitempty"`
+	Users   []string `yaml:"users,omitempty" toml:"users,omitempty" json:"users,omitempty"`
+	CIDRs   []string `yaml:"cidrs,omitempty" toml:"cidrs,omitempty" json:"cidrs,omitempty"`
+	// StmtTypes are the statement types returned by the TiDB parser, such as DropTable and TruncateTable.
+	// DDL and DML match all the DDL and DML statements.
+	StmtTypes []string `yaml:"stmt-types,omitempty" toml:"stmt-types,omitempty" json:"stmt-types,omitempty"`
+	// Digest pins the rule to one statement shape, e.g. a digest copied from TiDB statement summary.
+	Digest string `yaml:"digest,omitempty" toml:"digest,omitempty" json:"digest,omitempty"`
+	// Regex covers many statement shapes, e.g. `^select .* from secret\b`. It's matched against the normalized SQL,
+	// so the literals must be written as `?`.
+	Regex string `yaml:"regex,omitempty" toml:"regex,omitempty" json:"regex,omitempty"`
+	// MaintenanceWindows skip the rule during the windows, e.g. to allow DROP TABLE only in the windows.
+	// The format is `[weekdays ]HH:MM-HH:MM`, such as `Sat,Sun 02:00-04:00` and `23:00-01:00`.
+	MaintenanceWindows []string `yaml:"maintenance-windows,omitempty" toml:"maintenance-windows,omitempty" json:"maintenance-windows,omitempty"`
+}
+
+// TimeWindow is a time range of a day on some weekdays. The range crosses midnight if End is before Start.
+type TimeWindow struct {
+	// Weekdays are the days when the window starts. Empty means every day.
+	Weekdays []time.Weekday
+	// Start and End are the offsets from midnight.
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseTimeWindow parses the window in the format of `[weekdays ]HH:MM-HH:MM`.
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	var window TimeWindow
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+	case 2:
+		for _, day := range strings.Split(fields[0], ",") {
+			weekday, ok := parseWeekday(day)
+			if !ok {
+				return window, errors.Errorf("invalid weekday %s in time window %s", day, s)
+			}
+			window.Weekdays = append(window.Weekdays, weekday)
+		}
+	default:
+		return window, errors.Errorf("invalid time window %s", s)
+	}
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return window, errors.Errorf("invalid time range in time window %s", s)
+	}
+	var okStart, okEnd bool
+	window.Start, okStart = parseClock(start)
+	window.End, okEnd = parseClock(end)
+	if !okStart || !okEnd {
+		return window, errors.Errorf("invalid clock in time window %s", s)
+	}
+	return window, nil
+}
+
+// Contains returns whether the time is in the window. The time should be in the time zone of the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.Start <= w.End {
+		return w.onDay(t.Weekday()) && offset >= w.Start && offset < w.End
+	}
+	// The window crosses midnight, so it may start on the previous day.
+	return (w.onDay(t.Weekday()) && offset >= w.Start) || (w.onDay((t.Weekday()+6)%7) && offset < w.End)
+}
+
+func (w TimeWindow) onDay(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, weekday := range w.Weekdays {
+		if weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(s, day.String()) || strings.EqualFold(s, day.String()[:3]) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// parseClock parses HH:MM to the offset from midnight. 24:00 is allowed to represent the end of a day.
+func parseClock(s string) (time.Duration, bool) {
+	hour, minute, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, false
+	}
+	h, errH := strconv.Atoi(hour)
+	m, errM := strconv.Atoi(minute)
+	if errH != nil || errM != nil || h < 0 || m < 0 || m >= 60 || h > 24 || (h == 24 && m > 0) {
+		return 0, false
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, true
+}
+
+func (fw *Firewall) Check() error {
+	if _, err := time.LoadLocation(fw.TimeZone); err != nil {
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid firewall.time-zone %s: %s", fw.TimeZone, err.Error())
+	}
+	for _, rule := range fw.Rules {
+		switch rule.Action {
+		case FirewallActionAllow, FirewallActionDeny, FirewallActionLog:
+		default:
+			return errors.Wrapf(ErrInvalidConfigValue, "invalid firewall.rules.action %s", rule.Action)
+		}
+		for _, cidr := range rule.CIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return errors.Wrapf(ErrInvalidConfigValue, "invalid firewall.rules.cidrs %s: %s", cidr, err.Error())
+			}
+		}
+		if len(rule.Regex) > 0 {
+			if _, err := regexp.Compile(rule.Regex); err != nil {
+				return errors.Wrapf(ErrInvalidConfigValue, "invalid firewall.rules.regex %s: %s", rule.Regex, err.Error())
+			}
+		}
+		for _, window := range rule.MaintenanceWindows {
+			if _, err := ParseTimeWindow(window); err != nil {
+				return errors.Wrapf(ErrInvalidConfigValue, "invalid firewall.rules.maintenance-windows: %s", err.Error())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFirewall(t *testing.T) {
+	firewalls := []Firewall{
+		{
+			Rules: []FirewallRule{{}},
+		},
+		{
+			Rules: []FirewallRule{{Action: "reject"}},
+		},
+		{
+			Rules: []FirewallRule{{Action: FirewallActionDeny, CIDRs: []string{"10.0.0.1"}}},
+		},
+		{
+			Rules: []FirewallRule{{Action: FirewallActionDeny, Regex: "select ("}},
+		},
+		{
+			Rules: []FirewallRule{{Action: FirewallActionDeny, MaintenanceWindows: []string{"Someday 02:00-04:00"}}},
+		},
+		{
+			TimeZone: "Mars/Olympus",
+		},
+	}
+	for i, fw := range firewalls {
+		require.ErrorIs(t, fw.Check(), ErrInvalidConfigValue, "%d", i)
+	}
+
+	fw := Firewall{
+		Enable: true,
+		Rules: []FirewallRule{
+			{Name: "admin", Action: FirewallActionAllow, Users: []string{"root"}, CIDRs: []string{"10.0.0.0/8"}},
+			{Name: "no-ddl", Action: FirewallActionDeny, StmtTypes: []string{"DDL"}, Message: "DDL is not allowed"},
+			{Name: "audit", Action: FirewallActionLog, Regex: "from `?orders`?"},
+			{Name: "no-drop", Action: FirewallActionDeny, StmtTypes: []string{"DropTable"}, MaintenanceWindows: []string{"Sat,Sun 02:00-04:00"}},
+		},
+		TimeZone: "UTC",
+	}
+	require.NoError(t, fw.Check())
+	require.NoError(t, NewConfig().Firewall.Check())
+}
+
+func TestTimeWindow(t *testing.T) {
+	invalid := []string{"", "02:00", "02:00-", "25:00-26:00", "02:60-03:00", "Mon Tue 02:00-03:00", "Mon,Someday 02:00-03:00", "a:b-c:d"}
+	for _, s := range invalid {
+		_, err := ParseTimeWindow(s)
+		require.Error(t, err, s)
+	}
+
+	// 2025-01-04 is Saturday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		window   string
+		t        time.Time
+		contains bool
+	}{
+		{"02:00-04:00", at(1, 2, 0), true},
+		{"02:00-04:00", at(1, 4, 0), false},
+		{"02:00-04:00", at(1, 1, 59), false},
+		{"Sat,sunday 02:00-04:00", at(4, 3, 0), true},
+		{"Sat,sunday 02:00-04:00", at(5, 3, 0), true},
+		{"Sat,sunday 02:00-04:00", at(6, 3, 0), false},
+		{"Fri 23:00-01:00", at(3, 23, 30), true},
+		{"Fri 23:00-01:00", at(4, 0, 30), true},
+		{"Fri 23:00-01:00", at(4, 23, 30), false},
+		{"Fri 23:00-01:00", at(3, 0, 30), false},
+		{"00:00-24:00", at(3, 23, 59), true},
+	}
+	for _, test := range tests {
+		window, err := ParseTimeWindow(test.window)
+		require.NoError(t, err, test.window)
+		require.Equal(t, test.contains, window.Contains(test.t), "%s %s", test.window, test.t)
+	}
+}
@@ -9,6 +9,8 @@ const (
 	LocationLabelName = "zone"
 	KeyspaceLabelName = "keyspace"
 	CidrLabelName     = "cidr"
+	// SNILabelName indicates the comma-separated SNI hostnames that are routed to the backend.
+	SNILabelName = "sni"
 )
 
 func (cfg *Config) GetLocation() string {
@@ -5,6 +5,7 @@ package config
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -16,13 +17,23 @@ type Namespace struct {
 }
 
 type FrontendNamespace struct {
-	User     string    `yaml:"user" json:"user" toml:"user"`
-	Security TLSConfig `yaml:"security" json:"security" toml:"security"`
+	User string `yaml:"user" json:"user" toml:"user"`
+	// VPCEndpointIDs routes the clients from these VPC endpoints to this namespace.
+	// The ID is read from the PROXY protocol v2 header sent by the cloud load balancer.
+	VPCEndpointIDs []string `yaml:"vpc-endpoint-ids,omitempty" json:"vpc-endpoint-ids,omitempty" toml:"vpc-endpoint-ids,omitempty"`
+	// SNIHosts routes the TLS clients that connect with these SNI hostnames to this namespace. They are case-insensitive.
+	SNIHosts []string `yaml:"sni-hosts,omitempty" json:"sni-hosts,omitempty" toml:"sni-hosts,omitempty"`
+	// StatementTimeout overrides the default statement timeout in the proxy config for this namespace.
+	StatementTimeout time.Duration `yaml:"statement-timeout,omitempty" json:"statement-timeout,omitempty" toml:"statement-timeout,omitempty"`
+	// LoadDataLocal overrides the default LOAD DATA LOCAL INFILE policy in the proxy config for this namespace.
+	LoadDataLocal *LoadDataPolicy `yaml:"load-data-local,omitempty" json:"load-data-local,omitempty" toml:"load-data-local,omitempty"`
+	Security      TLSConfig       `yaml:"security" json:"security" toml:"security"`
 }
 
 type BackendNamespace struct {
-	Instances []string  `yaml:"instances" json:"instances" toml:"instances"`
-	Security  TLSConfig `yaml:"security" json:"security" toml:"security"`
+	Instances []string `yaml:"instances" json:"instances" toml:"instances"`
+	// Security overrides sql-tls for the backends of this namespace if ca or skip-ca is set.
+	Security TLSConfig `yaml:"security" json:"security" toml:"security"`
 }
 
 func NewNamespace(data []byte) (*Namespace, error) {
@@ -5,6 +5,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -12,7 +13,9 @@ import (
 var testNamespaceConfig = Namespace{
 	Namespace: "test_ns",
 	Frontend: FrontendNamespace{
-		User: "xx",
+		User:             "xx",
+		StatementTimeout: time.Minute,
+		LoadDataLocal:    &LoadDataPolicy{MaxFileSize: 10},
 		Security: TLSConfig{
 			CA:        "t",
 			Cert:      "t",
@@ -9,6 +9,8 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +19,11 @@ import (
 	"github.com/pingcap/tiproxy/lib/util/errors"
 )
 
+const (
+	// UnixSocketPrefix is the prefix of a Unix domain socket address in proxy.addr, e.g. unix:///var/run/tiproxy.sock.
+	UnixSocketPrefix = "unix://"
+)
+
 var (
 	ErrUnsupportedProxyProtocolVersion = errors.New("unsupported proxy protocol version")
 	ErrInvalidConfigValue              = errors.New("invalid config value")
@@ -33,6 +40,10 @@ type Config struct {
 	HA                  HA                    `yaml:"ha,omitempty" toml:"ha,omitempty" json:"ha,omitempty"`
 	Metering            config.MeteringConfig `yaml:"metering,omitempty" toml:"metering,omitempty" json:"metering,omitempty" reloadable:"false"`
 	EnableTrafficReplay bool                  `yaml:"enable-traffic-replay,omitempty" toml:"enable-traffic-replay,omitempty" json:"enable-traffic-replay,omitempty" reloadable:"true"`
+	ResultCache         ResultCache           `yaml:"result-cache,omitempty" toml:"result-cache,omitempty" json:"result-cache,omitempty"`
+	Firewall            Firewall              `yaml:"firewall,omitempty" toml:"firewall,omitempty" json:"firewall,omitempty"`
+	QueryRewrite        QueryRewrite          `yaml:"query-rewrite,omitempty" toml:"query-rewrite,omitempty" json:"query-rewrite,omitempty"`
+	SQLStats            SQLStats              `yaml:"sql-stats,omitempty" toml:"sql-stats,omitempty" json:"sql-stats,omitempty"`
 }
 
 type KeepAlive struct {
@@ -63,15 +74,110 @@ type ProxyServerOnline struct {
 	GracefulCloseConnTimeout   int `yaml:"graceful-close-conn-timeout,omitempty" toml:"graceful-close-conn-timeout,omitempty" json:"graceful-close-conn-timeout,omitempty" reloadable:"true"`
 	// Public and private traffic are metered separately.
 	PublicEndpoints []string `yaml:"public-endpoints,omitempty" toml:"public-endpoints,omitempty" json:"public-endpoints,omitempty" reloadable:"true"`
+	// ConnPool shares backend connections among client connections between transactions.
+	ConnPool ConnPool `yaml:"conn-pool" toml:"conn-pool" json:"conn-pool"`
+	// StatementTimeout kills the statements that run longer than the timeout on the backends.
+	StatementTimeout StatementTimeout `yaml:"statement-timeout" toml:"statement-timeout" json:"statement-timeout"`
+	// LoadDataLocal restricts LOAD DATA LOCAL INFILE, which lets the backends read files from the clients.
+	LoadDataLocal LoadDataLocal `yaml:"load-data-local" toml:"load-data-local" json:"load-data-local"`
+	// RetryReads re-executes the autocommit read-only statements on another backend if the backend fails before
+	// responding. The session states are queried before the reads, so it adds some latency. It only affects new connections.
+	RetryReads bool `yaml:"retry-reads,omitempty" toml:"retry-reads,omitempty" json:"retry-reads,omitempty" reloadable:"true"`
+	// SessionFailover restores the idle sessions on other backends when their backends are lost unexpectedly.
+	SessionFailover SessionFailover `yaml:"session-failover" toml:"session-failover" json:"session-failover"`
+}
+
+// ConnPool is the config of the transaction-level connection pooling mode.
+// The backend connection is bound to the client only during a transaction or a statement.
+type ConnPool struct {
+	Enable bool `yaml:"enable,omitempty" toml:"enable,omitempty" json:"enable,omitempty" reloadable:"true"`
+	// MaxIdleConns is the maximum number of idle connections for each user on each backend.
+	MaxIdleConns int `yaml:"max-idle-conns,omitempty" toml:"max-idle-conns,omitempty" json:"max-idle-conns,omitempty" reloadable:"true"`
+	// IdleTimeout is the time after which an idle connection is closed.
+	IdleTimeout time.Duration `yaml:"idle-timeout,omitempty" toml:"idle-timeout,omitempty" json:"idle-timeout,omitempty" reloadable:"true"`
+}
+
+// StatementTimeout is the timeout of COM_QUERY and COM_STMT_EXECUTE. 0 means no timeout.
+// The user timeout overrides the namespace timeout, which overrides the default one. It only affects new connections.
+type StatementTimeout struct {
+	Default time.Duration          `yaml:"default,omitempty" toml:"default,omitempty" json:"default,omitempty" reloadable:"true"`
+	Users   []UserStatementTimeout `yaml:"users,omitempty" toml:"users,omitempty" json:"users,omitempty" reloadable:"true"`
+}
+
+type UserStatementTimeout struct {
+	User    string        `yaml:"user,omitempty" toml:"user,omitempty" json:"user,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty" toml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// LoadDataLocal is the policy of LOAD DATA LOCAL INFILE.
+// The user policy overrides the namespace policy, which overrides the default one. It only affects new connections.
+type LoadDataLocal struct {
+	Default LoadDataPolicy       `yaml:"default,omitempty" toml:"default,omitempty" json:"default,omitempty" reloadable:"true"`
+	Users   []UserLoadDataPolicy `yaml:"users,omitempty" toml:"users,omitempty" json:"users,omitempty" reloadable:"true"`
+	// MaxMemory is the memory limit in MB of all the files held for MaxFileSize.
+	// A file is rejected if holding it exceeds the limit.
+	MaxMemory int `yaml:"max-memory,omitempty" toml:"max-memory,omitempty" json:"max-memory,omitempty" reloadable:"true"`
+}
+
+type LoadDataPolicy struct {
+	// Disable rejects all the LOAD DATA LOCAL INFILE statements.
+	Disable bool `yaml:"disable,omitempty" toml:"disable,omitempty" json:"disable,omitempty"`
+	// MaxFileSize is the size limit of a transferred file in MB. 0 means no limit.
+	// The file is held in the proxy until it's fully received so that an oversized file loads no rows.
+	MaxFileSize int `yaml:"max-file-size,omitempty" toml:"max-file-size,omitempty" json:"max-file-size,omitempty"`
+}
+
+type UserLoadDataPolicy struct {
+	User           string `yaml:"user,omitempty" toml:"user,omitempty" json:"user,omitempty"`
+	LoadDataPolicy `yaml:",inline" toml:",inline" json:",inline"`
+}
+
+// SessionFailover keeps the snapshots of the session states of idle sessions so that the sessions can be restored on
+// other backends when their backends are lost. Sessions in transactions or with unmigratable states are not restored.
+type SessionFailover struct {
+	Enable bool `yaml:"enable,omitempty" toml:"enable,omitempty" json:"enable,omitempty" reloadable:"true"`
+	// SnapshotInterval is the interval to refresh the snapshot of an idle session. It must be shorter than 1 minute,
+	// which is the lifetime of the session token.
+	SnapshotInterval time.Duration `yaml:"snapshot-interval,omitempty" toml:"snapshot-interval,omitempty" json:"snapshot-interval,omitempty" reloadable:"true"`
+	// MaxMemory is the memory limit of all the snapshots in MB, including the ones for RetryReads.
+	// The sessions can't fail over if their snapshots exceed the limit.
+	MaxMemory int `yaml:"max-memory,omitempty" toml:"max-memory,omitempty" json:"max-memory,omitempty" reloadable:"true"`
 }
 
 type ProxyServer struct {
-	Addr              string `yaml:"addr,omitempty" toml:"addr,omitempty" json:"addr,omitempty" reloadable:"false"`
-	AdvertiseAddr     string `yaml:"advertise-addr,omitempty" toml:"advertise-addr,omitempty" json:"advertise-addr,omitempty" reloadable:"false"`
-	PDAddrs           string `yaml:"pd-addrs,omitempty" toml:"pd-addrs,omitempty" json:"pd-addrs,omitempty" reloadable:"false"`
+	Addr          string `yaml:"addr,omitempty" toml:"addr,omitempty" json:"addr,omitempty" reloadable:"false"`
+	AdvertiseAddr string `yaml:"advertise-addr,omitempty" toml:"advertise-addr,omitempty" json:"advertise-addr,omitempty" reloadable:"false"`
+	PDAddrs       string `yaml:"pd-addrs,omitempty" toml:"pd-addrs,omitempty" json:"pd-addrs,omitempty" reloadable:"false"`
+	// UnixSocketPermission is the file mode of the Unix domain sockets in octal, e.g. "0660".
+	UnixSocketPermission string `yaml:"unix-socket-permission,omitempty" toml:"unix-socket-permission,omitempty" json:"unix-socket-permission,omitempty" reloadable:"false"`
+	// Listeners are the extra listeners that have their own settings, in addition to the ones in Addr.
+	Listeners         []ProxyListener `yaml:"listeners,omitempty" toml:"listeners,omitempty" json:"listeners,omitempty" reloadable:"false"`
 	ProxyServerOnline `yaml:",inline" toml:",inline" json:",inline"`
 }
 
+// ProxyListener is a listener with its own settings.
+type ProxyListener struct {
+	Addr string `yaml:"addr,omitempty" toml:"addr,omitempty" json:"addr,omitempty"`
+	// ProxyProtocol is not inherited from proxy.proxy-protocol, so empty means disabled.
+	// It only controls parsing the PROXY header from clients. Sending the PROXY header to backends is still
+	// controlled by proxy.proxy-protocol because it depends on the backends.
+	ProxyProtocol string `yaml:"proxy-protocol,omitempty" toml:"proxy-protocol,omitempty" json:"proxy-protocol,omitempty"`
+	// MaxConnections limits the connections of this listener. The global max-connections still works.
+	MaxConnections uint64 `yaml:"max-connections,omitempty" toml:"max-connections,omitempty" json:"max-connections,omitempty"`
+	// Namespace is used when the user doesn't match any namespace.
+	Namespace string `yaml:"namespace,omitempty" toml:"namespace,omitempty" json:"namespace,omitempty"`
+	// RoutingGroup routes the connections to the backend group that has this value, such as a CIDR or an SNI
+	// hostname in the backend labels, instead of matching balance.routing-rule.
+	RoutingGroup string `yaml:"routing-group,omitempty" toml:"routing-group,omitempty" json:"routing-group,omitempty"`
+	// ServerTLS overrides security.server-tls if it has certs or enables auto-certs.
+	ServerTLS TLSConfig `yaml:"server-tls,omitempty" toml:"server-tls,omitempty" json:"server-tls,omitempty"`
+}
+
+// HasServerTLS returns true if the listener overrides security.server-tls.
+func (l ProxyListener) HasServerTLS() bool {
+	return l.ServerTLS.HasCert() || l.ServerTLS.AutoCerts
+}
+
 type API struct {
 	Addr          string `yaml:"addr,omitempty" toml:"addr,omitempty" json:"addr,omitempty" reloadable:"false"`
 	ProxyProtocol string `yaml:"proxy-protocol,omitempty" toml:"proxy-protocol,omitempty" json:"proxy-protocol,omitempty" reloadable:"false"`
@@ -86,6 +192,15 @@ type Log struct {
 	Encoder   string `yaml:"encoder,omitempty" toml:"encoder,omitempty" json:"encoder,omitempty" reloadable:"false"`
 	Simple    bool   `yaml:"simple,omitempty" toml:"simple,omitempty" json:"simple,omitempty" reloadable:"false"`
 	LogOnline `yaml:",inline" toml:",inline" json:",inline"`
+	// SlowLog records the commands whose end-to-end latency exceeds the threshold.
+	SlowLog SlowLog `yaml:"slow-log,omitempty" toml:"slow-log,omitempty" json:"slow-log,omitempty"`
+}
+
+// SlowLog is the config of the slow command log. The log is written to stdout if the filename is empty.
+type SlowLog struct {
+	// Threshold is the end-to-end latency above which a command is logged. 0 disables the slow log.
+	Threshold time.Duration `yaml:"threshold,omitempty" toml:"threshold,omitempty" json:"threshold,omitempty" reloadable:"true"`
+	LogFile   LogFile       `yaml:"log-file,omitempty" toml:"log-file,omitempty" json:"log-file,omitempty"`
 }
 
 type LogFile struct {
@@ -122,6 +237,11 @@ func NewConfig() *Config {
 	cfg.Proxy.FrontendKeepalive, cfg.Proxy.BackendHealthyKeepalive, cfg.Proxy.BackendUnhealthyKeepalive = DefaultKeepAlive()
 	cfg.Proxy.PDAddrs = "127.0.0.1:2379"
 	cfg.Proxy.GracefulCloseConnTimeout = 15
+	cfg.Proxy.ConnPool.MaxIdleConns = 16
+	cfg.Proxy.ConnPool.IdleTimeout = 10 * time.Minute
+	cfg.Proxy.SessionFailover.SnapshotInterval = 30 * time.Second
+	cfg.Proxy.SessionFailover.MaxMemory = 128
+	cfg.Proxy.LoadDataLocal.MaxMemory = 256
 
 	cfg.API.Addr = "0.0.0.0:3080"
 
@@ -130,6 +250,9 @@ func NewConfig() *Config {
 	cfg.Log.LogFile.MaxSize = 300
 	cfg.Log.LogFile.MaxDays = 3
 	cfg.Log.LogFile.MaxBackups = 3
+	cfg.Log.SlowLog.LogFile.MaxSize = 300
+	cfg.Log.SlowLog.LogFile.MaxDays = 3
+	cfg.Log.SlowLog.LogFile.MaxBackups = 3
 
 	cfg.Security.SQLTLS.MinTLSVersion = "1.2"
 	cfg.Security.ServerSQLTLS.MinTLSVersion = "1.2"
@@ -140,6 +263,11 @@ func NewConfig() *Config {
 
 	cfg.EnableTrafficReplay = true
 
+	cfg.ResultCache.MaxMemory = 64
+	cfg.ResultCache.MaxResultSize = 1024
+	cfg.SQLStats.MaxDigests = 1000
+	cfg.SQLStats.ResetInterval = time.Hour
+
 	return &cfg
 }
 
@@ -158,8 +286,40 @@ func (cfg *Config) Check() error {
 		cfg.Workdir = filepath.Clean(filepath.Join(d, "work"))
 	}
 
+	addrs := strings.Split(cfg.Proxy.Addr, ",")
+	for _, listener := range cfg.Proxy.Listeners {
+		if len(listener.Addr) == 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "proxy.listeners.addr is empty")
+		}
+		if slices.Contains(addrs, listener.Addr) {
+			return errors.Wrapf(ErrInvalidConfigValue, "duplicated listener address %s", listener.Addr)
+		}
+		switch listener.ProxyProtocol {
+		case "", "v1", "v2":
+		default:
+			return errors.Wrapf(ErrUnsupportedProxyProtocolVersion, "%s", listener.ProxyProtocol)
+		}
+		if len(listener.RoutingGroup) > 0 && len(cfg.Balance.RoutingRule) == 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "proxy.listeners.routing-group requires balance.routing-rule")
+		}
+		if err := listener.ServerTLS.Check("proxy.listeners.server-tls"); err != nil {
+			return err
+		}
+		addrs = append(addrs, listener.Addr)
+	}
+	for _, addr := range addrs {
+		if network, address := ParseListenAddr(addr); network == "unix" && len(address) == 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "invalid unix socket address %s", addr)
+		}
+	}
+	if len(cfg.Proxy.UnixSocketPermission) > 0 {
+		if _, err := ParseUnixSocketPermission(cfg.Proxy.UnixSocketPermission); err != nil {
+			return err
+		}
+	}
+
 	switch cfg.Proxy.ProxyProtocol {
-	case "v2":
+	case "v1", "v2":
 	case "":
 	default:
 		return errors.Wrapf(ErrUnsupportedProxyProtocolVersion, "%s", cfg.Proxy.ProxyProtocol)
@@ -168,10 +328,79 @@ func (cfg *Config) Check() error {
 	if cfg.Proxy.ConnBufferSize > 0 && (cfg.Proxy.ConnBufferSize > 16*1024*1024 || cfg.Proxy.ConnBufferSize < 1024) {
 		return errors.Wrapf(ErrInvalidConfigValue, "conn-buffer-size must be between 1K and 16M")
 	}
+	if cfg.Proxy.ConnPool.MaxIdleConns < 0 || cfg.Proxy.ConnPool.IdleTimeout < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "conn-pool.max-idle-conns and conn-pool.idle-timeout must not be negative")
+	}
+	if cfg.Proxy.SessionFailover.SnapshotInterval < 0 || cfg.Proxy.SessionFailover.SnapshotInterval >= time.Minute {
+		return errors.Wrapf(ErrInvalidConfigValue, "session-failover.snapshot-interval must be shorter than 1m")
+	}
+	if cfg.Proxy.SessionFailover.MaxMemory < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "session-failover.max-memory must not be negative")
+	}
+	if err := cfg.Security.ServerSQLTLS.Check("security.server-tls"); err != nil {
+		return err
+	}
+	if err := cfg.Security.ServerHTTPTLS.Check("security.server-http-tls"); err != nil {
+		return err
+	}
+	if err := cfg.Security.ClusterTLS.Check("security.cluster-tls"); err != nil {
+		return err
+	}
+	if err := cfg.Security.SQLTLS.Check("security.sql-tls"); err != nil {
+		return err
+	}
+	for _, backendTLS := range cfg.Security.BackendTLS {
+		if len(backendTLS.LabelName) == 0 || len(backendTLS.LabelValue) == 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "security.backend-tls.label-name and security.backend-tls.label-value must be set")
+		}
+		if !backendTLS.TLS.HasClientTLS() {
+			return errors.Wrapf(ErrInvalidConfigValue, "security.backend-tls.tls of %s must set ca or skip-ca", backendTLS.Name())
+		}
+		if err := backendTLS.TLS.Check("security.backend-tls.tls"); err != nil {
+			return err
+		}
+	}
+	if cfg.Security.ProxyAuth.Enable && len(cfg.Security.ProxyAuth.UsersFile) == 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "security.proxy-auth.users-file must be set if security.proxy-auth.enable is true")
+	}
+	if cfg.Log.SlowLog.Threshold < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "log.slow-log.threshold must not be negative")
+	}
+	if cfg.Proxy.StatementTimeout.Default < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "statement-timeout.default must not be negative")
+	}
+	for _, ut := range cfg.Proxy.StatementTimeout.Users {
+		if len(ut.User) == 0 || ut.Timeout < 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "statement-timeout.users.user must be set and statement-timeout.users.timeout must not be negative")
+		}
+	}
+	if cfg.Proxy.LoadDataLocal.Default.MaxFileSize < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "load-data-local.default.max-file-size must not be negative")
+	}
+	for _, up := range cfg.Proxy.LoadDataLocal.Users {
+		if len(up.User) == 0 || up.MaxFileSize < 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "load-data-local.users.user must be set and load-data-local.users.max-file-size must not be negative")
+		}
+	}
+	if cfg.Proxy.LoadDataLocal.MaxMemory < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "load-data-local.max-memory must not be negative")
+	}
 
 	if err := cfg.Balance.Check(); err != nil {
 		return err
 	}
+	if err := cfg.ResultCache.Check(); err != nil {
+		return err
+	}
+	if err := cfg.Firewall.Check(); err != nil {
+		return err
+	}
+	if err := cfg.QueryRewrite.Check(); err != nil {
+		return err
+	}
+	if err := cfg.SQLStats.Check(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -182,18 +411,36 @@ func (cfg *Config) ToBytes() ([]byte, error) {
 	return b.Bytes(), errors.WithStack(err)
 }
 
+// GetIPPort returns the advertised IP, the SQL port and the status port.
+// The port is empty if the SQL port only listens on Unix sockets, and then the IP is inferred from the API address.
 func (cfg *Config) GetIPPort() (ip, port, statusPort string, err error) {
+	// Unix sockets are only accessible locally, so report the first TCP address.
+	addr := ""
 	addrs := strings.Split(cfg.Proxy.Addr, ",")
-	ip, port, err = net.SplitHostPort(addrs[0])
-	if err != nil {
-		err = errors.WithStack(err)
-		return
+	for _, listener := range cfg.Proxy.Listeners {
+		addrs = append(addrs, listener.Addr)
+	}
+	for _, a := range addrs {
+		if network, _ := ParseListenAddr(a); network == "tcp" && len(a) > 0 {
+			addr = a
+			break
+		}
 	}
-	_, statusPort, err = net.SplitHostPort(cfg.API.Addr)
+	var apiIP string
+	apiIP, statusPort, err = net.SplitHostPort(cfg.API.Addr)
 	if err != nil {
 		err = errors.WithStack(err)
 		return
 	}
+	if len(addr) > 0 {
+		ip, port, err = net.SplitHostPort(addr)
+		if err != nil {
+			err = errors.WithStack(err)
+			return
+		}
+	} else {
+		ip = apiIP
+	}
 	// AdvertiseAddr may be a DNS in k8s and certificate SAN typically contains DNS but not IP.
 	if len(cfg.Proxy.AdvertiseAddr) > 0 {
 		ip = cfg.Proxy.AdvertiseAddr
@@ -217,3 +464,20 @@ func (cfg *Config) GetIPPort() (ip, port, statusPort string, err error) {
 	}
 	return
 }
+
+// ParseListenAddr returns the network and the address of an entry in proxy.addr.
+func ParseListenAddr(addr string) (network, address string) {
+	if path, ok := strings.CutPrefix(addr, UnixSocketPrefix); ok {
+		return "unix", path
+	}
+	return "tcp", addr
+}
+
+// ParseUnixSocketPermission parses the octal file mode of Unix sockets.
+func ParseUnixSocketPermission(perm string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(perm, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, errors.Wrapf(ErrInvalidConfigValue, "invalid unix-socket-permission %s", perm)
+	}
+	return os.FileMode(mode), nil
+}
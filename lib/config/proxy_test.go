@@ -8,23 +8,51 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pingcap/tiproxy/lib/util/sys"
 	"github.com/stretchr/testify/require"
 )
 
+var sessionTickets = true
+
 var testProxyConfig = Config{
 	Workdir: "./wd",
 	Proxy: ProxyServer{
 		Addr:    "0.0.0.0:4000",
 		PDAddrs: "127.0.0.1:4089",
+		Listeners: []ProxyListener{
+			{
+				Addr:           "0.0.0.0:4001",
+				ProxyProtocol:  "v1",
+				MaxConnections: 10,
+				Namespace:      "ns",
+				ServerTLS:      TLSConfig{AutoCerts: true},
+			},
+		},
 		ProxyServerOnline: ProxyServerOnline{
 			MaxConnections:             1,
 			FrontendKeepalive:          KeepAlive{Enabled: true},
 			ProxyProtocol:              "v2",
 			GracefulWaitBeforeShutdown: 10,
 			ConnBufferSize:             32 * 1024,
+			ConnPool:                   ConnPool{Enable: true, MaxIdleConns: 4, IdleTimeout: time.Minute},
+			StatementTimeout: StatementTimeout{
+				Default: time.Minute,
+				Users:   []UserStatementTimeout{{User: "bi", Timeout: 10 * time.Minute}},
+			},
+			LoadDataLocal: LoadDataLocal{
+				Default:   LoadDataPolicy{MaxFileSize: 100},
+				Users:     []UserLoadDataPolicy{{User: "app", LoadDataPolicy: LoadDataPolicy{Disable: true}}},
+				MaxMemory: 128,
+			},
+			RetryReads: true,
+			SessionFailover: SessionFailover{
+				Enable:           true,
+				SnapshotInterval: 20 * time.Second,
+				MaxMemory:        64,
+			},
 		},
 	},
 	API: API{
@@ -41,13 +69,28 @@ var testProxyConfig = Config{
 				MaxBackups: 1,
 			},
 		},
+		SlowLog: SlowLog{
+			Threshold: time.Second,
+			LogFile: LogFile{
+				Filename: "slow.log",
+				MaxSize:  10,
+			},
+		},
 	},
 	Security: Security{
 		ServerSQLTLS: TLSConfig{
-			CA:        "a",
-			Cert:      "b",
-			Key:       "c",
-			AutoCerts: true,
+			CA:                       "a",
+			Cert:                     "b",
+			Key:                      "c",
+			AutoCerts:                true,
+			MaxTLSVersion:            "1.3",
+			SessionTickets:           &sessionTickets,
+			SessionTicketKeyRotation: "12h",
+			CertUserMode:             CertUserModeSubstitute,
+			CertUserRules: []CertUserRule{
+				{Field: CertFieldCN, Pattern: "app-(.+)", User: "$1"},
+				{Field: CertFieldSANEmail},
+			},
 		},
 		ServerHTTPTLS: TLSConfig{
 			CA:        "a",
@@ -63,13 +106,32 @@ var testProxyConfig = Config{
 		},
 		SQLTLS: TLSConfig{
 			CA:                 "a",
+			CRL:                "crl",
 			RSAKeySize:         0,
 			AutoExpireDuration: "1y",
+			AutoRenewRatio:     0.5,
+			CipherSuites:       []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			Curves:             []string{"X25519"},
+			ServerName:         "tidb",
 			SkipCA:             true,
 			Cert:               "b",
 			Key:                "c",
 		},
+		BackendTLS: []BackendTLS{
+			{
+				LabelName:  "zone",
+				LabelValue: "east",
+				TLS: TLSConfig{
+					CA:         "a",
+					ServerName: "tidb-east",
+				},
+			},
+		},
 		RequireBackendTLS: true,
+		ProxyAuth: ProxyAuth{
+			Enable:    true,
+			UsersFile: "users.toml",
+		},
 	},
 }
 
@@ -104,6 +166,14 @@ func TestProxyCheck(t *testing.T) {
 			pre: func(t *testing.T, c *Config) {
 				c.Proxy.ProxyProtocol = "v1"
 			},
+			post: func(t *testing.T, c *Config) {
+				require.Equal(t, "v1", c.Proxy.ProxyProtocol)
+			},
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.ProxyProtocol = "v3"
+			},
 			err: ErrUnsupportedProxyProtocolVersion,
 		},
 		{
@@ -112,6 +182,200 @@ func TestProxyCheck(t *testing.T) {
 			},
 			err: ErrInvalidConfigValue,
 		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.ConnPool.MaxIdleConns = -1
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.StatementTimeout.Default = -time.Second
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.StatementTimeout.Users = []UserStatementTimeout{{Timeout: time.Second}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.LoadDataLocal.Default.MaxFileSize = -1
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.LoadDataLocal.Users = []UserLoadDataPolicy{{LoadDataPolicy: LoadDataPolicy{Disable: true}}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.LoadDataLocal.MaxMemory = -1
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.SessionFailover.SnapshotInterval = time.Minute
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.SessionFailover.MaxMemory = -1
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.ProxyAuth.UsersFile = ""
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.ServerSQLTLS.CertUserMode = "unknown"
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.SQLTLS.AutoRenewRatio = 1
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.ClusterTLS.SessionTicketKeyRotation = "1"
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.SQLTLS.CA = ""
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.ServerSQLTLS.CA = ""
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.BackendTLS = []BackendTLS{{LabelName: "zone", TLS: TLSConfig{CA: "a"}}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.BackendTLS = []BackendTLS{{LabelName: "zone", LabelValue: "east"}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.BackendTLS = []BackendTLS{{LabelName: "zone", LabelValue: "east", TLS: TLSConfig{SkipCA: true, SessionTicketKeyRotation: "1"}}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.ServerSQLTLS.CertUserRules = nil
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.ServerSQLTLS.CertUserRules = []CertUserRule{{Field: "ou"}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Security.ServerSQLTLS.CertUserRules = []CertUserRule{{Field: CertFieldCN, Pattern: "("}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Log.SlowLog.Threshold = -time.Second
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.Addr = "0.0.0.0:6000,unix://"
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.UnixSocketPermission = "0999"
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.Listeners = []ProxyListener{{Addr: ""}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.Listeners = []ProxyListener{{Addr: "0.0.0.0:6001"}, {Addr: "0.0.0.0:6001"}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.Listeners = []ProxyListener{{Addr: "0.0.0.0:6001", ProxyProtocol: "v3"}}
+			},
+			err: ErrUnsupportedProxyProtocolVersion,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.RoutingRule = ""
+				c.Proxy.Listeners = []ProxyListener{{Addr: "0.0.0.0:6001", RoutingGroup: "10.0.0.0/24"}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.RoutingRule = MatchClientCIDRStr
+				c.Proxy.Listeners = []ProxyListener{{Addr: "0.0.0.0:6001", RoutingGroup: "10.0.0.0/24"}}
+			},
+			post: func(t *testing.T, c *Config) {
+				require.Equal(t, "10.0.0.0/24", c.Proxy.Listeners[0].RoutingGroup)
+			},
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.Addr = "unix:///tmp/tiproxy.sock"
+				c.Proxy.Listeners = []ProxyListener{{Addr: "0.0.0.0:6001", ProxyProtocol: "v2", Namespace: "ns"}}
+			},
+			post: func(t *testing.T, c *Config) {
+				_, port, _, err := c.GetIPPort()
+				require.NoError(t, err)
+				require.Equal(t, "6001", port)
+			},
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.Addr = "unix:///tmp/tiproxy.sock,0.0.0.0:6000"
+				c.Proxy.UnixSocketPermission = "0660"
+			},
+			post: func(t *testing.T, c *Config) {
+				_, port, _, err := c.GetIPPort()
+				require.NoError(t, err)
+				require.Equal(t, "6000", port)
+			},
+		},
 	}
 	for _, tc := range testcases {
 		cfg := testProxyConfig
@@ -168,6 +432,68 @@ func TestGetIPPort(t *testing.T) {
 	}
 }
 
+func TestGetIPPortOfUnixSocket(t *testing.T) {
+	cfg := &Config{
+		Proxy: ProxyServer{
+			Addr: "unix:///tmp/tiproxy.sock",
+		},
+		API: API{
+			Addr: "192.0.0.1:3080",
+		},
+	}
+	ip, port, statusPort, err := cfg.GetIPPort()
+	require.NoError(t, err)
+	require.Equal(t, "192.0.0.1", ip)
+	require.Empty(t, port)
+	require.Equal(t, "3080", statusPort)
+
+	cfg.Proxy.AdvertiseAddr = "tc-tiproxy-0.tc-tiproxy-peer.ns.svc"
+	ip, port, _, err = cfg.GetIPPort()
+	require.NoError(t, err)
+	require.Equal(t, cfg.Proxy.AdvertiseAddr, ip)
+	require.Empty(t, port)
+}
+
+func TestGetIPPortOfListeners(t *testing.T) {
+	// The first TCP address of the extra listeners is reported if the main address only listens on Unix sockets.
+	for _, addr := range []string{"unix:///tmp/tiproxy.sock", ""} {
+		cfg := &Config{
+			Proxy: ProxyServer{
+				Addr: addr,
+				Listeners: []ProxyListener{
+					{Addr: "unix:///tmp/tiproxy2.sock"},
+					{Addr: "192.0.0.2:6001"},
+				},
+			},
+			API: API{
+				Addr: "192.0.0.1:3080",
+			},
+		}
+		ip, port, statusPort, err := cfg.GetIPPort()
+		require.NoError(t, err, addr)
+		require.Equal(t, "192.0.0.2", ip, addr)
+		require.Equal(t, "6001", port, addr)
+		require.Equal(t, "3080", statusPort, addr)
+	}
+}
+
+func TestParseListenAddr(t *testing.T) {
+	network, address := ParseListenAddr("0.0.0.0:6000")
+	require.Equal(t, "tcp", network)
+	require.Equal(t, "0.0.0.0:6000", address)
+	network, address = ParseListenAddr("unix:///var/run/tiproxy.sock")
+	require.Equal(t, "unix", network)
+	require.Equal(t, "/var/run/tiproxy.sock", address)
+
+	mode, err := ParseUnixSocketPermission("0660")
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0660), mode)
+	for _, perm := range []string{"abc", "1777", "-1"} {
+		_, err = ParseUnixSocketPermission(perm)
+		require.ErrorIs(t, err, ErrInvalidConfigValue)
+	}
+}
+
 func TestCloneConfig(t *testing.T) {
 	cfg := testProxyConfig
 	cfg.Labels = map[string]string{"a": "b"}
@@ -0,0 +1,41 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+// QueryRewrite rewrites COM_QUERY and COM_STMT_PREPARE before they are sent to the backends.
+type QueryRewrite struct {
+	Enable bool               `yaml:"enable,omitempty" toml:"enable,omitempty" json:"enable,omitempty" reloadable:"true"`
+	Rules  []QueryRewriteRule `yaml:"rules,omitempty" toml:"rules,omitempty" json:"rules,omitempty" reloadable:"true"`
+}
+
+// QueryRewriteRule replaces the statements that have the digest with the replacement.
+// The `?` placeholders in the replacement are filled with the literals of the original statement in order.
+// The statement is not rewritten if the number of placeholders doesn't equal the number of literals.
+type QueryRewriteRule struct {
+	// Name is used in logs and metrics. The digest is used if it's empty.
+	Name string `yaml:"name,omitempty" toml:"name,omitempty" json:"name,omitempty"`
+	// Digest is the statement to rewrite. Each digest can only have one rule.
+	Digest      string `yaml:"digest,omitempty" toml:"digest,omitempty" json:"digest,omitempty"`
+	Replacement string `yaml:"replacement,omitempty" toml:"replacement,omitempty" json:"replacement,omitempty"`
+	// DryRun only logs the rewritten statement and still sends the original one.
+	DryRun bool `yaml:"dry-run,omitempty" toml:"dry-run,omitempty" json:"dry-run,omitempty"`
+}
+
+func (qr *QueryRewrite) Check() error {
+	digests := make(map[string]struct{}, len(qr.Rules))
+	for _, rule := range qr.Rules {
+		if len(rule.Digest) == 0 || len(rule.Replacement) == 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "query-rewrite.rules.digest and query-rewrite.rules.replacement must be set")
+		}
+		if _, ok := digests[rule.Digest]; ok {
+			return errors.Wrapf(ErrInvalidConfigValue, "duplicated query-rewrite.rules.digest %s", rule.Digest)
+		}
+		digests[rule.Digest] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckQueryRewrite(t *testing.T) {
+	rewrites := []QueryRewrite{
+		{
+			Rules: []QueryRewriteRule{{Replacement: "select 1"}},
+		},
+		{
+			Rules: []QueryRewriteRule{{Digest: "abc"}},
+		},
+		{
+			Rules: []QueryRewriteRule{{Digest: "abc", Replacement: "select 1"}, {Digest: "abc", Replacement: "select 2"}},
+		},
+	}
+	for i, qr := range rewrites {
+		require.ErrorIs(t, qr.Check(), ErrInvalidConfigValue, "%d", i)
+	}
+
+	qr := QueryRewrite{
+		Enable: true,
+		Rules: []QueryRewriteRule{
+			{Name: "hint", Digest: "abc", Replacement: "select /*+ use_index(t, idx) */ * from t where id = ?"},
+			{Digest: "def", Replacement: "select * from t limit 1000", DryRun: true},
+		},
+	}
+	require.NoError(t, qr.Check())
+	require.NoError(t, NewConfig().QueryRewrite.Check())
+}
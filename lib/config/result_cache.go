@@ -0,0 +1,55 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+// ResultCache caches the results of read-only queries at the proxy.
+// The results are replayed to the clients without touching the backends until they expire, so only cache the queries
+// that tolerate stale results and don't depend on session variables.
+type ResultCache struct {
+	Enable bool `yaml:"enable,omitempty" toml:"enable,omitempty" json:"enable,omitempty" reloadable:"true"`
+	// MaxMemory is the memory limit of all the cached results in MB.
+	MaxMemory int `yaml:"max-memory,omitempty" toml:"max-memory,omitempty" json:"max-memory,omitempty" reloadable:"true"`
+	// MaxResultSize is the size limit of a single result in KB. Larger results are not cached.
+	MaxResultSize int               `yaml:"max-result-size,omitempty" toml:"max-result-size,omitempty" json:"max-result-size,omitempty" reloadable:"true"`
+	Rules         []ResultCacheRule `yaml:"rules,omitempty" toml:"rules,omitempty" json:"rules,omitempty" reloadable:"true"`
+}
+
+// ResultCacheRule decides which queries are cached. Either Digest or Regex should be set.
+type ResultCacheRule struct {
+	// Digest matches the SQL digest, which is the same as the digest in TiDB statement summary.
+	Digest string `yaml:"digest,omitempty" toml:"digest,omitempty" json:"digest,omitempty"`
+	// Regex matches the normalized SQL, in which the literals are replaced with `?`.
+	Regex string        `yaml:"regex,omitempty" toml:"regex,omitempty" json:"regex,omitempty"`
+	TTL   time.Duration `yaml:"ttl,omitempty" toml:"ttl,omitempty" json:"ttl,omitempty"`
+}
+
+func (rc *ResultCache) Check() error {
+	if rc.MaxMemory < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid result-cache.max-memory")
+	}
+	if rc.MaxResultSize < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid result-cache.max-result-size")
+	}
+	for _, rule := range rc.Rules {
+		if (len(rule.Digest) == 0) == (len(rule.Regex) == 0) {
+			return errors.Wrapf(ErrInvalidConfigValue, "either result-cache.rules.digest or result-cache.rules.regex should be set")
+		}
+		if len(rule.Regex) > 0 {
+			if _, err := regexp.Compile(rule.Regex); err != nil {
+				return errors.Wrapf(ErrInvalidConfigValue, "invalid result-cache.rules.regex %s: %s", rule.Regex, err.Error())
+			}
+		}
+		if rule.TTL <= 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "result-cache.rules.ttl must be positive")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckResultCache(t *testing.T) {
+	caches := []ResultCache{
+		{
+			MaxMemory: -1,
+		},
+		{
+			MaxResultSize: -1,
+		},
+		{
+			Rules: []ResultCacheRule{{TTL: time.Second}},
+		},
+		{
+			Rules: []ResultCacheRule{{Digest: "abc", Regex: "select", TTL: time.Second}},
+		},
+		{
+			Rules: []ResultCacheRule{{Regex: "select (", TTL: time.Second}},
+		},
+		{
+			Rules: []ResultCacheRule{{Digest: "abc"}},
+		},
+	}
+
+	for i, cache := range caches {
+		require.ErrorIs(t, cache.Check(), ErrInvalidConfigValue, "%d", i)
+	}
+
+	cache := ResultCache{
+		Enable: true,
+		Rules: []ResultCacheRule{
+			{Digest: "abc", TTL: time.Second},
+			{Regex: "^select .* from dashboard", TTL: time.Minute},
+		},
+	}
+	require.NoError(t, cache.Check())
+	require.NoError(t, NewConfig().ResultCache.Check())
+}
@@ -3,6 +3,13 @@
 
 package config
 
+import (
+	"regexp"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
 type TLSConfig struct {
 	Cert               string   `yaml:"cert,omitempty" toml:"cert,omitempty" json:"cert,omitempty" reloadable:"true"`
 	Key                string   `yaml:"key,omitempty" toml:"key,omitempty" json:"key,omitempty" reloadable:"true"`
@@ -13,6 +20,98 @@ type TLSConfig struct {
 	RSAKeySize         int      `yaml:"rsa-key-size,omitempty" toml:"rsa-key-size,omitempty" json:"rsa-key-size,omitempty" reloadable:"true"`
 	AutoExpireDuration string   `yaml:"autocert-expire-duration,omitempty" toml:"autocert-expire-duration,omitempty" json:"autocert-expire-duration,omitempty" reloadable:"true"`
 	SkipCA             bool     `yaml:"skip-ca,omitempty" toml:"skip-ca,omitempty" json:"skip-ca,omitempty" reloadable:"true"`
+	// AutoRenewRatio is the fraction of the lifetime after which the auto certs are renewed. 0 means the default.
+	AutoRenewRatio float64 `yaml:"autocert-renew-ratio,omitempty" toml:"autocert-renew-ratio,omitempty" json:"autocert-renew-ratio,omitempty" reloadable:"true"`
+	// ServerName is the hostname used for SNI and verifying the server certificate. It only works for client configs.
+	// Empty means using the host of the dial address for SNI and not verifying the hostname.
+	ServerName string `yaml:"server-name,omitempty" toml:"server-name,omitempty" json:"server-name,omitempty" reloadable:"true"`
+	// MaxTLSVersion is the max TLS version, such as 1.2. Empty means the highest supported version.
+	MaxTLSVersion string `yaml:"max-tls-version,omitempty" toml:"max-tls-version,omitempty" json:"max-tls-version,omitempty" reloadable:"true"`
+	// CipherSuites restricts the cipher suites of TLS 1.2 and below, such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+	// The cipher suites of TLS 1.3 are not configurable.
+	CipherSuites []string `yaml:"cipher-suites,omitempty" toml:"cipher-suites,omitempty" json:"cipher-suites,omitempty" reloadable:"true"`
+	// Curves are the preferred key exchange curves, such as X25519 and P-256.
+	Curves []string `yaml:"curves,omitempty" toml:"curves,omitempty" json:"curves,omitempty" reloadable:"true"`
+	// SessionTickets manages TLS session resumption. If it's true, the server rotates the ticket keys every
+	// SessionTicketKeyRotation and the client caches the sessions. If it's false, session tickets are disabled.
+	// Unset means the default behavior of crypto/tls.
+	SessionTickets           *bool  `yaml:"session-tickets,omitempty" toml:"session-tickets,omitempty" json:"session-tickets,omitempty" reloadable:"true"`
+	SessionTicketKeyRotation string `yaml:"session-ticket-key-rotation,omitempty" toml:"session-ticket-key-rotation,omitempty" json:"session-ticket-key-rotation,omitempty" reloadable:"true"`
+	// CRL is the certificate revocation list file in PEM or DER format. The peer certificates are rejected if revoked.
+	CRL string `yaml:"crl,omitempty" toml:"crl,omitempty" json:"crl,omitempty" reloadable:"true"`
+	// CertUserMode and CertUserRules map the identities in client certificates to MySQL users. They only work for server-tls.
+	// CertUserMode is verify or substitute. Empty means disabled.
+	CertUserMode  string         `yaml:"cert-user-mode,omitempty" toml:"cert-user-mode,omitempty" json:"cert-user-mode,omitempty" reloadable:"true"`
+	CertUserRules []CertUserRule `yaml:"cert-user-rules,omitempty" toml:"cert-user-rules,omitempty" json:"cert-user-rules,omitempty" reloadable:"true"`
+}
+
+const (
+	// CertUserModeVerify rejects the handshake if the user claimed by the client is not mapped from the certificate.
+	CertUserModeVerify = "verify"
+	// CertUserModeSubstitute replaces the user claimed by the client with the first user mapped from the certificate.
+	CertUserModeSubstitute = "substitute"
+)
+
+const (
+	CertFieldCN       = "cn"
+	CertFieldSANDNS   = "san-dns"
+	CertFieldSANEmail = "san-email"
+	CertFieldSANURI   = "san-uri"
+)
+
+// CertUserRule maps a certificate field to a user. The rules are evaluated in order.
+// The clients without certificates are rejected and the password is still checked for the mapped user.
+type CertUserRule struct {
+	// Field is one of cn, san-dns, san-email and san-uri.
+	Field string `yaml:"field,omitempty" toml:"field,omitempty" json:"field,omitempty"`
+	// Pattern is a regular expression that must match the whole field. Empty matches any value.
+	Pattern string `yaml:"pattern,omitempty" toml:"pattern,omitempty" json:"pattern,omitempty"`
+	// User is the mapped user, which can refer to the groups in Pattern, such as $1. Empty means the field itself.
+	User string `yaml:"user,omitempty" toml:"user,omitempty" json:"user,omitempty"`
+}
+
+func (c TLSConfig) HasCertUserMapping() bool {
+	return len(c.CertUserMode) > 0
+}
+
+// Check validates the CRL and the cert user mapping of a TLS config.
+func (c TLSConfig) Check(name string) error {
+	if c.AutoRenewRatio < 0 || c.AutoRenewRatio >= 1 {
+		return errors.Wrapf(ErrInvalidConfigValue, "%s.autocert-renew-ratio must be in [0, 1)", name)
+	}
+	if len(c.SessionTicketKeyRotation) > 0 {
+		if d, err := time.ParseDuration(c.SessionTicketKeyRotation); err != nil || d <= 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "invalid %s.session-ticket-key-rotation %s", name, c.SessionTicketKeyRotation)
+		}
+	}
+	if len(c.CRL) > 0 && !c.HasCA() {
+		return errors.Wrapf(ErrInvalidConfigValue, "%s.ca must be set to verify peer certificates if %s.crl is set", name, name)
+	}
+	if !c.HasCertUserMapping() {
+		return nil
+	}
+	switch c.CertUserMode {
+	case CertUserModeVerify, CertUserModeSubstitute:
+	default:
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid %s.cert-user-mode %s", name, c.CertUserMode)
+	}
+	if !c.HasCA() {
+		return errors.Wrapf(ErrInvalidConfigValue, "%s.ca must be set to verify client certificates if %s.cert-user-mode is set", name, name)
+	}
+	if len(c.CertUserRules) == 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "%s.cert-user-rules must be set if %s.cert-user-mode is set", name, name)
+	}
+	for _, rule := range c.CertUserRules {
+		switch rule.Field {
+		case CertFieldCN, CertFieldSANDNS, CertFieldSANEmail, CertFieldSANURI:
+		default:
+			return errors.Wrapf(ErrInvalidConfigValue, "invalid %s.cert-user-rules.field %s", name, rule.Field)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return errors.Wrapf(ErrInvalidConfigValue, "invalid %s.cert-user-rules.pattern %s: %s", name, rule.Pattern, err.Error())
+		}
+	}
+	return nil
 }
 
 func (c TLSConfig) HasCert() bool {
@@ -23,6 +122,11 @@ func (c TLSConfig) HasCA() bool {
 	return c.CA != ""
 }
 
+// HasClientTLS returns true if the client config enables TLS.
+func (c TLSConfig) HasClientTLS() bool {
+	return c.HasCA() || c.SkipCA
+}
+
 type Security struct {
 	ServerSQLTLS      TLSConfig `yaml:"server-tls,omitempty" toml:"server-tls,omitempty" json:"server-tls,omitempty"`
 	ServerHTTPTLS     TLSConfig `yaml:"server-http-tls,omitempty" toml:"server-http-tls,omitempty" json:"server-http-tls,omitempty"`
@@ -30,4 +134,29 @@ type Security struct {
 	SQLTLS            TLSConfig `yaml:"sql-tls,omitempty" toml:"sql-tls,omitempty" json:"sql-tls,omitempty"`
 	EncryptionKeyPath string    `yaml:"encryption-key-path,omitempty" toml:"encryption-key-path,omitempty" json:"encryption-key-path,omitempty" reloadable:"true"`
 	RequireBackendTLS bool      `yaml:"require-backend-tls,omitempty" toml:"require-backend-tls,omitempty" json:"require-backend-tls,omitempty" reloadable:"true"`
+	ProxyAuth         ProxyAuth `yaml:"proxy-auth,omitempty" toml:"proxy-auth,omitempty" json:"proxy-auth,omitempty"`
+	// BackendTLS overrides sql-tls and the namespace backend security for the backends with the labels.
+	// The first matched one is used.
+	BackendTLS []BackendTLS `yaml:"backend-tls,omitempty" toml:"backend-tls,omitempty" json:"backend-tls,omitempty" reloadable:"true"`
+}
+
+// BackendTLS is the TLS config to connect to the backends whose label LabelName equals LabelValue.
+// The backend groups are also distinguished by labels, so it can also be used for the groups.
+type BackendTLS struct {
+	LabelName  string    `yaml:"label-name,omitempty" toml:"label-name,omitempty" json:"label-name,omitempty" reloadable:"true"`
+	LabelValue string    `yaml:"label-value,omitempty" toml:"label-value,omitempty" json:"label-value,omitempty" reloadable:"true"`
+	TLS        TLSConfig `yaml:"tls,omitempty" toml:"tls,omitempty" json:"tls,omitempty" reloadable:"true"`
+}
+
+// Name identifies the override in logs and metrics.
+func (b BackendTLS) Name() string {
+	return b.LabelName + "=" + b.LabelValue
+}
+
+// ProxyAuth makes the proxy authenticate the users in the users file and log in to the backends as the mapped users.
+// The other users are still authenticated by the backends. It only affects new connections.
+type ProxyAuth struct {
+	Enable bool `yaml:"enable,omitempty" toml:"enable,omitempty" json:"enable,omitempty" reloadable:"true"`
+	// UsersFile is the path of the TOML users file. It's reloaded once it's modified.
+	UsersFile string `yaml:"users-file,omitempty" toml:"users-file,omitempty" json:"users-file,omitempty" reloadable:"true"`
 }
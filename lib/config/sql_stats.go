@@ -0,0 +1,29 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+// SQLStats keeps the in-memory statistics of the statements forwarded by the proxy, aggregated by SQL digest.
+type SQLStats struct {
+	Enable bool `yaml:"enable,omitempty" toml:"enable,omitempty" json:"enable,omitempty" reloadable:"true"`
+	// MaxDigests limits the number of tracked digests. The statements of new digests are not tracked after the limit.
+	MaxDigests int `yaml:"max-digests,omitempty" toml:"max-digests,omitempty" json:"max-digests,omitempty" reloadable:"true"`
+	// ResetInterval is the interval to clear the statistics. 0 means never clearing them.
+	ResetInterval time.Duration `yaml:"reset-interval,omitempty" toml:"reset-interval,omitempty" json:"reset-interval,omitempty" reloadable:"true"`
+}
+
+func (ss *SQLStats) Check() error {
+	if ss.Enable && ss.MaxDigests <= 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "sql-stats.max-digests must be positive")
+	}
+	if ss.ResetInterval < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "sql-stats.reset-interval must not be negative")
+	}
+	return nil
+}
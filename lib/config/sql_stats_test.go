@@ -0,0 +1,34 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSQLStats(t *testing.T) {
+	stats := []SQLStats{
+		{
+			Enable: true,
+		},
+		{
+			Enable:     true,
+			MaxDigests: -1,
+		},
+		{
+			MaxDigests:    100,
+			ResetInterval: -time.Second,
+		},
+	}
+	for i, ss := range stats {
+		require.ErrorIs(t, ss.Check(), ErrInvalidConfigValue, "%d", i)
+	}
+
+	ss := SQLStats{Enable: true, MaxDigests: 100, ResetInterval: time.Hour}
+	require.NoError(t, ss.Check())
+	require.NoError(t, NewConfig().SQLStats.Check())
+}
@@ -67,6 +67,19 @@ func BuildLogger(cfg *config.Log) (*zap.Logger, *AtomicWriteSyncer, zap.AtomicLe
 	return zap.New(zapcore.NewCore(encoder, syncer, level), zap.ErrorOutput(syncer), zap.AddStacktrace(zapcore.FatalLevel), zap.AddCaller()), syncer, level, nil
 }
 
+// BuildSlowLogger builds the logger for the slow log, which uses the same encoder as the main logger.
+func BuildSlowLogger(cfg *config.Log) (*zap.Logger, *AtomicWriteSyncer, error) {
+	encoder, err := buildEncoder(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	syncer := &AtomicWriteSyncer{}
+	if err := syncer.RebuildFile(&cfg.SlowLog.LogFile); err != nil {
+		return nil, nil, err
+	}
+	return zap.New(zapcore.NewCore(encoder, syncer, zap.InfoLevel), zap.ErrorOutput(syncer)), syncer, nil
+}
+
 type testingLog struct {
 	*testing.T
 	sync.Mutex
@@ -52,9 +52,15 @@ type AtomicWriteSyncer struct {
 
 // Rebuild creates a new output and replaces the current one.
 func (ws *AtomicWriteSyncer) Rebuild(cfg *config.LogOnline) error {
+	return ws.RebuildFile(&cfg.LogFile)
+}
+
+// RebuildFile creates a new output writing to the file and replaces the current one.
+// It writes to stdout if the filename is empty.
+func (ws *AtomicWriteSyncer) RebuildFile(cfg *config.LogFile) error {
 	var output closableSyncer
-	if len(cfg.LogFile.Filename) > 0 {
-		fileLogger, err := initFileLog(&cfg.LogFile)
+	if len(cfg.Filename) > 0 {
+		fileLogger, err := initFileLog(cfg)
 		if err != nil {
 			return err
 		}
@@ -17,25 +17,34 @@ import (
 )
 
 const (
-	// Recreate the auto certs one hour before it expires.
-	// It should be longer than defaultRetryInterval.
-	recreateAutoCertAdvance = 24 * time.Hour
+	// DefaultAutoCertRenewRatio is the default fraction of the lifetime after which the auto certs are recreated.
+	DefaultAutoCertRenewRatio = 2.0 / 3
 )
 
 var emptyCert = new(tls.Certificate)
 
 type CertInfo struct {
-	cfg         atomic.Pointer[config.TLSConfig]
-	ca          atomic.Pointer[x509.CertPool]
-	cert        atomic.Pointer[tls.Certificate]
-	autoCertExp atomic.Int64
-	server      bool
+	cfg  atomic.Pointer[config.TLSConfig]
+	ca   atomic.Pointer[x509.CertPool]
+	cert atomic.Pointer[tls.Certificate]
+	// autoCertRenew is the unix time when the auto cert should be recreated.
+	autoCertRenew atomic.Int64
+	crl           atomic.Pointer[revocationList]
+	onRevoked     atomic.Pointer[func(*x509.Certificate)]
+	ticketKeys    atomic.Pointer[sessionTicketKeys]
+	// sessionCache is kept across reloads so that the client can resume the sessions after reloading.
+	sessionCache tls.ClientSessionCache
+	server       bool
 }
 
 func NewCert(server bool) *CertInfo {
-	return &CertInfo{
+	ci := &CertInfo{
 		server: server,
 	}
+	if !server {
+		ci.sessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	return ci
 }
 
 func (ci *CertInfo) Reload(lg *zap.Logger) (tlsConfig *tls.Config, err error) {
@@ -45,7 +54,7 @@ func (ci *CertInfo) Reload(lg *zap.Logger) (tlsConfig *tls.Config, err error) {
 	// Some methods to rotate client config:
 	// - For certs: customize GetClientCertificate
 	// - For CA: customize InsecureSkipVerify + VerifyPeerCertificate
-	prevExpireTime := ci.getExpireTime()
+	prevExpireTime := ci.ExpireTime()
 	if ci.server {
 		lg = lg.With(zap.String("tls", "server"), zap.Any("cfg", ci.cfg.Load()))
 		tlsConfig, err = ci.buildServerConfig(lg)
@@ -54,11 +63,12 @@ func (ci *CertInfo) Reload(lg *zap.Logger) (tlsConfig *tls.Config, err error) {
 		tlsConfig, err = ci.buildClientConfig(lg)
 	}
 	if err == nil {
-		curExpireTime := ci.getExpireTime()
+		curExpireTime := ci.ExpireTime()
 		if prevExpireTime != curExpireTime {
 			lg.Info("update cert expiration", zap.Time("prev", prevExpireTime), zap.Time("cur", curExpireTime))
 		}
-		if !curExpireTime.IsZero() && time.Now().Add(24*time.Hour).After(curExpireTime) {
+		// The auto certs are renewed before they expire, so only warn about the user-provided certs.
+		if !curExpireTime.IsZero() && ci.NextRenewal().IsZero() && time.Now().Add(24*time.Hour).After(curExpireTime) {
 			lg.Warn("cert will expire in 24 hours", zap.Time("expire", curExpireTime))
 		}
 	}
@@ -69,6 +79,11 @@ func (ci *CertInfo) SetConfig(cfg config.TLSConfig) {
 	ci.cfg.Store(&cfg)
 }
 
+// SetOnRevoked sets the callback that is called when a peer presents a revoked certificate.
+func (ci *CertInfo) SetOnRevoked(fn func(cert *x509.Certificate)) {
+	ci.onRevoked.Store(&fn)
+}
+
 func (ci *CertInfo) getCert(*tls.ClientHelloInfo) (*tls.Certificate, error) {
 	return ci.cert.Load(), nil
 }
@@ -82,7 +97,8 @@ func (ci *CertInfo) getClientCert(*tls.CertificateRequestInfo) (*tls.Certificate
 	return cert, nil
 }
 
-func (ci *CertInfo) verifyCA(rawCerts [][]byte) error {
+// verifyCA verifies the peer certificates with the CA. The hostname is also verified if dnsName is set.
+func (ci *CertInfo) verifyCA(rawCerts [][]byte, dnsName string) error {
 	if len(rawCerts) == 0 {
 		return nil
 	}
@@ -111,13 +127,15 @@ func (ci *CertInfo) verifyCA(rawCerts [][]byte) error {
 		// it is not necessary but explicit
 		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
 	}
-	// TODO: not implemented, maybe later
-	// opts.DNSName = ci.serverName
+	opts.DNSName = dnsName
 	for _, cert := range certs[1:] {
 		opts.Intermediates.AddCert(cert)
 	}
-	_, err := certs[0].Verify(opts)
-	return err
+	chains, err := certs[0].Verify(opts)
+	if err != nil {
+		return err
+	}
+	return ci.checkRevoked(chains)
 }
 
 func verifyCommonName(allowedCN []string, verifiedChains [][]*x509.Certificate) error {
@@ -151,34 +169,44 @@ func (ci *CertInfo) buildServerConfig(lg *zap.Logger) (*tls.Config, error) {
 		}
 	}
 
+	verify := func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if err := ci.verifyCA(rawCerts, ""); err != nil {
+			return err
+		}
+		return verifyCommonName(cfg.CertAllowedCN, verifiedChains)
+	}
 	tcfg := &tls.Config{
-		MinVersion:           GetMinTLSVer(cfg.MinTLSVersion, lg),
-		GetCertificate:       ci.getCert,
-		GetClientCertificate: ci.getClientCert,
-		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
-			if err := ci.verifyCA(rawCerts); err != nil {
-				return err
-			}
-			return verifyCommonName(cfg.CertAllowedCN, verifiedChains)
-		},
+		MinVersion:            GetMinTLSVer(cfg.MinTLSVersion, lg),
+		GetCertificate:        ci.getCert,
+		GetClientCertificate:  ci.getClientCert,
+		VerifyPeerCertificate: verify,
+	}
+	if err := ci.applyOptions(lg, tcfg, cfg, verify); err != nil {
+		return nil, err
 	}
 
 	var certPEM, keyPEM []byte
 	var err error
 	if autoCerts {
 		now := time.Now()
-		if time.Unix(ci.autoCertExp.Load(), 0).Before(now) {
+		if !time.Unix(ci.autoCertRenew.Load(), 0).After(now) {
 			dur, err := time.ParseDuration(cfg.AutoExpireDuration)
-			if err != nil {
+			if err != nil || dur <= 0 {
 				dur = DefaultCertExpiration
 			}
-			ci.autoCertExp.Store(now.Add(DefaultCertExpiration - recreateAutoCertAdvance).Unix())
+			ratio := cfg.AutoRenewRatio
+			if ratio <= 0 || ratio >= 1 {
+				ratio = DefaultAutoCertRenewRatio
+			}
 			certPEM, keyPEM, _, err = createTempTLS(cfg.RSAKeySize, dur, "")
 			if err != nil {
 				return nil, err
 			}
+			ci.autoCertRenew.Store(now.Add(time.Duration(float64(dur) * ratio)).Unix())
 		}
 	} else {
+		// Recreate the auto cert once it's enabled again.
+		ci.autoCertRenew.Store(0)
 		certPEM, err = os.ReadFile(cfg.Cert)
 		if err != nil {
 			return nil, err
@@ -210,6 +238,9 @@ func (ci *CertInfo) buildServerConfig(lg *zap.Logger) (*tls.Config, error) {
 	if !certPool.AppendCertsFromPEM(caPEM) {
 		return nil, errors.New("failed to append ca certs")
 	}
+	if err = ci.reloadCRL(lg, cfg.CRL, caPEM); err != nil {
+		return nil, err
+	}
 	ci.ca.Store(certPool)
 
 	// RequireAndVerifyClientCert requires ClientCAs to verify client certificates.
@@ -237,10 +268,15 @@ func (ci *CertInfo) buildClientConfig(lg *zap.Logger) (*tls.Config, error) {
 	if !cfg.HasCA() {
 		if cfg.SkipCA {
 			// still enable TLS without verify server certs
-			return &tls.Config{
+			tcfg := &tls.Config{
 				InsecureSkipVerify: true,
 				MinVersion:         GetMinTLSVer(cfg.MinTLSVersion, lg),
-			}, nil
+				ServerName:         cfg.ServerName,
+			}
+			if err := ci.applyOptions(lg, tcfg, cfg, nil); err != nil {
+				return nil, err
+			}
+			return tcfg, nil
 		}
 		lg.Debug("no CA to verify server connections, disable TLS")
 		return nil, nil
@@ -251,10 +287,14 @@ func (ci *CertInfo) buildClientConfig(lg *zap.Logger) (*tls.Config, error) {
 		GetCertificate:       ci.getCert,
 		GetClientCertificate: ci.getClientCert,
 		InsecureSkipVerify:   true,
+		ServerName:           cfg.ServerName,
 		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
-			return ci.verifyCA(rawCerts)
+			return ci.verifyCA(rawCerts, cfg.ServerName)
 		},
 	}
+	if err := ci.applyOptions(lg, tcfg, cfg, tcfg.VerifyPeerCertificate); err != nil {
+		return nil, err
+	}
 
 	caPEM, err := os.ReadFile(cfg.CA)
 	if err != nil {
@@ -264,6 +304,9 @@ func (ci *CertInfo) buildClientConfig(lg *zap.Logger) (*tls.Config, error) {
 	if !certPool.AppendCertsFromPEM(caPEM) {
 		return nil, errors.New("failed to append ca certs")
 	}
+	if err = ci.reloadCRL(lg, cfg.CRL, caPEM); err != nil {
+		return nil, err
+	}
 	ci.ca.Store(certPool)
 	tcfg.RootCAs = certPool
 
@@ -281,7 +324,8 @@ func (ci *CertInfo) buildClientConfig(lg *zap.Logger) (*tls.Config, error) {
 	return tcfg, nil
 }
 
-func (ci *CertInfo) getExpireTime() time.Time {
+// ExpireTime returns the not-after time of the local certificate. It returns zero if there is no certificate.
+func (ci *CertInfo) ExpireTime() time.Time {
 	cert := ci.cert.Load()
 	if cert == nil {
 		return time.Time{}
@@ -292,3 +336,12 @@ func (ci *CertInfo) getExpireTime() time.Time {
 	}
 	return cp.NotAfter
 }
+
+// NextRenewal returns the time when the auto cert should be recreated. It returns zero if auto certs are not used.
+func (ci *CertInfo) NextRenewal() time.Time {
+	cfg := ci.cfg.Load()
+	if !ci.server || cfg == nil || cfg.HasCert() || !cfg.AutoCerts {
+		return time.Time{}
+	}
+	return time.Unix(ci.autoCertRenew.Load(), 0)
+}
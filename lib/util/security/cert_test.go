@@ -275,7 +275,7 @@ func TestReload(t *testing.T) {
 	tcfg, err := ci.Reload(lg)
 	require.NoError(t, err)
 	require.NotNil(t, tcfg)
-	expire1 := ci.getExpireTime()
+	expire1 := ci.ExpireTime()
 	require.Equal(t, 1, strings.Count(text.String(), "update cert expiration"))
 	require.Equal(t, 1, strings.Count(text.String(), "cert will expire"))
 
@@ -284,7 +284,7 @@ func TestReload(t *testing.T) {
 	require.NoError(t, err)
 	_, err = ci.Reload(lg)
 	require.NoError(t, err)
-	expire2 := ci.getExpireTime()
+	expire2 := ci.ExpireTime()
 	require.NotEqual(t, expire1, expire2)
 	require.Equal(t, 2, strings.Count(text.String(), "update cert expiration"))
 	require.Equal(t, 1, strings.Count(text.String(), "cert will expire"))
@@ -304,8 +304,8 @@ func TestAutoCerts(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, tcfg)
 	cert1 := ci.cert.Load()
-	expire1 := ci.getExpireTime()
-	require.True(t, ci.autoCertExp.Load() < expire1.Unix())
+	expire1 := ci.ExpireTime()
+	require.True(t, ci.autoCertRenew.Load() < expire1.Unix())
 
 	// The cert will not be recreated now.
 	ci.cfg.Load().AutoExpireDuration = (DefaultCertExpiration - time.Hour).String()
@@ -313,16 +313,30 @@ func TestAutoCerts(t *testing.T) {
 	_, err = ci.Reload(lg)
 	cert2 := ci.cert.Load()
 	require.Equal(t, cert1, cert2)
-	expire2 := ci.getExpireTime()
+	expire2 := ci.ExpireTime()
 	require.Equal(t, expire1, expire2)
 
 	// The cert will be recreated when it almost expires.
-	ci.autoCertExp.Store(time.Now().Add(-time.Minute).Unix())
+	ci.autoCertRenew.Store(time.Now().Add(-time.Minute).Unix())
 	require.NoError(t, err)
 	_, err = ci.Reload(lg)
 	require.NoError(t, err)
-	expire3 := ci.getExpireTime()
+	expire3 := ci.ExpireTime()
 	require.NotEqual(t, expire1, expire3)
+
+	// The renewal time follows the lifetime and the renew ratio.
+	ci.cfg.Load().AutoExpireDuration = "1h"
+	ci.cfg.Load().AutoRenewRatio = 0.5
+	ci.autoCertRenew.Store(0)
+	now := time.Now()
+	_, err = ci.Reload(lg)
+	require.NoError(t, err)
+	require.WithinDuration(t, now.Add(time.Hour), ci.ExpireTime(), time.Minute)
+	require.WithinDuration(t, now.Add(30*time.Minute), ci.NextRenewal(), time.Minute)
+	require.True(t, ci.NextRenewal().Before(ci.ExpireTime()))
+
+	// The client config never renews auto certs.
+	require.True(t, NewCert(false).NextRenewal().IsZero())
 }
 
 func TestSetConfig(t *testing.T) {
@@ -489,3 +503,39 @@ func TestCommonName(t *testing.T) {
 		}
 	}
 }
+
+func TestServerName(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	tmpdir := t.TempDir()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, ln.Close())
+	}()
+	ca := newTestCA(t)
+	caPath := filepath.Join(tmpdir, "ca")
+	require.NoError(t, os.WriteFile(caPath, ca.pem, 0600))
+	stls := &tls.Config{Certificates: []tls.Certificate{ca.issue(t, 1)}}
+
+	client := NewCert(false)
+	for _, tc := range []struct {
+		serverName string
+		err        string
+	}{
+		// The hostname is not verified if the server name is not set.
+		{serverName: ""},
+		{serverName: "peer"},
+		{serverName: "other", err: "other"},
+	} {
+		client.SetConfig(config.TLSConfig{CA: caPath, ServerName: tc.serverName})
+		ctls, err := client.Reload(lg)
+		require.NoError(t, err)
+		require.Equal(t, tc.serverName, ctls.ServerName)
+		_, err = handshake(t, ln, ctls, stls)
+		if len(tc.err) > 0 {
+			require.ErrorContains(t, err, tc.err, tc.serverName)
+		} else {
+			require.NoError(t, err, tc.serverName)
+		}
+	}
+}
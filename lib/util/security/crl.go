@@ -0,0 +1,126 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package security
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"go.uber.org/zap"
+)
+
+// revocationList is the set of revoked serial numbers, keyed by the raw issuer and then the serial number.
+type revocationList struct {
+	revoked map[string]map[string]struct{}
+}
+
+func (rl *revocationList) isRevoked(cert *x509.Certificate) bool {
+	serials, ok := rl.revoked[string(cert.RawIssuer)]
+	if !ok {
+		return false
+	}
+	_, ok = serials[cert.SerialNumber.String()]
+	return ok
+}
+
+// loadCRL reads the CRLs in PEM or DER format from the file. Each CRL must be signed by one of the CA certs.
+func loadCRL(lg *zap.Logger, file string, caPEM []byte) (*revocationList, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var ders [][]byte
+	for rest := data; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type == "X509 CRL" {
+			ders = append(ders, block.Bytes)
+		}
+	}
+	// Not a PEM file, try DER.
+	if len(ders) == 0 {
+		ders = append(ders, data)
+	}
+
+	var cas []*x509.Certificate
+	for rest := caPEM; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if ca, err := x509.ParseCertificate(block.Bytes); err == nil {
+			cas = append(cas, ca)
+		}
+	}
+
+	rl := &revocationList{revoked: make(map[string]map[string]struct{})}
+	now := time.Now()
+	for _, der := range ders {
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse crl %s", file)
+		}
+		signed := false
+		for _, ca := range cas {
+			if crl.CheckSignatureFrom(ca) == nil {
+				signed = true
+				break
+			}
+		}
+		if !signed {
+			return nil, errors.Errorf("crl %s is not signed by the ca", file)
+		}
+		if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+			lg.Warn("crl is outdated", zap.String("crl", file), zap.Time("next_update", crl.NextUpdate))
+		}
+		serials, ok := rl.revoked[string(crl.RawIssuer)]
+		if !ok {
+			serials = make(map[string]struct{}, len(crl.RevokedCertificateEntries))
+			rl.revoked[string(crl.RawIssuer)] = serials
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			serials[entry.SerialNumber.String()] = struct{}{}
+		}
+	}
+	return rl, nil
+}
+
+// checkRevoked returns an error if any cert in the verified chains is revoked.
+// The root CA at the end of each chain is trusted and not checked.
+func (ci *CertInfo) checkRevoked(chains [][]*x509.Certificate) error {
+	rl := ci.crl.Load()
+	if rl == nil {
+		return nil
+	}
+	for _, chain := range chains {
+		for i := 0; i < len(chain)-1; i++ {
+			cert := chain[i]
+			if rl.isRevoked(cert) {
+				if onRevoked := ci.onRevoked.Load(); onRevoked != nil {
+					(*onRevoked)(cert)
+				}
+				return errors.Errorf("certificate is revoked, subject: %s, serial: %s", cert.Subject.String(), cert.SerialNumber.String())
+			}
+		}
+	}
+	return nil
+}
+
+func (ci *CertInfo) reloadCRL(lg *zap.Logger, file string, caPEM []byte) error {
+	if len(file) == 0 {
+		ci.crl.Store(nil)
+		return nil
+	}
+	rl, err := loadCRL(lg, file, caPEM)
+	if err != nil {
+		return err
+	}
+	ci.crl.Store(rl)
+	return nil
+}
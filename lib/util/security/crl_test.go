@@ -0,0 +1,172 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (ca *testCA) issue(t *testing.T, serial int64) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "peer"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{"peer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (ca *testCA) crl(t *testing.T, serials ...int64) []byte {
+	entries := make([]x509.RevocationListEntry, 0, len(serials))
+	for _, serial := range serials {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(time.Now().UnixNano()),
+		ThisUpdate:                time.Now().Add(-time.Hour),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	require.NoError(t, err)
+	return der
+}
+
+// connectWithTCP is like connectWithTLS, but the pipe may block when the client aborts the handshake.
+func connectWithTCP(t *testing.T, ctls, stls *tls.Config) (clientErr, serverErr error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, ln.Close())
+	}()
+	var wg waitgroup.WaitGroup
+	wg.Run(func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			serverErr = err
+			return
+		}
+		serverErr = tls.Server(conn, stls).Handshake()
+		_ = conn.Close()
+	})
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	clientErr = tls.Client(conn, ctls).Handshake()
+	_ = conn.Close()
+	wg.Wait()
+	return
+}
+
+func TestCRL(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	tmpdir := t.TempDir()
+	ca := newTestCA(t)
+	caPath, crlPath := filepath.Join(tmpdir, "ca"), filepath.Join(tmpdir, "crl")
+	require.NoError(t, os.WriteFile(caPath, ca.pem, 0600))
+	require.NoError(t, os.WriteFile(crlPath, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: ca.crl(t, 3)}), 0600))
+	validCert, revokedCert := ca.issue(t, 2), ca.issue(t, 3)
+
+	// The server rejects the revoked client certs.
+	var revoked []*x509.Certificate
+	serverCert := NewCert(true)
+	serverCert.SetOnRevoked(func(cert *x509.Certificate) {
+		revoked = append(revoked, cert)
+	})
+	serverCert.SetConfig(config.TLSConfig{AutoCerts: true, CA: caPath, CRL: crlPath})
+	serverTLS, err := serverCert.Reload(lg)
+	require.NoError(t, err)
+	clientTLS := &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{validCert}}
+	clientErr, serverErr := connectWithTCP(t, clientTLS, serverTLS)
+	require.NoError(t, clientErr)
+	require.NoError(t, serverErr)
+	require.Empty(t, revoked)
+	clientTLS = &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{revokedCert}}
+	_, serverErr = connectWithTCP(t, clientTLS, serverTLS)
+	require.ErrorContains(t, serverErr, "revoked")
+	require.Len(t, revoked, 1)
+	require.EqualValues(t, 3, revoked[0].SerialNumber.Int64())
+
+	// The client rejects the revoked server certs.
+	clientCert := NewCert(false)
+	clientCert.SetConfig(config.TLSConfig{CA: caPath, CRL: crlPath})
+	clientTLS, err = clientCert.Reload(lg)
+	require.NoError(t, err)
+	clientErr, _ = connectWithTCP(t, clientTLS, &tls.Config{Certificates: []tls.Certificate{validCert}})
+	require.NoError(t, clientErr)
+	clientErr, _ = connectWithTCP(t, clientTLS, &tls.Config{Certificates: []tls.Certificate{revokedCert}})
+	require.ErrorContains(t, clientErr, "revoked")
+
+	// The updated CRL in DER format is reloaded.
+	require.NoError(t, os.WriteFile(crlPath, ca.crl(t, 2), 0600))
+	clientTLS, err = clientCert.Reload(lg)
+	require.NoError(t, err)
+	clientErr, _ = connectWithTCP(t, clientTLS, &tls.Config{Certificates: []tls.Certificate{validCert}})
+	require.ErrorContains(t, clientErr, "revoked")
+	clientErr, _ = connectWithTCP(t, clientTLS, &tls.Config{Certificates: []tls.Certificate{revokedCert}})
+	require.NoError(t, clientErr)
+
+	// The CRL that is invalid or not signed by the CA is rejected.
+	require.NoError(t, os.WriteFile(crlPath, newTestCA(t).crl(t, 2), 0600))
+	_, err = clientCert.Reload(lg)
+	require.ErrorContains(t, err, "not signed")
+	require.NoError(t, os.WriteFile(crlPath, []byte("invalid"), 0600))
+	_, err = clientCert.Reload(lg)
+	require.Error(t, err)
+
+	// The CRL is not checked after it's removed from the config.
+	clientCert.SetConfig(config.TLSConfig{CA: caPath})
+	clientTLS, err = clientCert.Reload(lg)
+	require.NoError(t, err)
+	clientErr, _ = connectWithTCP(t, clientTLS, &tls.Config{Certificates: []tls.Certificate{validCert}})
+	require.NoError(t, clientErr)
+}
@@ -0,0 +1,168 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package security
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultSessionTicketKeyRotation is the default interval to rotate the session ticket keys.
+	DefaultSessionTicketKeyRotation = 24 * time.Hour
+	// Keep the previous key so that the tickets issued in the last period can still be resumed.
+	maxSessionTicketKeys = 2
+)
+
+var curveNames = map[string]tls.CurveID{
+	"x25519": tls.X25519,
+	"p-256":  tls.CurveP256,
+	"p256":   tls.CurveP256,
+	"p-384":  tls.CurveP384,
+	"p384":   tls.CurveP384,
+	"p-521":  tls.CurveP521,
+	"p521":   tls.CurveP521,
+}
+
+type sessionTicketKeys struct {
+	keys     [][32]byte
+	rotateAt time.Time
+}
+
+type verifyFunc func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+
+// applyOptions sets the max version, cipher suites, curves and session resumption of the TLS config.
+// crypto/tls doesn't verify the peer certificates of resumed sessions, so verify is called for them in case the CA
+// or CRL has changed.
+func (ci *CertInfo) applyOptions(lg *zap.Logger, tcfg *tls.Config, cfg *config.TLSConfig, verify verifyFunc) error {
+	tcfg.MaxVersion = GetMaxTLSVer(cfg.MaxTLSVersion, lg)
+	if tcfg.MaxVersion != 0 && tcfg.MaxVersion < tcfg.MinVersion {
+		return errors.Errorf("max-tls-version %s is lower than min-tls-version %s", cfg.MaxTLSVersion, cfg.MinTLSVersion)
+	}
+	cipherSuites, err := parseCipherSuites(lg, cfg.CipherSuites)
+	if err != nil {
+		return err
+	}
+	tcfg.CipherSuites = cipherSuites
+	curves, err := parseCurves(cfg.Curves)
+	if err != nil {
+		return err
+	}
+	tcfg.CurvePreferences = curves
+
+	// Keep the default behavior of crypto/tls unless it's set explicitly.
+	if cfg.SessionTickets == nil || !*cfg.SessionTickets {
+		tcfg.SessionTicketsDisabled = cfg.SessionTickets != nil
+		ci.ticketKeys.Store(nil)
+		return nil
+	}
+	if ci.server {
+		keys, err := ci.rotateTicketKeys(cfg.SessionTicketKeyRotation)
+		if err != nil {
+			return err
+		}
+		tcfg.SetSessionTicketKeys(keys)
+	} else {
+		tcfg.ClientSessionCache = ci.sessionCache
+	}
+	if verify != nil {
+		tcfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if !cs.DidResume {
+				return nil
+			}
+			rawCerts := make([][]byte, 0, len(cs.PeerCertificates))
+			for _, cert := range cs.PeerCertificates {
+				rawCerts = append(rawCerts, cert.Raw)
+			}
+			return verify(rawCerts, cs.VerifiedChains)
+		}
+	}
+	return nil
+}
+
+// rotateTicketKeys generates a new session ticket key if the current one is used for longer than the rotation.
+func (ci *CertInfo) rotateTicketKeys(rotationStr string) ([][32]byte, error) {
+	rotation := DefaultSessionTicketKeyRotation
+	if len(rotationStr) > 0 {
+		var err error
+		if rotation, err = time.ParseDuration(rotationStr); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	now := time.Now()
+	cur := ci.ticketKeys.Load()
+	// The rotation may be shortened online.
+	if cur != nil && now.Before(cur.rotateAt) && !cur.rotateAt.After(now.Add(rotation)) {
+		return cur.keys, nil
+	}
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	keys := [][32]byte{key}
+	if cur != nil {
+		keys = append(keys, cur.keys[:min(len(cur.keys), maxSessionTicketKeys-1)]...)
+	}
+	ci.ticketKeys.Store(&sessionTicketKeys{keys: keys, rotateAt: now.Add(rotation)})
+	return keys, nil
+}
+
+// NextTicketRotation returns the time when the session ticket key should be rotated. It returns zero if session
+// tickets are not managed by the proxy.
+func (ci *CertInfo) NextTicketRotation() time.Time {
+	keys := ci.ticketKeys.Load()
+	if keys == nil {
+		return time.Time{}
+	}
+	return keys.rotateAt
+}
+
+func parseCipherSuites(lg *zap.Logger, names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	insecureSuites := make(map[string]uint16)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecureSuites[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if id, ok := suites[name]; ok {
+			ids = append(ids, id)
+		} else if id, ok := insecureSuites[name]; ok {
+			lg.Warn("insecure cipher suite is enabled, this is not recommended", zap.String("cipher-suite", name))
+			ids = append(ids, id)
+		} else {
+			return nil, errors.Errorf("unsupported cipher suite %s", name)
+		}
+	}
+	return ids, nil
+}
+
+func parseCurves(names []string) ([]tls.CurveID, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	curves := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		curve, ok := curveNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, errors.Errorf("unsupported curve %s", name)
+		}
+		curves = append(curves, curve)
+	}
+	return curves, nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCertFiles(t *testing.T, cert tls.Certificate, certPath, keyPath string) {
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
+
+// handshake connects to the listener and returns the client connection state.
+// The client reads until the server closes so that it receives the session tickets of TLS 1.3.
+func handshake(t *testing.T, ln net.Listener, ctls, stls *tls.Config) (tls.ConnectionState, error) {
+	var wg waitgroup.WaitGroup
+	wg.Run(func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = tls.Server(conn, stls).Handshake()
+		_ = conn.Close()
+	})
+	defer wg.Wait()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	tlsConn := tls.Client(conn, ctls)
+	if err := tlsConn.Handshake(); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	_ = tlsConn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = tlsConn.Read(make([]byte, 1))
+	return tlsConn.ConnectionState(), nil
+}
+
+func TestTLSOptions(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, ln.Close())
+	}()
+
+	server := NewCert(true)
+	server.SetConfig(config.TLSConfig{
+		AutoCerts:     true,
+		RSAKeySize:    1024,
+		MaxTLSVersion: "1.2",
+		CipherSuites:  []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		Curves:        []string{"P-384", "x25519"},
+	})
+	stls, err := server.Reload(lg)
+	require.NoError(t, err)
+	require.Equal(t, []tls.CurveID{tls.CurveP384, tls.X25519}, stls.CurvePreferences)
+	// Session tickets keep the default of crypto/tls if it's unset.
+	require.False(t, stls.SessionTicketsDisabled)
+	require.True(t, server.NextTicketRotation().IsZero())
+	client := NewCert(false)
+	client.SetConfig(config.TLSConfig{SkipCA: true})
+	ctls, err := client.Reload(lg)
+	require.NoError(t, err)
+	state, err := handshake(t, ln, ctls, stls)
+	require.NoError(t, err)
+	require.EqualValues(t, tls.VersionTLS12, state.Version)
+	require.Equal(t, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, state.CipherSuite)
+	require.False(t, state.DidResume)
+
+	// The options don't match.
+	client.SetConfig(config.TLSConfig{SkipCA: true, MinTLSVersion: "1.3"})
+	ctls, err = client.Reload(lg)
+	require.NoError(t, err)
+	_, err = handshake(t, ln, ctls, stls)
+	require.Error(t, err)
+
+	// Invalid options.
+	for _, cfg := range []config.TLSConfig{
+		{SkipCA: true, CipherSuites: []string{"unknown"}},
+		{SkipCA: true, Curves: []string{"unknown"}},
+		{SkipCA: true, MinTLSVersion: "1.3", MaxTLSVersion: "1.2"},
+	} {
+		client.SetConfig(cfg)
+		_, err = client.Reload(lg)
+		require.Error(t, err)
+	}
+}
+
+func TestSessionTickets(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	tmpdir := t.TempDir()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, ln.Close())
+	}()
+	ca := newTestCA(t)
+	caPath, crlPath := filepath.Join(tmpdir, "ca"), filepath.Join(tmpdir, "crl")
+	certPath, keyPath := filepath.Join(tmpdir, "cert"), filepath.Join(tmpdir, "key")
+	require.NoError(t, os.WriteFile(caPath, ca.pem, 0600))
+	require.NoError(t, os.WriteFile(crlPath, ca.crl(t), 0600))
+	writeCertFiles(t, ca.issue(t, 2), certPath, keyPath)
+
+	enable, disable := true, false
+	for _, ver := range []string{"1.2", "1.3"} {
+		server := NewCert(true)
+		// Rotate the key on every reload.
+		serverCfg := config.TLSConfig{Cert: certPath, Key: keyPath, MaxTLSVersion: ver, SessionTickets: &enable, SessionTicketKeyRotation: "1ns"}
+		server.SetConfig(serverCfg)
+		stls, err := server.Reload(lg)
+		require.NoError(t, err)
+		require.False(t, server.NextTicketRotation().IsZero())
+		client := NewCert(false)
+		client.SetConfig(config.TLSConfig{CA: caPath, CRL: crlPath, SessionTickets: &enable})
+		ctls, err := client.Reload(lg)
+		require.NoError(t, err)
+		// The session cache is keyed by the server name.
+		ctls.ServerName = "server"
+
+		state, err := handshake(t, ln, ctls, stls)
+		require.NoError(t, err, ver)
+		require.False(t, state.DidResume, ver)
+		state, err = handshake(t, ln, ctls, stls)
+		require.NoError(t, err, ver)
+		require.True(t, state.DidResume, ver)
+
+		// The tickets encrypted by the previous key can still be resumed.
+		stls, err = server.Reload(lg)
+		require.NoError(t, err)
+		state, err = handshake(t, ln, ctls, stls)
+		require.NoError(t, err, ver)
+		require.True(t, state.DidResume, ver)
+
+		// The tickets encrypted by older keys can't be resumed.
+		for range 2 {
+			stls, err = server.Reload(lg)
+			require.NoError(t, err)
+		}
+		state, err = handshake(t, ln, ctls, stls)
+		require.NoError(t, err, ver)
+		require.False(t, state.DidResume, ver)
+
+		// The resumed sessions are verified again.
+		require.NoError(t, os.WriteFile(crlPath, ca.crl(t, 2), 0600))
+		ctls, err = client.Reload(lg)
+		require.NoError(t, err)
+		ctls.ServerName = "server"
+		_, err = handshake(t, ln, ctls, stls)
+		require.ErrorContains(t, err, "revoked", ver)
+		require.NoError(t, os.WriteFile(crlPath, ca.crl(t), 0600))
+		ctls, err = client.Reload(lg)
+		require.NoError(t, err)
+		ctls.ServerName = "server"
+
+		// Disable session tickets.
+		serverCfg.SessionTickets = &disable
+		server.SetConfig(serverCfg)
+		stls, err = server.Reload(lg)
+		require.NoError(t, err)
+		require.True(t, stls.SessionTicketsDisabled, ver)
+		require.True(t, server.NextTicketRotation().IsZero())
+		for range 2 {
+			state, err = handshake(t, ln, ctls, stls)
+			require.NoError(t, err, ver)
+			require.False(t, state.DidResume, ver)
+		}
+	}
+}
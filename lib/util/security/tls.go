@@ -244,17 +244,9 @@ func BuildClientTLSConfig(logger *zap.Logger, cfg config.TLSConfig) (*tls.Config
 // GetMinTLSVer parses the min tls version from config and reports warning if necessary.
 func GetMinTLSVer(tlsVerStr string, logger *zap.Logger) uint16 {
 	var minTLSVersion uint16 = tls.VersionTLS12
-	switch {
-	case strings.HasSuffix(tlsVerStr, "1.0"):
-		minTLSVersion = tls.VersionTLS10
-	case strings.HasSuffix(tlsVerStr, "1.1"):
-		minTLSVersion = tls.VersionTLS11
-	case strings.HasSuffix(tlsVerStr, "1.2"):
-		minTLSVersion = tls.VersionTLS12
-	case strings.HasSuffix(tlsVerStr, "1.3"):
-		minTLSVersion = tls.VersionTLS13
-	case len(tlsVerStr) == 0:
-	default:
+	if ver, ok := parseTLSVer(tlsVerStr); ok {
+		minTLSVersion = ver
+	} else if len(tlsVerStr) > 0 {
 		logger.Warn("Invalid TLS version, using default instead", zap.String("tls-version", tlsVerStr))
 	}
 	if minTLSVersion < tls.VersionTLS12 {
@@ -262,3 +254,27 @@ func GetMinTLSVer(tlsVerStr string, logger *zap.Logger) uint16 {
 	}
 	return minTLSVersion
 }
+
+// GetMaxTLSVer parses the max tls version from config. 0 means the highest version supported by crypto/tls.
+func GetMaxTLSVer(tlsVerStr string, logger *zap.Logger) uint16 {
+	ver, ok := parseTLSVer(tlsVerStr)
+	if !ok && len(tlsVerStr) > 0 {
+		logger.Warn("Invalid max TLS version, using default instead", zap.String("tls-version", tlsVerStr))
+	}
+	return ver
+}
+
+func parseTLSVer(tlsVerStr string) (uint16, bool) {
+	switch {
+	case len(tlsVerStr) == 0:
+	case strings.HasSuffix(tlsVerStr, "1.0"):
+		return tls.VersionTLS10, true
+	case strings.HasSuffix(tlsVerStr, "1.1"):
+		return tls.VersionTLS11, true
+	case strings.HasSuffix(tlsVerStr, "1.2"):
+		return tls.VersionTLS12, true
+	case strings.HasSuffix(tlsVerStr, "1.3"):
+		return tls.VersionTLS13, true
+	}
+	return 0, false
+}
@@ -38,6 +38,7 @@ type FactorBasedBalance struct {
 	factorMemory    *FactorMemory
 	factorCPU       *FactorCPU
 	factorLocation  *FactorLocation
+	factorRTT       *FactorRTT
 	factorConnCount *FactorConnCount
 	totalBitNum     int
 	lastMetricTime  time.Time
@@ -110,11 +111,29 @@ func (fbb *FactorBasedBalance) setFactors(cfg *config.Config) {
 		}
 	}
 
+	// The RTT factor refines the location factor, so it's always right after the location factor.
+	rttEnabled := cfg.Balance.RTT.Enable && fbb.factorLocation != nil
+	if rttEnabled {
+		if fbb.factorRTT == nil {
+			fbb.factorRTT = NewFactorRTT()
+		}
+	} else if fbb.factorRTT != nil {
+		fbb.factorRTT.Close()
+		fbb.factorRTT = nil
+	}
+
 	switch cfg.Balance.Policy {
 	case config.BalancePolicyResource:
 		fbb.factors = append(fbb.factors, fbb.factorHealth, fbb.factorMemory, fbb.factorCPU, fbb.factorLocation)
+		if rttEnabled {
+			fbb.factors = append(fbb.factors, fbb.factorRTT)
+		}
 	case config.BalancePolicyLocation:
-		fbb.factors = append(fbb.factors, fbb.factorLocation, fbb.factorHealth, fbb.factorMemory, fbb.factorCPU)
+		fbb.factors = append(fbb.factors, fbb.factorLocation)
+		if rttEnabled {
+			fbb.factors = append(fbb.factors, fbb.factorRTT)
+		}
+		fbb.factors = append(fbb.factors, fbb.factorHealth, fbb.factorMemory, fbb.factorCPU)
 	}
 
 	if fbb.factorConnCount == nil {
@@ -567,6 +567,26 @@ func TestSetFactors(t *testing.T) {
 			},
 			expectedNames: []string{"label", "status", "conn"},
 		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.RTT.Enable = true
+			},
+			expectedNames: []string{"status", "health", "memory", "cpu", "location", "rtt", "conn"},
+		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.Policy = config.BalancePolicyLocation
+				balance.RTT.Enable = true
+			},
+			expectedNames: []string{"status", "location", "rtt", "health", "memory", "cpu", "conn"},
+		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.Policy = config.BalancePolicyConnection
+				balance.RTT.Enable = true
+			},
+			expectedNames: []string{"status", "conn"},
+		},
 	}
 
 	lg, _ := logger.CreateLoggerForTest(t)
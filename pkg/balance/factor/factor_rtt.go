@@ -0,0 +1,101 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package factor
+
+import (
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"go.uber.org/zap"
+)
+
+const (
+	// balanceCount4RTT indicates how many connections to balance per second.
+	balanceCount4RTT = 1
+	// defaultRTTTolerance is used when balance.rtt.tolerance is not set.
+	defaultRTTTolerance = 5 * time.Millisecond
+)
+
+var _ Factor = (*FactorRTT)(nil)
+
+// FactorRTT prefers the backends with lower RTT.
+// It's useful for multi-region deployments when the location labels are missing or too coarse.
+type FactorRTT struct {
+	bitNum              int
+	tolerance           time.Duration
+	migrationsPerSecond float64
+}
+
+func NewFactorRTT() *FactorRTT {
+	return &FactorRTT{
+		bitNum:    2,
+		tolerance: defaultRTTTolerance,
+	}
+}
+
+func (fr *FactorRTT) Name() string {
+	return "rtt"
+}
+
+// UpdateScore divides the backends into levels by RTT. Each level spans a tolerance band from the lowest RTT,
+// so the backends with similar RTTs have the same score.
+func (fr *FactorRTT) UpdateScore(backends []scoredBackend) {
+	if len(backends) <= 1 {
+		return
+	}
+	var minRTT time.Duration
+	for i := range backends {
+		rtt := backends[i].RTT()
+		if rtt > 0 && (minRTT == 0 || rtt < minRTT) {
+			minRTT = rtt
+		}
+	}
+	maxScore := 1<<fr.bitNum - 1
+	for i := range backends {
+		// The RTT is unknown before the first health check or when it's disabled, so treat it as the highest one.
+		// Otherwise, the connections would be routed to the backends that are not measured yet.
+		score := maxScore
+		if rtt := backends[i].RTT(); rtt > 0 {
+			score = min(int((rtt-minRTT)/fr.tolerance), maxScore)
+		}
+		backends[i].addScore(score, fr.bitNum)
+	}
+}
+
+func (fr *FactorRTT) ScoreBitNum() int {
+	return fr.bitNum
+}
+
+func (fr *FactorRTT) BalanceCount(from, to scoredBackend) (BalanceAdvice, float64, []zap.Field) {
+	// The RTT jitters, so only migrate connections when the difference is obviously larger than the tolerance.
+	// Otherwise, the connections may be migrated back and forth.
+	// Don't migrate connections from or to the backends whose RTT is unknown.
+	if from.RTT() <= 0 || to.RTT() <= 0 || from.RTT()-to.RTT() < 2*fr.tolerance {
+		return AdviceNeutral, 0, nil
+	}
+	count := float64(balanceCount4RTT)
+	if fr.migrationsPerSecond > 0 {
+		count = fr.migrationsPerSecond
+	}
+	fields := []zap.Field{
+		zap.Duration("from_rtt", from.RTT()),
+		zap.Duration("to_rtt", to.RTT()),
+	}
+	return AdvicePositive, count, fields
+}
+
+func (fr *FactorRTT) SetConfig(cfg *config.Config) {
+	fr.migrationsPerSecond = cfg.Balance.RTT.MigrationsPerSecond
+	fr.tolerance = cfg.Balance.RTT.Tolerance
+	if fr.tolerance <= 0 {
+		fr.tolerance = defaultRTTTolerance
+	}
+}
+
+func (fr *FactorRTT) CanBeRouted(_ uint64) bool {
+	return true
+}
+
+func (fr *FactorRTT) Close() {
+}
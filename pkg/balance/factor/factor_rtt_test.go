@@ -0,0 +1,120 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package factor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFactorRTTScore(t *testing.T) {
+	tests := []struct {
+		rtt           time.Duration
+		expectedScore uint64
+	}{
+		{
+			rtt:           time.Millisecond,
+			expectedScore: 0,
+		},
+		{
+			rtt:           4 * time.Millisecond,
+			expectedScore: 0,
+		},
+		{
+			rtt:           7 * time.Millisecond,
+			expectedScore: 1,
+		},
+		{
+			rtt:           12 * time.Millisecond,
+			expectedScore: 2,
+		},
+		{
+			rtt:           100 * time.Millisecond,
+			expectedScore: 3,
+		},
+		{
+			// unknown RTT
+			rtt:           0,
+			expectedScore: 3,
+		},
+	}
+
+	factor := NewFactorRTT()
+	backends := make([]scoredBackend, 0, len(tests))
+	for _, test := range tests {
+		backends = append(backends, scoredBackend{
+			BackendCtx: &mockBackend{
+				rtt: test.rtt,
+			},
+		})
+	}
+	factor.UpdateScore(backends)
+	for i, test := range tests {
+		require.Equal(t, test.expectedScore, backends[i].score(), "test idx: %d", i)
+	}
+}
+
+func TestFactorRTTConfig(t *testing.T) {
+	tests := []struct {
+		rtt        []time.Duration
+		tolerance  time.Duration
+		migrations float64
+		advice     BalanceAdvice
+		speed      float64
+	}{
+		{
+			rtt:    []time.Duration{time.Millisecond, 8 * time.Millisecond},
+			advice: AdviceNeutral,
+		},
+		{
+			rtt:    []time.Duration{time.Millisecond, 20 * time.Millisecond},
+			advice: AdvicePositive,
+			speed:  1,
+		},
+		{
+			rtt:        []time.Duration{time.Millisecond, 20 * time.Millisecond},
+			migrations: 10,
+			advice:     AdvicePositive,
+			speed:      10,
+		},
+		{
+			rtt:       []time.Duration{time.Millisecond, 20 * time.Millisecond},
+			tolerance: 10 * time.Millisecond,
+			advice:    AdviceNeutral,
+		},
+		{
+			// unknown RTT of the target
+			rtt:    []time.Duration{0, 20 * time.Millisecond},
+			advice: AdviceNeutral,
+		},
+		{
+			// unknown RTT of the source
+			rtt:    []time.Duration{time.Millisecond, 0},
+			advice: AdviceNeutral,
+		},
+	}
+
+	for i, test := range tests {
+		factor := NewFactorRTT()
+		factor.SetConfig(&config.Config{Balance: config.Balance{RTT: config.RTTFactor{
+			Factor:    config.Factor{MigrationsPerSecond: test.migrations},
+			Tolerance: test.tolerance,
+		}}})
+		backends := make([]scoredBackend, 0, len(test.rtt))
+		for _, rtt := range test.rtt {
+			backends = append(backends, scoredBackend{
+				BackendCtx: &mockBackend{
+					rtt: rtt,
+				},
+			})
+		}
+		factor.UpdateScore(backends)
+		advice, balanceCount, _ := factor.BalanceCount(backends[1], backends[0])
+		require.Equal(t, test.advice, advice, "case id: %d", i)
+		require.EqualValues(t, test.speed, balanceCount, "case id: %d", i)
+	}
+}
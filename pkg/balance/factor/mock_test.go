@@ -26,6 +26,7 @@ type mockBackend struct {
 	connCount int
 	healthy   bool
 	local     bool
+	rtt       time.Duration
 }
 
 func newMockBackend(healthy bool, connScore int) *mockBackend {
@@ -60,6 +61,10 @@ func (mb *mockBackend) Local() bool {
 	return mb.local
 }
 
+func (mb *mockBackend) RTT() time.Duration {
+	return mb.rtt
+}
+
 func (mb *mockBackend) Keyspace() string {
 	return ""
 }
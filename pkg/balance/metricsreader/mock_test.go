@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/lib/util/logger"
@@ -150,6 +151,10 @@ func (mb *mockBackend) Local() bool {
 	return true
 }
 
+func (mb *mockBackend) RTT() time.Duration {
+	return 0
+}
+
 func (mb *mockBackend) Keyspace() string {
 	return ""
 }
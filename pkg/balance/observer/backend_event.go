@@ -0,0 +1,148 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package observer
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tiproxy/pkg/metrics"
+)
+
+type BackendEventType string
+
+const (
+	BackendEventAdded          BackendEventType = "added"
+	BackendEventRemoved        BackendEventType = "removed"
+	BackendEventHealthy        BackendEventType = "healthy"
+	BackendEventUnhealthy      BackendEventType = "unhealthy"
+	BackendEventLabelsChanged  BackendEventType = "labels_changed"
+	BackendEventVersionChanged BackendEventType = "version_changed"
+)
+
+const (
+	// The default buffer size of each event subscriber.
+	// If the subscriber is too slow to consume the events, the newer events are dropped.
+	defaultEventBufferSize = 1024
+)
+
+// BackendEvent describes a change of a backend in the health result.
+type BackendEvent struct {
+	Type       BackendEventType  `json:"type"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Addr       string            `json:"addr"`
+	Time       time.Time         `json:"time"`
+	Healthy    bool              `json:"healthy"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	PrevLabels map[string]string `json:"prev_labels,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	PrevVer    string            `json:"prev_version,omitempty"`
+	Err        string            `json:"err,omitempty"`
+}
+
+// diffBackends compares 2 health results and returns the events of the changed backends.
+func diffBackends(prev, cur map[string]*BackendHealth, now time.Time) []BackendEvent {
+	var events []BackendEvent
+	for addr, newHealth := range cur {
+		oldHealth, ok := prev[addr]
+		if !ok {
+			events = append(events, newBackendEvent(BackendEventAdded, addr, newHealth, now))
+			continue
+		}
+		if oldHealth.Healthy != newHealth.Healthy {
+			tp := BackendEventUnhealthy
+			if newHealth.Healthy {
+				tp = BackendEventHealthy
+			}
+			events = append(events, newBackendEvent(tp, addr, newHealth, now))
+		}
+		if !maps.Equal(oldHealth.Labels, newHealth.Labels) {
+			event := newBackendEvent(BackendEventLabelsChanged, addr, newHealth, now)
+			event.PrevLabels = oldHealth.Labels
+			events = append(events, event)
+		}
+		// The version is empty when the backend is down, so only compare non-empty versions.
+		if len(oldHealth.ServerVersion) > 0 && len(newHealth.ServerVersion) > 0 && oldHealth.ServerVersion != newHealth.ServerVersion {
+			event := newBackendEvent(BackendEventVersionChanged, addr, newHealth, now)
+			event.PrevVer = oldHealth.ServerVersion
+			events = append(events, event)
+		}
+	}
+	for addr, oldHealth := range prev {
+		if _, ok := cur[addr]; !ok {
+			event := newBackendEvent(BackendEventRemoved, addr, oldHealth, now)
+			event.Healthy = false
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func newBackendEvent(tp BackendEventType, addr string, health *BackendHealth, now time.Time) BackendEvent {
+	event := BackendEvent{
+		Type:    tp,
+		Addr:    addr,
+		Time:    now,
+		Healthy: health.Healthy,
+		Labels:  health.Labels,
+		Version: health.ServerVersion,
+	}
+	if health.PingErr != nil {
+		event.Err = health.PingErr.Error()
+	}
+	return event
+}
+
+// EventHub broadcasts backend events to the subscribers, such as the HTTP API.
+// It's shared by the observers of all namespaces.
+type EventHub struct {
+	sync.Mutex
+	subscribers map[uint64]chan BackendEvent
+	nextID      uint64
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subscribers: make(map[uint64]chan BackendEvent),
+	}
+}
+
+// Subscribe returns the subscriber ID and the event channel.
+// The caller must call Unsubscribe after it finishes.
+func (eh *EventHub) Subscribe() (uint64, <-chan BackendEvent) {
+	ch := make(chan BackendEvent, defaultEventBufferSize)
+	eh.Lock()
+	defer eh.Unlock()
+	eh.nextID++
+	eh.subscribers[eh.nextID] = ch
+	return eh.nextID, ch
+}
+
+func (eh *EventHub) Unsubscribe(id uint64) {
+	eh.Lock()
+	defer eh.Unlock()
+	if ch, ok := eh.subscribers[id]; ok {
+		close(ch)
+		delete(eh.subscribers, id)
+	}
+}
+
+// Publish never blocks. If a subscriber is too slow, the events are dropped for it.
+func (eh *EventHub) Publish(events []BackendEvent) {
+	if len(events) == 0 {
+		return
+	}
+	eh.Lock()
+	defer eh.Unlock()
+	for _, ch := range eh.subscribers {
+		for _, event := range events {
+			select {
+			case ch <- event:
+			default:
+				metrics.BackendEventDropCounter.Inc()
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package observer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffBackends(t *testing.T) {
+	prev := map[string]*BackendHealth{
+		"0": {Healthy: true, ServerVersion: "8.5.0"},
+		"1": {Healthy: true, BackendInfo: BackendInfo{Labels: map[string]string{"zone": "a"}}},
+		"2": {Healthy: false},
+		"3": {Healthy: true, ServerVersion: "8.5.0"},
+		"4": {Healthy: true, ServerVersion: "8.5.0"},
+	}
+	cur := map[string]*BackendHealth{
+		"0": {Healthy: false},
+		"1": {Healthy: true, BackendInfo: BackendInfo{Labels: map[string]string{"zone": "b"}}},
+		"2": {Healthy: true},
+		"3": {Healthy: true, ServerVersion: "8.5.1"},
+		"5": {Healthy: true},
+	}
+	events := diffBackends(prev, cur, time.Now())
+	expected := map[string]BackendEventType{
+		"0": BackendEventUnhealthy,
+		"1": BackendEventLabelsChanged,
+		"2": BackendEventHealthy,
+		"3": BackendEventVersionChanged,
+		"4": BackendEventRemoved,
+		"5": BackendEventAdded,
+	}
+	require.Len(t, events, len(expected))
+	for _, event := range events {
+		require.Equal(t, expected[event.Addr], event.Type, event.Addr)
+		switch event.Type {
+		case BackendEventLabelsChanged:
+			require.Equal(t, "a", event.PrevLabels["zone"])
+			require.Equal(t, "b", event.Labels["zone"])
+		case BackendEventVersionChanged:
+			require.Equal(t, "8.5.0", event.PrevVer)
+			require.Equal(t, "8.5.1", event.Version)
+		}
+	}
+	require.Empty(t, diffBackends(cur, cur, time.Now()))
+}
+
+func TestEventHub(t *testing.T) {
+	hub := NewEventHub()
+	id1, ch1 := hub.Subscribe()
+	id2, ch2 := hub.Subscribe()
+	hub.Publish([]BackendEvent{{Type: BackendEventAdded, Addr: "0"}})
+	require.Equal(t, "0", (<-ch1).Addr)
+	require.Equal(t, "0", (<-ch2).Addr)
+
+	// A slow subscriber doesn't block publishing.
+	events := make([]BackendEvent, defaultEventBufferSize+1)
+	hub.Publish(events)
+	require.Len(t, ch1, defaultEventBufferSize)
+
+	hub.Unsubscribe(id1)
+	_, ok := <-ch2
+	require.True(t, ok)
+	hub.Unsubscribe(id2)
+	for range ch2 {
+	}
+	_, ok = <-ch2
+	require.False(t, ok)
+}
@@ -5,9 +5,11 @@ package observer
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/pingcap/tiproxy/lib/util/retry"
 	"github.com/pingcap/tiproxy/pkg/manager/infosync"
 	"github.com/pingcap/tiproxy/pkg/metrics"
@@ -32,6 +34,9 @@ type PDFetcher struct {
 	tpFetcher TopologyFetcher
 	logger    *zap.Logger
 	config    *config.HealthCheck
+	// snapshot is optional. It's used when PD is unavailable before the first successful fetch.
+	snapshot *topologySnapshot
+	fetched  bool
 }
 
 func NewPDFetcher(tpFetcher TopologyFetcher, logger *zap.Logger, config *config.HealthCheck) *PDFetcher {
@@ -43,8 +48,32 @@ func NewPDFetcher(tpFetcher TopologyFetcher, logger *zap.Logger, config *config.
 	}
 }
 
+// SetSnapshotPath makes the fetcher persist the backend list to the file and fall back to it
+// when PD is unavailable on startup. It must be called before fetching.
+func (pf *PDFetcher) SetSnapshotPath(path string) {
+	pf.snapshot = newTopologySnapshot(path)
+}
+
 func (pf *PDFetcher) GetBackendList(ctx context.Context) (map[string]*BackendInfo, error) {
-	backends := pf.fetchBackendList(ctx)
+	// Before the first successful fetch, only retry limited times so that we can fall back to the snapshot.
+	// After that, the router keeps the last backend list during retrying, so retry infinitely.
+	useSnapshot := pf.snapshot != nil && !pf.fetched
+	retryCnt := uint64(retry.InfiniteCnt)
+	if useSnapshot {
+		retryCnt = uint64(pf.config.MaxRetries)
+	}
+	backends, err := pf.fetchBackendList(ctx, retryCnt)
+	if err != nil && useSnapshot && ctx.Err() == nil {
+		if infos := pf.loadSnapshot(); infos != nil {
+			return infos, nil
+		}
+		backends, err = pf.fetchBackendList(ctx, retry.InfiniteCnt)
+	}
+	// Must be cancelled if err != nil, we do not log errors.
+	if err != nil {
+		return nil, nil
+	}
+
 	infos := make(map[string]*BackendInfo, len(backends))
 	for addr, backend := range backends {
 		infos[addr] = &BackendInfo{
@@ -53,17 +82,35 @@ func (pf *PDFetcher) GetBackendList(ctx context.Context) (map[string]*BackendInf
 			StatusPort: backend.StatusPort,
 		}
 	}
+	pf.fetched = true
+	if pf.snapshot != nil {
+		if err := pf.snapshot.save(infos); err != nil {
+			pf.logger.Warn("saving topology snapshot failed", zap.String("path", pf.snapshot.path), zap.Error(err))
+		}
+	}
 	return infos, nil
 }
 
-func (pf *PDFetcher) fetchBackendList(ctx context.Context) map[string]*infosync.TiDBTopologyInfo {
+func (pf *PDFetcher) loadSnapshot() map[string]*BackendInfo {
+	infos, updateTime, err := pf.snapshot.load()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			pf.logger.Warn("loading topology snapshot failed", zap.String("path", pf.snapshot.path), zap.Error(err))
+		}
+		return nil
+	}
+	pf.logger.Warn("fetching backend list failed, use the topology snapshot instead",
+		zap.String("path", pf.snapshot.path), zap.Time("update_time", updateTime), zap.Int("backends", len(infos)))
+	return infos
+}
+
+func (pf *PDFetcher) fetchBackendList(ctx context.Context, retryCnt uint64) (map[string]*infosync.TiDBTopologyInfo, error) {
 	var backends map[string]*infosync.TiDBTopologyInfo
-	// The jobs of PDFetcher all rely on the topology, so we retry infinitely.
 	err := retry.RetryNotify(func() error {
 		var err error
 		backends, err = pf.tpFetcher.GetTiDBTopology(ctx)
 		return err
-	}, ctx, pf.config.RetryInterval, retry.InfiniteCnt,
+	}, ctx, pf.config.RetryInterval, retryCnt,
 		func(err error, duration time.Duration) {
 			// Ignore errors when TiProxy shuts down.
 			if ctx.Err() != nil {
@@ -72,12 +119,7 @@ func (pf *PDFetcher) fetchBackendList(ctx context.Context) map[string]*infosync.
 			pf.logger.Error("fetch backend list failed, retrying", zap.Error(err))
 			metrics.ServerErrCounter.WithLabelValues("fetchBackendList").Inc()
 		}, 10)
-
-	// Must be cancelled if err != nil, we do not log errors.
-	if err != nil {
-		return nil
-	}
-	return backends
+	return backends, err
 }
 
 // StaticFetcher uses configured static addrs. This is only used for testing.
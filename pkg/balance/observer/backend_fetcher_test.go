@@ -5,6 +5,8 @@ package observer
 
 import (
 	"context"
+	"errors"
+	"path/filepath"
 	"testing"
 
 	"github.com/pingcap/tiproxy/lib/util/logger"
@@ -85,3 +87,49 @@ func TestPDFetcher(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+
+func TestPDFetcherFallbackToSnapshot(t *testing.T) {
+	tpFetcher := newMockTpFetcher(t)
+	lg, _ := logger.CreateLoggerForTest(t)
+	path := filepath.Join(t.TempDir(), "default.json")
+	infos := map[string]*infosync.TiDBTopologyInfo{
+		"1.1.1.1:4000": {
+			Labels:     map[string]string{"k1": "v1"},
+			IP:         "1.1.1.1",
+			StatusPort: 10080,
+		},
+	}
+
+	// PD is available and the snapshot is saved.
+	pf := NewPDFetcher(tpFetcher, lg, newHealthCheckConfigForTest())
+	pf.SetSnapshotPath(path)
+	tpFetcher.infos = infos
+	backends, err := pf.GetBackendList(context.Background())
+	require.NoError(t, err)
+	require.Len(t, backends, 1)
+
+	// PD is unavailable on restart and the snapshot is used.
+	pf = NewPDFetcher(tpFetcher, lg, newHealthCheckConfigForTest())
+	pf.SetSnapshotPath(path)
+	tpFetcher.infos, tpFetcher.err = nil, errors.New("mock error")
+	backends, err = pf.GetBackendList(context.Background())
+	require.NoError(t, err)
+	require.Len(t, backends, 1)
+	require.Equal(t, "1.1.1.1", backends["1.1.1.1:4000"].IP)
+	require.Equal(t, map[string]string{"k1": "v1"}, backends["1.1.1.1:4000"].Labels)
+
+	// PD recovers and the new topology is used and saved.
+	tpFetcher.infos, tpFetcher.err = map[string]*infosync.TiDBTopologyInfo{
+		"2.2.2.2:4000": {
+			IP:         "2.2.2.2",
+			StatusPort: 10080,
+		},
+	}, nil
+	backends, err = pf.GetBackendList(context.Background())
+	require.NoError(t, err)
+	require.Len(t, backends, 1)
+	require.NotNil(t, backends["2.2.2.2:4000"])
+	saved, _, err := newTopologySnapshot(path).load()
+	require.NoError(t, err)
+	require.True(t, backendsEqual(backends, saved))
+}
@@ -12,6 +12,11 @@ import (
 	"github.com/pingcap/tiproxy/lib/config"
 )
 
+const (
+	// The weight of the latest RTT sample is 1/rttSmoothFactor.
+	rttSmoothFactor = 8
+)
+
 type BackendHealth struct {
 	BackendInfo
 	Healthy bool
@@ -25,6 +30,8 @@ type BackendHealth struct {
 	SupportRedirection bool
 	// Whether the backend in the same zone with TiProxy. If TiProxy location is undefined, take all backends as local.
 	Local bool
+	// The smoothed round-trip time of connecting to the SQL port. It's 0 if it's never measured.
+	RTT time.Duration
 }
 
 func (bh *BackendHealth) setLocal(cfg *config.Config) {
@@ -44,6 +51,15 @@ func (bh *BackendHealth) setLocal(cfg *config.Config) {
 	bh.Local = false
 }
 
+// updateRTT smooths the RTT like TCP does (RFC 6298) to avoid routing jitter.
+func (bh *BackendHealth) updateRTT(rtt time.Duration) {
+	if bh.RTT == 0 {
+		bh.RTT = rtt
+		return
+	}
+	bh.RTT = bh.RTT - bh.RTT/rttSmoothFactor + rtt/rttSmoothFactor
+}
+
 func (bh *BackendHealth) Equals(other BackendHealth) bool {
 	return bh.BackendInfo.Equals(other.BackendInfo) &&
 		bh.Healthy == other.Healthy &&
@@ -64,6 +80,9 @@ func (bh *BackendHealth) String() string {
 	if !bh.SupportRedirection {
 		_, _ = sb.WriteString(", support redirection: false")
 	}
+	if bh.RTT > 0 {
+		_, _ = sb.WriteString(fmt.Sprintf(", rtt: %s", bh.RTT))
+	}
 	if len(bh.ServerVersion) > 0 {
 		_, _ = sb.WriteString(", version: ")
 		_, _ = sb.WriteString(bh.ServerVersion)
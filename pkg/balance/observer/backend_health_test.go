@@ -6,6 +6,7 @@ package observer
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -24,6 +25,7 @@ func TestBackendHealthToString(t *testing.T) {
 			ServerVersion:      "v1.0.0",
 			SupportRedirection: true,
 			Local:              true,
+			RTT:                time.Millisecond,
 		},
 	}
 	// Just test no error happens
@@ -118,3 +120,16 @@ func TestBackendHealthEquals(t *testing.T) {
 		require.Equal(t, test.equal, test.b.Equals(test.a), "test %d", i)
 	}
 }
+
+func TestBackendHealthUpdateRTT(t *testing.T) {
+	var bh BackendHealth
+	bh.updateRTT(8 * time.Millisecond)
+	require.Equal(t, 8*time.Millisecond, bh.RTT)
+	// A single spike only affects the RTT a little.
+	bh.updateRTT(80 * time.Millisecond)
+	require.Equal(t, 17*time.Millisecond, bh.RTT)
+	for range 100 {
+		bh.updateRTT(time.Millisecond)
+	}
+	require.Less(t, bh.RTT, 2*time.Millisecond)
+}
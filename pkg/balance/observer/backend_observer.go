@@ -49,6 +49,9 @@ type DefaultBackendObserver struct {
 	logger            *zap.Logger
 	healthCheckConfig *config.HealthCheck
 	wgp               *waitgroup.WaitGroupPool
+	// eventHub is optional. If it's set, the backend changes are published to it.
+	eventHub  *EventHub
+	namespace string
 }
 
 // NewDefaultBackendObserver creates a BackendObserver.
@@ -70,6 +73,12 @@ func NewDefaultBackendObserver(logger *zap.Logger, config *config.HealthCheck, b
 	return bo
 }
 
+// SetEventHub makes the observer publish backend events to the hub. It must be called before Start.
+func (bo *DefaultBackendObserver) SetEventHub(namespace string, hub *EventHub) {
+	bo.namespace = namespace
+	bo.eventHub = hub
+}
+
 // Start starts watching.
 func (bo *DefaultBackendObserver) Start(ctx context.Context) {
 	childCtx, cancelFunc := context.WithCancel(ctx)
@@ -192,6 +201,7 @@ func (bo *DefaultBackendObserver) updateHealthResult(result HealthResult) {
 	if result.err != nil {
 		return
 	}
+	bo.publishEvents(result.backends)
 	for addr, newHealth := range result.backends {
 		if !newHealth.Healthy {
 			continue
@@ -220,6 +230,17 @@ func (bo *DefaultBackendObserver) updateHealthResult(result HealthResult) {
 	bo.curBackends = result.backends
 }
 
+func (bo *DefaultBackendObserver) publishEvents(backends map[string]*BackendHealth) {
+	if bo.eventHub == nil {
+		return
+	}
+	events := diffBackends(bo.curBackends, backends, time.Now())
+	for i := range events {
+		events[i].Namespace = bo.namespace
+	}
+	bo.eventHub.Publish(events)
+}
+
 func (bo *DefaultBackendObserver) notifySubscribers(ctx context.Context, result HealthResult) {
 	if ctx.Err() != nil {
 		return
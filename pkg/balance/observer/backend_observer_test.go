@@ -47,6 +47,34 @@ func TestObserveBackends(t *testing.T) {
 	ts.checkStatus(backend1, false, info1)
 }
 
+func TestPublishBackendEvents(t *testing.T) {
+	ts := newObserverTestSuite(t)
+	t.Cleanup(ts.close)
+	hub := NewEventHub()
+	_, ch := hub.Subscribe()
+	ts.bo.SetEventHub("ns", hub)
+	ts.bo.Start(context.Background())
+
+	checkEvent := func(addr string, tp BackendEventType) {
+		select {
+		case event := <-ch:
+			require.Equal(t, addr, event.Addr)
+			require.Equal(t, tp, event.Type)
+			require.Equal(t, "ns", event.Namespace)
+		case <-time.After(3 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	backend, _ := ts.addBackend()
+	checkEvent(backend, BackendEventAdded)
+	ts.setHealth(backend, false)
+	checkEvent(backend, BackendEventUnhealthy)
+	ts.setLabels(backend, map[string]string{"k": "v"})
+	checkEvent(backend, BackendEventLabelsChanged)
+	ts.removeBackend(backend)
+	checkEvent(backend, BackendEventRemoved)
+}
+
 func TestObserveInParallel(t *testing.T) {
 	ts := newObserverTestSuite(t)
 	t.Cleanup(ts.close)
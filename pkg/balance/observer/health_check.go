@@ -72,6 +72,7 @@ func (dhc *DefaultHealthCheck) Check(ctx context.Context, addr string, info *Bac
 	if lastBh != nil {
 		bh.SupportRedirection = lastBh.SupportRedirection
 		bh.lastCheckSigningCertTime = lastBh.lastCheckSigningCertTime
+		bh.RTT = lastBh.RTT
 	}
 	if !dhc.cfg.Enable {
 		return bh
@@ -98,6 +99,8 @@ func (dhc *DefaultHealthCheck) checkSqlPort(ctx context.Context, addr string, bh
 		if err != nil {
 			return err
 		}
+		// Establishing a TCP connection takes one round trip.
+		bh.updateRTT(time.Since(startTime))
 		if err = conn.SetReadDeadline(time.Now().Add(dhc.cfg.DialTimeout)); err != nil {
 			return err
 		}
@@ -56,6 +56,7 @@ func TestHealthCheck(t *testing.T) {
 	backend.setHasSigningCert(true)
 	health := hc.Check(context.Background(), backend.sqlAddr, info, nil)
 	require.True(t, health.Healthy)
+	require.Greater(t, health.RTT, time.Duration(0))
 
 	backend.stopSQLServer()
 	health = hc.Check(context.Background(), backend.sqlAddr, info, nil)
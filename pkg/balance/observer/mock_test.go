@@ -85,8 +85,10 @@ func newMockHealthCheck() *mockHealthCheck {
 func (mhc *mockHealthCheck) Check(_ context.Context, addr string, info *BackendInfo, _ *BackendHealth) *BackendHealth {
 	mhc.Lock()
 	defer mhc.Unlock()
-	mhc.backends[addr].BackendInfo = *info
-	return mhc.backends[addr]
+	// Return a copy like the real health check so that the last result is not modified.
+	health := *mhc.backends[addr]
+	health.BackendInfo = *info
+	return &health
 }
 
 func (mhc *mockHealthCheck) setBackend(addr string, health *BackendHealth) {
@@ -0,0 +1,85 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package observer
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+// topologySnapshotData is the content of the snapshot file.
+type topologySnapshotData struct {
+	UpdateTime time.Time               `json:"update_time"`
+	Backends   map[string]*BackendInfo `json:"backends"`
+}
+
+// topologySnapshot persists the last fetched backend list to the disk.
+// If PD is unavailable when TiProxy starts, the snapshot is used until PD recovers.
+type topologySnapshot struct {
+	path string
+	// saved is the last backend list written to the file. It avoids writing the same content repeatedly.
+	saved map[string]*BackendInfo
+}
+
+func newTopologySnapshot(path string) *topologySnapshot {
+	return &topologySnapshot{
+		path: path,
+	}
+}
+
+func (ts *topologySnapshot) load() (map[string]*BackendInfo, time.Time, error) {
+	content, err := os.ReadFile(ts.path)
+	if err != nil {
+		return nil, time.Time{}, errors.WithStack(err)
+	}
+	var data topologySnapshotData
+	if err = json.Unmarshal(content, &data); err != nil {
+		return nil, time.Time{}, errors.WithStack(err)
+	}
+	return data.Backends, data.UpdateTime, nil
+}
+
+func (ts *topologySnapshot) save(backends map[string]*BackendInfo) error {
+	if backendsEqual(ts.saved, backends) {
+		return nil
+	}
+	content, err := json.Marshal(topologySnapshotData{
+		UpdateTime: time.Now(),
+		Backends:   backends,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(ts.path), 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	// Write to a temporary file and then rename it to avoid leaving a broken file on crash.
+	tmpPath := ts.path + ".tmp"
+	if err = os.WriteFile(tmpPath, content, 0600); err != nil {
+		return errors.WithStack(err)
+	}
+	if err = os.Rename(tmpPath, ts.path); err != nil {
+		return errors.WithStack(err)
+	}
+	ts.saved = maps.Clone(backends)
+	return nil
+}
+
+func backendsEqual(m1, m2 map[string]*BackendInfo) bool {
+	if m1 == nil || len(m1) != len(m2) {
+		return false
+	}
+	for addr, info1 := range m1 {
+		info2, ok := m2[addr]
+		if !ok || !info1.Equals(*info2) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package observer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopologySnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology", "default.json")
+	ts := newTopologySnapshot(path)
+	_, _, err := ts.load()
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	backends := map[string]*BackendInfo{
+		"1.1.1.1:4000": {IP: "1.1.1.1", StatusPort: 10080, Labels: map[string]string{"zone": "east"}},
+		"2.2.2.2:4000": {IP: "2.2.2.2", StatusPort: 10080},
+	}
+	require.NoError(t, ts.save(backends))
+	loaded, updateTime, err := ts.load()
+	require.NoError(t, err)
+	require.False(t, updateTime.IsZero())
+	require.True(t, backendsEqual(backends, loaded))
+
+	// The same content is not written again.
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, ts.save(loaded))
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// A new snapshot overwrites the old one.
+	delete(backends, "2.2.2.2:4000")
+	require.NoError(t, ts.save(backends))
+	loaded, _, err = newTopologySnapshot(path).load()
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	require.Equal(t, "east", loaded["1.1.1.1:4000"].Labels["zone"])
+}
@@ -4,6 +4,8 @@
 package policy
 
 import (
+	"time"
+
 	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
 	"go.uber.org/zap"
@@ -25,6 +27,8 @@ type BackendCtx interface {
 	ConnScore() int
 	Healthy() bool
 	Local() bool
+	// RTT is the smoothed network latency to the backend. It's 0 if unknown.
+	RTT() time.Duration
 	Keyspace() string
 	GetBackendInfo() observer.BackendInfo
 }
@@ -3,7 +3,11 @@
 
 package policy
 
-import "github.com/pingcap/tiproxy/pkg/balance/observer"
+import (
+	"time"
+
+	"github.com/pingcap/tiproxy/pkg/balance/observer"
+)
 
 var _ BackendCtx = (*mockBackend)(nil)
 
@@ -39,6 +43,10 @@ func (mb *mockBackend) Local() bool {
 	return true
 }
 
+func (mb *mockBackend) RTT() time.Duration {
+	return 0
+}
+
 func (mb *mockBackend) Keyspace() string {
 	return ""
 }
@@ -8,6 +8,11 @@ import "net"
 type ClientInfo struct {
 	ClientAddr net.Addr
 	ProxyAddr  net.Addr
+	// SNI is the server name sent by the client in the TLS handshake. It's empty if TLS is disabled.
+	SNI string
+	// Group binds the connection to the backend group that has this value, regardless of the routing rule.
+	// It's set by the listener.
+	Group string
 	// TODO: username, database, etc.
 }
 
@@ -8,6 +8,7 @@ import (
 	"net"
 	"reflect"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +31,8 @@ const (
 	MatchClientCIDR
 	// Match connections based on proxy CIDR. If proxy-protocol is disabled, route by the client CIDR.
 	MatchProxyCIDR
+	// Match connections based on the SNI hostname of the TLS handshake.
+	MatchSNI
 )
 
 var _ ConnEventReceiver = (*Group)(nil)
@@ -88,6 +91,10 @@ func (g *Group) Match(clientInfo ClientInfo) bool {
 		if g.matchType == MatchClientCIDR {
 			addr = clientInfo.ClientAddr
 		}
+		// Unix socket clients have no IP.
+		if _, ok := addr.(*net.UnixAddr); ok {
+			return false
+		}
 		ip, err := netutil.NetAddr2IP(addr)
 		if err != nil {
 			g.lg.Error("checking CIDR failed", zap.Stringer("addr", addr), zap.Error(err))
@@ -98,13 +105,33 @@ func (g *Group) Match(clientInfo ClientInfo) bool {
 			g.lg.Error("checking CIDR failed", zap.Stringer("addr", addr), zap.Error(err))
 		}
 		return contains
+	case MatchSNI:
+		return matchSNI(g.values, clientInfo.SNI)
 	}
 	return true
 }
 
+// matchSNI returns true if the hostname matches any of the patterns case-insensitively.
+// A pattern like `*.example.com` matches exactly one label in the place of `*`.
+func matchSNI(patterns []string, sni string) bool {
+	if len(sni) == 0 {
+		return false
+	}
+	for _, pattern := range patterns {
+		if strings.EqualFold(pattern, sni) {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") && len(sni) > len(suffix) &&
+			strings.EqualFold(sni[len(sni)-len(suffix):], suffix) && !strings.Contains(sni[:len(sni)-len(suffix)], ".") {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *Group) EqualValues(values []string) bool {
 	switch g.matchType {
-	case MatchClientCIDR, MatchProxyCIDR:
+	case MatchClientCIDR, MatchProxyCIDR, MatchSNI:
 		if len(g.values) != len(values) {
 			return false
 		}
@@ -123,7 +150,7 @@ func (g *Group) EqualValues(values []string) bool {
 // E.g. enable public endpoint (3 cidrs) -> enable private endpoint (6 cidrs) -> disable public endpoint (3 cidrs).
 func (g *Group) Intersect(values []string) bool {
 	switch g.matchType {
-	case MatchClientCIDR, MatchProxyCIDR:
+	case MatchClientCIDR, MatchProxyCIDR, MatchSNI:
 		for _, v := range g.values {
 			if slices.Contains(values, v) {
 				return true
@@ -134,17 +161,16 @@ func (g *Group) Intersect(values []string) bool {
 	return false
 }
 
-// Backend CIDRs may change anytime.
+// Backend CIDRs and SNI hostnames may change anytime.
 func (g *Group) RefreshCidr() {
 	g.Lock()
 	defer g.Unlock()
 	switch g.matchType {
-	case MatchClientCIDR, MatchProxyCIDR:
+	case MatchClientCIDR, MatchProxyCIDR, MatchSNI:
 		valueMap := make(map[string]struct{}, len(g.values))
 		for _, b := range g.backends {
-			cidrs := b.Cidr()
-			for _, cidr := range cidrs {
-				valueMap[cidr] = struct{}{}
+			for _, value := range b.GroupValues(g.matchType) {
+				valueMap[value] = struct{}{}
 			}
 		}
 		values := make([]string, 0, len(valueMap))
@@ -185,7 +211,7 @@ func setConnWrapper(conn RedirectableConn, ce *glist.Element[*connWrapper]) {
 	conn.SetValue(_routerKey, ce)
 }
 
-func (g *Group) Route(excluded []BackendInst) (policy.BackendCtx, error) {
+func (g *Group) Route(excluded []BackendInst) (BackendInst, error) {
 	g.Lock()
 	defer g.Unlock()
 
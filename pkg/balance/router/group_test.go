@@ -106,9 +106,71 @@ func TestMatchIP(t *testing.T) {
 			}
 			require.Equal(t, test.success, g.Match(ci))
 		}
+		// Unix socket clients never match CIDRs.
+		g, err := NewGroup([]string{"0.0.0.0/0"}, nopBpCreator, matchType, lg)
+		require.NoError(t, err)
+		addr := &net.UnixAddr{Name: "/tmp/tiproxy.sock", Net: "unix"}
+		require.False(t, g.Match(ClientInfo{ClientAddr: addr, ProxyAddr: addr}))
 	}
 }
 
+func TestMatchSNI(t *testing.T) {
+	tests := []struct {
+		sni     string
+		hosts   []string
+		success bool
+	}{
+		{
+			sni:     "tenant1.example.com",
+			hosts:   []string{"tenant1.example.com"},
+			success: true,
+		},
+		{
+			sni:     "Tenant1.Example.com",
+			hosts:   []string{"tenant2.example.com", "tenant1.example.com"},
+			success: true,
+		},
+		{
+			sni:     "tenant1.example.com",
+			hosts:   []string{"tenant2.example.com"},
+			success: false,
+		},
+		{
+			sni:     "tenant1.example.com",
+			hosts:   []string{"*.example.com"},
+			success: true,
+		},
+		{
+			sni:     "a.tenant1.example.com",
+			hosts:   []string{"*.example.com"},
+			success: false,
+		},
+		{
+			sni:     "example.com",
+			hosts:   []string{"*.example.com"},
+			success: false,
+		},
+		{
+			sni:     "",
+			hosts:   []string{"tenant1.example.com"},
+			success: false,
+		},
+	}
+
+	lg, _ := logger.CreateLoggerForTest(t)
+	for i, test := range tests {
+		g, err := NewGroup(test.hosts, nopBpCreator, MatchSNI, lg)
+		require.NoError(t, err)
+		require.Equal(t, test.success, g.Match(ClientInfo{SNI: test.sni}), "case %d", i)
+	}
+
+	b := &backendWrapper{}
+	b.mu.BackendHealth.Labels = map[string]string{config.SNILabelName: "tenant1.example.com, *.example.org", config.CidrLabelName: "1.1.1.1/32"}
+	require.Equal(t, []string{"tenant1.example.com", "*.example.org"}, b.GroupValues(MatchSNI))
+	require.Equal(t, []string{"1.1.1.1/32"}, b.GroupValues(MatchClientCIDR))
+	require.Nil(t, b.GroupValues(MatchAll))
+}
+
 func TestRefreshCidr(t *testing.T) {
 	tests := []struct {
 		cidrs1    []string
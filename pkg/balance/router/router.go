@@ -78,6 +78,8 @@ type BackendInst interface {
 	Healthy() bool
 	Local() bool
 	Keyspace() string
+	// Label returns the value of the label. It returns empty if the label doesn't exist.
+	Label(name string) string
 }
 
 // backendWrapper contains the connections on the backend.
@@ -152,6 +154,13 @@ func (b *backendWrapper) Local() bool {
 	return local
 }
 
+func (b *backendWrapper) RTT() time.Duration {
+	b.mu.RLock()
+	rtt := b.mu.RTT
+	b.mu.RUnlock()
+	return rtt
+}
+
 func (b *backendWrapper) GetBackendInfo() observer.BackendInfo {
 	b.mu.RLock()
 	info := b.mu.BackendInfo
@@ -176,25 +185,51 @@ func (b *backendWrapper) Keyspace() string {
 	return labels[config.KeyspaceLabelName]
 }
 
+func (b *backendWrapper) Label(name string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.mu.BackendHealth.Labels[name]
+}
+
 func (b *backendWrapper) Cidr() []string {
+	return b.labelValues(config.CidrLabelName)
+}
+
+func (b *backendWrapper) SNIs() []string {
+	return b.labelValues(config.SNILabelName)
+}
+
+// GroupValues returns the values that the group of this backend is matched by.
+func (b *backendWrapper) GroupValues(matchType MatchType) []string {
+	switch matchType {
+	case MatchClientCIDR, MatchProxyCIDR:
+		return b.Cidr()
+	case MatchSNI:
+		return b.SNIs()
+	}
+	return nil
+}
+
+// labelValues splits the comma-separated label value.
+func (b *backendWrapper) labelValues(name string) []string {
 	labels := b.getHealth().Labels
 	if len(labels) == 0 {
 		return nil
 	}
-	cidr := labels[config.CidrLabelName]
-	if len(cidr) == 0 {
+	label := labels[name]
+	if len(label) == 0 {
 		return nil
 	}
-	cidrs := strings.Split(cidr, ",")
-	for i := len(cidrs) - 1; i >= 0; i-- {
-		cidr = strings.TrimSpace(cidrs[i])
-		if len(cidr) == 0 {
-			cidrs = append(cidrs[:i], cidrs[i+1:]...)
+	values := strings.Split(label, ",")
+	for i := len(values) - 1; i >= 0; i-- {
+		value := strings.TrimSpace(values[i])
+		if len(value) == 0 {
+			values = append(values[:i], values[i+1:]...)
 		} else {
-			cidrs[i] = cidr
+			values[i] = value
 		}
 	}
-	return cidrs
+	return values
 }
 
 func (b *backendWrapper) String() string {
@@ -74,6 +74,8 @@ func (r *ScoreBasedRouter) Init(ctx context.Context, ob observer.BackendObserver
 		r.matchType = MatchClientCIDR
 	case config.MatchProxyCIDRStr:
 		r.matchType = MatchProxyCIDR
+	case config.MatchSNIStr:
+		r.matchType = MatchSNI
 	case "":
 	default:
 		r.logger.Error("unsupported routing rule, use the default rule", zap.String("rule", cfg.Balance.RoutingRule))
@@ -143,6 +145,14 @@ func (router *ScoreBasedRouter) HealthyBackendCount() int {
 
 // called in the lock
 func (router *ScoreBasedRouter) routeToGroup(clientInfo ClientInfo) *Group {
+	if len(clientInfo.Group) > 0 && router.matchType != MatchAll {
+		for _, group := range router.groups {
+			if group.Intersect([]string{clientInfo.Group}) {
+				return group
+			}
+		}
+		return nil
+	}
 	// TODO: binary search
 	for _, group := range router.groups {
 		if group.Match(clientInfo) {
@@ -259,19 +269,19 @@ func (router *ScoreBasedRouter) updateGroups() {
 				router.groups = append(router.groups, group)
 			}
 			group = router.groups[0]
-		case MatchClientCIDR, MatchProxyCIDR:
-			cidrs := backend.Cidr()
-			if len(cidrs) == 0 {
+		case MatchClientCIDR, MatchProxyCIDR, MatchSNI:
+			values := backend.GroupValues(router.matchType)
+			if len(values) == 0 {
 				break
 			}
 			for _, g := range router.groups {
-				if g.Intersect(cidrs) {
+				if g.Intersect(values) {
 					group = g
 					break
 				}
 			}
 			if group == nil {
-				g, err := NewGroup(cidrs, router.bpCreator, router.matchType, router.logger)
+				g, err := NewGroup(values, router.bpCreator, router.matchType, router.logger)
 				if err == nil {
 					group = g
 					router.groups = append(router.groups, group)
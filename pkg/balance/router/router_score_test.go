@@ -7,6 +7,7 @@ import (
 	"context"
 	"math"
 	"math/rand"
+	"net"
 	"reflect"
 	"strconv"
 	"testing"
@@ -1109,3 +1110,54 @@ func TestGroupBackends(t *testing.T) {
 		}, 3*time.Second, 10*time.Millisecond, "test %d", i)
 	}
 }
+
+func TestRouteToBoundGroup(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	router := NewScoreBasedRouter(lg)
+	cfg := &config.Config{
+		Balance: config.Balance{
+			RoutingRule: config.MatchClientCIDRStr,
+		},
+	}
+	bo := newMockBackendObserver()
+	router.Init(context.Background(), bo, func(_ *zap.Logger) policy.BalancePolicy {
+		p := &mockBalancePolicy{
+			backendToRoute: func(backends []policy.BackendCtx) policy.BackendCtx {
+				return backends[0]
+			},
+		}
+		p.Init(cfg)
+		return p
+	}, newMockConfigGetter(cfg), make(chan *config.Config))
+	t.Cleanup(bo.Close)
+	t.Cleanup(router.Close)
+
+	bo.addBackend("0", map[string]string{"cidr": "1.1.1.0/24"})
+	bo.addBackend("1", map[string]string{"cidr": "1.1.2.0/24"})
+	bo.notify(nil)
+	require.Eventually(t, func() bool {
+		router.Lock()
+		defer router.Unlock()
+		return len(router.groups) == 2
+	}, 3*time.Second, 10*time.Millisecond)
+
+	clientAddr := &net.TCPAddr{IP: net.IPv4(1, 1, 1, 1), Port: 3000}
+	tests := []struct {
+		group string
+		addr  string
+	}{
+		{group: "", addr: "0"},
+		{group: "1.1.2.0/24", addr: "1"},
+		{group: "1.1.3.0/24", addr: ""},
+	}
+	for i, test := range tests {
+		selector := router.GetBackendSelector(ClientInfo{ClientAddr: clientAddr, Group: test.group})
+		backend, err := selector.Next()
+		if len(test.addr) == 0 {
+			require.ErrorIs(t, err, ErrNoBackend, "test %d", i)
+			continue
+		}
+		require.NoError(t, err, "test %d", i)
+		require.Equal(t, test.addr, backend.Addr(), "test %d", i)
+	}
+}
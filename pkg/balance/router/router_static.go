@@ -116,3 +116,8 @@ func (b *StaticBackend) Keyspace() string {
 func (b *StaticBackend) SetKeyspace(k string) {
 	b.keyspace = k
 }
+
+// Label returns empty because the static backends have no labels.
+func (b *StaticBackend) Label(string) string {
+	return ""
+}
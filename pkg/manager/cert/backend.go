@@ -0,0 +1,151 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cert
+
+import (
+	"crypto/tls"
+	"reflect"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"go.uber.org/zap"
+)
+
+// labelCerts are the certs that override sql-tls for the backends with the labels.
+type labelCerts struct {
+	rules []config.BackendTLS
+	certs []*overrideCert
+}
+
+func labelCertName(rule config.BackendTLS) string {
+	return "sql-tls:label:" + rule.Name()
+}
+
+func namespaceCertName(ns string) string {
+	return "sql-tls:namespace:" + ns
+}
+
+func (cm *CertManager) newOverrideCert(server bool, name string, cfg config.TLSConfig) *overrideCert {
+	oc := &overrideCert{
+		name: name,
+		cfg:  cfg,
+		cert: cm.newCert(server, name),
+	}
+	oc.cert.SetConfig(cfg)
+	return oc
+}
+
+// load returns the TLS config of the backend override. The override always enables TLS, so nil means it fails to load.
+func (oc *overrideCert) load() (*tls.Config, error) {
+	if tlsConfig := oc.tlsConfig.Load(); tlsConfig != nil {
+		return tlsConfig, nil
+	}
+	return nil, errors.Errorf("failed to load the TLS config %s", oc.name)
+}
+
+func (cm *CertManager) reloadOverride(oc *overrideCert) error {
+	tlsConfig, err := oc.cert.Reload(cm.logger.With(zap.String("name", oc.name)))
+	if err != nil {
+		return err
+	}
+	oc.tlsConfig.Store(tlsConfig)
+	cm.updateStatus(oc.name, oc.cert, tlsConfig)
+	return nil
+}
+
+// overrideCerts returns all the certs that override the default ones.
+func (cm *CertManager) overrideCerts() []*overrideCert {
+	certs := make([]*overrideCert, 0, len(cm.listenerSQLTLS))
+	for _, lc := range cm.listenerSQLTLS {
+		certs = append(certs, lc)
+	}
+	if lc := cm.labelSQLTLS.Load(); lc != nil {
+		certs = append(certs, lc.certs...)
+	}
+	cm.nsMu.Lock()
+	for _, nc := range cm.nsSQLTLS {
+		certs = append(certs, nc)
+	}
+	cm.nsMu.Unlock()
+	return certs
+}
+
+// setBackendTLS rebuilds the label overrides if they change. The new certs are loaded before they are used.
+func (cm *CertManager) setBackendTLS(rules []config.BackendTLS) {
+	cur := cm.labelSQLTLS.Load()
+	if cur != nil && reflect.DeepEqual(cur.rules, rules) {
+		return
+	}
+	lc := &labelCerts{rules: rules, certs: make([]*overrideCert, 0, len(rules))}
+	for _, rule := range rules {
+		oc := cm.newOverrideCert(false, labelCertName(rule), rule.TLS)
+		if err := cm.reloadOverride(oc); err != nil {
+			cm.logger.Error("failed to load backend tls", zap.String("name", oc.name), zap.Error(err))
+		}
+		lc.certs = append(lc.certs, oc)
+	}
+	cm.labelSQLTLS.Store(lc)
+	if cur == nil {
+		return
+	}
+	for _, oc := range cur.certs {
+		if !lc.contains(oc.name) {
+			cm.removeStatus(oc.name)
+		}
+	}
+}
+
+func (lc *labelCerts) contains(name string) bool {
+	for _, oc := range lc.certs {
+		if oc.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// BackendSQLTLS returns the TLS config that overrides sql-tls for the backend. It returns nil if it's not overridden.
+// The label overrides in security.backend-tls take precedence over the namespace override nsTLS.
+// label returns the label value of the backend.
+func (cm *CertManager) BackendSQLTLS(ns string, nsTLS *config.TLSConfig, label func(name string) string) (*tls.Config, error) {
+	if lc := cm.labelSQLTLS.Load(); lc != nil {
+		for i, rule := range lc.rules {
+			if label(rule.LabelName) == rule.LabelValue {
+				return lc.certs[i].load()
+			}
+		}
+	}
+	if nsTLS == nil {
+		return nil, nil
+	}
+
+	cm.nsMu.Lock()
+	defer cm.nsMu.Unlock()
+	oc, ok := cm.nsSQLTLS[ns]
+	// The namespace may be updated online.
+	if !ok || !reflect.DeepEqual(oc.cfg, *nsTLS) {
+		if ok {
+			cm.removeStatus(oc.name)
+		}
+		oc = cm.newOverrideCert(false, namespaceCertName(ns), *nsTLS)
+		err := cm.reloadOverride(oc)
+		// If it fails, it will be reloaded again in the next round.
+		cm.nsSQLTLS[ns] = oc
+		if err != nil {
+			return nil, err
+		}
+	}
+	return oc.load()
+}
+
+// RemoveNamespaceTLS drops the TLS config of the namespace after the namespace is deleted or its backend
+// security is cleared, so that it's neither reloaded nor reported anymore.
+func (cm *CertManager) RemoveNamespaceTLS(ns string) {
+	cm.nsMu.Lock()
+	defer cm.nsMu.Unlock()
+	if oc, ok := cm.nsSQLTLS[ns]; ok {
+		delete(cm.nsSQLTLS, ns)
+		cm.removeStatus(oc.name)
+	}
+}
@@ -6,6 +6,9 @@ package cert
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,11 +22,12 @@ import (
 
 const (
 	defaultRetryInterval = 1 * time.Hour
+	// minRenewInterval avoids reloading too frequently if renewing the auto certs keeps failing.
+	minRenewInterval = time.Second
 )
 
 // CertManager reloads certs and offers interfaces for fetching TLS configs.
-// Currently, all the namespaces share the same certs but there might be per-namespace
-// certs in the future.
+// The sql-tls can be overridden for some backends by labels or namespaces.
 type CertManager struct {
 	serverSQLTLS        *security.CertInfo // client -> proxy
 	serverSQLTLSConfig  atomic.Pointer[tls.Config]
@@ -33,6 +37,19 @@ type CertManager struct {
 	clusterTLSConfig    atomic.Pointer[tls.Config]
 	sqlTLS              *security.CertInfo // proxy -> tidb sql port
 	sqlTLSConfig        atomic.Pointer[tls.Config]
+	// listenerSQLTLS overrides serverSQLTLS for some listeners. It's keyed by the listener address
+	// and only built in Init because the listeners can't be changed online.
+	listenerSQLTLS map[string]*overrideCert
+	// labelSQLTLS overrides sqlTLS for the backends with some labels. It's rebuilt once security.backend-tls changes.
+	labelSQLTLS atomic.Pointer[labelCerts]
+	// nsSQLTLS overrides sqlTLS for the backends of some namespaces. It's keyed by the namespace name
+	// and built once the namespace is used.
+	nsMu     sync.Mutex
+	nsSQLTLS map[string]*overrideCert
+
+	// status is the expiration status of the certs, keyed by the cert name.
+	statusMu sync.Mutex
+	status   map[string]CertStatus
 
 	cancel        context.CancelFunc
 	wg            waitgroup.WaitGroup
@@ -40,9 +57,28 @@ type CertManager struct {
 	logger        *zap.Logger
 }
 
+// overrideCert overrides a default cert, such as the server-tls of a listener or the sql-tls of some backends.
+type overrideCert struct {
+	name      string
+	cfg       config.TLSConfig
+	cert      *security.CertInfo
+	tlsConfig atomic.Pointer[tls.Config]
+}
+
+// CertStatus is the expiration status of a local certificate.
+type CertStatus struct {
+	Name     string    `json:"name"`
+	NotAfter time.Time `json:"not-after"`
+	// NextRenewal is only set for the auto certs.
+	NextRenewal *time.Time `json:"next-renewal,omitempty"`
+}
+
 // NewCertManager creates a new CertManager.
 func NewCertManager() *CertManager {
-	cm := &CertManager{}
+	cm := &CertManager{
+		status:   make(map[string]CertStatus),
+		nsSQLTLS: make(map[string]*overrideCert),
+	}
 	cm.SetRetryInterval(defaultRetryInterval)
 	return cm
 }
@@ -51,10 +87,16 @@ func NewCertManager() *CertManager {
 // cfgch can be set to nil for the serverless tier because it has no config manager.
 func (cm *CertManager) Init(cfg *config.Config, logger *zap.Logger, cfgch <-chan *config.Config) error {
 	cm.logger = logger
-	cm.serverSQLTLS = security.NewCert(true)
-	cm.serverHTTPTLS = security.NewCert(true)
-	cm.clusterTLS = security.NewCert(false)
-	cm.sqlTLS = security.NewCert(false)
+	cm.serverSQLTLS = cm.newCert(true, "server-tls")
+	cm.serverHTTPTLS = cm.newCert(true, "server-http-tls")
+	cm.clusterTLS = cm.newCert(false, "cluster-tls")
+	cm.sqlTLS = cm.newCert(false, "sql-tls")
+	cm.listenerSQLTLS = make(map[string]*overrideCert)
+	for _, listener := range cfg.Proxy.Listeners {
+		if listener.HasServerTLS() {
+			cm.listenerSQLTLS[listener.Addr] = cm.newOverrideCert(true, listenerCertName(listener.Addr), listener.ServerTLS)
+		}
+	}
 	cm.setConfig(cfg)
 	if err := cm.reload(); err != nil {
 		return err
@@ -66,11 +108,32 @@ func (cm *CertManager) Init(cfg *config.Config, logger *zap.Logger, cfgch <-chan
 	return nil
 }
 
+// newCert creates a CertInfo that logs and counts the revoked peer certificates.
+func (cm *CertManager) newCert(server bool, name string) *security.CertInfo {
+	ci := security.NewCert(server)
+	ci.SetOnRevoked(func(cert *x509.Certificate) {
+		metrics.RevokedCertCounter.WithLabelValues(name).Inc()
+		cm.logger.Warn("reject revoked certificate", zap.String("tls", name), zap.Stringer("subject", cert.Subject),
+			zap.Stringer("serial", cert.SerialNumber))
+	})
+	return ci
+}
+
+func listenerCertName(addr string) string {
+	return "server-tls:" + addr
+}
+
 func (cm *CertManager) setConfig(cfg *config.Config) {
 	cm.serverSQLTLS.SetConfig(cfg.Security.ServerSQLTLS)
 	cm.serverHTTPTLS.SetConfig(cfg.Security.ServerHTTPTLS)
 	cm.clusterTLS.SetConfig(cfg.Security.ClusterTLS)
 	cm.sqlTLS.SetConfig(cfg.Security.SQLTLS)
+	for _, listener := range cfg.Proxy.Listeners {
+		if lc, ok := cm.listenerSQLTLS[listener.Addr]; ok {
+			lc.cert.SetConfig(listener.ServerTLS)
+		}
+	}
+	cm.setBackendTLS(cfg.Security.BackendTLS)
 }
 
 func (cm *CertManager) SetRetryInterval(interval time.Duration) {
@@ -81,6 +144,15 @@ func (cm *CertManager) ServerSQLTLS() *tls.Config {
 	return cm.serverSQLTLSConfig.Load()
 }
 
+// ListenerSQLTLS returns the server TLS config of the listener. It falls back to ServerSQLTLS
+// if the listener doesn't override it.
+func (cm *CertManager) ListenerSQLTLS(addr string) *tls.Config {
+	if lc, ok := cm.listenerSQLTLS[addr]; ok {
+		return lc.tlsConfig.Load()
+	}
+	return cm.ServerSQLTLS()
+}
+
 func (cm *CertManager) ServerHTTPTLS() *tls.Config {
 	return cm.serverHTTPTLSConfig.Load()
 }
@@ -113,7 +185,7 @@ func (cm *CertManager) reloadLoop(ctx context.Context, cfgch <-chan *config.Conf
 				}
 				cm.setConfig(cfg)
 				_ = cm.reload()
-			case <-time.After(time.Duration(cm.retryInterval.Load())):
+			case <-time.After(cm.nextReloadInterval()):
 				_ = cm.reload()
 			}
 		}
@@ -122,26 +194,36 @@ func (cm *CertManager) reloadLoop(ctx context.Context, cfgch <-chan *config.Conf
 
 // If any error happens, we still continue and use the old cert.
 func (cm *CertManager) reload() error {
-	errs := make([]error, 0, 4)
+	overrides := cm.overrideCerts()
+	errs := make([]error, 0, 4+len(overrides))
 	if tlsConfig, err := cm.serverSQLTLS.Reload(cm.logger); err != nil {
 		errs = append(errs, err)
 	} else {
 		cm.serverSQLTLSConfig.Store(tlsConfig)
+		cm.updateStatus("server-tls", cm.serverSQLTLS, tlsConfig)
 	}
 	if tlsConfig, err := cm.serverHTTPTLS.Reload(cm.logger); err != nil {
 		errs = append(errs, err)
 	} else {
 		cm.serverHTTPTLSConfig.Store(tlsConfig)
+		cm.updateStatus("server-http-tls", cm.serverHTTPTLS, tlsConfig)
 	}
 	if tlsConfig, err := cm.clusterTLS.Reload(cm.logger); err != nil {
 		errs = append(errs, err)
 	} else {
 		cm.clusterTLSConfig.Store(tlsConfig)
+		cm.updateStatus("cluster-tls", cm.clusterTLS, tlsConfig)
 	}
 	if tlsConfig, err := cm.sqlTLS.Reload(cm.logger); err != nil {
 		errs = append(errs, err)
 	} else {
 		cm.sqlTLSConfig.Store(tlsConfig)
+		cm.updateStatus("sql-tls", cm.sqlTLS, tlsConfig)
+	}
+	for _, oc := range overrides {
+		if err := cm.reloadOverride(oc); err != nil {
+			errs = append(errs, err)
+		}
 	}
 	var err error
 	if len(errs) > 0 {
@@ -152,6 +234,67 @@ func (cm *CertManager) reload() error {
 	return err
 }
 
+// nextReloadInterval returns the retry interval, or a shorter one if any auto cert needs to be renewed or any
+// session ticket key needs to be rotated earlier.
+// Otherwise, the auto certs may expire when the lifetime is shorter than the retry interval.
+func (cm *CertManager) nextReloadInterval() time.Duration {
+	interval := time.Duration(cm.retryInterval.Load())
+	certs := []*security.CertInfo{cm.serverSQLTLS, cm.serverHTTPTLS}
+	for _, lc := range cm.listenerSQLTLS {
+		certs = append(certs, lc.cert)
+	}
+	for _, ci := range certs {
+		for _, next := range []time.Time{ci.NextRenewal(), ci.NextTicketRotation()} {
+			if next.IsZero() {
+				continue
+			}
+			if d := max(time.Until(next), minRenewInterval); d < interval {
+				interval = d
+			}
+		}
+	}
+	return interval
+}
+
+// updateStatus records the expiration time of the local cert. The cert is not recorded if TLS is disabled or
+// the config has no local cert.
+func (cm *CertManager) updateStatus(name string, ci *security.CertInfo, tlsConfig *tls.Config) {
+	expire := ci.ExpireTime()
+	if tlsConfig == nil || expire.IsZero() {
+		cm.removeStatus(name)
+		return
+	}
+	status := CertStatus{Name: name, NotAfter: expire}
+	if renew := ci.NextRenewal(); !renew.IsZero() {
+		status.NextRenewal = &renew
+	}
+	cm.statusMu.Lock()
+	cm.status[name] = status
+	cm.statusMu.Unlock()
+	metrics.CertExpireTimeGauge.WithLabelValues(name).Set(float64(expire.Unix()))
+}
+
+func (cm *CertManager) removeStatus(name string) {
+	cm.statusMu.Lock()
+	delete(cm.status, name)
+	cm.statusMu.Unlock()
+	metrics.CertExpireTimeGauge.DeleteLabelValues(name)
+}
+
+// CertStatus returns the expiration status of all the local certs, sorted by the name.
+func (cm *CertManager) CertStatus() []CertStatus {
+	cm.statusMu.Lock()
+	status := make([]CertStatus, 0, len(cm.status))
+	for _, s := range cm.status {
+		status = append(status, s)
+	}
+	cm.statusMu.Unlock()
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Name < status[j].Name
+	})
+	return status
+}
+
 func (cm *CertManager) Close() {
 	if cm.cancel != nil {
 		cm.cancel()
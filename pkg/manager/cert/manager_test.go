@@ -18,6 +18,7 @@ import (
 	"github.com/pingcap/tiproxy/lib/util/logger"
 	"github.com/pingcap/tiproxy/lib/util/security"
 	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	"github.com/pingcap/tiproxy/pkg/metrics"
 	"github.com/stretchr/testify/require"
 )
 
@@ -113,6 +114,23 @@ func TestInit(t *testing.T) {
 				require.Nil(t, cm.ServerSQLTLS())
 			},
 		},
+		{
+			name: "listener config",
+			cfg: config.Config{
+				Proxy: config.ProxyServer{
+					Listeners: []config.ProxyListener{
+						{Addr: "0.0.0.0:6001", ServerTLS: config.TLSConfig{AutoCerts: true}},
+						{Addr: "0.0.0.0:6002"},
+					},
+				},
+			},
+			check: func(t *testing.T, cm *CertManager) {
+				require.Nil(t, cm.ServerSQLTLS())
+				require.NotNil(t, cm.ListenerSQLTLS("0.0.0.0:6001"))
+				require.Nil(t, cm.ListenerSQLTLS("0.0.0.0:6002"))
+				require.Nil(t, cm.ListenerSQLTLS("0.0.0.0:6000"))
+			},
+		},
 		{
 			name: "invalid config",
 			cfg: config.Config{
@@ -426,3 +444,140 @@ func TestWatchConfig(t *testing.T) {
 		}, time.Second, 10*time.Millisecond)
 	}
 }
+
+func TestRenewAutoCerts(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	cfg := &config.Config{
+		Security: config.Security{
+			ServerSQLTLS: config.TLSConfig{
+				AutoCerts:          true,
+				RSAKeySize:         1024,
+				AutoExpireDuration: "3s",
+				AutoRenewRatio:     0.5,
+			},
+		},
+	}
+	certMgr := NewCertManager()
+	require.NoError(t, certMgr.Init(cfg, lg, nil))
+	t.Cleanup(certMgr.Close)
+
+	status := certMgr.CertStatus()
+	require.Len(t, status, 1)
+	require.Equal(t, "server-tls", status[0].Name)
+	require.NotNil(t, status[0].NextRenewal)
+	require.True(t, status[0].NextRenewal.Before(status[0].NotAfter))
+	expire, err := metrics.ReadGauge(metrics.CertExpireTimeGauge.WithLabelValues("server-tls"))
+	require.NoError(t, err)
+	require.EqualValues(t, status[0].NotAfter.Unix(), expire)
+
+	// The auto cert is renewed before it expires although the retry interval is much longer.
+	firstExpire := status[0].NotAfter
+	require.Eventually(t, func() bool {
+		status := certMgr.CertStatus()
+		require.Len(t, status, 1)
+		return status[0].NotAfter.After(firstExpire)
+	}, 5*time.Second, 100*time.Millisecond)
+	require.True(t, time.Now().Before(firstExpire))
+}
+
+func TestBackendSQLTLS(t *testing.T) {
+	tmpdir := t.TempDir()
+	lg, _ := logger.CreateLoggerForTest(t)
+	caPath1 := filepath.Join(tmpdir, "c1", "ca")
+	keyPath1 := filepath.Join(tmpdir, "c1", "key")
+	certPath1 := filepath.Join(tmpdir, "c1", "cert")
+	caPath2 := filepath.Join(tmpdir, "c2", "ca")
+	keyPath2 := filepath.Join(tmpdir, "c2", "key")
+	certPath2 := filepath.Join(tmpdir, "c2", "cert")
+	require.NoError(t, security.CreateTLSCertificates(lg, certPath1, keyPath1, caPath1, 0, security.DefaultCertExpiration, ""))
+	require.NoError(t, security.CreateTLSCertificates(lg, certPath2, keyPath2, caPath2, 0, security.DefaultCertExpiration, ""))
+	cert1, err := tls.LoadX509KeyPair(certPath1, keyPath1)
+	require.NoError(t, err)
+	cert2, err := tls.LoadX509KeyPair(certPath2, keyPath2)
+	require.NoError(t, err)
+	stls1 := &tls.Config{Certificates: []tls.Certificate{cert1}}
+	stls2 := &tls.Config{Certificates: []tls.Certificate{cert2}}
+
+	cfg := &config.Config{
+		Security: config.Security{
+			BackendTLS: []config.BackendTLS{
+				{
+					LabelName:  "zone",
+					LabelValue: "east",
+					TLS:        config.TLSConfig{CA: caPath2, Cert: certPath2, Key: keyPath2},
+				},
+			},
+		},
+	}
+	certMgr := NewCertManager()
+	require.NoError(t, certMgr.Init(cfg, lg, nil))
+	t.Cleanup(certMgr.Close)
+	labels := func(value string) func(string) string {
+		return func(name string) string {
+			if name == "zone" {
+				return value
+			}
+			return ""
+		}
+	}
+
+	// No override.
+	ctls, err := certMgr.BackendSQLTLS("ns", nil, labels("west"))
+	require.NoError(t, err)
+	require.Nil(t, ctls)
+
+	// The label override takes precedence over the namespace override.
+	nsTLS := &config.TLSConfig{CA: caPath1}
+	ctls, err = certMgr.BackendSQLTLS("ns", nsTLS, labels("east"))
+	require.NoError(t, err)
+	clientErr, serverErr := connectWithTLS(ctls, stls2)
+	require.NoError(t, clientErr)
+	require.NoError(t, serverErr)
+	clientErr, _ = connectWithTLS(ctls, stls1)
+	require.Error(t, clientErr)
+
+	// The namespace override.
+	ctls, err = certMgr.BackendSQLTLS("ns", nsTLS, labels("west"))
+	require.NoError(t, err)
+	clientErr, serverErr = connectWithTLS(ctls, stls1)
+	require.NoError(t, clientErr)
+	require.NoError(t, serverErr)
+	status := certMgr.CertStatus()
+	require.Len(t, status, 1)
+	require.Equal(t, "sql-tls:label:zone=east", status[0].Name)
+
+	// The namespace is updated.
+	nsTLS = &config.TLSConfig{CA: caPath2, Cert: certPath1, Key: keyPath1}
+	ctls, err = certMgr.BackendSQLTLS("ns", nsTLS, labels("west"))
+	require.NoError(t, err)
+	clientErr, serverErr = connectWithTLS(ctls, stls2)
+	require.NoError(t, clientErr)
+	require.NoError(t, serverErr)
+	status = certMgr.CertStatus()
+	require.Len(t, status, 2)
+	require.Equal(t, "sql-tls:namespace:ns", status[1].Name)
+
+	// The namespace is removed.
+	certMgr.RemoveNamespaceTLS("ns")
+	status = certMgr.CertStatus()
+	require.Len(t, status, 1)
+	require.NoError(t, certMgr.reload())
+	require.Len(t, certMgr.CertStatus(), 1)
+	ctls, err = certMgr.BackendSQLTLS("ns", nsTLS, labels("west"))
+	require.NoError(t, err)
+	require.NotNil(t, ctls)
+	require.Len(t, certMgr.CertStatus(), 2)
+
+	// Failed to load.
+	_, err = certMgr.BackendSQLTLS("ns", &config.TLSConfig{CA: filepath.Join(tmpdir, "none")}, labels("west"))
+	require.Error(t, err)
+	status = certMgr.CertStatus()
+	require.Len(t, status, 1)
+
+	// The label override is removed.
+	certMgr.setBackendTLS(nil)
+	ctls, err = certMgr.BackendSQLTLS("ns", nil, labels("east"))
+	require.NoError(t, err)
+	require.Nil(t, ctls)
+	require.Empty(t, certMgr.CertStatus())
+}
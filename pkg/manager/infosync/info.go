@@ -83,7 +83,7 @@ type TopologyInfo struct {
 	Version        string `json:"version"`
 	GitHash        string `json:"git_hash"`
 	IP             string `json:"ip"`
-	Port           string `json:"port"`
+	Port           string `json:"port"` // empty if the SQL port only listens on Unix sockets
 	StatusPort     string `json:"status_port"`
 	DeployPath     string `json:"deploy_path"`
 	StartTimestamp int64  `json:"start_timestamp"`
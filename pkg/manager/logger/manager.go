@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 
 	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
 	lg "github.com/pingcap/tiproxy/lib/util/logger"
 	"github.com/pingcap/tiproxy/pkg/util/waitgroup"
 	"go.uber.org/zap"
@@ -20,8 +21,11 @@ type LoggerManager struct {
 	logger *zap.Logger
 	syncer *lg.AtomicWriteSyncer
 	level  zap.AtomicLevel
-	cancel context.CancelFunc
-	wg     waitgroup.WaitGroup
+	// slowLogger writes the slow log to a dedicated output.
+	slowLogger *zap.Logger
+	slowSyncer *lg.AtomicWriteSyncer
+	cancel     context.CancelFunc
+	wg         waitgroup.WaitGroup
 }
 
 // NewLoggerManager creates a new LoggerManager.
@@ -37,6 +41,10 @@ func NewLoggerManager(cfg *config.Log) (*LoggerManager, *zap.Logger, error) {
 	}
 	lm.syncer = syncer
 	lm.level = level
+	if lm.slowLogger, lm.slowSyncer, err = lg.BuildSlowLogger(cfg); err != nil {
+		_ = syncer.Close()
+		return nil, nil, err
+	}
 	mainLogger = mainLogger.Named("main")
 	lm.logger = mainLogger.Named("lgmgr")
 	return lm, mainLogger, nil
@@ -52,6 +60,11 @@ func (lm *LoggerManager) Init(cfgch <-chan *config.Config) {
 	}, nil, lm.logger)
 }
 
+// SlowLogger returns the logger of the slow log.
+func (lm *LoggerManager) SlowLogger() *zap.Logger {
+	return lm.slowLogger
+}
+
 func (lm *LoggerManager) SetLoggerLevel(l zapcore.Level) {
 	lm.level.SetLevel(l)
 }
@@ -69,8 +82,11 @@ func (lm *LoggerManager) watchCfg(ctx context.Context, cfgch <-chan *config.Conf
 
 			cfg := &acfg.Log.LogOnline
 			err := lm.updateLoggerCfg(cfg)
+			if err == nil {
+				err = lm.slowSyncer.RebuildFile(&acfg.Log.SlowLog.LogFile)
+			}
 			if err != nil {
-				bytes, merr := json.Marshal(cfg)
+				bytes, merr := json.Marshal(acfg.Log)
 				lm.logger.Error("update logger configuration failed",
 					zap.NamedError("update error", err),
 					zap.String("cfg", string(bytes)),
@@ -101,5 +117,5 @@ func (lm *LoggerManager) Close() error {
 		lm.cancel()
 	}
 	lm.wg.Wait()
-	return lm.syncer.Close()
+	return errors.Collect(errors.New("closing logger manager failed"), lm.syncer.Close(), lm.slowSyncer.Close())
 }
@@ -10,10 +10,14 @@ import (
 	"context"
 	"fmt"
 	"maps"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/pingcap/tiproxy/pkg/balance/factor"
 	"github.com/pingcap/tiproxy/pkg/balance/metricsreader"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
@@ -24,6 +28,11 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// topologySnapshotDir is the directory under the workdir to store the backend topology of each namespace.
+	topologySnapshotDir = "topology"
+)
+
 type NamespaceManager interface {
 	Init(logger *zap.Logger, nscs []*config.Namespace, tpFetcher observer.TopologyFetcher,
 		promFetcher metricsreader.PromInfoFetcher, httpCli *http.Client, cfgMgr *mconfig.ConfigManager,
@@ -31,7 +40,10 @@ type NamespaceManager interface {
 	CommitNamespaces(nss []*config.Namespace, nssDelete []bool) error
 	GetNamespace(nm string) (*Namespace, bool)
 	GetNamespaceByUser(user string) (*Namespace, bool)
+	GetNamespaceByVPCEndpoint(id string) (*Namespace, bool)
+	GetNamespaceBySNI(host string) (*Namespace, bool)
 	RedirectConnections() []error
+	BackendEventHub() *observer.EventHub
 	Ready() bool
 	Close() error
 }
@@ -45,10 +57,21 @@ type namespaceManager struct {
 	httpCli       *http.Client
 	logger        *zap.Logger
 	cfgMgr        *mconfig.ConfigManager
+	eventHub      *observer.EventHub
+	// removeBackendTLS drops the cached backend TLS config of a namespace once it's unused.
+	removeBackendTLS func(ns string)
 }
 
 func NewNamespaceManager() *namespaceManager {
-	return &namespaceManager{}
+	return &namespaceManager{
+		eventHub: observer.NewEventHub(),
+	}
+}
+
+// SetBackendTLSRemover sets the function that drops the cached backend TLS config of a namespace.
+// It's called when the namespace is deleted or its backend security is cleared.
+func (mgr *namespaceManager) SetBackendTLSRemover(remove func(ns string)) {
+	mgr.removeBackendTLS = remove
 }
 
 func (mgr *namespaceManager) buildNamespace(cfg *config.Namespace) (*Namespace, error) {
@@ -58,7 +81,16 @@ func (mgr *namespaceManager) buildNamespace(cfg *config.Namespace) (*Namespace,
 	var fetcher observer.BackendFetcher
 	healthCheckCfg := config.NewDefaultHealthCheckConfig()
 	if mgr.tpFetcher != nil && !reflect.ValueOf(mgr.tpFetcher).IsNil() {
-		fetcher = observer.NewPDFetcher(mgr.tpFetcher, logger.Named("be_fetcher"), healthCheckCfg)
+		pdFetcher := observer.NewPDFetcher(mgr.tpFetcher, logger.Named("be_fetcher"), healthCheckCfg)
+		// Persist the topology so that TiProxy can still route to the backends if PD is unavailable on restart.
+		if workdir := mgr.cfgMgr.GetConfig().Workdir; len(workdir) > 0 {
+			if path, err := topologySnapshotPath(workdir, cfg.Namespace); err != nil {
+				logger.Warn("skip persisting the backend topology", zap.Error(err))
+			} else {
+				pdFetcher.SetSnapshotPath(path)
+			}
+		}
+		fetcher = pdFetcher
 	} else {
 		fetcher = observer.NewStaticFetcher(cfg.Backend.Instances)
 	}
@@ -67,6 +99,7 @@ func (mgr *namespaceManager) buildNamespace(cfg *config.Namespace) (*Namespace,
 	rt := router.NewScoreBasedRouter(logger.Named("router"))
 	hc := observer.NewDefaultHealthCheck(mgr.httpCli, healthCheckCfg, logger.Named("hc"))
 	bo := observer.NewDefaultBackendObserver(logger.Named("observer"), healthCheckCfg, fetcher, hc, mgr.cfgMgr)
+	bo.SetEventHub(cfg.Namespace, mgr.eventHub)
 	bo.Start(context.Background())
 	bpCreator := func(lg *zap.Logger) policy.BalancePolicy {
 		policy := factor.NewFactorBasedBalance(lg, mgr.metricsReader)
@@ -75,14 +108,32 @@ func (mgr *namespaceManager) buildNamespace(cfg *config.Namespace) (*Namespace,
 	}
 	rt.Init(context.Background(), bo, bpCreator, mgr.cfgMgr, mgr.cfgMgr.WatchConfig())
 
+	var backendTLS *config.TLSConfig
+	if cfg.Backend.Security.HasClientTLS() {
+		backendTLS = &cfg.Backend.Security
+	}
 	return &Namespace{
-		name:   cfg.Namespace,
-		user:   cfg.Frontend.User,
-		bo:     bo,
-		router: rt,
+		name:           cfg.Namespace,
+		user:           cfg.Frontend.User,
+		vpcEndpointIDs: cfg.Frontend.VPCEndpointIDs,
+		sniHosts:       cfg.Frontend.SNIHosts,
+		stmtTimeout:    cfg.Frontend.StatementTimeout,
+		loadDataLocal:  cfg.Frontend.LoadDataLocal,
+		backendTLS:     backendTLS,
+		bo:             bo,
+		router:         rt,
 	}, nil
 }
 
+// topologySnapshotPath returns the snapshot file of the namespace. The namespace name is used as the file name,
+// so it must not escape the snapshot directory.
+func topologySnapshotPath(workdir, ns string) (string, error) {
+	if len(ns) == 0 || ns == "." || ns == ".." || strings.ContainsAny(ns, `/\`) || filepath.Base(ns) != ns {
+		return "", errors.Errorf("invalid namespace name %q for a file name", ns)
+	}
+	return filepath.Join(workdir, topologySnapshotDir, ns+".json"), nil
+}
+
 func (mgr *namespaceManager) CommitNamespaces(nss []*config.Namespace, nssDelete []bool) error {
 	nsm := make(map[string]*Namespace)
 	mgr.RLock()
@@ -103,11 +154,31 @@ func (mgr *namespaceManager) CommitNamespaces(nss []*config.Namespace, nssDelete
 	}
 
 	mgr.Lock()
+	oldNsm := mgr.nsm
 	mgr.nsm = nsm
 	mgr.Unlock()
+	if mgr.removeBackendTLS != nil {
+		for _, name := range unusedBackendTLS(oldNsm, nsm) {
+			mgr.removeBackendTLS(name)
+		}
+	}
 	return nil
 }
 
+// unusedBackendTLS returns the namespaces whose backend TLS config is no longer used after the commit.
+func unusedBackendTLS(oldNsm, newNsm map[string]*Namespace) []string {
+	var names []string
+	for name, old := range oldNsm {
+		if old.backendTLS == nil {
+			continue
+		}
+		if ns, ok := newNsm[name]; !ok || ns.backendTLS == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func (mgr *namespaceManager) Init(logger *zap.Logger, nscs []*config.Namespace, tpFetcher observer.TopologyFetcher,
 	promFetcher metricsreader.PromInfoFetcher, httpCli *http.Client, cfgMgr *mconfig.ConfigManager,
 	metricsReader metricsreader.MetricsReader) error {
@@ -142,6 +213,32 @@ func (mgr *namespaceManager) GetNamespaceByUser(user string) (*Namespace, bool)
 	return nil, false
 }
 
+func (mgr *namespaceManager) GetNamespaceByVPCEndpoint(id string) (*Namespace, bool) {
+	mgr.RLock()
+	defer mgr.RUnlock()
+
+	for _, ns := range mgr.nsm {
+		if slices.Contains(ns.VPCEndpointIDs(), id) {
+			return ns, true
+		}
+	}
+	return nil, false
+}
+
+func (mgr *namespaceManager) GetNamespaceBySNI(host string) (*Namespace, bool) {
+	mgr.RLock()
+	defer mgr.RUnlock()
+
+	for _, ns := range mgr.nsm {
+		if slices.ContainsFunc(ns.SNIHosts(), func(h string) bool {
+			return strings.EqualFold(h, host)
+		}) {
+			return ns, true
+		}
+	}
+	return nil, false
+}
+
 func (mgr *namespaceManager) RedirectConnections() []error {
 	mgr.RLock()
 	defer mgr.RUnlock()
@@ -156,6 +253,11 @@ func (mgr *namespaceManager) RedirectConnections() []error {
 	return errs
 }
 
+// BackendEventHub returns the hub that publishes the backend changes of all namespaces.
+func (mgr *namespaceManager) BackendEventHub() *observer.EventHub {
+	return mgr.eventHub
+}
+
 func (mgr *namespaceManager) Ready() bool {
 	mgr.RLock()
 	defer mgr.RUnlock()
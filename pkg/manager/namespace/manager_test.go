@@ -4,8 +4,11 @@
 package namespace
 
 import (
+	"path/filepath"
+	"sort"
 	"testing"
 
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/pkg/balance/router"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -30,3 +33,72 @@ func TestReady(t *testing.T) {
 	ns.router = rt
 	require.True(t, nsMgr.Ready())
 }
+
+func TestGetNamespaceByVPCEndpoint(t *testing.T) {
+	nsMgr := NewNamespaceManager()
+	nsMgr.nsm = map[string]*Namespace{
+		"ns1": {
+			name:           "ns1",
+			vpcEndpointIDs: []string{"vpce-1", "vpce-2"},
+		},
+		"ns2": {
+			name: "ns2",
+		},
+	}
+	ns, ok := nsMgr.GetNamespaceByVPCEndpoint("vpce-2")
+	require.True(t, ok)
+	require.Equal(t, "ns1", ns.Name())
+	_, ok = nsMgr.GetNamespaceByVPCEndpoint("vpce-3")
+	require.False(t, ok)
+}
+
+func TestGetNamespaceBySNI(t *testing.T) {
+	nsMgr := NewNamespaceManager()
+	nsMgr.nsm = map[string]*Namespace{
+		"ns1": {
+			name:     "ns1",
+			sniHosts: []string{"tenant1.example.com"},
+		},
+		"ns2": {
+			name:     "ns2",
+			sniHosts: []string{"tenant2.example.com"},
+		},
+	}
+	ns, ok := nsMgr.GetNamespaceBySNI("Tenant2.Example.com")
+	require.True(t, ok)
+	require.Equal(t, "ns2", ns.Name())
+	_, ok = nsMgr.GetNamespaceBySNI("tenant3.example.com")
+	require.False(t, ok)
+	_, ok = nsMgr.GetNamespaceBySNI("")
+	require.False(t, ok)
+}
+
+func TestTopologySnapshotPath(t *testing.T) {
+	dir := t.TempDir()
+	path, err := topologySnapshotPath(dir, "default")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "topology", "default.json"), path)
+	for _, ns := range []string{"", ".", "..", "../default", "a/b", `a\b`} {
+		_, err = topologySnapshotPath(dir, ns)
+		require.Error(t, err, ns)
+	}
+}
+
+func TestUnusedBackendTLS(t *testing.T) {
+	tlsCfg := &config.TLSConfig{CA: "ca"}
+	oldNsm := map[string]*Namespace{
+		"deleted":   {name: "deleted", backendTLS: tlsCfg},
+		"cleared":   {name: "cleared", backendTLS: tlsCfg},
+		"kept":      {name: "kept", backendTLS: tlsCfg},
+		"plaintext": {name: "plaintext"},
+	}
+	newNsm := map[string]*Namespace{
+		"cleared":   {name: "cleared"},
+		"kept":      {name: "kept", backendTLS: &config.TLSConfig{CA: "ca2"}},
+		"plaintext": {name: "plaintext"},
+	}
+	names := unusedBackendTLS(oldNsm, newNsm)
+	sort.Strings(names)
+	require.Equal(t, []string{"cleared", "deleted"}, names)
+	require.Empty(t, unusedBackendTLS(nil, newNsm))
+}
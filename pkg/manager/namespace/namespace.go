@@ -7,15 +7,23 @@
 package namespace
 
 import (
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
 	"github.com/pingcap/tiproxy/pkg/balance/router"
 )
 
 type Namespace struct {
-	name   string
-	user   string
-	bo     observer.BackendObserver
-	router router.Router
+	name           string
+	user           string
+	vpcEndpointIDs []string
+	sniHosts       []string
+	stmtTimeout    time.Duration
+	loadDataLocal  *config.LoadDataPolicy
+	backendTLS     *config.TLSConfig
+	bo             observer.BackendObserver
+	router         router.Router
 }
 
 func (n *Namespace) Name() string {
@@ -26,6 +34,29 @@ func (n *Namespace) User() string {
 	return n.user
 }
 
+func (n *Namespace) VPCEndpointIDs() []string {
+	return n.vpcEndpointIDs
+}
+
+func (n *Namespace) SNIHosts() []string {
+	return n.sniHosts
+}
+
+// StatementTimeout returns the statement timeout of the namespace. 0 means using the default one.
+func (n *Namespace) StatementTimeout() time.Duration {
+	return n.stmtTimeout
+}
+
+// LoadDataLocal returns the LOAD DATA LOCAL INFILE policy of the namespace. nil means using the default one.
+func (n *Namespace) LoadDataLocal() *config.LoadDataPolicy {
+	return n.loadDataLocal
+}
+
+// BackendTLS returns the TLS config to connect to the backends of the namespace. nil means using sql-tls.
+func (n *Namespace) BackendTLS() *config.TLSConfig {
+	return n.backendTLS
+}
+
 func (n *Namespace) GetRouter() router.Router {
 	return n.router
 }
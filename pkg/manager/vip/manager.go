@@ -69,10 +69,14 @@ func (vm *vipManager) Start(ctx context.Context, etcdCli *clientv3.Client) error
 	vm.delOnRetire.Store(true)
 
 	cfg := vm.cfgGetter.GetConfig()
-	ip, port, _, err := cfg.GetIPPort()
+	ip, port, statusPort, err := cfg.GetIPPort()
 	if err != nil {
 		return err
 	}
+	// The SQL port may only listen on Unix sockets.
+	if len(port) == 0 {
+		port = statusPort
+	}
 
 	id := net.JoinHostPort(ip, port)
 	electionCfg := elect.DefaultElectionConfig(sessionTTL)
@@ -67,4 +67,28 @@ var (
 			Name:      "backend_metric",
 			Help:      "The backend metric.",
 		}, []string{LblBackend, LblMetricName})
+
+	BackendEventDropCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelBackend,
+			Name:      "event_drop",
+			Help:      "Counter of backend events dropped because the subscriber is slow.",
+		})
+
+	PooledConnGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelBackend,
+			Name:      "pooled_conn",
+			Help:      "Number of idle backend connections in the connection pool.",
+		})
+
+	AcquirePooledConnCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelBackend,
+			Name:      "acquire_pooled_conn",
+			Help:      "Counter of acquiring backend connections in the pooling mode.",
+		}, []string{LblType})
 )
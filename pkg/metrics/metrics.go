@@ -103,12 +103,25 @@ func init() {
 		OwnerGauge,
 		ServerEventCounter,
 		ServerErrCounter,
+		RevokedCertCounter,
+		CertExpireTimeGauge,
 		TimeJumpBackCounter,
 		KeepAliveCounter,
 		QueryTotalCounter,
 		QueryDurationHistogram,
 		QueryTimeSinceConnCreationHistogram,
 		ConnLifetimeHistogram,
+		ResultCacheCounter,
+		ResultCacheBytesGauge,
+		FirewallHitCounter,
+		QueryRewriteCounter,
+		StmtTimeoutCounter,
+		LoadDataLocalCounter,
+		LoadDataLocalBytesCounter,
+		LoadDataLocalHeldBytesGauge,
+		RetryReadCounter,
+		SessionFailoverCounter,
+		SessionSnapshotBytesGauge,
 		HandshakeDurationHistogram,
 		BackendStatusGauge,
 		GetBackendHistogram,
@@ -119,6 +132,9 @@ func init() {
 		BackendScoreGauge,
 		HealthCheckCycleGauge,
 		BackendMetricGauge,
+		BackendEventDropCounter,
+		PooledConnGauge,
+		AcquirePooledConnCounter,
 		PendingMigrateGuage,
 		MigrateCounter,
 		MigrateDurationHistogram,
@@ -74,6 +74,22 @@ var (
 			Help:      "Counter of server error.",
 		}, []string{LblType})
 
+	RevokedCertCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelServer,
+			Name:      "revoked_cert_total",
+			Help:      "Counter of rejected revoked peer certificates of each TLS config.",
+		}, []string{LblType})
+
+	CertExpireTimeGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelServer,
+			Name:      "cert_expire_timestamp_seconds",
+			Help:      "Unix timestamp when the certificate of each TLS config expires.",
+		}, []string{LblType})
+
 	TimeJumpBackCounter = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Namespace: ModuleProxy,
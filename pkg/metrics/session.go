@@ -11,6 +11,8 @@ import "github.com/prometheus/client_golang/prometheus"
 // LblCmdType is the label constant.
 const (
 	LblCmdType = "cmd_type"
+	LblRule    = "rule"
+	LblAction  = "action"
 )
 
 var (
@@ -57,4 +59,92 @@ var (
 			Help:      "Bucketed histogram of connection lifetime (s).",
 			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 25), // 1s ~ 38days
 		})
+
+	ResultCacheCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "result_cache",
+			Help:      "Counter of result cache hits and misses.",
+		}, []string{LblType})
+
+	ResultCacheBytesGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "result_cache_bytes",
+			Help:      "Memory usage (bytes) of the result cache.",
+		})
+
+	FirewallHitCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "firewall_hit",
+			Help:      "Counter of statements matched by firewall rules.",
+		}, []string{LblRule, LblAction})
+
+	QueryRewriteCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "query_rewrite",
+			Help:      "Counter of statements matched by query rewrite rules.",
+		}, []string{LblRule, LblType})
+
+	StmtTimeoutCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "stmt_timeout",
+			Help:      "Counter of statements that exceed the statement timeout and are killed.",
+		}, []string{LblType})
+
+	LoadDataLocalCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "load_data_local",
+			Help:      "Counter of LOAD DATA LOCAL INFILE transfers.",
+		}, []string{LblType})
+
+	LoadDataLocalBytesCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "load_data_local_bytes",
+			Help:      "Counter of bytes transferred by LOAD DATA LOCAL INFILE.",
+		})
+
+	LoadDataLocalHeldBytesGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "load_data_local_held_bytes",
+			Help:      "Memory of the LOAD DATA LOCAL INFILE files held until they are fully received.",
+		})
+
+	RetryReadCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "retry_read",
+			Help:      "Counter of read-only statements retried on another backend after the backend fails.",
+		}, []string{LblType})
+
+	SessionFailoverCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "failover",
+			Help:      "Counter of idle sessions that fail over to another backend after the backend is lost.",
+		}, []string{LblType})
+
+	SessionSnapshotBytesGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelSession,
+			Name:      "snapshot_bytes",
+			Help:      "Memory of the session state snapshots kept for failover and retry.",
+		})
 )
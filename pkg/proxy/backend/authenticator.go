@@ -9,6 +9,7 @@ import (
 	"crypto/tls"
 	"encoding/binary"
 	"net"
+	"slices"
 	"strings"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
@@ -35,37 +36,55 @@ const SupportedServerCapabilities = pnet.ClientLongPassword | pnet.ClientFoundRo
 
 // Authenticator handshakes with the client and the backend.
 type Authenticator struct {
-	dbname            string // default database name
-	user              string
+	dbname string // default database name
+	user   string
+	// backendUser is the user to log in to the backends. It differs from user if the user is authenticated by the proxy.
+	backendUser       string
 	attrs             map[string]string
 	capability        pnet.Capability
 	zstdLevel         int
 	collation         uint8
 	proxyProtocol     bool
+	proxyVersion      proxyprotocol.ProxyVersion
 	requireBackendTLS bool
+	proxyAuth         *ProxyAuth
+	certUserMapper    *CertUserMapper
 }
 
 func NewAuthenticator(config *BCConfig) *Authenticator {
 	auth := &Authenticator{
 		proxyProtocol:     config.ProxyProtocol,
+		proxyVersion:      config.ProxyProtocolVersion,
 		requireBackendTLS: config.RequireBackendTLS,
+		proxyAuth:         config.ProxyAuth,
+		certUserMapper:    config.CertUserMapper,
 	}
 	return auth
 }
 
 func (auth *Authenticator) writeProxyProtocol(clientIO, backendIO pnet.PacketIO) error {
 	if auth.proxyProtocol {
-		proxy := clientIO.Proxy()
-		if proxy == nil {
-			proxy = &proxyprotocol.Proxy{
+		var proxy proxyprotocol.Proxy
+		if clientProxy := clientIO.Proxy(); clientProxy != nil {
+			// pass through the TLVs, but the checksum is invalid after the header is re-encoded
+			proxy = *clientProxy
+			proxy.TLV = slices.DeleteFunc(slices.Clone(proxy.TLV), func(tlv proxyprotocol.ProxyTlv) bool {
+				return tlv.Typ == proxyprotocol.ProxyTlvCRC32C
+			})
+		} else {
+			proxy = proxyprotocol.Proxy{
 				SrcAddress: clientIO.RemoteAddr(),
 				DstAddress: backendIO.RemoteAddr(),
-				Version:    proxyprotocol.ProxyVersion2,
 			}
 		}
+		// the version sent to backends may differ from the one received from clients
+		proxy.Version = proxyprotocol.ProxyVersion2
+		if auth.proxyVersion != 0 {
+			proxy.Version = auth.proxyVersion
+		}
 		// either from another proxy or directly from clients, we are acting as a proxy
 		proxy.Command = proxyprotocol.ProxyCommandProxy
-		backendIO.EnableProxyClient(proxy)
+		backendIO.EnableProxyClient(&proxy)
 	}
 	return nil
 }
@@ -84,10 +103,11 @@ func (auth *Authenticator) verifyBackendCaps(logger *zap.Logger, backendCapabili
 	return nil
 }
 
-type backendIOGetter func(ctx context.Context, cctx ConnContext, resp *pnet.HandshakeResp) (pnet.PacketIO, error)
+// backendIOGetter connects to a backend and returns the TLS config to connect to it.
+type backendIOGetter func(ctx context.Context, cctx ConnContext, resp *pnet.HandshakeResp) (pnet.PacketIO, *tls.Config, error)
 
 func (auth *Authenticator) handshakeFirstTime(ctx context.Context, logger *zap.Logger, cctx ConnContext, clientIO pnet.PacketIO, handshakeHandler HandshakeHandler,
-	getBackendIO backendIOGetter, frontendTLSConfig, backendTLSConfig *tls.Config) error {
+	getBackendIO backendIOGetter, frontendTLSConfig *tls.Config) (backendConnID uint64, err error) {
 	clientIO.ResetSequence()
 
 	proxyCapability := handshakeHandler.GetCapability()
@@ -97,33 +117,33 @@ func (auth *Authenticator) handshakeFirstTime(ctx context.Context, logger *zap.L
 
 	var salt [20]byte
 	if err := pnet.GenerateSalt(&salt); err != nil {
-		return err
+		return 0, err
 	}
 
 	cid, _ := cctx.Value(ConnContextKeyConnID).(uint64)
 	if err := clientIO.WritePacket(pnet.MakeInitialHandshake(proxyCapability, salt, pnet.AuthNativePassword, handshakeHandler.GetServerVersion(), cid), true); err != nil {
-		return err
+		return 0, err
 	}
 	pkt, err := clientIO.ReadPacket()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	// The first packet is either `HandshakeResponse` or `SSLRequest`. They are both at least 32 bytes.
 	// Ref https://dev.mysql.com/doc/dev/mysql-server/8.0.44/page_protocol_connection_phase_packets_protocol_handshake_response.html
 	// Ref https://dev.mysql.com/doc/dev/mysql-server/8.0.44/page_protocol_connection_phase_packets_protocol_ssl_request.html
 	// The following logic will panic if the length is less than 32, so we check it here.
 	if len(pkt) < 32 {
-		return errors.Wrap(ErrClientHandshake, mysql.ErrMalformPacket)
+		return 0, errors.Wrap(ErrClientHandshake, mysql.ErrMalformPacket)
 	}
 	isSSL := pnet.ParseSSLRequestOrHandshakeResp(pkt)
 	frontendCapability := pnet.Capability(binary.LittleEndian.Uint32(pkt))
 	if isSSL {
 		if _, err = clientIO.ServerTLSHandshake(frontendTLSConfig); err != nil {
-			return errors.Wrap(ErrClientHandshake, err)
+			return 0, errors.Wrap(ErrClientHandshake, err)
 		}
 		pkt, err = clientIO.ReadPacket()
 		if err != nil {
-			return err
+			return 0, err
 		}
 		frontendCapabilityResponse := pnet.Capability(binary.LittleEndian.Uint32(pkt))
 		if frontendCapability != frontendCapabilityResponse {
@@ -136,9 +156,9 @@ func (auth *Authenticator) handshakeFirstTime(ctx context.Context, logger *zap.L
 	if commonCaps := frontendCapability & requiredFrontendCaps; commonCaps != requiredFrontendCaps {
 		logger.Error("require frontend capabilities", zap.Stringer("common", commonCaps), zap.Stringer("required", requiredFrontendCaps))
 		if writeErr := clientIO.WritePacket(pnet.MakeErrPacket(mysql.NewDefaultError(mysql.ER_NOT_SUPPORTED_AUTH_MODE)), true); writeErr != nil {
-			return writeErr
+			return 0, writeErr
 		}
-		return errors.Wrapf(ErrClientCap, "require %s from frontend", requiredFrontendCaps&^commonCaps)
+		return 0, errors.Wrapf(ErrClientCap, "require %s from frontend", requiredFrontendCaps&^commonCaps)
 	}
 	commonCaps := frontendCapability & proxyCapability
 	if frontendCapability^commonCaps != 0 {
@@ -153,51 +173,74 @@ func (auth *Authenticator) handshakeFirstTime(ctx context.Context, logger *zap.L
 	}
 
 	if isSSL {
-		cctx.SetValue(ConnContextKeyTLSState, clientIO.TLSConnectionState())
+		state := clientIO.TLSConnectionState()
+		cctx.SetValue(ConnContextKeyTLSState, state)
+		if len(state.ServerName) > 0 {
+			cctx.SetValue(ConnContextKeySNI, state.ServerName)
+			cctx.UpdateLogger(zap.String("sni", state.ServerName))
+		}
 	}
 	clientResp, err := pnet.ParseHandshakeResponse(pkt)
 	var warning *errors.Warning
 	if errors.As(err, &warning) {
 		logger.Warn("parse handshake response encounters error", zap.Error(err))
 	} else if err != nil {
-		return err
+		return 0, err
+	}
+	// Map the user before routing so that the mapped user is used everywhere.
+	if auth.certUserMapper != nil {
+		user, err := auth.certUserMapper.mapUser(clientResp.User, clientIO.TLSConnectionState())
+		if err != nil {
+			logger.Warn("client certificate doesn't match the user", zap.String("user", clientResp.User), zap.Error(err))
+			return 0, writeAccessDenied(clientIO, clientResp.User, len(clientResp.AuthData) > 0)
+		}
+		clientResp.User = user
 	}
 	if err = handshakeHandler.HandleHandshakeResp(cctx, clientResp); err != nil {
-		return errors.Wrap(err, ErrProxyErr)
+		return 0, errors.Wrap(err, ErrProxyErr)
 	}
 	auth.user = clientResp.User
+	auth.backendUser = clientResp.User
 	auth.dbname = clientResp.DB
 	auth.collation = clientResp.Collation
 	auth.attrs = clientResp.Attrs
 	auth.zstdLevel = clientResp.ZstdLevel
+	// The users in the users file are authenticated by the proxy and log in to the backends as the mapped users.
+	proxyUser := auth.proxyAuth.lookup(clientResp.User)
+	if proxyUser != nil {
+		if err = auth.authenticateClient(clientIO, clientResp, proxyUser, salt); err != nil {
+			return 0, err
+		}
+		auth.backendUser = proxyUser.BackendUser
+	}
 
 RECONNECT:
 
 	// In case of testing, backendIO is passed manually that we don't want to bother with the routing logic.
-	backendIO, err := getBackendIO(ctx, cctx, clientResp)
+	backendIO, backendTLSConfig, err := getBackendIO(ctx, cctx, clientResp)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	backendIO.ResetSequence()
 
 	// write proxy header
 	if err := auth.writeProxyProtocol(clientIO, backendIO); err != nil {
-		return err
+		return 0, err
 	}
 
 	// read backend initial handshake
-	serverPkt, backendCapability, err := auth.readInitialHandshake(backendIO)
+	serverPkt, backendCapability, backendConnID, err := auth.readInitialHandshake(backendIO)
 	if err != nil {
 		if pnet.IsMySQLError(err) {
 			if writeErr := clientIO.WritePacket(serverPkt, true); writeErr != nil {
-				return writeErr
+				return 0, writeErr
 			}
 		}
-		return err
+		return 0, err
 	}
 
 	if err := auth.verifyBackendCaps(logger, backendCapability); err != nil {
-		return err
+		return 0, err
 	}
 
 	if common := proxyCapability & backendCapability; (proxyCapability^common)&^pnet.ClientSSL != 0 {
@@ -212,6 +255,31 @@ RECONNECT:
 		logger.Debug("backend does not support capabilities from proxy", zap.Stringer("proxy", proxyCapability^common), zap.Stringer("backend", backendCapability^common))
 	}
 
+	if proxyUser != nil {
+		if serverPkt, err = auth.loginBackend(backendIO, serverPkt, backendTLSConfig, backendCapability, proxyUser.BackendPassword); err != nil {
+			return 0, err
+		}
+		var packetErr *mysql.MyError
+		if serverPkt[0] == pnet.ErrHeader.Byte() {
+			packetErr = pnet.ParseErrorPacket(serverPkt)
+			if handshakeHandler.HandleHandshakeErr(cctx, packetErr) {
+				logger.Warn("handle handshake error, start reconnect", zap.Error(packetErr))
+				if closeErr := backendIO.Close(); closeErr != nil {
+					logger.Warn("close backend error", zap.Error(closeErr))
+				}
+				goto RECONNECT
+			}
+			logger.Warn("the backend rejects the mapped user", zap.String("backend_user", auth.backendUser), zap.Error(packetErr))
+		}
+		if err = clientIO.WritePacket(serverPkt, true); err != nil {
+			return 0, err
+		}
+		if packetErr != nil {
+			return 0, errors.Wrap(packetErr, ErrBackendHandshake)
+		}
+		return backendConnID, auth.setCompress(clientIO, backendIO, backendCapability)
+	}
+
 	// forward client handshake resp
 	if err := auth.writeAuthHandshake(
 		backendIO, backendTLSConfig, backendCapability,
@@ -219,7 +287,7 @@ RECONNECT:
 		// Copy the auth data so that the backend can set correct `using password` in the error message.
 		unknownAuthPlugin, clientResp.AuthData, 0,
 	); err != nil {
-		return err
+		return 0, err
 	}
 
 	// forward other packets
@@ -229,7 +297,7 @@ loop:
 	for {
 		serverPkt, err := backendIO.ReadPacket()
 		if err != nil {
-			return err
+			return 0, err
 		}
 		var packetErr *mysql.MyError
 		if serverPkt[0] == pnet.ErrHeader.Byte() {
@@ -244,22 +312,16 @@ loop:
 		}
 		err = clientIO.WritePacket(serverPkt, true)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		if packetErr != nil {
-			return handleHandshakeError(pktIdx, packetErr)
+			return 0, handleHandshakeError(pktIdx, packetErr)
 		}
 
 		pktIdx++
 		switch serverPkt[0] {
 		case pnet.OKHeader.Byte():
-			if err := setCompress(clientIO, auth.capability, auth.zstdLevel); err != nil {
-				return errors.Wrap(err, ErrClientHandshake)
-			}
-			if err := setCompress(backendIO, auth.capability&backendCapability, auth.zstdLevel); err != nil {
-				return errors.Wrap(err, ErrBackendHandshake)
-			}
-			return nil
+			return backendConnID, auth.setCompress(clientIO, backendIO, backendCapability)
 		default: // mysql.AuthSwitchRequest, ShaCommand
 			if serverPkt[0] == pnet.AuthSwitchHeader.Byte() {
 				pluginName = string(serverPkt[1 : bytes.IndexByte(serverPkt[1:], 0)+1])
@@ -268,12 +330,23 @@ loop:
 				continue loop
 			}
 			if _, err = forwardMsg(clientIO, backendIO); err != nil {
-				return err
+				return 0, err
 			}
 		}
 	}
 }
 
+// setCompress enables compression on both connections after the handshake succeeds.
+func (auth *Authenticator) setCompress(clientIO, backendIO pnet.PacketIO, backendCapability pnet.Capability) error {
+	if err := setCompress(clientIO, auth.capability, auth.zstdLevel); err != nil {
+		return errors.Wrap(err, ErrClientHandshake)
+	}
+	if err := setCompress(backendIO, auth.capability&backendCapability, auth.zstdLevel); err != nil {
+		return errors.Wrap(err, ErrBackendHandshake)
+	}
+	return nil
+}
+
 func forwardMsg(srcIO, destIO pnet.PacketIO) (data []byte, err error) {
 	data, err = srcIO.ReadPacket()
 	if err != nil {
@@ -285,8 +358,8 @@ func forwardMsg(srcIO, destIO pnet.PacketIO) (data []byte, err error) {
 
 // handshake with backend directly without the clientIO
 func (auth *Authenticator) handshakeWithBackend(ctx context.Context, logger *zap.Logger, cctx ConnContext, handshakeHandler HandshakeHandler,
-	username, password, dbName string, getBackendIO backendIOGetter, backendTLSConfig *tls.Config) error {
-	backendIO, err := getBackendIO(ctx, cctx, &pnet.HandshakeResp{User: username})
+	username, password, dbName string, getBackendIO backendIOGetter) error {
+	backendIO, backendTLSConfig, err := getBackendIO(ctx, cctx, &pnet.HandshakeResp{User: username})
 	if err != nil {
 		return err
 	}
@@ -300,6 +373,7 @@ func (auth *Authenticator) handshakeWithBackend(ctx context.Context, logger *zap
 		return err
 	}
 	auth.user = username
+	auth.backendUser = username
 	auth.dbname = dbName
 	auth.capability = handshakeHandler.GetCapability() | pnet.ClientConnectWithDB
 	auth.collation = pnet.Collation
@@ -338,41 +412,41 @@ func (auth *Authenticator) handshakeWithBackend(ctx context.Context, logger *zap
 	}
 }
 
-func (auth *Authenticator) handshakeSecondTime(logger *zap.Logger, clientIO, backendIO pnet.PacketIO, backendTLSConfig *tls.Config, sessionToken string) error {
+func (auth *Authenticator) handshakeSecondTime(logger *zap.Logger, clientIO, backendIO pnet.PacketIO, backendTLSConfig *tls.Config, sessionToken string) (backendConnID uint64, err error) {
 	if len(sessionToken) == 0 {
-		return errors.Wrapf(ErrBackendHandshake, "session token is empty")
+		return 0, errors.Wrapf(ErrBackendHandshake, "session token is empty")
 	}
 
 	// write proxy header
 	if err := auth.writeProxyProtocol(clientIO, backendIO); err != nil {
-		return err
+		return 0, err
 	}
 
-	_, backendCapability, err := auth.readInitialHandshake(backendIO)
+	_, backendCapability, backendConnID, err := auth.readInitialHandshake(backendIO)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if err := auth.verifyBackendCaps(logger, backendCapability); err != nil {
-		return err
+		return 0, err
 	}
 
 	if err = auth.writeAuthHandshake(
 		backendIO, backendTLSConfig, backendCapability,
 		pnet.AuthTiDBSessionToken, hack.Slice(sessionToken), pnet.ClientPluginAuth,
 	); err != nil {
-		return err
+		return 0, err
 	}
 
 	if err = auth.handleSecondAuthResult(backendIO); err == nil {
 		if err = setCompress(backendIO, auth.capability&backendCapability, auth.zstdLevel); err != nil {
-			return errors.Wrap(err, ErrBackendHandshake)
+			return 0, errors.Wrap(err, ErrBackendHandshake)
 		}
 	}
-	return errors.Wrap(err, ErrBackendHandshake)
+	return backendConnID, errors.Wrap(err, ErrBackendHandshake)
 }
 
-func (auth *Authenticator) readInitialHandshake(backendIO pnet.PacketIO) (serverPkt []byte, capability pnet.Capability, err error) {
+func (auth *Authenticator) readInitialHandshake(backendIO pnet.PacketIO) (serverPkt []byte, capability pnet.Capability, connID uint64, err error) {
 	if serverPkt, err = backendIO.ReadPacket(); err != nil {
 		err = errors.Wrap(err, ErrBackendHandshake)
 		return
@@ -382,7 +456,7 @@ func (auth *Authenticator) readInitialHandshake(backendIO pnet.PacketIO) (server
 		return
 	}
 	initialHandshake := pnet.ParseInitialHandshake(serverPkt)
-	capability = initialHandshake.Capability
+	capability, connID = initialHandshake.Capability, initialHandshake.ConnID
 	return
 }
 
@@ -396,7 +470,7 @@ func (auth *Authenticator) writeAuthHandshake(
 ) error {
 	// Always handshake with SSL enabled and enable auth_plugin.
 	resp := &pnet.HandshakeResp{
-		User:       auth.user,
+		User:       auth.backendUser,
 		DB:         auth.dbname,
 		Attrs:      auth.attrs,
 		Collation:  auth.collation,
@@ -430,10 +504,13 @@ func (auth *Authenticator) writeAuthHandshake(
 		}
 		// Send TLS / SSL request packet. The server must have supported TLS.
 		tcfg := backendTLSConfig.Clone()
-		addr := backendIO.RemoteAddr().String()
-		host, _, err := net.SplitHostPort(addr)
-		if err == nil {
-			tcfg.ServerName = host
+		// The server name may be specified by the config, otherwise use the host of the dial address.
+		if len(tcfg.ServerName) == 0 {
+			addr := backendIO.RemoteAddr().String()
+			host, _, err := net.SplitHostPort(addr)
+			if err == nil {
+				tcfg.ServerName = host
+			}
 		}
 		if err := backendIO.ClientTLSHandshake(tcfg); err != nil {
 			// tiproxy pp enabled, tidb pp disabled, tls enabled => tls handshake encounters unrecognized packet
@@ -469,8 +546,10 @@ func (auth *Authenticator) handleSecondAuthResult(backendIO pnet.PacketIO) error
 }
 
 // changeUser is called once the client sends COM_CHANGE_USER.
+// The new user is always authenticated by the backend.
 func (auth *Authenticator) changeUser(req *pnet.ChangeUserReq) {
 	auth.user = req.User
+	auth.backendUser = req.User
 	auth.dbname = req.DB
 	auth.attrs = req.Attrs
 }
@@ -5,10 +5,13 @@ package backend
 
 import (
 	"encoding/binary"
+	"net"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"github.com/stretchr/testify/require"
 )
 
@@ -423,6 +426,14 @@ func TestProxyProtocol(t *testing.T) {
 				cfg.proxyConfig.bcConfig.ProxyProtocol = false
 			},
 		},
+		{
+			func(cfg *testConfig) {
+				cfg.proxyConfig.bcConfig.ProxyProtocolVersion = proxyprotocol.ProxyVersion1
+			},
+			func(cfg *testConfig) {
+				cfg.proxyConfig.bcConfig.ProxyProtocolVersion = proxyprotocol.ProxyVersion2
+			},
+		},
 		{
 			func(cfg *testConfig) {
 				cfg.backendConfig.proxyProtocol = true
@@ -466,6 +477,28 @@ func TestProxyProtocol(t *testing.T) {
 	}
 }
 
+func TestProxyProtocolFromUnixSocket(t *testing.T) {
+	tc := newTCPConnSuite(t)
+	require.NoError(t, tc.proxyListener.Close())
+	var err error
+	tc.proxyListener, err = net.Listen("unix", filepath.Join(t.TempDir(), "tiproxy.sock"))
+	require.NoError(t, err)
+	for _, version := range []proxyprotocol.ProxyVersion{proxyprotocol.ProxyVersion1, proxyprotocol.ProxyVersion2} {
+		ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+			cfg.proxyConfig.bcConfig.ProxyProtocol = true
+			cfg.proxyConfig.bcConfig.ProxyProtocolVersion = version
+			cfg.backendConfig.proxyProtocol = true
+		})
+		ts.authenticateFirstTime(t, func(t *testing.T, ts *testSuite) {
+			require.NoError(t, ts.mp.err)
+			require.NoError(t, ts.mb.err)
+			// the backend falls back to the real connection address
+			require.Equal(t, ts.tc.proxyBIO.LocalAddr().String(), ts.tc.backendIO.RemoteAddr().String())
+		})
+		clean()
+	}
+}
+
 func TestCompressProtocol(t *testing.T) {
 	cfgs := [][]cfgOverrider{
 		{
@@ -625,3 +658,23 @@ func TestMalformedHandshakePacket(t *testing.T) {
 
 	clean()
 }
+
+func TestSNI(t *testing.T) {
+	tc := newTCPConnSuite(t)
+	for _, sni := range []string{"tenant1.example.com", ""} {
+		clientTLSConfig := tc.clientTLSConfig.Clone()
+		clientTLSConfig.ServerName = sni
+		ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+			cfg.clientConfig.tlsConfig = clientTLSConfig
+		})
+		ts.authenticateFirstTime(t, func(t *testing.T, ts *testSuite) {
+			require.NoError(t, ts.mp.err)
+			if len(sni) > 0 {
+				require.Equal(t, sni, ts.mp.Value(ConnContextKeySNI))
+			} else {
+				require.Nil(t, ts.mp.Value(ConnContextKeySNI))
+			}
+		})
+		clean()
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -25,6 +26,7 @@ import (
 	"github.com/pingcap/tiproxy/pkg/balance/router"
 	"github.com/pingcap/tiproxy/pkg/metrics"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
 	"github.com/pingcap/tiproxy/pkg/util/waitgroup"
 	"github.com/siddontang/go/hack"
@@ -62,6 +64,7 @@ type signalType int
 const (
 	signalTypeRedirect signalType = iota
 	signalTypeGracefulClose
+	signalTypeKill
 	signalTypeNums
 )
 
@@ -96,8 +99,53 @@ type BCConfig struct {
 	DialTimeout          time.Duration
 	ConnectTimeout       time.Duration
 	ConnBufferSize       int
+	// ProxyProtocol enables sending the PROXY header to backends.
 	ProxyProtocol        bool
+	ProxyProtocolVersion proxyprotocol.ProxyVersion
 	RequireBackendTLS    bool
+	// ClientProxyProtocol enables parsing the PROXY header from clients.
+	ClientProxyProtocol bool
+	// DefaultNamespace is the namespace of the listener, which is used when the user doesn't match any namespace.
+	DefaultNamespace string
+	// RoutingGroup is the backend group of the listener. Empty means matching the routing rule.
+	RoutingGroup string
+	// ConnPool is not nil in the transaction-level pooling mode.
+	ConnPool *ConnPool
+	// ResultCache caches the results of read-only queries. It may be disabled.
+	ResultCache *ResultCache
+	// Firewall checks the statements before they are sent to the backends. It may be disabled.
+	Firewall *Firewall
+	// QueryRewriter rewrites the statements before they are sent to the backends. It may be disabled.
+	QueryRewriter *QueryRewriter
+	// StatementTimeout is the default statement timeout, which may be overridden by the namespace or the user.
+	StatementTimeout      time.Duration
+	UserStatementTimeouts map[string]time.Duration
+	// LoadDataLocal is the default LOAD DATA LOCAL INFILE policy, which may be overridden by the namespace or the user.
+	LoadDataLocal     config.LoadDataPolicy
+	UserLoadDataLocal map[string]config.LoadDataPolicy
+	// LoadDataMemory limits the memory of the files held for the file size limits of all the connections.
+	LoadDataMemory *LoadDataMemory
+	// RetryReads re-executes the autocommit read-only statements on another backend if the backend fails.
+	RetryReads bool
+	// SessionFailover restores the idle sessions on other backends when the backends are lost. It may be disabled.
+	SessionFailover *SessionFailover
+	// FindConn returns the connection of the connection ID on this instance, which is used to translate KILL statements.
+	FindConn func(connID uint64) *BackendConnManager
+	// ProxyAuth authenticates some users on the proxy instead of the backends. It may be disabled.
+	ProxyAuth *ProxyAuth
+	// CertUserMapper maps the client certificates to users. It's nil if the mapping is disabled.
+	CertUserMapper *CertUserMapper
+	// SlowLog logs the slow commands. It may be disabled.
+	SlowLog *SlowLog
+	// SQLStats aggregates the statistics of the statements. It may be disabled.
+	SQLStats *SQLStats
+	// BackendTLS overrides the backend TLS config for some backends. It may be nil.
+	BackendTLS BackendTLSGetter
+}
+
+// BackendTLSGetter returns the TLS config that overrides sql-tls for a backend. It returns nil if it's not overridden.
+type BackendTLSGetter interface {
+	BackendSQLTLS(ns string, nsTLS *config.TLSConfig, label func(name string) string) (*tls.Config, error)
 }
 
 func (cfg *BCConfig) check() {
@@ -139,7 +187,8 @@ type BackendConnManager struct {
 	eventReceiver  atomic.Pointer[router.ConnEventReceiver]
 	config         *BCConfig
 	logger         *zap.Logger
-	curBackend     router.BackendInst
+	// curBackend is the backend of the current connection. KILL reads it from other sessions without lock.
+	curBackend atomic.Pointer[router.BackendInst]
 	// Redirect() sets it without lock. It will be set to nil after migration.
 	redirectInfo atomic.Pointer[router.BackendInst]
 	// redirectResCh is used to notify the event receiver asynchronously.
@@ -156,10 +205,30 @@ type BackendConnManager struct {
 	cancelFunc context.CancelFunc
 	clientIO   pnet.PacketIO
 	// backendIO may be written during redirection and be read in ExecuteCmd/Redirect/setKeepalive.
-	backendIO        atomic.Pointer[pnet.PacketIO]
-	backendTLS       *tls.Config
-	handshakeHandler HandshakeHandler
-	ctxmap           struct {
+	backendIO atomic.Pointer[pnet.PacketIO]
+	// backendConnID is the connection ID of backendIO on the backend, which is used to translate KILL statements.
+	backendConnID atomic.Uint64
+	// killed means the session is killed by KILL CONNECTION from another session.
+	killed atomic.Bool
+	// pooledAddr is the backend address when the backend connection is released to the pool.
+	pooledAddr atomic.Pointer[string]
+	// pooling keeps the session states to restore when the session acquires a backend connection again.
+	pooling struct {
+		handoff *sessionHandoff
+		// pinned means the session has unmigratable states and never releases the backend connection.
+		pinned bool
+	}
+	// stmtKiller is used to kill the statements that exceed the statement timeout.
+	stmtKiller stmtKiller
+	// snapshot keeps the session states to retry the reads or fail over to another backend.
+	snapshot sessionSnapshot
+	// backendRouter is the router of the session, which is used to choose another backend when the backend fails.
+	backendRouter router.Router
+	// defaultBackendTLS is the sql-tls, which may be overridden by the backend labels or the namespace.
+	// The backends in the same group may have different labels, so getBackendTLS is called for every new connection.
+	defaultBackendTLS *tls.Config
+	handshakeHandler  HandshakeHandler
+	ctxmap            struct {
 		sync.Mutex
 		m map[any]any
 	}
@@ -188,6 +257,12 @@ func NewBackendConnManager(logger *zap.Logger, handshakeHandler HandshakeHandler
 		cpt:            cpt,
 		meter:          meter,
 	}
+	mgr.cmdProcessor.resultCache = config.ResultCache
+	mgr.cmdProcessor.slowLog = config.SlowLog
+	mgr.cmdProcessor.sqlStats = config.SQLStats
+	mgr.cmdProcessor.loadDataMemory = config.LoadDataMemory
+	mgr.cmdProcessor.certUserMapper = config.CertUserMapper
+	mgr.cmdProcessor.connID = connectionID
 	mgr.ctxmap.m = make(map[any]any)
 	mgr.SetValue(ConnContextKeyConnID, connectionID)
 	return mgr
@@ -204,7 +279,7 @@ func (mgr *BackendConnManager) Connect(ctx context.Context, clientIO pnet.Packet
 	mgr.processLock.Lock()
 	defer mgr.processLock.Unlock()
 
-	mgr.backendTLS = backendTLSConfig
+	mgr.defaultBackendTLS = backendTLSConfig
 	mgr.clientIO = clientIO
 
 	if mgr.closeStatus.Load() >= statusNotifyClose {
@@ -213,13 +288,16 @@ func (mgr *BackendConnManager) Connect(ctx context.Context, clientIO pnet.Packet
 	}
 	startTime := time.Now()
 	mgr.createTime = startTime
-	var err error
+	var (
+		backendConnID uint64
+		err           error
+	)
 	if len(username) == 0 {
 		// real client
-		err = mgr.authenticator.handshakeFirstTime(ctx, mgr.logger.Named("authenticator"), mgr, clientIO, mgr.handshakeHandler, mgr.getBackendIO, frontendTLSConfig, backendTLSConfig)
+		backendConnID, err = mgr.authenticator.handshakeFirstTime(ctx, mgr.logger.Named("authenticator"), mgr, clientIO, mgr.handshakeHandler, mgr.getBackendIO, frontendTLSConfig)
 	} else {
 		// fake client, used for replaying traffic
-		err = mgr.authenticator.handshakeWithBackend(ctx, mgr.logger.Named("authenticator"), mgr, mgr.handshakeHandler, username, password, dbName, mgr.getBackendIO, backendTLSConfig)
+		err = mgr.authenticator.handshakeWithBackend(ctx, mgr.logger.Named("authenticator"), mgr, mgr.handshakeHandler, username, password, dbName, mgr.getBackendIO)
 	}
 	mgr.logger = mgr.logger.With(zap.Stringer("client_addr", clientIO.RemoteAddr()), zap.Stringer("proxy_addr", clientIO.ProxyAddr()))
 	if err != nil {
@@ -234,6 +312,7 @@ func (mgr *BackendConnManager) Connect(ctx context.Context, clientIO pnet.Packet
 		mgr.quitSource = src
 		return err
 	}
+	mgr.backendConnID.Store(backendConnID)
 	mgr.handshakeHandler.OnHandshake(mgr, mgr.ServerAddr(), nil, SrcNone)
 	endTime := time.Now()
 	addHandshakeMetrics(mgr.ServerAddr(), endTime.Sub(startTime))
@@ -243,12 +322,17 @@ func (mgr *BackendConnManager) Connect(ctx context.Context, clientIO pnet.Packet
 	mgr.updateTraffic(*mgr.backendIO.Load())
 
 	mgr.cmdProcessor.capability = mgr.authenticator.capability
+	mgr.cmdProcessor.namespace, _ = mgr.Value(ConnContextKeyNamespace).(string)
+	mgr.cmdProcessor.setSession(mgr.authenticator.user, mgr.authenticator.dbname, mgr.authenticator.collation)
+	mgr.cmdProcessor.stmtTimeout = mgr.statementTimeout(mgr.authenticator.user)
+	mgr.cmdProcessor.loadDataPolicy = mgr.loadDataPolicy(mgr.authenticator.user)
 	childCtx, cancelFunc := context.WithCancel(ctx)
 	mgr.cancelFunc = cancelFunc
 	mgr.lastActiveTime = endTime
 	if mgr.cpt != nil && !reflect.ValueOf(mgr.cpt).IsNil() {
-		mgr.cpt.InitConn(endTime, mgr.connectionID, mgr.authenticator.dbname)
+		mgr.cpt.InitConn(endTime, mgr.connectionID, mgr.authenticator.dbname, mgr.clientIO.ServerName())
 	}
+	mgr.releaseBackend()
 	mgr.wg.RunWithRecover(func() {
 		mgr.processSignals(childCtx)
 	}, func(_ any) {
@@ -273,20 +357,26 @@ func (mgr *BackendConnManager) newExponentialBackOff() *backoff.ExponentialBackO
 	return b
 }
 
-func (mgr *BackendConnManager) getBackendIO(ctx context.Context, cctx ConnContext, resp *pnet.HandshakeResp) (pnet.PacketIO, error) {
+func (mgr *BackendConnManager) getBackendIO(ctx context.Context, cctx ConnContext, resp *pnet.HandshakeResp) (pnet.PacketIO, *tls.Config, error) {
+	// The PROXY header is parsed when reading the handshake response, so it's available now.
+	if mgr.clientIO != nil {
+		mgr.setProxyContext(mgr.clientIO.Proxy())
+	}
 	r, err := mgr.handshakeHandler.GetRouter(cctx, resp)
 	if err != nil {
-		return nil, errors.Wrap(err, ErrProxyErr)
+		return nil, nil, errors.Wrap(err, ErrProxyErr)
 	}
 	// Reasons to wait:
 	// - The TiDB instances may not be initialized yet
 	// - One TiDB may be just shut down and another is just started but not ready yet
 	bctx, cancel := context.WithTimeout(ctx, mgr.config.ConnectTimeout)
-	ci := router.ClientInfo{}
+	ci := router.ClientInfo{Group: mgr.config.RoutingGroup}
 	if mgr.clientIO != nil {
 		ci.ClientAddr = mgr.clientIO.RemoteAddr()
 		ci.ProxyAddr = mgr.clientIO.ProxyAddr()
+		ci.SNI = mgr.clientIO.ServerName()
 	}
+	mgr.backendRouter = r
 	selector := r.GetBackendSelector(ci)
 	startTime := time.Now()
 	var addr string
@@ -318,7 +408,7 @@ func (mgr *BackendConnManager) getBackendIO(ctx context.Context, cctx ConnContex
 			// And `RemoteAddr()` will return IP addr
 			backendIO := pnet.PacketIO(pnet.NewPacketIO(cn, mgr.logger, mgr.config.ConnBufferSize, pnet.WithRemoteAddr(addr, cn.RemoteAddr()), pnet.WithWrapError(ErrBackendConn)))
 			mgr.backendIO.Store(&backendIO)
-			mgr.curBackend = backend
+			mgr.curBackend.Store(&backend)
 			mgr.setKeepAlive()
 			return backendIO, nil
 		},
@@ -343,7 +433,36 @@ func (mgr *BackendConnManager) getBackendIO(ctx context.Context, cctx ConnContex
 			err = origErr
 		}
 	}
-	return io, err
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig, err := mgr.getBackendTLS(cctx, backend)
+	if err != nil {
+		mgr.logger.Error("get backend tls failed", zap.String("backend_addr", addr), zap.Error(err))
+		if closeErr := io.Close(); closeErr != nil {
+			mgr.logger.Warn("close backend error", zap.Error(closeErr))
+		}
+		return nil, nil, err
+	}
+	return io, tlsConfig, nil
+}
+
+// getBackendTLS returns the TLS config to connect to the backend, which may be overridden by the labels or the namespace.
+// The backend is nil if it's unknown, e.g. the backend connection is created by the caller.
+func (mgr *BackendConnManager) getBackendTLS(cctx ConnContext, backend router.BackendInst) (*tls.Config, error) {
+	if backend == nil || mgr.config.BackendTLS == nil || reflect.ValueOf(mgr.config.BackendTLS).IsNil() {
+		return mgr.defaultBackendTLS, nil
+	}
+	ns, _ := cctx.Value(ConnContextKeyNamespace).(string)
+	nsTLS, _ := cctx.Value(ConnContextKeyBackendTLS).(*config.TLSConfig)
+	tlsConfig, err := mgr.config.BackendTLS.BackendSQLTLS(ns, nsTLS, backend.Label)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrProxyErr)
+	}
+	if tlsConfig == nil {
+		return mgr.defaultBackendTLS, nil
+	}
+	return tlsConfig, nil
 }
 
 // ExecuteCmd forwards messages between the client and the backend.
@@ -389,10 +508,63 @@ func (mgr *BackendConnManager) ExecuteCmd(ctx context.Context, request []byte) (
 		err = ErrClosing
 		return
 	}
+	// The statement is shared by the following steps so that it's normalized at most once.
+	stmt := newSQLStmt(request)
+	// The firewall checks the statement from the client before it's rewritten so that a rewrite rule can't bypass
+	// a deny rule, and it checks the rewritten statement again because that's the one actually sent.
+	firewall := mgr.config.Firewall.Enabled()
+	if firewall {
+		if err = mgr.checkFirewall(stmt); err != nil {
+			return
+		}
+	}
+	if mgr.config.QueryRewriter.Enabled() {
+		var rewritten bool
+		if request, rewritten = mgr.rewriteQuery(request, stmt); rewritten && firewall {
+			if err = mgr.checkFirewall(stmt); err != nil {
+				return
+			}
+		}
+	}
+	if mgr.pooledAddr.Load() != nil {
+		// No need to restore the session just for quitting.
+		if cmd == pnet.ComQuit {
+			return
+		}
+		if err = mgr.acquireBackend(); err != nil {
+			if clientErr := ErrToClient(err); clientErr != nil {
+				if writeErr := mgr.clientIO.WritePacket(pnet.MakeUserError(clientErr), true); writeErr != nil {
+					mgr.logger.Warn("writing error to client failed", zap.NamedError("mysql_err", clientErr), zap.NamedError("write_err", writeErr))
+				}
+			}
+			return
+		}
+	}
 	waitingRedirect := mgr.redirectInfo.Load() != nil
 	var holdRequest bool
 	backendIO := *mgr.backendIO.Load()
-	holdRequest, err = mgr.cmdProcessor.executeCmd(request, mgr.clientIO, backendIO, waitingRedirect)
+	if kr := mgr.translateKill(request); kr != nil {
+		err = mgr.executeKill(kr, backendIO)
+	} else {
+		retryable := mgr.prepareRetry(request, backendIO, waitingRedirect)
+		clientOutBytes := mgr.clientIO.OutBytes()
+		mgr.prepareStmtKiller(request, backendIO)
+		holdRequest, err = mgr.cmdProcessor.executeCmd(request, stmt, mgr.clientIO, backendIO, waitingRedirect)
+		// The read can be retried only if nothing has been sent to the client and the session is not killed.
+		if retryable && err != nil && errors.Is(err, ErrBackendConn) && mgr.clientIO.OutBytes() == clientOutBytes && !mgr.killed.Load() {
+			failedAddr := backendIO.RemoteAddr().String()
+			if newBackendIO, retryErr := mgr.failover(backendIO); retryErr == nil {
+				metrics.RetryReadCounter.WithLabelValues(retryReadSucceeded).Inc()
+				mgr.logger.Info("backend fails, retry the read on another backend", zap.String("from", failedAddr), zap.String("to", mgr.ServerAddr()))
+				backendIO = newBackendIO
+				mgr.prepareStmtKiller(request, backendIO)
+				_, err = mgr.cmdProcessor.executeCmd(request, stmt, mgr.clientIO, backendIO, false)
+			} else {
+				metrics.RetryReadCounter.WithLabelValues(retryReadFailed).Inc()
+				mgr.logger.Warn("backend fails and retrying the read failed", zap.String("backend_addr", failedAddr), zap.Error(retryErr))
+			}
+		}
+	}
 	if !holdRequest {
 		addCmdMetrics(cmd, backendIO.RemoteAddr().String(), startTime)
 		mgr.updateTraffic(backendIO)
@@ -424,7 +596,11 @@ func (mgr *BackendConnManager) ExecuteCmd(ctx context.Context, request []byte) (
 		case pnet.ComChangeUser:
 			// Critical errors should not happen because CmdProcessor has parsed it already.
 			req, _ := pnet.ParseChangeUser(request, mgr.authenticator.capability)
+			// The user may be substituted by the certificate user mapping.
+			req.User = mgr.cmdProcessor.user
 			mgr.authenticator.changeUser(req)
+			mgr.cmdProcessor.stmtTimeout = mgr.statementTimeout(mgr.authenticator.user)
+			mgr.cmdProcessor.loadDataPolicy = mgr.loadDataPolicy(mgr.authenticator.user)
 		}
 	}
 	// Even if it meets an MySQL error, it may have changed the status, such as when executing multi-statements.
@@ -438,23 +614,28 @@ func (mgr *BackendConnManager) ExecuteCmd(ctx context.Context, request []byte) (
 	// Execute the held request no matter redirection succeeds or not.
 	if holdRequest && mgr.closeStatus.Load() < statusNotifyClose {
 		backendIO = *mgr.backendIO.Load()
-		_, err = mgr.cmdProcessor.executeCmd(request, mgr.clientIO, backendIO, false)
+		mgr.prepareStmtKiller(request, backendIO)
+		_, err = mgr.cmdProcessor.executeCmd(request, stmt, mgr.clientIO, backendIO, false)
 		addCmdMetrics(cmd, backendIO.RemoteAddr().String(), startTime)
 		mgr.updateTraffic(backendIO)
 	}
+	if err == nil || pnet.IsMySQLError(err) {
+		mgr.releaseBackend()
+	}
 	return
 }
 
 func (mgr *BackendConnManager) updateTraffic(backendIO pnet.PacketIO) {
 	inBytes, inPackets, outBytes, outPackets := backendIO.InBytes(), backendIO.InPackets(), backendIO.OutBytes(), backendIO.OutPackets()
-	addTraffic(backendIO.RemoteAddr().String(), inBytes-mgr.inBytes, inPackets-mgr.inPackets, outBytes-mgr.outBytes, outPackets-mgr.outPackets, mgr.curBackend.Local())
+	curBackend := mgr.currentBackend()
+	addTraffic(backendIO.RemoteAddr().String(), inBytes-mgr.inBytes, inPackets-mgr.inPackets, outBytes-mgr.outBytes, outPackets-mgr.outPackets, curBackend.Local())
 	// Update metering.
-	if mgr.meter != nil && !reflect.ValueOf(mgr.meter).IsNil() && mgr.curBackend != nil {
+	if mgr.meter != nil && !reflect.ValueOf(mgr.meter).IsNil() && curBackend != nil {
 		// The keyspace of one connection should not change.
-		keyspace := mgr.curBackend.Keyspace()
+		keyspace := curBackend.Keyspace()
 		if len(keyspace) > 0 {
 			crossAZBytes := int64(0)
-			if !mgr.curBackend.Local() {
+			if !curBackend.Local() {
 				crossAZBytes = int64(outBytes - mgr.outBytes + inBytes - mgr.inBytes)
 			}
 			mgr.meter.IncTraffic(keyspace, int64(inBytes-mgr.inBytes), crossAZBytes, mgr.fromPublicEndpoint)
@@ -488,8 +669,16 @@ func (mgr *BackendConnManager) initSessionStates(backendIO pnet.PacketIO, sessio
 
 func (mgr *BackendConnManager) querySessionStates(backendIO pnet.PacketIO) (sessionStates, sessionToken string, err error) {
 	// Do not lock here because the caller already locks.
+	if sessionStates, sessionToken, err = querySessionStates(mgr.cmdProcessor, backendIO); err == nil {
+		// Keep the token for killing the statements that exceed the statement timeout.
+		mgr.stmtKiller.setToken(sessionToken, time.Now())
+	}
+	return
+}
+
+func querySessionStates(cp *CmdProcessor, backendIO pnet.PacketIO) (sessionStates, sessionToken string, err error) {
 	var result *mysql.Resultset
-	if result, _, err = mgr.cmdProcessor.query(backendIO, sqlQueryState); err != nil {
+	if result, _, err = cp.query(backendIO, sqlQueryState); err != nil {
 		return
 	}
 	if sessionStates, err = result.GetStringByName(0, sessionStatesCol); err != nil {
@@ -508,6 +697,11 @@ func (mgr *BackendConnManager) initForCapture() (string, error) {
 	if !mgr.cmdProcessor.finishedTxn() {
 		return "", ErrInTxn
 	}
+	// The states of the pooled session may be outdated, so get the connection back to query them.
+	if err := mgr.acquireBackend(); err != nil {
+		return "", err
+	}
+	defer mgr.releaseBackend()
 	sessionStates, _, err := mgr.querySessionStates(*mgr.backendIO.Load())
 	if err != nil {
 		return "", err
@@ -535,6 +729,10 @@ func (mgr *BackendConnManager) processSignals(ctx context.Context) {
 					mgr.tryGracefulClose(ctx)
 				case signalTypeRedirect:
 					mgr.tryRedirect(ctx)
+					// The pooled session gets a connection to redirect, so release it again.
+					mgr.releaseBackend()
+				case signalTypeKill:
+					mgr.closeKilled()
 				}
 			}()
 		case rs := <-mgr.redirectResCh:
@@ -544,6 +742,8 @@ func (mgr *BackendConnManager) processSignals(ctx context.Context) {
 				mgr.checkBackendActive()
 				mgr.processLock.Lock()
 				defer mgr.processLock.Unlock()
+				mgr.refreshPooledSession()
+				mgr.snapshotIdleSession()
 				mgr.setKeepAlive()
 			}()
 		case <-ctx.Done():
@@ -587,6 +787,10 @@ func (mgr *BackendConnManager) tryRedirect(ctx context.Context) {
 		rs.err = ErrTargetUnhealthy
 		return
 	}
+	// The backend connection is in the pool and only it has the latest session states.
+	if rs.err = mgr.acquireBackend(); rs.err != nil {
+		return
+	}
 	backendIO := *mgr.backendIO.Load()
 	var sessionStates, sessionToken string
 	if sessionStates, sessionToken, rs.err = mgr.querySessionStates(backendIO); rs.err != nil {
@@ -614,6 +818,10 @@ func (mgr *BackendConnManager) tryRedirect(ctx context.Context) {
 		return
 	}
 
+	var tlsConfig *tls.Config
+	if tlsConfig, rs.err = mgr.getBackendTLS(mgr, *backendInst); rs.err != nil {
+		return
+	}
 	var cn net.Conn
 	cn, rs.err = net.DialTimeout("tcp", rs.to, mgr.config.DialTimeout)
 	if rs.err != nil {
@@ -622,7 +830,8 @@ func (mgr *BackendConnManager) tryRedirect(ctx context.Context) {
 	}
 	newBackendIO := pnet.PacketIO(pnet.NewPacketIO(cn, mgr.logger, mgr.config.ConnBufferSize, pnet.WithRemoteAddr(rs.to, cn.RemoteAddr()), pnet.WithWrapError(ErrBackendConn)))
 
-	if rs.err = mgr.authenticator.handshakeSecondTime(mgr.logger, mgr.clientIO, newBackendIO, mgr.backendTLS, sessionToken); rs.err == nil {
+	var backendConnID uint64
+	if backendConnID, rs.err = mgr.authenticator.handshakeSecondTime(mgr.logger, mgr.clientIO, newBackendIO, tlsConfig, sessionToken); rs.err == nil {
 		rs.err = mgr.initSessionStates(newBackendIO, sessionStates)
 	} else {
 		mgr.handshakeHandler.OnHandshake(mgr, newBackendIO.RemoteAddr().String(), rs.err, Error2Source(rs.err))
@@ -639,8 +848,9 @@ func (mgr *BackendConnManager) tryRedirect(ctx context.Context) {
 	if ignoredErr := backendIO.Close(); ignoredErr != nil && !pnet.IsDisconnectError(ignoredErr) {
 		mgr.logger.Error("close previous backend connection failed", zap.Error(ignoredErr))
 	}
+	mgr.backendConnID.Store(backendConnID)
 	mgr.backendIO.Store(&newBackendIO)
-	mgr.curBackend = *backendInst
+	mgr.curBackend.Store(backendInst)
 	mgr.setKeepAlive()
 	mgr.handshakeHandler.OnHandshake(mgr, mgr.ServerAddr(), nil, SrcNone)
 }
@@ -725,21 +935,36 @@ func (mgr *BackendConnManager) checkBackendActive() {
 	if mgr.lastActiveTime.Add(mgr.config.CheckBackendInterval).After(now) {
 		return
 	}
-	backendIO := *mgr.backendIO.Load()
+	ptr := mgr.backendIO.Load()
+	// The backend connection is in the pool.
+	if ptr == nil {
+		return
+	}
+	backendIO := *ptr
 	if !backendIO.IsPeerActive() {
-		mgr.logger.Info("backend connection is closed, close client connection",
-			zap.Stringer("backend_addr", backendIO.RemoteAddr()),
-			zap.Bool("backend_healthy", mgr.curBackend.Healthy()))
-		mgr.quitSource = SrcBackendNetwork
-		if err := mgr.clientIO.GracefulClose(); err != nil {
-			mgr.logger.Warn("graceful close client IO error", zap.Error(err))
-		}
-		mgr.closeStatus.CompareAndSwap(statusActive, statusClosing)
+		mgr.onBackendLost(backendIO)
 	} else {
 		mgr.lastActiveTime = now
 	}
 }
 
+// onBackendLost fails over the idle session to another backend if possible, or closes the client connection otherwise.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) onBackendLost(backendIO pnet.PacketIO) {
+	if mgr.failoverIdleSession(backendIO) {
+		mgr.lastActiveTime = time.Now()
+		return
+	}
+	mgr.logger.Info("backend connection is closed, close client connection",
+		zap.Stringer("backend_addr", backendIO.RemoteAddr()),
+		zap.Bool("backend_healthy", mgr.currentBackend().Healthy()))
+	mgr.quitSource = SrcBackendNetwork
+	if err := mgr.clientIO.GracefulClose(); err != nil {
+		mgr.logger.Warn("graceful close client IO error", zap.Error(err))
+	}
+	mgr.closeStatus.CompareAndSwap(statusActive, statusClosing)
+}
+
 func (mgr *BackendConnManager) ClientAddr() string {
 	if mgr.clientIO == nil {
 		return ""
@@ -751,6 +976,9 @@ func (mgr *BackendConnManager) ServerAddr() string {
 	if backendIO := mgr.backendIO.Load(); backendIO != nil {
 		return (*backendIO).RemoteAddr().String()
 	}
+	if addr := mgr.pooledAddr.Load(); addr != nil {
+		return *addr
+	}
 	return ""
 }
 
@@ -799,6 +1027,7 @@ func (mgr *BackendConnManager) Close() error {
 	}
 
 	mgr.closeStatus.Store(statusClosing)
+	mgr.releaseSnapshot()
 	if mgr.cancelFunc != nil {
 		mgr.cancelFunc()
 		mgr.cancelFunc = nil
@@ -812,6 +1041,10 @@ func (mgr *BackendConnManager) Close() error {
 	if backendIO := mgr.backendIO.Swap(nil); backendIO != nil {
 		addr = (*backendIO).RemoteAddr().String()
 		connErr = (*backendIO).Close()
+	} else if pooledAddr := mgr.pooledAddr.Load(); pooledAddr != nil {
+		// The backend connection is in the pool and may be used by other sessions.
+		addr = *pooledAddr
+		mgr.forgetPooledSession(addr)
 	}
 
 	eventReceiver := mgr.getEventReceiver()
@@ -854,7 +1087,7 @@ func (mgr *BackendConnManager) setKeepAlive() {
 	// The request to the unhealthy backend may block, instead of fail immediately.
 	// So we set a shorter keep alive timeout for the unhealthy backends.
 	cfg := mgr.config.HealthyKeepAlive
-	curHealthy := mgr.curBackend.Healthy()
+	curHealthy := mgr.currentBackend().Healthy()
 	if !curHealthy {
 		cfg = mgr.config.UnhealthyKeepAlive
 	}
@@ -882,6 +1115,30 @@ func (mgr *BackendConnManager) UpdateLogger(fields ...zap.Field) {
 	mgr.logger = mgr.logger.With(fields...)
 }
 
+// setProxyContext exposes the PROXY header to the HandshakeHandler and adds the TLVs to the logs.
+func (mgr *BackendConnManager) setProxyContext(proxy *proxyprotocol.Proxy) {
+	if proxy == nil || mgr.Value(ConnContextKeyProxyProtocol) != nil {
+		return
+	}
+	mgr.SetValue(ConnContextKeyProxyProtocol, proxy)
+	var fields []zap.Field
+	if authority := proxy.Authority(); len(authority) > 0 {
+		fields = append(fields, zap.String("proxy_authority", authority))
+	}
+	if uniqueID := proxy.UniqueID(); len(uniqueID) > 0 {
+		fields = append(fields, zap.String("proxy_unique_id", hex.EncodeToString(uniqueID)))
+	}
+	if vpceID := proxy.AWSVPCEndpointID(); len(vpceID) > 0 {
+		fields = append(fields, zap.String("proxy_vpce_id", vpceID))
+	}
+	if ssl := proxy.SSL(); ssl != nil && len(ssl.CN) > 0 {
+		fields = append(fields, zap.String("proxy_ssl_cn", ssl.CN))
+	}
+	if len(fields) > 0 {
+		mgr.UpdateLogger(fields...)
+	}
+}
+
 // ConnInfo returns detailed info of the connection, which should not be logged too many times.
 // Be careful about deadlocks.
 func (mgr *BackendConnManager) ConnInfo() []zap.Field {
@@ -896,3 +1153,11 @@ func (mgr *BackendConnManager) ConnInfo() []zap.Field {
 		zap.Time("last_active_time", mgr.lastActiveTime))
 	return fields
 }
+
+// currentBackend returns the backend of the current connection. It returns nil if the backend is unknown.
+func (mgr *BackendConnManager) currentBackend() router.BackendInst {
+	if backend := mgr.curBackend.Load(); backend != nil {
+		return *backend
+	}
+	return nil
+}
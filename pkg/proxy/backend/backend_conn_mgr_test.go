@@ -5,6 +5,7 @@ package backend
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"strings"
@@ -13,12 +14,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/pingcap/tiproxy/lib/util/logger"
 	"github.com/pingcap/tiproxy/lib/util/waitgroup"
 	"github.com/pingcap/tiproxy/pkg/balance/router"
 	"github.com/pingcap/tiproxy/pkg/metrics"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -80,6 +83,7 @@ func (mer *mockEventReceiver) checkEvent(t *testing.T, eventName int) event {
 type mockBackendInst struct {
 	addr     string
 	keyspace string
+	labels   map[string]string
 	healthy  atomic.Bool
 	local    atomic.Bool
 }
@@ -121,6 +125,10 @@ func (mbi *mockBackendInst) setKeyspace(k string) {
 	mbi.keyspace = k
 }
 
+func (mbi *mockBackendInst) Label(name string) string {
+	return mbi.labels[name]
+}
+
 type runner struct {
 	client  func(packetIO pnet.PacketIO) error
 	proxy   func(clientIO, backendIO pnet.PacketIO) error
@@ -1025,7 +1033,7 @@ func TestGetBackendIO(t *testing.T) {
 				require.NoError(t, cn.Close())
 			}
 		})
-		io, err := mgr.getBackendIO(context.Background(), mgr, nil)
+		io, _, err := mgr.getBackendIO(context.Background(), mgr, nil)
 		if err == nil {
 			require.NoError(t, io.Close())
 		}
@@ -1147,11 +1155,11 @@ func TestKeepAlive(t *testing.T) {
 		{
 			proxy: func(clientIO, backendIO pnet.PacketIO) error {
 				require.Equal(t, time.Minute, (*ts.mp.backendIO.Load()).LastKeepAlive().Idle)
-				ts.mp.curBackend.(*router.StaticBackend).SetHealthy(false)
+				ts.mp.currentBackend().(*router.StaticBackend).SetHealthy(false)
 				require.Eventually(t, func() bool {
 					return (*ts.mp.backendIO.Load()).LastKeepAlive().Idle == time.Second
 				}, 3*time.Second, 10*time.Millisecond)
-				ts.mp.curBackend.(*router.StaticBackend).SetHealthy(true)
+				ts.mp.currentBackend().(*router.StaticBackend).SetHealthy(true)
 				require.Eventually(t, func() bool {
 					return (*ts.mp.backendIO.Load()).LastKeepAlive().Idle == time.Minute
 				}, 3*time.Second, 10*time.Millisecond)
@@ -1388,7 +1396,7 @@ func TestTrafficMetrics(t *testing.T) {
 				return ts.mc.request(packetIO)
 			},
 			proxy: func(clientIO, backendIO pnet.PacketIO) error {
-				ts.mp.curBackend.(*router.StaticBackend).SetKeyspace("key1")
+				ts.mp.currentBackend().(*router.StaticBackend).SetKeyspace("key1")
 				addr := ts.tc.backendListener.Addr().String()
 				var err error
 				inBytes, inPackets, outBytes, outPackets, err = readTraffic(addr)
@@ -1640,3 +1648,273 @@ func BenchmarkLockedMap(b *testing.B) {
 		lock.Unlock()
 	}
 }
+
+func TestProxyContext(t *testing.T) {
+	lg, text := logger.CreateLoggerForTest(t)
+	mgr := NewBackendConnManager(lg, &CustomHandshakeHandler{}, nil, 0, &BCConfig{}, nil)
+	mgr.setProxyContext(nil)
+	require.Nil(t, mgr.Value(ConnContextKeyProxyProtocol))
+
+	proxy := &proxyprotocol.Proxy{
+		TLV: []proxyprotocol.ProxyTlv{
+			{Typ: proxyprotocol.ProxyTlvAuthority, Content: []byte("tidb.example.com")},
+			{Typ: proxyprotocol.ProxyTlvAWS, Content: append([]byte{proxyprotocol.ProxyTlvAWSSubtypeVPCEndpointID}, "vpce-1"...)},
+		},
+	}
+	mgr.setProxyContext(proxy)
+	require.Equal(t, proxy, mgr.Value(ConnContextKeyProxyProtocol))
+	mgr.logger.Info("test")
+	require.Contains(t, text.String(), "tidb.example.com")
+	require.Contains(t, text.String(), "vpce-1")
+}
+
+func TestConnPool(t *testing.T) {
+	pool := NewConnPool(config.ConnPool{Enable: true})
+	t.Cleanup(pool.Close)
+	ts := newBackendMgrTester(t, func(config *testConfig) {
+		config.proxyConfig.bcConfig.ConnPool = pool
+	})
+	respondStates := func(packetIO pnet.PacketIO) error {
+		ts.mb.respondType = responseTypeResultSet
+		return ts.mb.respond(packetIO)
+	}
+	checkReleased := func(released bool) {
+		require.Equal(t, released, ts.mp.backendIO.Load() == nil)
+		require.Equal(t, released, ts.mp.pooledAddr.Load() != nil)
+		require.Equal(t, ts.tc.backendListener.Addr().String(), ts.mp.ServerAddr())
+	}
+	// takeConn pretends that another session takes the connection, which saves the states of the owner.
+	takeConn := func() *pooledConn {
+		return pool.Get(ts.mp.poolKey(*ts.mp.pooledAddr.Load()), ts.mp.ConnectionID()+1)
+	}
+	runners := []runner{
+		// the connection is released after handshake without querying the states
+		{
+			client: ts.mc.authenticate,
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.NoError(t, ts.firstHandshake4Proxy(clientIO, backendIO))
+				checkReleased(true)
+				require.Equal(t, 1, pool.IdleCount())
+				return nil
+			},
+			backend: ts.handshake4Backend,
+		},
+		// reuse the owned connection without resetting it
+		{
+			client: ts.mc.request,
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
+				checkReleased(true)
+				return nil
+			},
+			backend: ts.respondWithNoTxn4Backend,
+		},
+		// the connection is bound during the transaction
+		{
+			client: ts.mc.request,
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
+				checkReleased(false)
+				require.Equal(t, 0, pool.IdleCount())
+				return nil
+			},
+			backend: ts.startTxn4Backend,
+		},
+		{
+			client: ts.mc.request,
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
+				checkReleased(true)
+				// the states are saved when another session takes the connection
+				pc := takeConn()
+				require.NotNil(t, pc)
+				require.Nil(t, pc.handoff)
+				require.True(t, ts.mp.pooling.handoff.ready())
+				pc.owner = ts.mp.ConnectionID() + 1
+				pool.Put(ts.mp.poolKey(*ts.mp.pooledAddr.Load()), pc)
+				return nil
+			},
+			backend: func(packetIO pnet.PacketIO) error {
+				require.NoError(t, ts.respondWithNoTxn4Backend(packetIO))
+				return respondStates(packetIO)
+			},
+		},
+		// reset the connection and restore the session states
+		{
+			client: ts.mc.request,
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
+				checkReleased(true)
+				// the connection is closed by others
+				pc := takeConn()
+				require.NotNil(t, pc)
+				require.NoError(t, pc.backendIO.Close())
+				return nil
+			},
+			backend: func(packetIO pnet.PacketIO) error {
+				// COM_RESET_CONNECTION
+				pkt, err := packetIO.ReadPacket()
+				require.NoError(t, err)
+				require.Equal(t, pnet.ComResetConnection.Byte(), pkt[0])
+				require.NoError(t, packetIO.WritePacket(pnet.MakeOKPacket(0, pnet.OKHeader), true))
+				// SET SESSION_STATES and the client request
+				for range 2 {
+					require.NoError(t, ts.respondWithNoTxn4Backend(packetIO))
+				}
+				return respondStates(packetIO)
+			},
+		},
+		// dial a new connection with the session token
+		{
+			client: ts.mc.request,
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
+				checkReleased(true)
+				// the session has unmigratable states and the connection is pinned for it
+				require.Nil(t, takeConn())
+				require.True(t, ts.mp.pooling.handoff.pinned)
+				return nil
+			},
+			backend: func(packetIO pnet.PacketIO) error {
+				require.NoError(t, ts.handshake4Backend(packetIO))
+				// SET SESSION_STATES and the client request
+				for range 2 {
+					require.NoError(t, ts.respondWithNoTxn4Backend(ts.tc.backendIO))
+				}
+				ts.mb.respondType = responseTypeErr
+				return ts.mb.respond(ts.tc.backendIO)
+			},
+		},
+		// the session gets the pinned connection back and never releases it
+		{
+			client: ts.mc.request,
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
+				checkReleased(false)
+				require.True(t, ts.mp.pooling.pinned)
+				return nil
+			},
+			backend: ts.respondWithNoTxn4Backend,
+		},
+		{
+			client: ts.mc.request,
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
+				checkReleased(false)
+				return nil
+			},
+			backend: ts.respondWithNoTxn4Backend,
+		},
+	}
+	// The backend sequence differs from the client because of the internal queries.
+	for _, runner := range runners {
+		ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+			require.NoError(t, ts.mc.err)
+			require.NoError(t, ts.mb.err)
+			require.NoError(t, ts.mp.err)
+		}, runner.client, runner.backend, runner.proxy)
+	}
+}
+
+type mockBackendTLSGetter struct {
+	labelTLS *tls.Config
+	nsTLS    *tls.Config
+	ns       string
+}
+
+func (m *mockBackendTLSGetter) BackendSQLTLS(ns string, nsTLS *config.TLSConfig, label func(name string) string) (*tls.Config, error) {
+	if label("zone") == "east" {
+		return m.labelTLS, nil
+	}
+	if nsTLS == nil {
+		return nil, nil
+	}
+	if nsTLS.SkipCA {
+		return nil, errors.New("mock error")
+	}
+	m.ns = ns
+	return m.nsTLS, nil
+}
+
+func TestGetBackendTLS(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	defaultTLS, labelTLS, nsTLS := &tls.Config{}, &tls.Config{}, &tls.Config{}
+	getter := &mockBackendTLSGetter{labelTLS: labelTLS, nsTLS: nsTLS}
+	east := &mockBackendInst{labels: map[string]string{"zone": "east"}}
+	west := &mockBackendInst{labels: map[string]string{"zone": "west"}}
+
+	// Not overridden.
+	mgr := NewBackendConnManager(lg, nil, nil, 0, &BCConfig{}, nil)
+	mgr.defaultBackendTLS = defaultTLS
+	tlsConfig, err := mgr.getBackendTLS(mgr, east)
+	require.NoError(t, err)
+	require.Same(t, defaultTLS, tlsConfig)
+
+	mgr = NewBackendConnManager(lg, nil, nil, 0, &BCConfig{BackendTLS: getter}, nil)
+	mgr.defaultBackendTLS = defaultTLS
+	tlsConfig, err = mgr.getBackendTLS(mgr, west)
+	require.NoError(t, err)
+	require.Same(t, defaultTLS, tlsConfig)
+	tlsConfig, err = mgr.getBackendTLS(mgr, east)
+	require.NoError(t, err)
+	require.Same(t, labelTLS, tlsConfig)
+
+	// Overridden by the namespace.
+	mgr.SetValue(ConnContextKeyNamespace, "ns")
+	mgr.SetValue(ConnContextKeyBackendTLS, &config.TLSConfig{CA: "ca"})
+	tlsConfig, err = mgr.getBackendTLS(mgr, west)
+	require.NoError(t, err)
+	require.Same(t, nsTLS, tlsConfig)
+	require.Equal(t, "ns", getter.ns)
+	tlsConfig, err = mgr.getBackendTLS(mgr, east)
+	require.NoError(t, err)
+	require.Same(t, labelTLS, tlsConfig)
+
+	mgr.SetValue(ConnContextKeyBackendTLS, &config.TLSConfig{SkipCA: true})
+	_, err = mgr.getBackendTLS(mgr, west)
+	require.ErrorIs(t, err, ErrProxyErr)
+}
+
+// Test that the redirection uses the TLS config of the target backend instead of the previous one.
+func TestRedirectBackendTLS(t *testing.T) {
+	var defaultConns, eastConns atomic.Int32
+	ts := newBackendMgrTester(t, func(cfg *testConfig) {
+		defaultTLS := cfg.proxyConfig.backendTLSConfig.Clone()
+		defaultTLS.VerifyConnection = func(tls.ConnectionState) error {
+			defaultConns.Add(1)
+			return nil
+		}
+		labelTLS := cfg.proxyConfig.backendTLSConfig.Clone()
+		labelTLS.VerifyConnection = func(tls.ConnectionState) error {
+			eastConns.Add(1)
+			return nil
+		}
+		cfg.proxyConfig.backendTLSConfig = defaultTLS
+		cfg.proxyConfig.bcConfig.BackendTLS = &mockBackendTLSGetter{labelTLS: labelTLS}
+	})
+	runners := []runner{
+		// 1st handshake on the backend without labels
+		{
+			client:  ts.mc.authenticate,
+			proxy:   ts.firstHandshake4Proxy,
+			backend: ts.handshake4Backend,
+		},
+		// redirect to the backend labeled with zone=east
+		{
+			proxy: func(_, _ pnet.PacketIO) error {
+				require.EqualValues(t, 1, defaultConns.Load())
+				require.EqualValues(t, 0, eastConns.Load())
+				east := newMockBackendInst(ts)
+				east.labels = map[string]string{"zone": "east"}
+				ts.mp.Redirect(east)
+				ts.mp.getEventReceiver().(*mockEventReceiver).checkEvent(t, eventSucceed)
+				require.EqualValues(t, 1, defaultConns.Load())
+				require.EqualValues(t, 1, eastConns.Load())
+				require.Same(t, east, ts.mp.currentBackend())
+				return nil
+			},
+			backend: ts.redirectSucceed4Backend,
+		},
+	}
+	ts.runTests(runners)
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"regexp"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+type certUserRule struct {
+	field   string
+	pattern *regexp.Regexp
+	user    string
+}
+
+// CertUserMapper maps the identities in client certificates to MySQL users.
+// It's immutable and is recreated once the config changes.
+type CertUserMapper struct {
+	mode  string
+	rules []certUserRule
+}
+
+// NewCertUserMapper compiles the mapping rules. It returns nil if the mapping is disabled.
+func NewCertUserMapper(cfg config.TLSConfig) (*CertUserMapper, error) {
+	if !cfg.HasCertUserMapping() {
+		return nil, nil
+	}
+	mapper := &CertUserMapper{mode: cfg.CertUserMode, rules: make([]certUserRule, 0, len(cfg.CertUserRules))}
+	for _, rule := range cfg.CertUserRules {
+		// The pattern must match the whole field and empty pattern matches any value.
+		expr := rule.Pattern
+		if len(expr) == 0 {
+			expr = ".*"
+		}
+		pattern, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		mapper.rules = append(mapper.rules, certUserRule{field: rule.Field, pattern: pattern, user: rule.User})
+	}
+	return mapper, nil
+}
+
+// mapUser returns the user to log in as. In the verify mode, the claimed user is returned if it's mapped from the
+// certificate. In the substitute mode, the first mapped user is returned.
+func (m *CertUserMapper) mapUser(claimed string, state tls.ConnectionState) (string, error) {
+	if !state.HandshakeComplete || len(state.PeerCertificates) == 0 {
+		return "", errors.New("no client certificate")
+	}
+	cert := state.PeerCertificates[0]
+	for _, rule := range m.rules {
+		for _, value := range certFieldValues(cert, rule.field) {
+			match := rule.pattern.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			user := value
+			if len(rule.user) > 0 {
+				user = string(rule.pattern.ExpandString(nil, rule.user, value, match))
+			}
+			if len(user) == 0 {
+				continue
+			}
+			if m.mode == config.CertUserModeSubstitute || user == claimed {
+				return user, nil
+			}
+		}
+	}
+	return "", errors.Errorf("user %s is not mapped from the client certificate", claimed)
+}
+
+func certFieldValues(cert *x509.Certificate, field string) []string {
+	switch field {
+	case config.CertFieldCN:
+		if len(cert.Subject.CommonName) > 0 {
+			return []string{cert.Subject.CommonName}
+		}
+	case config.CertFieldSANDNS:
+		return cert.DNSNames
+	case config.CertFieldSANEmail:
+		return cert.EmailAddresses
+	case config.CertFieldSANURI:
+		values := make([]string, 0, len(cert.URIs))
+		for _, uri := range cert.URIs {
+			values = append(values, uri.String())
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiproxy/lib/config"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertUserMapping(t *testing.T) {
+	uri, err := url.Parse("spiffe://cluster/ns/app/sa/carol")
+	require.NoError(t, err)
+	state := tls.ConnectionState{
+		HandshakeComplete: true,
+		PeerCertificates: []*x509.Certificate{{
+			Subject:        pkix.Name{CommonName: "app-alice"},
+			DNSNames:       []string{"bob.example.com"},
+			EmailAddresses: []string{"dave@example.com"},
+			URIs:           []*url.URL{uri},
+		}},
+	}
+	rules := []config.CertUserRule{
+		{Field: config.CertFieldCN, Pattern: "app-(.+)", User: "$1"},
+		{Field: config.CertFieldSANDNS, Pattern: `(\w+)\.example\.com`, User: "$1"},
+		{Field: config.CertFieldSANURI, Pattern: "spiffe://cluster/ns/app/sa/(.+)", User: "${1}_svc"},
+		{Field: config.CertFieldSANEmail},
+		// The pattern must match the whole field.
+		{Field: config.CertFieldCN, Pattern: "app"},
+	}
+
+	mapper, err := NewCertUserMapper(config.TLSConfig{})
+	require.NoError(t, err)
+	require.Nil(t, mapper)
+
+	mapper, err = NewCertUserMapper(config.TLSConfig{CertUserMode: config.CertUserModeVerify, CertUserRules: rules})
+	require.NoError(t, err)
+	for _, user := range []string{"alice", "bob", "carol_svc", "dave@example.com"} {
+		mapped, err := mapper.mapUser(user, state)
+		require.NoError(t, err, user)
+		require.Equal(t, user, mapped)
+	}
+	for _, user := range []string{"root", "app", "app-alice", "carol"} {
+		_, err := mapper.mapUser(user, state)
+		require.Error(t, err, user)
+	}
+	_, err = mapper.mapUser("alice", tls.ConnectionState{})
+	require.Error(t, err)
+	_, err = mapper.mapUser("alice", tls.ConnectionState{HandshakeComplete: true})
+	require.Error(t, err)
+
+	// The first mapped user is used.
+	mapper, err = NewCertUserMapper(config.TLSConfig{CertUserMode: config.CertUserModeSubstitute, CertUserRules: rules})
+	require.NoError(t, err)
+	mapped, err := mapper.mapUser("root", state)
+	require.NoError(t, err)
+	require.Equal(t, "alice", mapped)
+	mapper, err = NewCertUserMapper(config.TLSConfig{CertUserMode: config.CertUserModeSubstitute, CertUserRules: rules[4:]})
+	require.NoError(t, err)
+	_, err = mapper.mapUser("root", state)
+	require.Error(t, err)
+}
+
+func createClientCertForTest(t *testing.T, cn string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCertUserHandshake(t *testing.T) {
+	tc := newTCPConnSuite(t)
+	frontendTLSConfig := tc.backendTLSConfig.Clone()
+	frontendTLSConfig.ClientAuth = tls.RequireAnyClientCert
+	clientTLSConfig := tc.clientTLSConfig.Clone()
+	clientTLSConfig.Certificates = []tls.Certificate{createClientCertForTest(t, "app-alice")}
+	rules := []config.CertUserRule{{Field: config.CertFieldCN, Pattern: "app-(.+)", User: "$1"}}
+
+	tests := []struct {
+		mode        string
+		user        string
+		noTLS       bool
+		backendUser string
+	}{
+		{mode: config.CertUserModeVerify, user: "alice", backendUser: "alice"},
+		{mode: config.CertUserModeVerify, user: "bob"},
+		{mode: config.CertUserModeSubstitute, user: "root", backendUser: "alice"},
+		{mode: config.CertUserModeVerify, user: "alice", noTLS: true},
+		{mode: config.CertUserModeSubstitute, user: "root", noTLS: true},
+	}
+	for i, test := range tests {
+		mapper, err := NewCertUserMapper(config.TLSConfig{CertUserMode: test.mode, CertUserRules: rules})
+		require.NoError(t, err)
+		ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+			cfg.proxyConfig.bcConfig.CertUserMapper = mapper
+			cfg.proxyConfig.frontendTLSConfig = frontendTLSConfig
+			cfg.clientConfig.tlsConfig = clientTLSConfig
+			cfg.clientConfig.username = test.user
+			if test.noTLS {
+				cfg.clientConfig.capability &^= pnet.ClientSSL
+			}
+		})
+		msg := fmt.Sprintf("case %d", i)
+		if len(test.backendUser) > 0 {
+			ts.authenticateFirstTime(t, func(t *testing.T, ts *testSuite) {
+				require.NoError(t, ts.mp.err, msg)
+				require.Equal(t, test.backendUser, ts.mb.username, msg)
+				require.Equal(t, test.backendUser, ts.mp.authenticator.user, msg)
+			})
+		} else {
+			// The proxy rejects the client without connecting to the backend.
+			ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+				require.NoError(t, ts.mc.err, msg)
+				require.False(t, ts.mc.authSucceed, msg)
+				var myErr *mysql.MyError
+				require.ErrorAs(t, ts.mc.mysqlErr, &myErr, msg)
+				require.EqualValues(t, mysql.ER_ACCESS_DENIED_ERROR, myErr.Code, msg)
+				require.ErrorIs(t, ts.mp.err, ErrClientAuthFail, msg)
+			}, ts.mc.authenticate, nil, ts.mp.authenticateFirstTime)
+		}
+		clean()
+	}
+}
+
+func TestCertUserChangeUser(t *testing.T) {
+	tc := newTCPConnSuite(t)
+	frontendTLSConfig := tc.backendTLSConfig.Clone()
+	frontendTLSConfig.ClientAuth = tls.RequireAnyClientCert
+	clientTLSConfig := tc.clientTLSConfig.Clone()
+	clientTLSConfig.Certificates = []tls.Certificate{createClientCertForTest(t, "app-alice")}
+	rules := []config.CertUserRule{{Field: config.CertFieldCN, Pattern: "app-(.+)", User: "$1"}}
+
+	tests := []struct {
+		mode        string
+		user        string
+		backendUser string
+	}{
+		{mode: config.CertUserModeVerify, user: "alice", backendUser: "alice"},
+		{mode: config.CertUserModeVerify, user: "bob"},
+		{mode: config.CertUserModeSubstitute, user: "root", backendUser: "alice"},
+	}
+	for i, test := range tests {
+		mapper, err := NewCertUserMapper(config.TLSConfig{CertUserMode: test.mode, CertUserRules: rules})
+		require.NoError(t, err)
+		ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+			cfg.proxyConfig.bcConfig.CertUserMapper = mapper
+			cfg.proxyConfig.frontendTLSConfig = frontendTLSConfig
+			cfg.clientConfig.tlsConfig = clientTLSConfig
+			cfg.clientConfig.username = "alice"
+		})
+		msg := fmt.Sprintf("case %d", i)
+		ts.authenticateFirstTime(t, func(t *testing.T, ts *testSuite) {
+			require.NoError(t, ts.mp.err, msg)
+		})
+		ts.mc.cmd = pnet.ComChangeUser
+		ts.mc.username = test.user
+		ts.mb.respondType = responseTypeOK
+		if len(test.backendUser) > 0 {
+			ts.executeCmd(t, func(t *testing.T, ts *testSuite) {
+				require.NoError(t, ts.mp.err, msg)
+				require.Nil(t, ts.mc.mysqlErr, msg)
+				require.Equal(t, test.backendUser, ts.mb.username, msg)
+				require.Equal(t, test.backendUser, ts.mp.cmdProcessor.user, msg)
+			})
+		} else {
+			// The proxy rejects the user without forwarding it to the backend and the connection is kept.
+			ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+				require.NoError(t, ts.mc.err, msg)
+				var myErr *mysql.MyError
+				require.ErrorAs(t, ts.mc.mysqlErr, &myErr, msg)
+				require.EqualValues(t, mysql.ER_ACCESS_DENIED_ERROR, myErr.Code, msg)
+				require.True(t, pnet.IsMySQLError(ts.mp.err), msg)
+			}, ts.mc.request, nil, ts.mp.processCmd)
+		}
+		clean()
+	}
+}
@@ -5,6 +5,7 @@ package backend
 
 import (
 	"encoding/binary"
+	"time"
 
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
 	"go.uber.org/zap"
@@ -25,6 +26,35 @@ type CmdProcessor struct {
 	// Only includes in_trans or quit status.
 	serverStatus uint32
 	logger       *zap.Logger
+	// resultCache is shared by all the connections. The session info below identifies the cached results.
+	resultCache *ResultCache
+	namespace   string
+	user        string
+	currentDB   string
+	collation   uint8
+	// dbUnknown means the current database may be changed by multi-statements, so the results are not cached.
+	dbUnknown bool
+	// varsChanged means the session variables may differ from the handshake, so the results are not cached.
+	varsChanged bool
+	// autocommitOff means every statement starts a transaction, whose results may differ from others.
+	autocommitOff bool
+	// stmtTimeout is the statement timeout of the session and killQuery kills the running statement.
+	// killQuery is nil if the statement can't be killed.
+	stmtTimeout time.Duration
+	killQuery   func() error
+	// loadDataPolicy restricts LOAD DATA LOCAL INFILE of the session and loadDataMemory limits the held files.
+	loadDataPolicy loadDataPolicy
+	loadDataMemory *LoadDataMemory
+	// certUserMapper maps the users of COM_CHANGE_USER the same as the handshake. It's nil if the mapping is disabled.
+	certUserMapper *CertUserMapper
+	// slowLog is shared by all the connections and connID identifies the connection in the slow log.
+	slowLog  *SlowLog
+	sqlStats *SQLStats
+	connID   uint64
+	// preparedStmts is the digests of the prepared statements. It's only tracked when the commands are recorded.
+	preparedStmts map[uint32]stmtDigest
+	// sentRows is the total number of result rows sent to the client.
+	sentRows uint64
 }
 
 func NewCmdProcessor(logger *zap.Logger) *CmdProcessor {
@@ -57,6 +87,7 @@ func (cp *CmdProcessor) updateServerStatus(request []byte, serverStatus uint16)
 }
 
 func (cp *CmdProcessor) updateTxnStatus(serverStatus uint16) {
+	cp.autocommitOff = serverStatus&pnet.ServerStatusAutocommit == 0
 	if serverStatus&pnet.ServerStatusInTrans > 0 {
 		cp.serverStatus |= StatusInTrans
 	} else {
@@ -75,6 +106,7 @@ func (cp *CmdProcessor) updatePrepStmtStatus(request []byte, serverStatus uint16
 		stmtID = int(binary.LittleEndian.Uint32(request[1:5]))
 	case pnet.ComResetConnection, pnet.ComChangeUser:
 		cp.preparedStmtStatus = make(map[int]uint32)
+		clear(cp.preparedStmts)
 		return
 	default:
 		return
@@ -91,6 +123,9 @@ func (cp *CmdProcessor) updatePrepStmtStatus(request []byte, serverStatus uint16
 			prepStmtStatus = StatusPrepareWaitFetch
 		}
 	}
+	if cmd == pnet.ComStmtClose {
+		delete(cp.preparedStmts, uint32(stmtID))
+	}
 	if prepStmtStatus > 0 {
 		cp.preparedStmtStatus[stmtID] = prepStmtStatus
 	} else {
@@ -0,0 +1,166 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/util/lex"
+)
+
+// setSession sets the session info that identifies the cached results.
+func (cp *CmdProcessor) setSession(user, db string, collation uint8) {
+	cp.user, cp.currentDB, cp.collation = user, db, collation
+	// COM_CHANGE_USER also resets the session variables.
+	cp.dbUnknown, cp.varsChanged = false, false
+}
+
+// trackSession updates the current database and whether the session variables are changed after the command succeeds.
+func (cp *CmdProcessor) trackSession(request []byte) {
+	if cp.resultCache == nil {
+		return
+	}
+	switch pnet.Command(request[0]) {
+	case pnet.ComInitDB:
+		cp.currentDB, cp.dbUnknown = string(request[1:]), false
+	case pnet.ComQuery:
+		sql := pnet.ParseQueryPacket(request[1:])
+		multiStmts := cp.capability&pnet.ClientMultiStatements > 0 && strings.Contains(sql, ";")
+		if db, ok := lex.UseDB(sql); ok {
+			cp.currentDB, cp.dbUnknown = db, false
+		} else if multiStmts && lex.ContainsKeyword(sql, "USE") {
+			// The database may be changed in the middle of multi-statements. Stop caching until it's known.
+			cp.dbUnknown = true
+		}
+		// The packets depend on character_set_results and the results may depend on other variables, such as
+		// collation_connection, sql_mode and time_zone. They are seldom changed, so just stop caching instead of
+		// adding them to the key.
+		if lex.ChangesSessionVars(sql) || (multiStmts && lex.ContainsKeyword(sql, "SET")) {
+			cp.varsChanged = true
+		}
+	}
+}
+
+// matchResultCache returns the cache key and the TTL if the request should be served by the result cache.
+func (cp *CmdProcessor) matchResultCache(request []byte, stmt *sqlStmt) (key resultCacheKey, ttl time.Duration, ok bool) {
+	if pnet.Command(request[0]) != pnet.ComQuery || !cp.resultCache.Enabled() || cp.dbUnknown || cp.varsChanged {
+		return
+	}
+	// The results in a transaction may differ from others, and a cached result doesn't start a transaction.
+	if cp.serverStatus&StatusInTrans > 0 || cp.autocommitOff {
+		return
+	}
+	if ttl, ok = cp.resultCache.match(stmt); !ok {
+		return
+	}
+	key = resultCacheKey{
+		namespace:  cp.namespace,
+		user:       cp.user,
+		db:         cp.currentDB,
+		sql:        stmt.sql,
+		collation:  cp.collation,
+		capability: cp.capability & pnet.ClientDeprecateEOF,
+	}
+	return
+}
+
+// cachingClientIO keeps the packets sent to the client so that the result can be cached. It doesn't implement
+// PacketIOWrapper, so ForwardUntil writes every packet through it instead of copying the data directly.
+type cachingClientIO struct {
+	pnet.PacketIO
+	packets   [][]byte
+	size      int
+	sizeLimit int
+	oversized bool
+}
+
+func (cio *cachingClientIO) WritePacket(data []byte, flush bool) error {
+	if !cio.oversized {
+		if cio.size += len(data); cio.size > cio.sizeLimit {
+			cio.oversized, cio.packets = true, nil
+		} else {
+			cio.packets = append(cio.packets, data)
+		}
+	}
+	return cio.PacketIO.WritePacket(data, flush)
+}
+
+// forwardCachedQuery replays the cached result-set packets, or forwards the query and caches the result.
+func (cp *CmdProcessor) forwardCachedQuery(clientIO, backendIO pnet.PacketIO, request []byte, key resultCacheKey, ttl time.Duration) error {
+	if packets, ok := cp.resultCache.get(key); ok {
+		for _, pkt := range packets {
+			if err := clientIO.WritePacket(pkt, false); err != nil {
+				return err
+			}
+		}
+		cp.sentRows += cp.cachedRows(packets)
+		return clientIO.Flush()
+	}
+
+	if err := backendIO.WritePacket(request, true); err != nil {
+		return err
+	}
+	cio := &cachingClientIO{PacketIO: clientIO, sizeLimit: cp.resultCache.resultSizeLimit()}
+	if err := cp.forwardQueryCmd(cio, backendIO, request); err != nil {
+		return err
+	}
+	if cio.oversized || cp.autocommitOff {
+		return nil
+	}
+	// Only a single result set out of a transaction is cached, excluding OK packets and multi-statements.
+	if status, ok := cp.singleResultSet(cio.packets); ok && status&(pnet.ServerMoreResultsExists|pnet.ServerStatusInTrans) == 0 {
+		cp.resultCache.put(key, cio.packets, ttl)
+	}
+	return nil
+}
+
+// singleResultSet returns the status of the end packet if the packets are exactly one complete result set.
+func (cp *CmdProcessor) singleResultSet(packets [][]byte) (uint16, bool) {
+	if len(packets) == 0 {
+		return 0, false
+	}
+	switch packets[0][0] {
+	case pnet.OKHeader.Byte(), pnet.ErrHeader.Byte(), pnet.LocalInFileHeader.Byte():
+		return 0, false
+	}
+	// Skip the column count, the columns, and the EOF of columns if EOF is not deprecated.
+	columns, _, _ := pnet.ParseLengthEncodedInt(packets[0])
+	idx := 1 + int(columns)
+	if cp.capability&pnet.ClientDeprecateEOF == 0 {
+		idx++
+	}
+	for ; idx < len(packets); idx++ {
+		pkt := packets[idx]
+		var status uint16
+		switch {
+		case pnet.IsErrorPacket(pkt[0]):
+			return 0, false
+		case cp.capability&pnet.ClientDeprecateEOF == 0 && pnet.IsEOFPacket(pkt[0], len(pkt)):
+			status = binary.LittleEndian.Uint16(pkt[3:])
+		case cp.capability&pnet.ClientDeprecateEOF > 0 && pnet.IsResultSetOKPacket(pkt[0], len(pkt)):
+			status = pnet.ParseOKPacket(pkt)
+		default:
+			continue
+		}
+		return status, idx == len(packets)-1
+	}
+	return 0, false
+}
+
+// cachedRows returns the number of rows in the cached result set.
+func (cp *CmdProcessor) cachedRows(packets [][]byte) uint64 {
+	if len(packets) == 0 {
+		return 0
+	}
+	// The column count, the columns, the EOF of columns if EOF is not deprecated, and the end packet.
+	columns, _, _ := pnet.ParseLengthEncodedInt(packets[0])
+	others := columns + 2
+	if cp.capability&pnet.ClientDeprecateEOF == 0 {
+		others++
+	}
+	return uint64(len(packets)) - min(uint64(len(packets)), others)
+}
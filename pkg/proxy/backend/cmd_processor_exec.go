@@ -15,9 +15,10 @@ import (
 )
 
 // executeCmd forwards requests and responses between the client and the backend.
+// stmt is the statement in the request. It's parsed from the request if it's nil.
 // holdRequest: should the proxy send the request to the new backend.
 // err: unexpected errors or MySQL errors.
-func (cp *CmdProcessor) executeCmd(request []byte, clientIO, backendIO pnet.PacketIO, waitingRedirect bool) (holdRequest bool, err error) {
+func (cp *CmdProcessor) executeCmd(request []byte, stmt *sqlStmt, clientIO, backendIO pnet.PacketIO, waitingRedirect bool) (holdRequest bool, err error) {
 	backendIO.ResetSequence()
 	if waitingRedirect && cp.needHoldRequest(request) {
 		var response []byte
@@ -33,11 +34,36 @@ func (cp *CmdProcessor) executeCmd(request []byte, clientIO, backendIO pnet.Pack
 		}
 		return true, err
 	}
-	return false, cp.forwardCommand(clientIO, backendIO, request)
+	if stmt == nil {
+		stmt = newSQLStmt(request)
+	}
+	if cp.recordEnabled() {
+		finish := cp.startCmdRecord(request, stmt, clientIO, backendIO)
+		defer func() {
+			finish(err)
+		}()
+	}
+	if cp.killQuery != nil && cp.needStmtTimer(request) {
+		var stop func()
+		clientIO, stop = cp.startStmtTimer(clientIO)
+		defer stop()
+	}
+	if key, ttl, ok := cp.matchResultCache(request, stmt); ok {
+		return false, cp.forwardCachedQuery(clientIO, backendIO, request, key, ttl)
+	}
+	if err = cp.forwardCommand(clientIO, backendIO, request, stmt); err == nil {
+		cp.trackSession(request)
+	}
+	return false, err
 }
 
-func (cp *CmdProcessor) forwardCommand(clientIO, backendIO pnet.PacketIO, request []byte) error {
+func (cp *CmdProcessor) forwardCommand(clientIO, backendIO pnet.PacketIO, request []byte, stmt *sqlStmt) error {
 	cmd := pnet.Command(request[0])
+	if cmd == pnet.ComQuery && cp.loadDataPolicy.disable && stmt != nil {
+		if loads, _ := lex.LoadsLocalFile(stmt.sql); loads {
+			return cp.rejectLoadStmt(clientIO, backendIO)
+		}
+	}
 	// ComChangeUser is special: we need to modify the packet before forwarding.
 	if cmd != pnet.ComChangeUser {
 		if err := backendIO.WritePacket(request, true); err != nil {
@@ -46,7 +72,7 @@ func (cp *CmdProcessor) forwardCommand(clientIO, backendIO pnet.PacketIO, reques
 	}
 	switch cmd {
 	case pnet.ComStmtPrepare:
-		return cp.forwardPrepareCmd(clientIO, backendIO)
+		return cp.forwardPrepareCmd(clientIO, backendIO, stmt)
 	case pnet.ComStmtFetch:
 		return cp.forwardFetchCmd(clientIO, backendIO, request)
 	case pnet.ComQuery, pnet.ComStmtExecute, pnet.ComProcessInfo:
@@ -95,17 +121,24 @@ func forwardOnePacket(destIO, srcIO pnet.PacketIO, flush bool) (data []byte, err
 	return data, destIO.WritePacket(data, flush)
 }
 
-func (cp *CmdProcessor) forwardUntilResultEnd(clientIO, backendIO pnet.PacketIO, request []byte) (uint16, error) {
+// forwardUntilResultEnd forwards the packets until the end of the result and returns the number of packets
+// before the end packet.
+func (cp *CmdProcessor) forwardUntilResultEnd(clientIO, backendIO pnet.PacketIO, request []byte) (uint16, uint64, error) {
 	var serverStatus uint16
+	var packets uint64
 	err := backendIO.ForwardUntil(clientIO, func(firstByte byte, length int) (end, needData bool) {
 		switch {
 		case pnet.IsErrorPacket(firstByte):
-			return true, true
+			end = true
 		case cp.capability&pnet.ClientDeprecateEOF == 0:
-			return pnet.IsEOFPacket(firstByte, length), true
+			end = pnet.IsEOFPacket(firstByte, length)
 		default:
-			return pnet.IsResultSetOKPacket(firstByte, length), true
+			end = pnet.IsResultSetOKPacket(firstByte, length)
 		}
+		if !end {
+			packets++
+		}
+		return end, true
 	}, func(response []byte) error {
 		switch {
 		case pnet.IsErrorPacket(response[0]):
@@ -121,16 +154,19 @@ func (cp *CmdProcessor) forwardUntilResultEnd(clientIO, backendIO pnet.PacketIO,
 			return clientIO.Flush()
 		}
 	})
-	return serverStatus, err
+	return serverStatus, packets, err
 }
 
-func (cp *CmdProcessor) forwardPrepareCmd(clientIO, backendIO pnet.PacketIO) error {
+func (cp *CmdProcessor) forwardPrepareCmd(clientIO, backendIO pnet.PacketIO, stmt *sqlStmt) error {
 	response, err := forwardOnePacket(clientIO, backendIO, false)
 	if err != nil {
 		return err
 	}
 	switch response[0] {
 	case pnet.OKHeader.Byte():
+		if cp.preparedStmts != nil {
+			cp.preparedStmts[binary.LittleEndian.Uint32(response[1:5])] = stmt.stmtDigest()
+		}
 		// The OK packet doesn't contain a server status.
 		// See https://mariadb.com/kb/en/com_stmt_prepare/
 		numColumns := binary.LittleEndian.Uint16(response[5:])
@@ -167,27 +203,30 @@ func (cp *CmdProcessor) forwardPrepareCmd(clientIO, backendIO pnet.PacketIO) err
 }
 
 func (cp *CmdProcessor) forwardFetchCmd(clientIO, backendIO pnet.PacketIO, request []byte) error {
-	_, err := cp.forwardUntilResultEnd(clientIO, backendIO, request)
+	// Only rows are sent for COM_STMT_FETCH.
+	_, rows, err := cp.forwardUntilResultEnd(clientIO, backendIO, request)
+	cp.sentRows += rows
 	return err
 }
 
 func (cp *CmdProcessor) forwardFieldListCmd(clientIO, backendIO pnet.PacketIO, request []byte) error {
-	_, err := cp.forwardUntilResultEnd(clientIO, backendIO, request)
+	_, _, err := cp.forwardUntilResultEnd(clientIO, backendIO, request)
 	return err
 }
 
 func (cp *CmdProcessor) forwardQueryCmd(clientIO, backendIO pnet.PacketIO, request []byte) error {
+	// The LOCAL INFILE request is not sent to the client if it's disabled.
+	destIO := clientIO
+	if cp.loadDataPolicy.disable {
+		destIO = &rejectInFileIO{PacketIO: clientIO}
+	}
 	for {
 		var serverStatus uint16
 		var first byte
-		err := backendIO.ForwardUntil(clientIO, func(firstByte byte, _ int) (end, needData bool) {
+		err := backendIO.ForwardUntil(destIO, func(firstByte byte, _ int) (end, needData bool) {
 			first = firstByte
-			switch firstByte {
-			case pnet.OKHeader.Byte(), pnet.ErrHeader.Byte():
-				return true, true
-			default:
-				return true, false
-			}
+			// The column count of the result set is also read to count the rows.
+			return true, true
 		}, func(response []byte) error {
 			var err error
 			switch first {
@@ -201,9 +240,10 @@ func (cp *CmdProcessor) forwardQueryCmd(clientIO, backendIO pnet.PacketIO, reque
 				// Subsequent statements won't be executed even if it's a multi-statement.
 				return cp.handleErrorPacket(response)
 			case pnet.LocalInFileHeader.Byte():
-				serverStatus, err = cp.forwardLoadInFile(clientIO, backendIO, request)
+				serverStatus, err = cp.forwardLoadInFile(clientIO, backendIO, request, response)
 			default:
-				serverStatus, err = cp.forwardResultSet(clientIO, backendIO, request)
+				columns, _, _ := pnet.ParseLengthEncodedInt(response)
+				serverStatus, err = cp.forwardResultSet(clientIO, backendIO, request, columns)
 			}
 			return err
 		})
@@ -218,39 +258,7 @@ func (cp *CmdProcessor) forwardQueryCmd(clientIO, backendIO pnet.PacketIO, reque
 	return nil
 }
 
-func (cp *CmdProcessor) forwardLoadInFile(clientIO, backendIO pnet.PacketIO, request []byte) (serverStatus uint16, err error) {
-	if err = clientIO.Flush(); err != nil {
-		return
-	}
-	// The client sends file data until an empty packet.
-	for {
-		var data []byte
-		// Do not call PacketIO.ForwardUntil. It peeks 5 bytes but there may be only 4 bytes here.
-		if data, err = forwardOnePacket(backendIO, clientIO, false); err != nil {
-			return
-		}
-		if len(data) == 0 {
-			if err := backendIO.Flush(); err != nil {
-				return 0, err
-			}
-			break
-		}
-	}
-	var response []byte
-	if response, err = forwardOnePacket(clientIO, backendIO, true); err != nil {
-		return
-	}
-	switch response[0] {
-	case pnet.OKHeader.Byte():
-		return cp.handleOKPacket(request, response), nil
-	case pnet.ErrHeader.Byte():
-		return serverStatus, cp.handleErrorPacket(response)
-	}
-	// impossible here
-	return serverStatus, errors.Errorf("unexpected response, cmd:%d resp:%d", pnet.ComQuery, response[0])
-}
-
-func (cp *CmdProcessor) forwardResultSet(clientIO, backendIO pnet.PacketIO, request []byte) (uint16, error) {
+func (cp *CmdProcessor) forwardResultSet(clientIO, backendIO pnet.PacketIO, request []byte, columns uint64) (uint16, error) {
 	if cp.capability&pnet.ClientDeprecateEOF == 0 {
 		var serverStatus uint16
 		// read columns
@@ -271,7 +279,13 @@ func (cp *CmdProcessor) forwardResultSet(clientIO, backendIO pnet.PacketIO, requ
 		}
 	}
 	// Deprecate EOF or no cursor.
-	return cp.forwardUntilResultEnd(clientIO, backendIO, request)
+	serverStatus, packets, err := cp.forwardUntilResultEnd(clientIO, backendIO, request)
+	// The columns are forwarded together with the rows if EOF is deprecated.
+	if cp.capability&pnet.ClientDeprecateEOF > 0 {
+		packets -= min(packets, columns)
+	}
+	cp.sentRows += packets
+	return serverStatus, err
 }
 
 func (cp *CmdProcessor) forwardCloseCmd(request []byte) error {
@@ -295,6 +309,16 @@ func (cp *CmdProcessor) forwardChangeUserCmd(clientIO, backendIO pnet.PacketIO,
 			return mysql.ErrMalformPacket
 		}
 	}
+	// Otherwise, the client can switch to any user after logging in with a valid certificate.
+	if cp.certUserMapper != nil {
+		user, err := cp.certUserMapper.mapUser(req.User, clientIO.TLSConnectionState())
+		if err != nil {
+			cp.logger.Warn("client certificate doesn't match the user", zap.String("user", req.User), zap.Error(err))
+			// The error contains the MySQL error, so the connection is kept, the same as the backend rejecting it.
+			return writeAccessDenied(clientIO, req.User, len(req.AuthData) > 0)
+		}
+		req.User = user
+	}
 	// The client may use the TiProxy salt to generate the auth data instead of using the TiDB salt,
 	// so we need another switch-auth request to pass the TiDB salt to the client.
 	// See https://github.com/pingcap/tiproxy/issues/127.
@@ -312,6 +336,11 @@ func (cp *CmdProcessor) forwardChangeUserCmd(clientIO, backendIO pnet.PacketIO,
 		switch response[0] {
 		case pnet.OKHeader.Byte():
 			cp.handleOKPacket(request, response)
+			collation := cp.collation
+			if len(req.Charset) > 0 {
+				collation = req.Charset[0]
+			}
+			cp.setSession(req.User, req.DB, collation)
 			return nil
 		case pnet.ErrHeader.Byte():
 			return cp.handleErrorPacket(response)
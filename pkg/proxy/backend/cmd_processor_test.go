@@ -70,6 +70,10 @@ func TestForwardCommands(t *testing.T) {
 	runTest := func(cfgs ...cfgOverrider) {
 		ts, clean := newTestSuite(t, tc, cfgs...)
 		ts.executeCmd(t, nil)
+		// Only columns are sent if a cursor exists.
+		if ts.mb.respondType == responseTypeRow || (ts.mb.respondType == responseTypeResultSet && ts.mb.status&pnet.ServerStatusCursorExists == 0) {
+			require.EqualValues(t, ts.mb.rows, ts.mp.cmdProcessor.sentRows)
+		}
 		clean()
 	}
 	// Test every respond type for every command.
@@ -0,0 +1,100 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"encoding/binary"
+	"time"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+)
+
+// The normalized SQL in the records is truncated to avoid huge logs and memory.
+const maxRecordQueryLen = 4096
+
+// stmtDigest is the normalized SQL and the digest of a statement.
+type stmtDigest struct {
+	normalized string
+	digest     string
+}
+
+// cmdRecord is the execution record of a command, which is used by the slow log and the SQL statistics.
+// The command starts when the client request is received and ends after the last response is sent to the client.
+// The wait time is spent in the proxy before forwarding the request, e.g. waiting for redirection or a pooled connection.
+type cmdRecord struct {
+	cmd         pnet.Command
+	stmt        stmtDigest
+	backendAddr string
+	startTime   time.Time
+	forwardTime time.Time
+	endTime     time.Time
+	outBytes    uint64
+	outPackets  uint64
+	rows        uint64
+	err         error
+}
+
+func (r *cmdRecord) queryTime() time.Duration {
+	return r.endTime.Sub(r.startTime)
+}
+
+func (cp *CmdProcessor) recordEnabled() bool {
+	return cp.slowLog.Enabled() || cp.sqlStats.Enabled()
+}
+
+// startCmdRecord records the start of the command and returns the function to call after the command finishes.
+func (cp *CmdProcessor) startCmdRecord(request []byte, stmt *sqlStmt, clientIO, backendIO pnet.PacketIO) func(err error) {
+	if cp.preparedStmts == nil {
+		cp.preparedStmts = make(map[uint32]stmtDigest)
+	}
+	forwardTime := time.Now()
+	// The clients of traffic replay don't record the read time.
+	var startTime time.Time
+	if rt, ok := clientIO.(pnet.ReadTimer); ok {
+		startTime = rt.LastReadTime()
+	}
+	if startTime.IsZero() || startTime.After(forwardTime) {
+		startTime = forwardTime
+	}
+	outBytes, outPackets, rows := clientIO.OutBytes(), clientIO.OutPackets(), cp.sentRows
+	return func(err error) {
+		record := &cmdRecord{
+			cmd:         pnet.Command(request[0]),
+			backendAddr: backendIO.RemoteAddr().String(),
+			startTime:   startTime,
+			forwardTime: forwardTime,
+			endTime:     time.Now(),
+			outBytes:    clientIO.OutBytes() - outBytes,
+			outPackets:  clientIO.OutPackets() - outPackets,
+			rows:        cp.sentRows - rows,
+			err:         err,
+		}
+		isStmt := cp.sqlStats.Enabled()
+		isSlow := cp.slowLog.isSlow(record.queryTime())
+		if !isStmt && !isSlow {
+			return
+		}
+		record.stmt = cp.requestDigest(request, stmt)
+		if isStmt && len(record.stmt.digest) > 0 {
+			cp.sqlStats.add(cp.user, record)
+		}
+		if isSlow {
+			cp.logSlowCmd(record)
+		}
+	}
+}
+
+// requestDigest returns the digest of the statement in the request, or an empty one if it's not a statement.
+func (cp *CmdProcessor) requestDigest(request []byte, stmt *sqlStmt) stmtDigest {
+	if stmt != nil {
+		return stmt.stmtDigest()
+	}
+	switch pnet.Command(request[0]) {
+	case pnet.ComStmtExecute, pnet.ComStmtFetch:
+		if len(request) >= 5 {
+			return cp.preparedStmts[binary.LittleEndian.Uint32(request[1:5])]
+		}
+	}
+	return stmtDigest{}
+}
@@ -66,7 +66,7 @@ func (tc *tcpConnSuite) newConn(t *testing.T, enableRoute bool) func() {
 		tc.proxyCIO = pnet.NewPacketIO(clientConn, lg, pnet.DefaultConnBufferSize, pnet.WithWrapError(ErrClientConn))
 	})
 	wg.Run(func() {
-		conn, err := net.Dial("tcp", tc.proxyListener.Addr().String())
+		conn, err := net.Dial(tc.proxyListener.Addr().Network(), tc.proxyListener.Addr().String())
 		require.NoError(t, err)
 		tc.clientIO = pnet.NewPacketIO(conn, lg, pnet.DefaultConnBufferSize)
 	})
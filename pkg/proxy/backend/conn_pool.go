@@ -0,0 +1,534 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/metrics"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/siddontang/go/hack"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultPoolMaxIdleConns = 16
+	defaultPoolIdleTimeout  = 10 * time.Minute
+	// sessionTokenRefreshInterval should be shorter than the lifetime of the session token signed by the backend.
+	// Each session adds a jitter of up to half of the interval so that the sessions don't refresh at the same time.
+	sessionTokenRefreshInterval = 30 * time.Second
+	// maxTokenRefreshRate limits the token refreshes of all the sessions per second to protect the backends.
+	maxTokenRefreshRate = 100
+)
+
+const (
+	// The released session gets its previous connection back, which needs no reset.
+	acquireTypeOwned = "owned"
+	// The released session gets a connection from another session, which needs to be reset.
+	acquireTypeReset = "reset"
+	// No connection is available and the session dials a new one.
+	acquireTypeDial = "dial"
+)
+
+var errPoolClosed = errors.New("connection pool is closed")
+
+// poolKey groups the pooled connections. A connection can only serve the sessions that authenticate as the same user
+// with the same capability on the same backend.
+type poolKey struct {
+	addr       string
+	user       string
+	capability pnet.Capability
+}
+
+// sessionHandoff keeps the session states of a released session. The states are only queried when the connection
+// is handed to another session or closed, so a session that gets its own connection back doesn't pay for them.
+type sessionHandoff struct {
+	// done is closed once the states are saved or saving them fails.
+	done          chan struct{}
+	capability    pnet.Capability
+	sessionStates string
+	sessionToken  string
+	queryTime     time.Time
+	err           error
+	// pinned means the states are unmigratable and the connection is kept for the owner.
+	pinned bool
+	// applied means the owner has updated its auth info from the states.
+	applied bool
+}
+
+func newSessionHandoff(capability pnet.Capability) *sessionHandoff {
+	return &sessionHandoff{done: make(chan struct{}), capability: capability}
+}
+
+// save queries the session states on the connection of the owner.
+func (h *sessionHandoff) save(backendIO pnet.PacketIO) error {
+	cp := NewCmdProcessor(zap.NewNop())
+	cp.capability = h.capability
+	sessionStates, sessionToken, err := querySessionStates(cp, backendIO)
+	if err == nil && len(sessionToken) == 0 {
+		err = errors.New("session token is empty")
+	}
+	if err == nil {
+		h.sessionStates, h.sessionToken, h.queryTime = sessionStates, sessionToken, time.Now()
+	}
+	return err
+}
+
+// ready returns whether the states are saved successfully.
+func (h *sessionHandoff) ready() bool {
+	select {
+	case <-h.done:
+		return h.err == nil
+	default:
+		return false
+	}
+}
+
+type pooledConn struct {
+	backendIO pnet.PacketIO
+	connID    uint64
+	// owner is the connection ID of the session that released it last time.
+	owner uint64
+	// handoff is not nil if the states of the owner haven't been saved.
+	handoff   *sessionHandoff
+	idleSince time.Time
+	// pinned means the connection can only serve the owner.
+	pinned bool
+}
+
+// ConnPool keeps the idle backend connections released by the sessions in the transaction-level pooling mode.
+// It's shared by all the BackendConnManagers.
+type ConnPool struct {
+	sync.Mutex
+	idle map[poolKey][]*pooledConn
+	// pinned keeps the connections of the sessions that have unmigratable states. They are never evicted.
+	pinned         map[uint64]*pooledConn
+	refreshLimiter *rate.Limiter
+	enable         bool
+	maxIdle        int
+	idleTimeout    time.Duration
+	closed         bool
+}
+
+// NewConnPool creates a ConnPool.
+func NewConnPool(cfg config.ConnPool) *ConnPool {
+	pool := &ConnPool{
+		idle:           make(map[poolKey][]*pooledConn),
+		pinned:         make(map[uint64]*pooledConn),
+		refreshLimiter: rate.NewLimiter(maxTokenRefreshRate, maxTokenRefreshRate),
+	}
+	pool.SetConfig(cfg)
+	return pool
+}
+
+// SetConfig updates the config. The idle connections are closed once the pool is disabled.
+func (pool *ConnPool) SetConfig(cfg config.ConnPool) {
+	var toClose []*pooledConn
+	pool.Lock()
+	pool.enable = cfg.Enable
+	pool.maxIdle = cfg.MaxIdleConns
+	if pool.maxIdle <= 0 {
+		pool.maxIdle = defaultPoolMaxIdleConns
+	}
+	pool.idleTimeout = cfg.IdleTimeout
+	if pool.idleTimeout <= 0 {
+		pool.idleTimeout = defaultPoolIdleTimeout
+	}
+	if !pool.enable {
+		toClose = pool.removeAllLocked()
+	}
+	pool.Unlock()
+	pool.closePooledConns(toClose)
+}
+
+// Enabled returns whether the sessions should release their backend connections.
+func (pool *ConnPool) Enabled() bool {
+	if pool == nil {
+		return false
+	}
+	pool.Lock()
+	defer pool.Unlock()
+	return pool.enable && !pool.closed
+}
+
+// Get returns an idle connection of the key. It prefers the one released by the owner.
+// If the connection was released by another session, the states of that session are saved before returning it.
+func (pool *ConnPool) Get(key poolKey, owner uint64) *pooledConn {
+	for {
+		pool.Lock()
+		toClose := pool.evictLocked(time.Now())
+		pc := pool.pinned[owner]
+		if pc != nil {
+			delete(pool.pinned, owner)
+		} else if conns := pool.idle[key]; len(conns) > 0 {
+			idx := len(conns) - 1
+			for i, c := range conns {
+				if c.owner == owner {
+					idx = i
+					break
+				}
+			}
+			pc = conns[idx]
+			conns = append(conns[:idx], conns[idx+1:]...)
+			pool.setIdleLocked(key, conns)
+			metrics.PooledConnGauge.Dec()
+		}
+		pool.Unlock()
+		pool.closePooledConns(toClose)
+		if pc == nil || pc.owner == owner || pool.handOff(pc) {
+			return pc
+		}
+	}
+}
+
+// Put returns a connection to the pool. The oldest one is closed if the pool is full.
+func (pool *ConnPool) Put(key poolKey, pc *pooledConn) {
+	pc.idleSince = time.Now()
+	pool.Lock()
+	if pool.closed {
+		pool.Unlock()
+		pool.closePooledConns([]*pooledConn{pc})
+		return
+	}
+	toClose := pool.evictLocked(pc.idleSince)
+	conns := append(pool.idle[key], pc)
+	metrics.PooledConnGauge.Inc()
+	if len(conns) > pool.maxIdle {
+		toClose = append(toClose, conns[0])
+		conns = conns[1:]
+		metrics.PooledConnGauge.Dec()
+	}
+	pool.setIdleLocked(key, conns)
+	pool.Unlock()
+	pool.closePooledConns(toClose)
+}
+
+// Forget is called when the owner closes. The states of the owner needn't be saved anymore.
+func (pool *ConnPool) Forget(key poolKey, owner uint64) {
+	pool.Lock()
+	pc := pool.pinned[owner]
+	delete(pool.pinned, owner)
+	for _, c := range pool.idle[key] {
+		if c.owner == owner {
+			c.handoff = nil
+		}
+	}
+	pool.Unlock()
+	if pc != nil {
+		_ = pc.backendIO.Close()
+	}
+}
+
+// IdleCount returns the number of idle connections, excluding the pinned ones.
+func (pool *ConnPool) IdleCount() int {
+	pool.Lock()
+	defer pool.Unlock()
+	count := 0
+	for _, conns := range pool.idle {
+		count += len(conns)
+	}
+	return count
+}
+
+// Close closes all the idle connections and refuses new ones.
+func (pool *ConnPool) Close() {
+	pool.Lock()
+	pool.closed = true
+	toClose := pool.removeAllLocked()
+	for _, pc := range pool.pinned {
+		toClose = append(toClose, pc)
+	}
+	pool.pinned = make(map[uint64]*pooledConn)
+	pool.Unlock()
+	pool.closePooledConns(toClose)
+}
+
+// allowRefresh returns whether a session can refresh its token now.
+func (pool *ConnPool) allowRefresh() bool {
+	return pool.refreshLimiter.Allow()
+}
+
+func (pool *ConnPool) setIdleLocked(key poolKey, conns []*pooledConn) {
+	if len(conns) == 0 {
+		delete(pool.idle, key)
+	} else {
+		pool.idle[key] = conns
+	}
+}
+
+// evictLocked removes the connections that have been idle for too long.
+// The connections are appended in order, so the expired ones are always at the front.
+func (pool *ConnPool) evictLocked(now time.Time) []*pooledConn {
+	var toClose []*pooledConn
+	for key, conns := range pool.idle {
+		i := 0
+		for i < len(conns) && now.Sub(conns[i].idleSince) >= pool.idleTimeout {
+			i++
+		}
+		if i > 0 {
+			toClose = append(toClose, conns[:i]...)
+			pool.setIdleLocked(key, conns[i:])
+		}
+	}
+	metrics.PooledConnGauge.Sub(float64(len(toClose)))
+	return toClose
+}
+
+func (pool *ConnPool) removeAllLocked() []*pooledConn {
+	var toClose []*pooledConn
+	for _, conns := range pool.idle {
+		toClose = append(toClose, conns...)
+	}
+	pool.idle = make(map[poolKey][]*pooledConn)
+	metrics.PooledConnGauge.Sub(float64(len(toClose)))
+	return toClose
+}
+
+// handOff saves the states of the owner before the connection serves another session or is closed.
+// It returns false if the connection is pinned for the owner or closed.
+func (pool *ConnPool) handOff(pc *pooledConn) bool {
+	h := pc.handoff
+	if h == nil {
+		return true
+	}
+	pc.handoff = nil
+	defer close(h.done)
+	if h.err = h.save(pc.backendIO); h.err == nil {
+		return true
+	}
+	// The session has unmigratable states, such as temporary tables, or the backend doesn't support session migration.
+	// Keep the connection for the session because it will probably fail next time.
+	if !errors.Is(h.err, net.ErrClosed) && !pnet.IsDisconnectError(h.err) && !errors.Is(h.err, os.ErrDeadlineExceeded) {
+		pool.Lock()
+		if !pool.closed {
+			pc.pinned, h.pinned = true, true
+			pool.pinned[pc.owner] = pc
+		}
+		pool.Unlock()
+		if h.pinned {
+			return false
+		}
+	}
+	_ = pc.backendIO.Close()
+	return false
+}
+
+// closePooledConns closes the connections that are removed from the pool.
+// The states of the owners are saved first unless the pool is closed.
+func (pool *ConnPool) closePooledConns(conns []*pooledConn) {
+	pool.Lock()
+	closed := pool.closed
+	pool.Unlock()
+	for _, pc := range conns {
+		if h := pc.handoff; h != nil && closed {
+			pc.handoff = nil
+			h.err = errPoolClosed
+			close(h.done)
+		} else if !pool.handOff(pc) {
+			continue
+		}
+		_ = pc.backendIO.Close()
+	}
+}
+
+func (mgr *BackendConnManager) poolKey(addr string) poolKey {
+	return poolKey{addr: addr, user: mgr.authenticator.backendUser, capability: mgr.authenticator.capability}
+}
+
+// releaseBackend returns the backend connection to the pool after a transaction or a statement finishes.
+// The session states are saved only when another session takes the connection.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) releaseBackend() {
+	if mgr.pooling.pinned || !mgr.config.ConnPool.Enabled() {
+		return
+	}
+	if mgr.closeStatus.Load() >= statusNotifyClose || mgr.redirectInfo.Load() != nil || !mgr.cmdProcessor.finishedTxn() {
+		return
+	}
+	ptr := mgr.backendIO.Load()
+	if ptr == nil {
+		return
+	}
+	backendIO := *ptr
+	mgr.updateTraffic(backendIO)
+	handoff := newSessionHandoff(mgr.cmdProcessor.capability)
+	mgr.pooling.handoff = handoff
+	addr := backendIO.RemoteAddr().String()
+	// Store pooledAddr before clearing backendIO so that ServerAddr() always returns the address.
+	mgr.pooledAddr.Store(&addr)
+	mgr.backendIO.Store(nil)
+	mgr.config.ConnPool.Put(mgr.poolKey(addr), &pooledConn{backendIO: backendIO, connID: mgr.backendConnID.Load(), owner: mgr.connectionID, handoff: handoff})
+}
+
+// acquireBackend binds a backend connection to the session before executing a command.
+// It reuses the connection released by this session if no one else has used it, or resets an idle connection of
+// the same user and restores the session states on it, or dials a new connection with the session token.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) acquireBackend() error {
+	ptr := mgr.pooledAddr.Load()
+	if ptr == nil {
+		return nil
+	}
+	addr := *ptr
+	key := mgr.poolKey(addr)
+	pool := mgr.config.ConnPool
+	pc := pool.Get(key, mgr.connectionID)
+	if pc == nil || pc.owner != mgr.connectionID {
+		// The connection has been taken by another session, so restore the saved states on another connection.
+		err := mgr.loadHandoff()
+		if err != nil && pc != nil {
+			pool.Put(key, pc)
+			pc = nil
+		}
+		if mgr.pooling.handoff.pinned {
+			// The states are unmigratable and the connection is kept for this session.
+			if pc = pool.Get(key, mgr.connectionID); pc != nil && pc.owner != mgr.connectionID {
+				pool.Put(key, pc)
+				pc = nil
+			}
+			if pc == nil {
+				return errors.Wrapf(ErrBackendConn, "the pinned backend connection on %s is closed", addr)
+			}
+		} else if err != nil {
+			return errors.Wrapf(ErrBackendHandshake, "restore pooled session on %s error: %s", addr, err.Error())
+		}
+	}
+	var (
+		backendIO pnet.PacketIO
+		connID    uint64
+	)
+	for pc != nil {
+		if pc.owner == mgr.connectionID {
+			backendIO, connID = pc.backendIO, pc.connID
+			if pc.pinned {
+				mgr.pooling.pinned = true
+				mgr.logger.Info("session can not be pooled, keep the backend connection", zap.Error(mgr.pooling.handoff.err))
+			}
+			metrics.AcquirePooledConnCounter.WithLabelValues(acquireTypeOwned).Inc()
+			break
+		}
+		if err := mgr.resetBackend(pc.backendIO); err != nil {
+			mgr.logger.Debug("reset pooled connection failed", zap.String("backend_addr", addr), zap.Error(err))
+			_ = pc.backendIO.Close()
+			pc = pool.Get(key, mgr.connectionID)
+			continue
+		}
+		backendIO, connID = pc.backendIO, pc.connID
+		metrics.AcquirePooledConnCounter.WithLabelValues(acquireTypeReset).Inc()
+		break
+	}
+	if backendIO == nil {
+		var err error
+		if backendIO, connID, err = mgr.dialPooledBackend(addr); err != nil {
+			return errors.Wrapf(ErrBackendHandshake, "restore pooled session on %s error: %s", addr, err.Error())
+		}
+		metrics.AcquirePooledConnCounter.WithLabelValues(acquireTypeDial).Inc()
+	}
+
+	// The connection may have been used by other sessions, so only count the traffic from now on.
+	mgr.inBytes, mgr.inPackets, mgr.outBytes, mgr.outPackets = backendIO.InBytes(), backendIO.InPackets(), backendIO.OutBytes(), backendIO.OutPackets()
+	mgr.backendConnID.Store(connID)
+	mgr.backendIO.Store(&backendIO)
+	mgr.pooledAddr.Store(nil)
+	mgr.setKeepAlive()
+	return nil
+}
+
+// loadHandoff waits until the session states are saved by the session that takes the connection.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) loadHandoff() error {
+	handoff := mgr.pooling.handoff
+	<-handoff.done
+	if handoff.err != nil || handoff.applied {
+		return handoff.err
+	}
+	handoff.applied = true
+	mgr.stmtKiller.setToken(handoff.sessionToken, handoff.queryTime)
+	return mgr.updateAuthInfoFromSessionStates(hack.Slice(handoff.sessionStates))
+}
+
+// resetBackend cleans the session left by another client and restores the session states of this session.
+func (mgr *BackendConnManager) resetBackend(backendIO pnet.PacketIO) error {
+	// Do not use the CmdProcessor because COM_RESET_CONNECTION clears the status of prepared statements.
+	backendIO.ResetSequence()
+	if err := backendIO.WritePacket([]byte{pnet.ComResetConnection.Byte()}, true); err != nil {
+		return err
+	}
+	response, err := backendIO.ReadPacket()
+	if err != nil {
+		return err
+	}
+	if pnet.IsErrorPacket(response[0]) {
+		return pnet.ParseErrorPacket(response)
+	}
+	return mgr.initSessionStates(backendIO, mgr.pooling.handoff.sessionStates)
+}
+
+// dialPooledBackend connects to the backend with the session token, just like session migration.
+// The session is restored on the backend where it's released, so it uses the TLS config of the current backend.
+func (mgr *BackendConnManager) dialPooledBackend(addr string) (pnet.PacketIO, uint64, error) {
+	tlsConfig, err := mgr.getBackendTLS(mgr, mgr.currentBackend())
+	if err != nil {
+		return nil, 0, err
+	}
+	cn, err := net.DialTimeout("tcp", addr, mgr.config.DialTimeout)
+	if err != nil {
+		mgr.handshakeHandler.OnHandshake(mgr, addr, err, SrcBackendNetwork)
+		return nil, 0, err
+	}
+	backendIO := pnet.PacketIO(pnet.NewPacketIO(cn, mgr.logger, mgr.config.ConnBufferSize, pnet.WithRemoteAddr(addr, cn.RemoteAddr()), pnet.WithWrapError(ErrBackendConn)))
+	var connID uint64
+	handoff := mgr.pooling.handoff
+	if connID, err = mgr.authenticator.handshakeSecondTime(mgr.logger, mgr.clientIO, backendIO, tlsConfig, handoff.sessionToken); err == nil {
+		err = mgr.initSessionStates(backendIO, handoff.sessionStates)
+	} else {
+		mgr.handshakeHandler.OnHandshake(mgr, addr, err, Error2Source(err))
+	}
+	if err != nil {
+		if ignoredErr := backendIO.Close(); ignoredErr != nil && !pnet.IsDisconnectError(ignoredErr) {
+			mgr.logger.Error("close new backend connection failed", zap.Error(ignoredErr))
+		}
+		return nil, 0, err
+	}
+	return backendIO, connID, nil
+}
+
+// refreshPooledSession refreshes the session token of an idle released session whose connection is taken by others.
+// The token expires soon, and the session may need it to dial a new connection if the pool is empty.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) refreshPooledSession() {
+	if mgr.pooledAddr.Load() == nil || mgr.closeStatus.Load() >= statusNotifyClose {
+		return
+	}
+	// If the connection is still in the pool, the session gets it back without the token.
+	handoff := mgr.pooling.handoff
+	if !handoff.ready() || time.Since(handoff.queryTime) < mgr.tokenRefreshInterval() {
+		return
+	}
+	if !mgr.config.ConnPool.allowRefresh() {
+		return
+	}
+	if err := mgr.acquireBackend(); err != nil {
+		mgr.logger.Warn("refresh pooled session failed", zap.Error(err))
+		return
+	}
+	mgr.releaseBackend()
+}
+
+// tokenRefreshInterval spreads the refreshes of the sessions by their connection IDs.
+func (mgr *BackendConnManager) tokenRefreshInterval() time.Duration {
+	jitter := sessionTokenRefreshInterval / 2
+	return sessionTokenRefreshInterval + time.Duration(mgr.connectionID%1000)*jitter/1000
+}
+
+// forgetPooledSession drops the pooled connection that only serves this session when the session closes.
+func (mgr *BackendConnManager) forgetPooledSession(addr string) {
+	mgr.config.ConnPool.Forget(mgr.poolKey(addr), mgr.connectionID)
+}
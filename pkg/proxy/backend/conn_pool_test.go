@@ -0,0 +1,104 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func newPooledConn(t *testing.T, owner uint64) *pooledConn {
+	lg, _ := logger.CreateLoggerForTest(t)
+	cli, srv := net.Pipe()
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+	return &pooledConn{
+		backendIO: pnet.NewPacketIO(cli, lg, pnet.DefaultConnBufferSize),
+		owner:     owner,
+	}
+}
+
+func TestConnPoolGetPut(t *testing.T) {
+	pool := NewConnPool(config.ConnPool{Enable: true, MaxIdleConns: 2})
+	t.Cleanup(pool.Close)
+	require.True(t, pool.Enabled())
+	key1 := poolKey{addr: "addr", user: "u1"}
+	key2 := poolKey{addr: "addr", user: "u2"}
+	require.Nil(t, pool.Get(key1, 1))
+
+	pc1, pc2, pc3 := newPooledConn(t, 1), newPooledConn(t, 2), newPooledConn(t, 3)
+	pool.Put(key1, pc1)
+	pool.Put(key1, pc2)
+	pool.Put(key2, pc3)
+	require.Equal(t, 3, pool.IdleCount())
+	// prefer the owned connection
+	require.Same(t, pc1, pool.Get(key1, 1))
+	// otherwise, return the latest one
+	require.Same(t, pc2, pool.Get(key1, 4))
+	require.Nil(t, pool.Get(key1, 1))
+	require.Equal(t, 1, pool.IdleCount())
+
+	// the oldest one is closed when the pool is full
+	pool.Put(key2, pc1)
+	pool.Put(key2, pc2)
+	require.Equal(t, 2, pool.IdleCount())
+	require.False(t, pc3.backendIO.IsPeerActive())
+	require.Same(t, pc2, pool.Get(key2, 3))
+}
+
+func TestConnPoolIdleTimeout(t *testing.T) {
+	pool := NewConnPool(config.ConnPool{Enable: true, IdleTimeout: 100 * time.Millisecond})
+	t.Cleanup(pool.Close)
+	key := poolKey{addr: "addr", user: "u1"}
+	pool.Put(key, newPooledConn(t, 1))
+	require.Eventually(t, func() bool {
+		return pool.Get(key, 1) == nil
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, 0, pool.IdleCount())
+}
+
+func TestConnPoolDisable(t *testing.T) {
+	pool := NewConnPool(config.ConnPool{Enable: true})
+	key := poolKey{addr: "addr", user: "u1"}
+	pool.Put(key, newPooledConn(t, 1))
+	pool.SetConfig(config.ConnPool{Enable: false})
+	require.False(t, pool.Enabled())
+	require.Equal(t, 0, pool.IdleCount())
+
+	pool.SetConfig(config.ConnPool{Enable: true})
+	pool.Close()
+	require.False(t, pool.Enabled())
+	pool.Put(key, newPooledConn(t, 1))
+	require.Equal(t, 0, pool.IdleCount())
+
+	var nilPool *ConnPool
+	require.False(t, nilPool.Enabled())
+}
+
+func TestConnPoolHandoffOnClose(t *testing.T) {
+	pool := NewConnPool(config.ConnPool{Enable: true})
+	key := poolKey{addr: "addr", user: "u1"}
+	pc1, pc2 := newPooledConn(t, 1), newPooledConn(t, 2)
+	pc1.handoff, pc2.handoff = newSessionHandoff(0), newSessionHandoff(0)
+	pool.Put(key, pc1)
+	pool.Put(key, pc2)
+	// the owner closes, so its states needn't be saved
+	pool.Forget(key, 1)
+	require.Nil(t, pc1.handoff)
+
+	// the states are not saved after the pool is closed
+	handoff := pc2.handoff
+	pool.Close()
+	<-handoff.done
+	require.ErrorIs(t, handoff.err, errPoolClosed)
+	require.False(t, handoff.ready())
+	require.False(t, pc2.backendIO.IsPeerActive())
+}
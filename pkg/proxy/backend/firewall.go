@@ -0,0 +1,245 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	// The parser requires a driver to parse literals. Use the same driver as the other TiDB packages.
+	_ "github.com/pingcap/tidb/pkg/types/parser_driver"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/metrics"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/util/netutil"
+	"go.uber.org/zap"
+)
+
+const (
+	stmtTypeDDL = "ddl"
+	stmtTypeDML = "dml"
+)
+
+var sqlParserPool = sync.Pool{
+	New: func() any {
+		return parser.New()
+	},
+}
+
+type firewallRule struct {
+	name      string
+	action    string
+	message   string
+	users     map[string]struct{}
+	cidrs     []*net.IPNet
+	stmtTypes map[string]struct{}
+	digest    string
+	regex     *regexp.Regexp
+	// windows are the maintenance windows in which the rule is skipped.
+	windows []config.TimeWindow
+}
+
+// firewallStmt computes the properties of a statement lazily because most rules don't need all of them.
+type firewallStmt struct {
+	*sqlStmt
+	stmtTypes []string
+	parsed    bool
+	parseErr  bool
+}
+
+// types returns the lower-case types of all the statements. It returns false if the SQL can't be parsed.
+func (s *firewallStmt) types() ([]string, bool) {
+	if s.parsed {
+		return s.stmtTypes, !s.parseErr
+	}
+	s.parsed = true
+	p := sqlParserPool.Get().(*parser.Parser)
+	stmts, _, err := p.Parse(s.sql, "", "")
+	sqlParserPool.Put(p)
+	if err != nil {
+		s.parseErr = true
+		return nil, false
+	}
+	for _, stmt := range stmts {
+		s.stmtTypes = append(s.stmtTypes, strings.ToLower(ast.GetStmtLabel(stmt)))
+		switch stmt.(type) {
+		case ast.DDLNode:
+			s.stmtTypes = append(s.stmtTypes, stmtTypeDDL)
+		case ast.DMLNode:
+			s.stmtTypes = append(s.stmtTypes, stmtTypeDML)
+		}
+	}
+	return s.stmtTypes, true
+}
+
+func (r *firewallRule) match(user string, ip net.IP, stmt *firewallStmt, now time.Time) bool {
+	for _, window := range r.windows {
+		if window.Contains(now) {
+			return false
+		}
+	}
+	if len(r.users) > 0 {
+		if _, ok := r.users[user]; !ok {
+			return false
+		}
+	}
+	if len(r.cidrs) > 0 {
+		if contains, err := netutil.CIDRContainsIP(r.cidrs, ip); err != nil || !contains {
+			return false
+		}
+	}
+	if len(r.digest) > 0 || r.regex != nil {
+		normalized, digest := stmt.normalize()
+		if len(r.digest) > 0 && r.digest != digest {
+			return false
+		}
+		if r.regex != nil && !r.regex.MatchString(normalized) {
+			return false
+		}
+	}
+	if len(r.stmtTypes) > 0 {
+		types, ok := stmt.types()
+		// The statement that can't be parsed may be of any type, so only the deny rules match it to fail closed.
+		if !ok {
+			return r.action == config.FirewallActionDeny
+		}
+		if !slices.ContainsFunc(types, func(tp string) bool {
+			_, ok := r.stmtTypes[tp]
+			return ok
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// Firewall decides whether a statement is allowed before it's sent to the backend.
+// The rules are checked in order and the first matched rule takes effect.
+type Firewall struct {
+	sync.Mutex
+	rules []*firewallRule
+	// loc is the time zone of the maintenance windows.
+	loc *time.Location
+}
+
+// NewFirewall creates a Firewall.
+func NewFirewall(cfg config.Firewall) *Firewall {
+	fw := &Firewall{}
+	fw.SetConfig(cfg)
+	return fw
+}
+
+// SetConfig replaces the rules in order. Invalid CIDRs, regexes and windows are skipped because Check rejects them.
+func (fw *Firewall) SetConfig(cfg config.Firewall) {
+	var rules []*firewallRule
+	if cfg.Enable {
+		rules = make([]*firewallRule, 0, len(cfg.Rules))
+		for _, r := range cfg.Rules {
+			rule := &firewallRule{
+				name:    r.Name,
+				action:  r.Action,
+				message: r.Message,
+				digest:  r.Digest,
+			}
+			if len(r.Users) > 0 {
+				rule.users = make(map[string]struct{}, len(r.Users))
+				for _, user := range r.Users {
+					rule.users[user] = struct{}{}
+				}
+			}
+			rule.cidrs, _ = netutil.ParseCIDRList(r.CIDRs)
+			if len(r.StmtTypes) > 0 {
+				rule.stmtTypes = make(map[string]struct{}, len(r.StmtTypes))
+				for _, tp := range r.StmtTypes {
+					rule.stmtTypes[strings.ToLower(tp)] = struct{}{}
+				}
+			}
+			if len(r.Regex) > 0 {
+				if regex, err := regexp.Compile(r.Regex); err == nil {
+					rule.regex = regex
+				}
+			}
+			for _, w := range r.MaintenanceWindows {
+				if window, err := config.ParseTimeWindow(w); err == nil {
+					rule.windows = append(rule.windows, window)
+				}
+			}
+			rules = append(rules, rule)
+		}
+	}
+	loc, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		loc = time.Local
+	}
+	fw.Lock()
+	defer fw.Unlock()
+	fw.rules = rules
+	fw.loc = loc
+}
+
+// Enabled returns whether any statement may be checked.
+func (fw *Firewall) Enabled() bool {
+	if fw == nil {
+		return false
+	}
+	fw.Lock()
+	defer fw.Unlock()
+	return len(fw.rules) > 0
+}
+
+// check returns the first matched rule at the time, or nil if no rule matches.
+func (fw *Firewall) check(user string, ip net.IP, sqlStmt *sqlStmt, now time.Time) *firewallRule {
+	fw.Lock()
+	rules, loc := fw.rules, fw.loc
+	fw.Unlock()
+	stmt := &firewallStmt{sqlStmt: sqlStmt}
+	now = now.In(loc)
+	for _, rule := range rules {
+		if rule.match(user, ip, stmt, now) {
+			metrics.FirewallHitCounter.WithLabelValues(rule.name, rule.action).Inc()
+			return rule
+		}
+	}
+	return nil
+}
+
+// checkFirewall returns a MySQL error if the statement is denied. The error is already sent to the client.
+func (mgr *BackendConnManager) checkFirewall(stmt *sqlStmt) error {
+	if stmt == nil {
+		return nil
+	}
+	// The IP is nil for Unix socket clients, so they never match rules with CIDRs.
+	ip, _ := netutil.NetAddr2IP(mgr.clientIO.RemoteAddr())
+	user := mgr.authenticator.user
+	rule := mgr.config.Firewall.check(user, ip, stmt, time.Now())
+	if rule == nil || rule.action == config.FirewallActionAllow {
+		return nil
+	}
+	query, _ := stmt.normalize()
+	if len(query) > 256 {
+		query = query[:256]
+	}
+	mgr.logger.Info("statement matches firewall rule", zap.String("rule", rule.name), zap.String("action", rule.action),
+		zap.String("user", user), zap.String("query", query))
+	if rule.action != config.FirewallActionDeny {
+		return nil
+	}
+	msg := rule.message
+	if len(msg) == 0 {
+		msg = fmt.Sprintf("statement is denied by firewall rule '%s'", rule.name)
+	}
+	myErr := mysql.NewError(mysql.ER_SPECIFIC_ACCESS_DENIED_ERROR, msg)
+	if err := mgr.clientIO.WritePacket(pnet.MakeErrPacket(myErr), true); err != nil {
+		return err
+	}
+	return myErr
+}
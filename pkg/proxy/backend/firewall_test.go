@@ -0,0 +1,145 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tiproxy/lib/config"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirewallCheck(t *testing.T) {
+	_, digest := parser.NormalizeDigest("select * from secrets where id = 1")
+	fw := NewFirewall(config.Firewall{
+		Enable: true,
+		Rules: []config.FirewallRule{
+			{Name: "admin", Action: config.FirewallActionAllow, Users: []string{"root"}, CIDRs: []string{"10.0.0.0/8"}},
+			{Name: "no-drop", Action: config.FirewallActionDeny, StmtTypes: []string{"DropTable", "TruncateTable"}},
+			{Name: "no-ddl", Action: config.FirewallActionDeny, Users: []string{"app"}, StmtTypes: []string{"DDL"}},
+			{Name: "bad-digest", Action: config.FirewallActionDeny, Digest: digest.String()},
+			{Name: "audit", Action: config.FirewallActionLog, Regex: "from `orders`"},
+		},
+	})
+	require.True(t, fw.Enabled())
+
+	internalIP, externalIP := net.ParseIP("10.1.1.1"), net.ParseIP("192.168.1.1")
+	tests := []struct {
+		user string
+		ip   net.IP
+		sql  string
+		rule string
+	}{
+		{"root", internalIP, "drop table t", "admin"},
+		{"root", externalIP, "drop table t", "no-drop"},
+		{"root", nil, "TRUNCATE t", "no-drop"},
+		{"app", externalIP, "create table t(id int)", "no-ddl"},
+		{"app", externalIP, "select 1; alter table t add column c int", "no-ddl"},
+		{"other", externalIP, "create table t(id int)", ""},
+		{"app", externalIP, "insert into t values (1)", ""},
+		{"app", externalIP, "SELECT * FROM secrets WHERE id = 100", "bad-digest"},
+		{"app", externalIP, "select * from orders", "audit"},
+		{"app", externalIP, "select * from customers", ""},
+		// fail closed if the statement can't be parsed
+		{"app", externalIP, "this is not sql", "no-drop"},
+	}
+	now := time.Now()
+	for i, test := range tests {
+		rule := fw.check(test.user, test.ip, &sqlStmt{sql: test.sql}, now)
+		if len(test.rule) == 0 {
+			require.Nil(t, rule, "case %d", i)
+		} else {
+			require.NotNil(t, rule, "case %d", i)
+			require.Equal(t, test.rule, rule.name, "case %d", i)
+		}
+	}
+
+	// the log rules don't match the statement that can't be parsed
+	fw.SetConfig(config.Firewall{Enable: true, Rules: []config.FirewallRule{{Name: "audit", Action: config.FirewallActionLog, StmtTypes: []string{"DDL"}}}})
+	require.Nil(t, fw.check("app", externalIP, &sqlStmt{sql: "this is not sql"}, now))
+
+	// disabled or no rules
+	fw.SetConfig(config.Firewall{Rules: []config.FirewallRule{{Name: "no-drop", Action: config.FirewallActionDeny}}})
+	require.False(t, fw.Enabled())
+	fw.SetConfig(config.Firewall{Enable: true})
+	require.False(t, fw.Enabled())
+	var nilFirewall *Firewall
+	require.False(t, nilFirewall.Enabled())
+}
+
+func TestFirewallMaintenanceWindows(t *testing.T) {
+	fw := NewFirewall(config.Firewall{
+		Enable:   true,
+		TimeZone: "Asia/Shanghai",
+		Rules: []config.FirewallRule{
+			{Name: "no-drop", Action: config.FirewallActionDeny, StmtTypes: []string{"DropTable"}, MaintenanceWindows: []string{"Sat 02:00-04:00"}},
+		},
+	})
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	// 2025-01-04 is Saturday.
+	inWindow := time.Date(2025, 1, 4, 3, 0, 0, 0, loc)
+	require.Nil(t, fw.check("app", nil, &sqlStmt{sql: "drop table t"}, inWindow))
+	require.Nil(t, fw.check("app", nil, &sqlStmt{sql: "drop table t"}, inWindow.UTC()))
+	require.NotNil(t, fw.check("app", nil, &sqlStmt{sql: "drop table t"}, inWindow.Add(2*time.Hour)))
+	require.NotNil(t, fw.check("app", nil, &sqlStmt{sql: "drop table t"}, inWindow.AddDate(0, 0, 1)))
+}
+
+func TestFirewallDeny(t *testing.T) {
+	fw := NewFirewall(config.Firewall{
+		Enable: true,
+		Rules: []config.FirewallRule{
+			{Name: "no-drop", Action: config.FirewallActionDeny, StmtTypes: []string{"DropTable"}, Message: "drop is forbidden"},
+		},
+	})
+	ts := newBackendMgrTester(t, func(config *testConfig) {
+		config.proxyConfig.bcConfig.Firewall = fw
+	})
+	runners := []runner{
+		{
+			client:  ts.mc.authenticate,
+			proxy:   ts.firstHandshake4Proxy,
+			backend: ts.handshake4Backend,
+		},
+		// the denied statement is not sent to the backend
+		{
+			client: func(packetIO pnet.PacketIO) error {
+				ts.mc.sql = "drop table t"
+				require.NoError(t, ts.mc.request(packetIO))
+				var myErr *mysql.MyError
+				require.ErrorAs(t, ts.mc.mysqlErr, &myErr)
+				require.Equal(t, uint16(mysql.ER_SPECIFIC_ACCESS_DENIED_ERROR), myErr.Code)
+				require.Contains(t, myErr.Message, "drop is forbidden")
+				ts.mc.mysqlErr = nil
+				return nil
+			},
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				clientIO.ResetSequence()
+				request, err := clientIO.ReadPacket()
+				require.NoError(t, err)
+				err = ts.mp.ExecuteCmd(context.Background(), request)
+				require.True(t, pnet.IsMySQLError(err))
+				return nil
+			},
+		},
+		// the allowed statement is forwarded
+		{
+			client: func(packetIO pnet.PacketIO) error {
+				ts.mc.sql = "select 1"
+				require.NoError(t, ts.mc.request(packetIO))
+				require.Nil(t, ts.mc.mysqlErr)
+				return nil
+			},
+			proxy:   ts.forwardCmd4Proxy,
+			backend: ts.respondWithNoTxn4Backend,
+		},
+	}
+	ts.runTests(runners)
+}
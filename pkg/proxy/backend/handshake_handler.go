@@ -9,6 +9,7 @@ import (
 	"github.com/pingcap/tiproxy/pkg/balance/router"
 	"github.com/pingcap/tiproxy/pkg/manager/namespace"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +22,21 @@ const (
 	ConnContextKeyTLSState ConnContextKey = "tls-state"
 	ConnContextKeyConnID   ConnContextKey = "conn-id"
 	ConnContextKeyConnAddr ConnContextKey = "conn-addr"
+	// ConnContextKeySNI is the SNI hostname sent by the client in the TLS handshake, which is absent if it's not sent.
+	ConnContextKeySNI ConnContextKey = "sni"
+	// ConnContextKeyProxyProtocol is the *proxyprotocol.Proxy received from the client, including the TLVs.
+	// It's set before GetRouter is called and it's absent if the client doesn't send the PROXY header.
+	ConnContextKeyProxyProtocol ConnContextKey = "proxy-protocol"
+	// ConnContextKeyDefaultNamespace is the namespace of the listener, which is absent if the listener doesn't set it.
+	ConnContextKeyDefaultNamespace ConnContextKey = "default-namespace"
+	// ConnContextKeyStatementTimeout is the statement timeout of the namespace, which is absent if it's not set.
+	ConnContextKeyStatementTimeout ConnContextKey = "statement-timeout"
+	// ConnContextKeyLoadDataLocal is the LOAD DATA LOCAL INFILE policy of the namespace, which is absent if it's not set.
+	ConnContextKeyLoadDataLocal ConnContextKey = "load-data-local"
+	// ConnContextKeyNamespace is the name of the namespace that the connection is routed to.
+	ConnContextKeyNamespace ConnContextKey = "namespace"
+	// ConnContextKeyBackendTLS is the *config.TLSConfig that overrides sql-tls for the namespace, which is absent if it's not set.
+	ConnContextKeyBackendTLS ConnContextKey = "backend-tls"
 )
 
 var _ HandshakeHandler = (*DefaultHandshakeHandler)(nil)
@@ -70,7 +86,30 @@ func (handler *DefaultHandshakeHandler) HandleHandshakeErr(ctx ConnContext, err
 }
 
 func (handler *DefaultHandshakeHandler) GetRouter(ctx ConnContext, resp *pnet.HandshakeResp) (router.Router, error) {
-	ns, ok := handler.nsManager.GetNamespaceByUser(resp.User)
+	var (
+		ns *namespace.Namespace
+		ok bool
+	)
+	// The VPC endpoint identifies the tenant at the network level, so it takes precedence over the user.
+	if proxy, _ := ctx.Value(ConnContextKeyProxyProtocol).(*proxyprotocol.Proxy); proxy != nil {
+		if id := proxy.AWSVPCEndpointID(); len(id) > 0 {
+			ns, ok = handler.nsManager.GetNamespaceByVPCEndpoint(id)
+		}
+	}
+	// Each tenant may connect with its own DNS name.
+	if !ok {
+		if sni, _ := ctx.Value(ConnContextKeySNI).(string); len(sni) > 0 {
+			ns, ok = handler.nsManager.GetNamespaceBySNI(sni)
+		}
+	}
+	if !ok {
+		ns, ok = handler.nsManager.GetNamespaceByUser(resp.User)
+	}
+	if !ok {
+		if defaultNs, _ := ctx.Value(ConnContextKeyDefaultNamespace).(string); len(defaultNs) > 0 {
+			ns, ok = handler.nsManager.GetNamespace(defaultNs)
+		}
+	}
 	if !ok {
 		ns, ok = handler.nsManager.GetNamespace("default")
 	}
@@ -78,6 +117,16 @@ func (handler *DefaultHandshakeHandler) GetRouter(ctx ConnContext, resp *pnet.Ha
 		return nil, errors.New("failed to find a namespace")
 	}
 	ctx.UpdateLogger(zap.String("ns", ns.Name()))
+	ctx.SetValue(ConnContextKeyNamespace, ns.Name())
+	if tlsConfig := ns.BackendTLS(); tlsConfig != nil {
+		ctx.SetValue(ConnContextKeyBackendTLS, tlsConfig)
+	}
+	if timeout := ns.StatementTimeout(); timeout > 0 {
+		ctx.SetValue(ConnContextKeyStatementTimeout, timeout)
+	}
+	if policy := ns.LoadDataLocal(); policy != nil {
+		ctx.SetValue(ConnContextKeyLoadDataLocal, *policy)
+	}
 	return ns.GetRouter(), nil
 }
 
@@ -0,0 +1,197 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"go.uber.org/zap"
+)
+
+// killPattern matches `KILL [TIDB] [CONNECTION | QUERY] id`.
+var killPattern = regexp.MustCompile(`(?is)^\s*(KILL\s+(?:TIDB\s+)?(?:(CONNECTION|QUERY)\s+)?)(\d+)\s*;?\s*$`)
+
+const (
+	// killTargetRetries and killTargetRetryInterval bound the wait for a busy target to bind a backend connection.
+	killTargetRetries       = 10
+	killTargetRetryInterval = 10 * time.Millisecond
+)
+
+// killRequest is a KILL statement or COM_PROCESS_KILL that targets a connection of this instance.
+type killRequest struct {
+	target *BackendConnManager
+	// prefix is the statement before the connection ID, e.g. `KILL TIDB QUERY `.
+	prefix string
+	connID uint64
+	query  bool
+}
+
+func (kr *killRequest) sql(backendConnID uint64) string {
+	return kr.prefix + strconv.FormatUint(backendConnID, 10)
+}
+
+// translateKill returns the KILL request if the request kills a connection of this instance.
+// The client only knows the connection ID allocated by the proxy, which differs from the one on the backend.
+// Other IDs, such as the ones in SHOW PROCESSLIST, are sent to the backend unchanged.
+func (mgr *BackendConnManager) translateKill(request []byte) *killRequest {
+	if mgr.config.FindConn == nil {
+		return nil
+	}
+	var kr killRequest
+	switch pnet.Command(request[0]) {
+	case pnet.ComProcessKill:
+		if len(request) < 5 {
+			return nil
+		}
+		kr.prefix, kr.connID = "KILL CONNECTION ", uint64(binary.LittleEndian.Uint32(request[1:]))
+	case pnet.ComQuery:
+		// Check the keyword first to avoid matching the regular expression for every statement.
+		sql := strings.TrimLeft(pnet.ParseQueryPacket(request[1:]), " \t\r\n")
+		if len(sql) < 4 || !strings.EqualFold(sql[:4], "KILL") {
+			return nil
+		}
+		matches := killPattern.FindStringSubmatch(sql)
+		if matches == nil {
+			return nil
+		}
+		connID, err := strconv.ParseUint(matches[3], 10, 64)
+		if err != nil {
+			return nil
+		}
+		kr.prefix, kr.connID, kr.query = matches[1], connID, strings.EqualFold(matches[2], "QUERY")
+	default:
+		return nil
+	}
+	if kr.target = mgr.config.FindConn(kr.connID); kr.target == nil {
+		return nil
+	}
+	return &kr
+}
+
+// executeKill kills the statement or the session of the target on its current backend connection.
+// The statement runs as this session so that the backend checks the privilege.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) executeKill(kr *killRequest, backendIO pnet.PacketIO) error {
+	addr, backendConnID, release := kr.target.killTarget()
+	var err error
+	switch {
+	case len(addr) == 0 || addr == backendIO.RemoteAddr().String():
+		// If the target can't bind a backend connection, its last connection ID is killed on the current backend,
+		// which relies on the global kill of the backends.
+		_, err = mgr.cmdProcessor.executeCmd(pnet.MakeQueryPacket(kr.sql(backendConnID)), nil, mgr.clientIO, backendIO, false)
+	default:
+		err = mgr.killOnBackend(kr.target, addr, kr.sql(backendConnID), backendIO)
+	}
+	mgr.logger.Info("translate KILL", zap.Uint64("target_conn_id", kr.connID), zap.String("target_backend_addr", addr),
+		zap.Uint64("target_backend_conn_id", backendConnID), zap.Bool("query", kr.query), zap.Error(err))
+	killed := err == nil && !kr.query
+	release(killed)
+	if killed {
+		kr.target.kill()
+	}
+	return err
+}
+
+// killOnBackend runs the KILL statement on the backend of the target with the session token of this session.
+// The TLS config is chosen by the target because the backend belongs to the target's namespace.
+// If the token is unavailable, e.g. in a transaction, it runs on the current backend and relies on the global kill
+// of the backends.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) killOnBackend(target *BackendConnManager, addr, sql string, backendIO pnet.PacketIO) error {
+	if mgr.cmdProcessor.serverStatus&StatusInTrans == 0 {
+		_, sessionToken, err := mgr.querySessionStates(backendIO)
+		if err == nil && len(sessionToken) > 0 {
+			var tlsConfig *tls.Config
+			if tlsConfig, err = target.getBackendTLS(target, target.currentBackend()); err == nil {
+				err = mgr.runOnBackend(addr, tlsConfig, sessionToken, sql)
+			}
+			if err == nil || pnet.IsMySQLError(err) {
+				return mgr.writeKillResult(err)
+			}
+		}
+		mgr.logger.Debug("killing on the target backend failed", zap.String("backend_addr", addr), zap.Error(err))
+	}
+	_, err := mgr.cmdProcessor.executeCmd(pnet.MakeQueryPacket(sql), nil, mgr.clientIO, backendIO, false)
+	return err
+}
+
+// writeKillResult sends OK or the MySQL error to the client.
+func (mgr *BackendConnManager) writeKillResult(err error) error {
+	var myErr *mysql.MyError
+	if errors.As(err, &myErr) {
+		if writeErr := mgr.clientIO.WritePacket(pnet.MakeErrPacket(myErr), true); writeErr != nil {
+			return writeErr
+		}
+		return myErr
+	}
+	return mgr.clientIO.WritePacket(pnet.MakeOKPacket(uint16(mgr.cmdProcessor.serverStatus), pnet.OKHeader), true)
+}
+
+// killTarget returns the current backend address and the backend connection ID of the session for KILL from other
+// sessions. If the session is idle and its backend connection is released to the pool, it binds a backend connection
+// again so that the backend checks the privilege of KILL as usual. The address is empty if the session can't get a
+// backend connection. release must be called after KILL to return the connection to the pool.
+func (mgr *BackendConnManager) killTarget() (addr string, backendConnID uint64, release func(killed bool)) {
+	release = func(bool) {}
+	for i := 0; ; i++ {
+		if ptr := mgr.backendIO.Load(); ptr != nil {
+			return (*ptr).RemoteAddr().String(), mgr.backendConnID.Load(), release
+		}
+		if mgr.processLock.TryLock() {
+			break
+		}
+		// The session is acquiring a backend connection to run a command.
+		if i >= killTargetRetries {
+			return "", mgr.backendConnID.Load(), release
+		}
+		time.Sleep(killTargetRetryInterval)
+	}
+	ptr := mgr.backendIO.Load()
+	if ptr == nil {
+		if err := mgr.acquireBackend(); err != nil {
+			mgr.logger.Warn("acquire backend connection for KILL failed", zap.Error(err))
+		}
+		if ptr = mgr.backendIO.Load(); ptr == nil {
+			mgr.processLock.Unlock()
+			return "", mgr.backendConnID.Load(), release
+		}
+	}
+	release = func(killed bool) {
+		// The killed connection is closed with the session instead of returning to the pool.
+		if !killed {
+			mgr.releaseBackend()
+		}
+		mgr.processLock.Unlock()
+	}
+	return (*ptr).RemoteAddr().String(), mgr.backendConnID.Load(), release
+}
+
+// kill closes the session after it's killed by KILL CONNECTION, even if it's in a transaction.
+// The session won't fail over or retry because its backend connection is killed on purpose.
+func (mgr *BackendConnManager) kill() {
+	if mgr.killed.CompareAndSwap(false, true) {
+		mgr.signalReceived <- signalTypeKill
+	}
+}
+
+// closeKilled closes the client connection of the killed session.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) closeKilled() {
+	if mgr.closeStatus.Load() >= statusClosing {
+		return
+	}
+	mgr.quitSource = SrcClientSQLErr
+	if err := mgr.clientIO.GracefulClose(); err != nil {
+		mgr.logger.Warn("graceful close client IO error", zap.Error(err))
+	}
+	mgr.closeStatus.Store(statusClosing)
+}
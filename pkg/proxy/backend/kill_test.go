@@ -0,0 +1,178 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateKill(t *testing.T) {
+	target := &BackendConnManager{}
+	mgr := &BackendConnManager{config: &BCConfig{
+		FindConn: func(connID uint64) *BackendConnManager {
+			if connID == 5 {
+				return target
+			}
+			return nil
+		},
+	}}
+	processKill := make([]byte, 5)
+	processKill[0] = pnet.ComProcessKill.Byte()
+	binary.LittleEndian.PutUint32(processKill[1:], 5)
+
+	tests := []struct {
+		request []byte
+		sql     string
+		query   bool
+	}{
+		{request: pnet.MakeQueryPacket("KILL 5"), sql: "KILL 100"},
+		{request: pnet.MakeQueryPacket(" kill  query 5;"), sql: "kill  query 100", query: true},
+		{request: pnet.MakeQueryPacket("KILL TIDB CONNECTION 5"), sql: "KILL TIDB CONNECTION 100"},
+		{request: pnet.MakeQueryPacket("KILL TIDB QUERY\n5 "), sql: "KILL TIDB QUERY\n100", query: true},
+		{request: processKill, sql: "KILL CONNECTION 100"},
+		// not a connection of this instance
+		{request: pnet.MakeQueryPacket("KILL 6")},
+		{request: pnet.MakeQueryPacket("KILL QUERY abc")},
+		{request: pnet.MakeQueryPacket("KILL 5, 6")},
+		{request: pnet.MakeQueryPacket("SELECT 5")},
+		{request: []byte{pnet.ComProcessKill.Byte(), 5}},
+	}
+	for i, test := range tests {
+		kr := mgr.translateKill(test.request)
+		if len(test.sql) == 0 {
+			require.Nil(t, kr, "case %d", i)
+			continue
+		}
+		require.NotNil(t, kr, "case %d", i)
+		require.Same(t, target, kr.target, "case %d", i)
+		require.EqualValues(t, 5, kr.connID, "case %d", i)
+		require.Equal(t, test.query, kr.query, "case %d", i)
+		require.Equal(t, test.sql, kr.sql(100), "case %d", i)
+	}
+
+	// KILL is not translated without FindConn.
+	mgr.config.FindConn = nil
+	require.Nil(t, mgr.translateKill(pnet.MakeQueryPacket("KILL 5")))
+}
+
+func TestKillOnSameBackend(t *testing.T) {
+	var ts *backendMgrTester
+	ts = newBackendMgrTester(t, func(config *testConfig) {
+		config.proxyConfig.bcConfig.FindConn = func(connID uint64) *BackendConnManager {
+			if connID == 5 {
+				return ts.mp.BackendConnManager
+			}
+			return nil
+		}
+	})
+	// The backend receives the backend connection ID instead of the one allocated by the proxy.
+	expectKill := func(sql string) func(packetIO pnet.PacketIO) error {
+		return func(packetIO pnet.PacketIO) error {
+			packetIO.ResetSequence()
+			request, err := packetIO.ReadPacket()
+			if err != nil {
+				return err
+			}
+			require.Equal(t, pnet.ComQuery.Byte(), request[0])
+			require.Equal(t, sql, string(request[1:]))
+			return packetIO.WritePacket(pnet.MakeOKPacket(0, pnet.OKHeader), true)
+		}
+	}
+	runners := []runner{
+		{
+			client:  ts.mc.authenticate,
+			proxy:   ts.firstHandshake4Proxy,
+			backend: ts.handshake4Backend,
+		},
+		{
+			client: func(packetIO pnet.PacketIO) error {
+				ts.mc.sql = "KILL QUERY 5"
+				return ts.mc.request(packetIO)
+			},
+			proxy:   ts.forwardCmd4Proxy,
+			backend: expectKill("KILL QUERY 100"),
+		},
+		// KILL QUERY doesn't close the session.
+		{
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.False(t, ts.mp.killed.Load())
+				require.Equal(t, statusActive, ts.mp.closeStatus.Load())
+				return nil
+			},
+		},
+		{
+			client: func(packetIO pnet.PacketIO) error {
+				ts.mc.sql = "KILL 5"
+				return ts.mc.request(packetIO)
+			},
+			proxy:   ts.forwardCmd4Proxy,
+			backend: expectKill("KILL 100"),
+		},
+		// KILL CONNECTION closes the session.
+		{
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.True(t, ts.mp.killed.Load())
+				return ts.checkConnClosed4Proxy(clientIO, backendIO)
+			},
+		},
+	}
+	ts.runTests(runners)
+	require.Equal(t, SrcClientSQLErr, ts.mp.QuitSource())
+}
+
+func TestKillPooledTarget(t *testing.T) {
+	pool := NewConnPool(config.ConnPool{Enable: true})
+	t.Cleanup(pool.Close)
+	ts := newBackendMgrTester(t, func(config *testConfig) {
+		config.proxyConfig.bcConfig.ConnPool = pool
+	})
+	runners := []runner{
+		{
+			client:  ts.mc.authenticate,
+			proxy:   ts.firstHandshake4Proxy,
+			backend: ts.handshake4Backend,
+		},
+		{
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.Nil(t, ts.mp.backendIO.Load())
+				backendConnID := ts.mp.backendConnID.Load()
+				require.NotZero(t, backendConnID)
+
+				// The released session binds its connection again so that the backend checks the privilege.
+				addr, connID, release := ts.mp.killTarget()
+				require.Equal(t, ts.tc.backendListener.Addr().String(), addr)
+				require.Equal(t, backendConnID, connID)
+				require.NotNil(t, ts.mp.backendIO.Load())
+				require.Equal(t, 0, pool.IdleCount())
+				release(false)
+				require.Nil(t, ts.mp.backendIO.Load())
+				require.Equal(t, 1, pool.IdleCount())
+
+				// The busy session that has no backend connection is killed by its last connection ID.
+				ts.mp.processLock.Lock()
+				addr, connID, release = ts.mp.killTarget()
+				require.Empty(t, addr)
+				require.Equal(t, backendConnID, connID)
+				release(false)
+				ts.mp.processLock.Unlock()
+				require.Equal(t, 1, pool.IdleCount())
+
+				// The killed connection is not returned to the pool.
+				_, _, release = ts.mp.killTarget()
+				release(true)
+				require.NotNil(t, ts.mp.backendIO.Load())
+				require.Equal(t, 0, pool.IdleCount())
+				require.True(t, ts.mp.processLock.TryLock())
+				ts.mp.processLock.Unlock()
+				return nil
+			},
+		},
+	}
+	ts.runTests(runners)
+}
@@ -0,0 +1,262 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/metrics"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/util/lex"
+	"go.uber.org/zap"
+)
+
+// The results of LOAD DATA LOCAL INFILE transfers.
+const (
+	loadDataAllowed   = "allowed"
+	loadDataDisabled  = "disabled"
+	loadDataOversized = "oversized"
+	// The proxy holds too many files of other connections.
+	loadDataMemoryExceeded = "memory_exceeded"
+)
+
+const defaultLoadDataMaxMemory = 256 * 1024 * 1024
+
+// LoadDataMemory limits the total memory of the LOAD DATA LOCAL INFILE files that are held until they're fully
+// received. Without it, each connection with a file size limit may hold a file as large as the limit.
+type LoadDataMemory struct {
+	maxMemory atomic.Int64
+	memory    atomic.Int64
+}
+
+// NewLoadDataMemory creates a LoadDataMemory.
+func NewLoadDataMemory(cfg config.LoadDataLocal) *LoadDataMemory {
+	lm := &LoadDataMemory{}
+	lm.SetConfig(cfg)
+	return lm
+}
+
+// SetConfig updates the memory limit. The held files are kept even if they exceed the new limit.
+func (lm *LoadDataMemory) SetConfig(cfg config.LoadDataLocal) {
+	maxMemory := int64(cfg.MaxMemory) << 20
+	if maxMemory <= 0 {
+		maxMemory = defaultLoadDataMaxMemory
+	}
+	lm.maxMemory.Store(maxMemory)
+}
+
+// acquire reserves memory for a packet of a held file. It returns false if the memory exceeds the limit.
+func (lm *LoadDataMemory) acquire(size int) bool {
+	if lm == nil {
+		return true
+	}
+	if lm.memory.Add(int64(size)) > lm.maxMemory.Load() {
+		lm.memory.Add(-int64(size))
+		return false
+	}
+	metrics.LoadDataLocalHeldBytesGauge.Add(float64(size))
+	return true
+}
+
+func (lm *LoadDataMemory) release(size int) {
+	if lm == nil || size == 0 {
+		return
+	}
+	lm.memory.Add(-int64(size))
+	metrics.LoadDataLocalHeldBytesGauge.Sub(float64(size))
+}
+
+// loadDataPolicy restricts LOAD DATA LOCAL INFILE of a session.
+type loadDataPolicy struct {
+	disable bool
+	// maxBytes is the size limit of a file. 0 means no limit.
+	maxBytes int
+}
+
+func newLoadDataPolicy(cfg config.LoadDataPolicy) loadDataPolicy {
+	return loadDataPolicy{disable: cfg.Disable, maxBytes: cfg.MaxFileSize << 20}
+}
+
+// loadDataPolicy returns the LOAD DATA LOCAL INFILE policy of the user.
+// The user policy overrides the namespace policy, which overrides the default one.
+func (mgr *BackendConnManager) loadDataPolicy(user string) loadDataPolicy {
+	policy, ok := mgr.config.UserLoadDataLocal[user]
+	if !ok {
+		if policy, ok = mgr.Value(ConnContextKeyLoadDataLocal).(config.LoadDataPolicy); !ok {
+			policy = mgr.config.LoadDataLocal
+		}
+	}
+	return newLoadDataPolicy(policy)
+}
+
+// rejectInFileIO drops the LOCAL INFILE request so that the client never reads the file.
+// It's only used to forward the first packet of a result.
+type rejectInFileIO struct {
+	pnet.PacketIO
+}
+
+func (rio *rejectInFileIO) WritePacket(data []byte, flush bool) error {
+	if len(data) > 0 && data[0] == pnet.LocalInFileHeader.Byte() {
+		return nil
+	}
+	return rio.PacketIO.WritePacket(data, flush)
+}
+
+// forwardLoadInFile forwards the file from the client to the backend after the backend sends the LOCAL INFILE request.
+// The request has been sent to the client unless LOAD DATA LOCAL INFILE is disabled.
+func (cp *CmdProcessor) forwardLoadInFile(clientIO, backendIO pnet.PacketIO, request, infileReq []byte) (serverStatus uint16, err error) {
+	fileName := string(infileReq[1:])
+	if cp.loadDataPolicy.disable {
+		return 0, cp.rejectLoadInFile(clientIO, backendIO, request, fileName, loadDataDisabled, 0)
+	}
+	if err = clientIO.Flush(); err != nil {
+		return
+	}
+	// The client sends file data until an empty packet.
+	// If the file size is limited, the file is held until it's fully received so that an oversized file loads no rows.
+	// After the file is rejected, the remaining data is still read and dropped so that the connection is usable.
+	maxBytes := cp.loadDataPolicy.maxBytes
+	var held [][]byte
+	size, heldSize, result := 0, 0, loadDataAllowed
+	defer func() {
+		cp.loadDataMemory.release(heldSize)
+	}()
+	for {
+		var data []byte
+		if maxBytes > 0 {
+			if data, err = clientIO.ReadPacket(); err != nil {
+				return
+			}
+			if result == loadDataAllowed {
+				switch {
+				case size+len(data) > maxBytes:
+					result = loadDataOversized
+				case !cp.loadDataMemory.acquire(len(data)):
+					result = loadDataMemoryExceeded
+				default:
+					held = append(held, data)
+					heldSize += len(data)
+				}
+				if result != loadDataAllowed {
+					cp.loadDataMemory.release(heldSize)
+					held, heldSize = nil, 0
+				}
+			}
+		} else {
+			// Do not call PacketIO.ForwardUntil. It peeks 5 bytes but there may be only 4 bytes here.
+			if data, err = forwardOnePacket(backendIO, clientIO, false); err != nil {
+				return
+			}
+		}
+		size += len(data)
+		if len(data) == 0 {
+			break
+		}
+	}
+	if maxBytes > 0 {
+		if result != loadDataAllowed {
+			return 0, cp.rejectLoadInFile(clientIO, backendIO, request, fileName, result, size)
+		}
+		for _, data := range held {
+			if err = backendIO.WritePacket(data, false); err != nil {
+				return
+			}
+		}
+	}
+	if err = backendIO.Flush(); err != nil {
+		return
+	}
+	cp.auditLoadInFile(backendIO, fileName, loadDataAllowed, size)
+
+	var response []byte
+	if response, err = forwardOnePacket(clientIO, backendIO, true); err != nil {
+		return
+	}
+	switch response[0] {
+	case pnet.OKHeader.Byte():
+		return cp.handleOKPacket(request, response), nil
+	case pnet.ErrHeader.Byte():
+		return serverStatus, cp.handleErrorPacket(response)
+	}
+	// impossible here
+	return serverStatus, errors.Errorf("unexpected response, cmd:%d resp:%d", pnet.ComQuery, response[0])
+}
+
+// rejectLoadStmt rejects the statement before it's sent if it contains LOAD DATA LOCAL INFILE and that's disabled,
+// so that none of the statements in a multi-statement runs.
+func (cp *CmdProcessor) rejectLoadStmt(clientIO, backendIO pnet.PacketIO) error {
+	cp.auditLoadInFile(backendIO, "", loadDataDisabled, 0)
+	myErr := cp.loadInFileError(loadDataDisabled)
+	if err := clientIO.WritePacket(pnet.MakeErrPacket(myErr), true); err != nil {
+		return err
+	}
+	return myErr
+}
+
+// rejectLoadInFile rejects the file after the backend asks for it and sends an error to the client.
+// Usually, an empty file is sent so that the statement loads no rows. However, the backend would run the remaining
+// statements of a multi-statement after the empty file, so the backend connection is closed to abort them instead.
+func (cp *CmdProcessor) rejectLoadInFile(clientIO, backendIO pnet.PacketIO, request []byte, fileName, reason string, size int) error {
+	cp.auditLoadInFile(backendIO, fileName, reason, size)
+	myErr := cp.loadInFileError(reason)
+	if _, followed := lex.LoadsLocalFile(pnet.ParseQueryPacket(request[1:])); followed {
+		return cp.abortLoadInFile(clientIO, backendIO, myErr)
+	}
+	if err := backendIO.WritePacket(nil, true); err != nil {
+		return err
+	}
+	response, err := backendIO.ReadPacket()
+	if err != nil {
+		return err
+	}
+	if pnet.IsOKPacket(response[0]) {
+		// The remaining statements have run if the lexer fails to find them, and their results can't be forwarded
+		// after the error, so the connection is closed and the client can't assume they failed.
+		if cp.handleOKPacket(request, response)&pnet.ServerMoreResultsExists > 0 {
+			return cp.abortLoadInFile(clientIO, backendIO, myErr)
+		}
+	}
+	if err := clientIO.WritePacket(pnet.MakeErrPacket(myErr), true); err != nil {
+		return err
+	}
+	return myErr
+}
+
+// abortLoadInFile closes the backend connection so that the backend aborts the statement and the remaining ones,
+// and then sends the error to the client. The session is closed because it has no backend connection.
+func (cp *CmdProcessor) abortLoadInFile(clientIO, backendIO pnet.PacketIO, myErr *mysql.MyError) error {
+	closeErr := backendIO.Close()
+	if err := clientIO.WritePacket(pnet.MakeErrPacket(myErr), true); err != nil {
+		return err
+	}
+	return errors.Wrapf(ErrBackendConn, "abort the multi-statement after rejecting LOAD DATA LOCAL INFILE: %s, close error: %v",
+		myErr.Message, closeErr)
+}
+
+func (cp *CmdProcessor) loadInFileError(reason string) *mysql.MyError {
+	var msg string
+	switch reason {
+	case loadDataDisabled:
+		msg = "LOAD DATA LOCAL INFILE is disabled by the proxy"
+	case loadDataMemoryExceeded:
+		msg = "LOAD DATA LOCAL INFILE files held by the proxy exceed the memory limit, please retry later"
+	default:
+		msg = fmt.Sprintf("LOAD DATA LOCAL INFILE file size exceeds the limit of %d bytes", cp.loadDataPolicy.maxBytes)
+	}
+	return mysql.NewError(mysql.ER_NOT_ALLOWED_COMMAND, msg)
+}
+
+func (cp *CmdProcessor) auditLoadInFile(backendIO pnet.PacketIO, fileName, result string, size int) {
+	metrics.LoadDataLocalCounter.WithLabelValues(result).Inc()
+	if result == loadDataAllowed {
+		metrics.LoadDataLocalBytesCounter.Add(float64(size))
+	}
+	cp.logger.Info("LOAD DATA LOCAL INFILE", zap.Uint64("conn_id", cp.connID), zap.String("user", cp.user),
+		zap.String("backend_addr", backendIO.RemoteAddr().String()), zap.String("file", fileName),
+		zap.Int("bytes", size), zap.String("result", result))
+}
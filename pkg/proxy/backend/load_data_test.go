@@ -0,0 +1,147 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDataPolicy(t *testing.T) {
+	tests := []struct {
+		policy    loadDataPolicy
+		maxMemory int
+		sql       string
+		stmtNum   int
+		filePkts  int
+		fileBytes int
+		result    string
+		// aborted means the backend connection is closed to abort the remaining statements.
+		aborted bool
+	}{
+		{
+			policy:    loadDataPolicy{},
+			stmtNum:   1,
+			filePkts:  3,
+			fileBytes: 3 * len(mockCmdBytes),
+			result:    loadDataAllowed,
+		},
+		{
+			policy:    loadDataPolicy{maxBytes: 2 * len(mockCmdBytes)},
+			stmtNum:   1,
+			filePkts:  2,
+			fileBytes: 2 * len(mockCmdBytes),
+			result:    loadDataAllowed,
+		},
+		{
+			policy:   loadDataPolicy{disable: true},
+			stmtNum:  1,
+			filePkts: 1,
+			result:   loadDataDisabled,
+		},
+		{
+			policy:   loadDataPolicy{maxBytes: 2*len(mockCmdBytes) - 1},
+			stmtNum:  1,
+			filePkts: 2,
+			result:   loadDataOversized,
+		},
+		{
+			// Other connections hold too many files.
+			policy:    loadDataPolicy{maxBytes: 2 * len(mockCmdBytes)},
+			maxMemory: 2*len(mockCmdBytes) - 1,
+			stmtNum:   1,
+			filePkts:  2,
+			result:    loadDataMemoryExceeded,
+		},
+		{
+			// The statement is rejected before it's sent, so none of the statements runs.
+			policy:   loadDataPolicy{disable: true},
+			sql:      "load data local infile '/tmp/a' into table t; insert into t values (1)",
+			stmtNum:  2,
+			filePkts: 1,
+			result:   loadDataDisabled,
+		},
+		{
+			// The file is rejected after the statement is sent, so the remaining statements are aborted.
+			policy:   loadDataPolicy{maxBytes: 2*len(mockCmdBytes) - 1},
+			sql:      "load data local infile '/tmp/a' into table t; insert into t values (1)",
+			stmtNum:  2,
+			filePkts: 2,
+			result:   loadDataOversized,
+			aborted:  true,
+		},
+		{
+			// The statement is not recognized, and the backend reports that the remaining statements have run.
+			policy:   loadDataPolicy{disable: true},
+			stmtNum:  3,
+			filePkts: 1,
+			result:   loadDataDisabled,
+			aborted:  true,
+		},
+	}
+
+	tc := newTCPConnSuite(t)
+	for i, test := range tests {
+		lg, text := logger.CreateLoggerForTest(t)
+		ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+			cfg.clientConfig.cmd = pnet.ComQuery
+			cfg.clientConfig.filePkts = test.filePkts
+			cfg.backendConfig.respondType = responseTypeLoadFile
+			cfg.backendConfig.stmtNum = test.stmtNum
+			if len(test.sql) > 0 {
+				cfg.clientConfig.sql = test.sql
+			}
+		})
+		ts.mp.cmdProcessor.logger = lg
+		ts.mp.cmdProcessor.loadDataPolicy = test.policy
+		memory := NewLoadDataMemory(config.LoadDataLocal{})
+		if test.maxMemory > 0 {
+			memory.maxMemory.Store(int64(test.maxMemory))
+		}
+		ts.mp.cmdProcessor.loadDataMemory = memory
+		ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+			if test.result == loadDataAllowed {
+				require.NoError(t, ts.mc.mysqlErr, "case %d", i)
+			} else {
+				var myErr *mysql.MyError
+				require.ErrorAs(t, ts.mc.mysqlErr, &myErr, "case %d", i)
+				require.EqualValues(t, mysql.ER_NOT_ALLOWED_COMMAND, myErr.Code, "case %d", i)
+			}
+			require.Equal(t, test.fileBytes, ts.mb.fileBytes, "case %d", i)
+			if len(test.sql) > 0 && test.policy.disable {
+				// The backend receives nothing.
+				require.Zero(t, ts.tc.backendIO.InBytes(), "case %d", i)
+			}
+			if test.aborted {
+				require.ErrorIs(t, ts.mp.err, ErrBackendConn, "case %d", i)
+			} else if test.result != loadDataAllowed {
+				require.True(t, pnet.IsMySQLError(ts.mp.err), "case %d", i)
+			}
+			require.Contains(t, text.String(), test.result, "case %d", i)
+		}, ts.mc.request, ts.mb.respond, ts.mp.processCmd)
+		// The session is still usable after the transfer is rejected.
+		require.Zero(t, ts.mp.cmdProcessor.serverStatus&StatusInTrans, "case %d", i)
+		require.Zero(t, memory.memory.Load(), "case %d", i)
+		clean()
+	}
+}
+
+func TestLoadDataPolicyPriority(t *testing.T) {
+	mgr := &BackendConnManager{config: &BCConfig{
+		LoadDataLocal:     config.LoadDataPolicy{MaxFileSize: 10},
+		UserLoadDataLocal: map[string]config.LoadDataPolicy{"etl": {}, "app": {Disable: true}},
+	}}
+	mgr.ctxmap.m = make(map[any]any)
+	require.Equal(t, loadDataPolicy{maxBytes: 10 << 20}, mgr.loadDataPolicy("bi"))
+	mgr.SetValue(ConnContextKeyLoadDataLocal, config.LoadDataPolicy{MaxFileSize: 1})
+	require.Equal(t, loadDataPolicy{maxBytes: 1 << 20}, mgr.loadDataPolicy("bi"))
+	require.Equal(t, loadDataPolicy{disable: true}, mgr.loadDataPolicy("app"))
+	// An empty user policy lifts the limits for the user.
+	require.Equal(t, loadDataPolicy{}, mgr.loadDataPolicy("etl"))
+}
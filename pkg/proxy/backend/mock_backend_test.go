@@ -55,6 +55,7 @@ type mockBackend struct {
 	attrs     map[string]string
 	authData  []byte
 	zstdLevel int
+	fileBytes int
 }
 
 func newMockBackend(cfg *backendConfig) *mockBackend {
@@ -351,6 +352,7 @@ func (mb *mockBackend) respondLoadFile(packetIO pnet.PacketIO) error {
 			if len(pkt) == 0 {
 				break
 			}
+			mb.fileBytes += len(pkt)
 		}
 		if err := packetIO.WritePacket(pnet.MakeOKPacket(status, pnet.OKHeader), true); err != nil {
 			return err
@@ -4,6 +4,7 @@
 package backend
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/binary"
 
@@ -20,6 +21,8 @@ type clientConfig struct {
 	attrs      map[string]string
 	dataBytes  []byte
 	authData   []byte
+	// password generates authData with the salt from the server if it's set.
+	password   string
 	filePkts   int
 	prepStmtID int
 	capability pnet.Capability
@@ -73,6 +76,11 @@ func (mc *mockClient) authenticate(packetIO pnet.PacketIO) error {
 	mc.capability = mc.capability & initialHandshake.Capability
 	mc.serverVersion = initialHandshake.ServerVersion
 	mc.connid = initialHandshake.ConnID
+	if len(mc.password) > 0 {
+		if mc.authData, err = pnet.GenerateAuthResp(mc.password, mc.authPlugin, initialHandshake.Salt[:]); err != nil {
+			return err
+		}
+	}
 
 	resp := &pnet.HandshakeResp{
 		User:       mc.username,
@@ -114,6 +122,16 @@ func (mc *mockClient) writePassword(packetIO pnet.PacketIO) error {
 			mc.mysqlErr = pnet.ParseErrorPacket(serverPkt)
 			return nil
 		case pnet.AuthSwitchHeader.Byte(), pnet.ShaCommand:
+			if serverPkt[0] == pnet.ShaCommand && len(serverPkt) == 2 && serverPkt[1] == pnet.FastAuthSuccess {
+				continue
+			}
+			if serverPkt[0] == pnet.AuthSwitchHeader.Byte() && len(mc.password) > 0 {
+				idx := bytes.IndexByte(serverPkt[1:], 0)
+				authPlugin, salt := string(serverPkt[1:idx+1]), bytes.TrimSuffix(serverPkt[idx+2:], []byte{0})
+				if mc.authData, err = pnet.GenerateAuthResp(mc.password, authPlugin, salt); err != nil {
+					return err
+				}
+			}
 			if err := packetIO.WritePacket(mc.authData, true); err != nil {
 				return err
 			}
@@ -335,6 +353,7 @@ func (mc *mockClient) readResultSet(packetIO pnet.PacketIO) error {
 			if pkt[0] == pnet.OKHeader.Byte() {
 				serverStatus = binary.LittleEndian.Uint16(pkt[3:])
 			} else {
+				mc.mysqlErr = pnet.ParseErrorPacket(pkt)
 				return nil
 			}
 		default:
@@ -68,10 +68,10 @@ func newMockProxy(t *testing.T, cfg *proxyConfig) *mockProxy {
 }
 
 func (mp *mockProxy) authenticateFirstTime(clientIO, backendIO pnet.PacketIO) error {
-	if err := mp.authenticator.handshakeFirstTime(context.Background(), mp.logger, mp, clientIO, mp.handshakeHandler,
-		func(ctx context.Context, cctx ConnContext, resp *pnet.HandshakeResp) (pnet.PacketIO, error) {
-			return backendIO, nil
-		}, mp.frontendTLSConfig, mp.backendTLSConfig); err != nil {
+	if _, err := mp.authenticator.handshakeFirstTime(context.Background(), mp.logger, mp, clientIO, mp.handshakeHandler,
+		func(ctx context.Context, cctx ConnContext, resp *pnet.HandshakeResp) (pnet.PacketIO, *tls.Config, error) {
+			return backendIO, mp.backendTLSConfig, nil
+		}, mp.frontendTLSConfig); err != nil {
 		return err
 	}
 	mp.cmdProcessor.capability = mp.authenticator.capability
@@ -79,14 +79,15 @@ func (mp *mockProxy) authenticateFirstTime(clientIO, backendIO pnet.PacketIO) er
 }
 
 func (mp *mockProxy) authenticateSecondTime(clientIO, backendIO pnet.PacketIO) error {
-	return mp.authenticator.handshakeSecondTime(mp.logger, clientIO, backendIO, mp.backendTLSConfig, mp.sessionToken)
+	_, err := mp.authenticator.handshakeSecondTime(mp.logger, clientIO, backendIO, mp.backendTLSConfig, mp.sessionToken)
+	return err
 }
 
 func (mp *mockProxy) authenticateWithBackend(_, backendIO pnet.PacketIO) error {
 	if err := mp.authenticator.handshakeWithBackend(context.Background(), mp.logger, mp, mp.handshakeHandler,
-		mp.username, mp.password, mp.dbName, func(ctx context.Context, cctx ConnContext, resp *pnet.HandshakeResp) (pnet.PacketIO, error) {
-			return backendIO, nil
-		}, mp.backendTLSConfig); err != nil {
+		mp.username, mp.password, mp.dbName, func(ctx context.Context, cctx ConnContext, resp *pnet.HandshakeResp) (pnet.PacketIO, *tls.Config, error) {
+			return backendIO, mp.backendTLSConfig, nil
+		}); err != nil {
 		return err
 	}
 	mp.cmdProcessor.capability = mp.authenticator.capability
@@ -99,12 +100,12 @@ func (mp *mockProxy) processCmd(clientIO, backendIO pnet.PacketIO) error {
 	if err != nil {
 		return err
 	}
-	if mp.holdRequest, err = mp.cmdProcessor.executeCmd(request, clientIO, backendIO, mp.waitRedirect); err != nil {
+	if mp.holdRequest, err = mp.cmdProcessor.executeCmd(request, nil, clientIO, backendIO, mp.waitRedirect); err != nil {
 		return err
 	}
 	// Pretend to redirect the held request to the new backend. The backend must respond for another loop.
 	if mp.holdRequest {
-		_, err = mp.cmdProcessor.executeCmd(request, clientIO, backendIO, false)
+		_, err = mp.cmdProcessor.executeCmd(request, nil, clientIO, backendIO, false)
 	}
 	return err
 }
@@ -119,6 +120,7 @@ var _ capture.Capture = (*mockCapture)(nil)
 
 type mockCapture struct {
 	db        string
+	sni       string
 	initSql   string
 	packet    []byte
 	startTime time.Time
@@ -135,8 +137,9 @@ func (mc *mockCapture) Wait() {
 func (mc *mockCapture) Stop(err error) {
 }
 
-func (mc *mockCapture) InitConn(startTime time.Time, connID uint64, dbname string) {
+func (mc *mockCapture) InitConn(startTime time.Time, connID uint64, dbname, sni string) {
 	mc.db = dbname
+	mc.sni = sni
 	mc.startTime = startTime
 	mc.connID = connID
 }
@@ -0,0 +1,222 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"go.uber.org/zap"
+)
+
+// proxyAuthCheckInterval is the minimum interval to check whether the users file is modified.
+const proxyAuthCheckInterval = time.Second
+
+// proxyAuthUser is a user authenticated by the proxy.
+type proxyAuthUser struct {
+	User       string `toml:"user"`
+	AuthPlugin string `toml:"auth-plugin"`
+	// AuthString is generated by pnet.HashPassword.
+	AuthString string `toml:"auth-string"`
+	// BackendUser and BackendPassword are used to log in to the backends.
+	BackendUser     string `toml:"backend-user"`
+	BackendPassword string `toml:"backend-password"`
+	hash            []byte
+}
+
+type proxyAuthFile struct {
+	Users []proxyAuthUser `toml:"users"`
+}
+
+// ProxyAuth authenticates the clients with the users file instead of the backends.
+// The users file is reloaded once it's modified.
+type ProxyAuth struct {
+	sync.Mutex
+	logger    *zap.Logger
+	enable    bool
+	file      string
+	modTime   time.Time
+	checkTime time.Time
+	users     map[string]*proxyAuthUser
+}
+
+// NewProxyAuth creates a ProxyAuth.
+func NewProxyAuth(logger *zap.Logger, cfg config.ProxyAuth) *ProxyAuth {
+	pa := &ProxyAuth{logger: logger}
+	pa.SetConfig(cfg)
+	return pa
+}
+
+// SetConfig updates the config and loads the users file.
+func (pa *ProxyAuth) SetConfig(cfg config.ProxyAuth) {
+	pa.Lock()
+	defer pa.Unlock()
+	pa.enable = cfg.Enable
+	if pa.file != cfg.UsersFile {
+		pa.file = cfg.UsersFile
+		pa.modTime = time.Time{}
+		pa.users = nil
+	}
+	if pa.enable {
+		pa.reload()
+	}
+}
+
+// lookup returns the user if it should be authenticated by the proxy.
+func (pa *ProxyAuth) lookup(user string) *proxyAuthUser {
+	if pa == nil {
+		return nil
+	}
+	pa.Lock()
+	defer pa.Unlock()
+	if !pa.enable {
+		return nil
+	}
+	if time.Since(pa.checkTime) >= proxyAuthCheckInterval {
+		pa.reload()
+	}
+	return pa.users[user]
+}
+
+// reload loads the users file if it's modified. The previous users are kept if the file is invalid.
+func (pa *ProxyAuth) reload() {
+	pa.checkTime = time.Now()
+	info, err := os.Stat(pa.file)
+	if err != nil {
+		pa.logger.Warn("checking the proxy auth users file failed", zap.String("file", pa.file), zap.Error(err))
+		return
+	}
+	if info.ModTime().Equal(pa.modTime) {
+		return
+	}
+	users, err := loadProxyAuthUsers(pa.file)
+	if err != nil {
+		pa.logger.Warn("loading the proxy auth users file failed, the previous users are kept", zap.String("file", pa.file), zap.Error(err))
+		return
+	}
+	pa.modTime, pa.users = info.ModTime(), users
+	pa.logger.Info("proxy auth users file is loaded", zap.String("file", pa.file), zap.Int("users", len(users)))
+}
+
+func loadProxyAuthUsers(file string) (map[string]*proxyAuthUser, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var f proxyAuthFile
+	if err = toml.Unmarshal(data, &f); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	users := make(map[string]*proxyAuthUser, len(f.Users))
+	for i := range f.Users {
+		user := &f.Users[i]
+		if len(user.User) == 0 || len(user.BackendUser) == 0 {
+			return nil, errors.New("user and backend-user must be set")
+		}
+		if _, ok := users[user.User]; ok {
+			return nil, errors.Errorf("duplicated user %s", user.User)
+		}
+		if user.hash, err = pnet.ParseAuthString(user.AuthPlugin, user.AuthString); err != nil {
+			return nil, errors.Wrapf(err, "invalid user %s", user.User)
+		}
+		users[user.User] = user
+	}
+	return users, nil
+}
+
+// authenticateClient verifies the password of the client with the users file.
+// The client is asked to switch the auth plugin if it uses a different one.
+func (auth *Authenticator) authenticateClient(clientIO pnet.PacketIO, resp *pnet.HandshakeResp, user *proxyAuthUser, salt [20]byte) error {
+	authData := resp.AuthData
+	if resp.AuthPlugin != user.AuthPlugin {
+		if err := clientIO.WritePacket(pnet.MakeSwitchRequest(user.AuthPlugin, salt), true); err != nil {
+			return err
+		}
+		var err error
+		if authData, err = clientIO.ReadPacket(); err != nil {
+			return err
+		}
+	}
+	if !pnet.VerifyAuthResp(user.AuthPlugin, user.hash, salt[:], authData) {
+		return writeAccessDenied(clientIO, resp.User, len(authData) > 0)
+	}
+	if user.AuthPlugin == pnet.AuthCachingSha2Password {
+		return clientIO.WritePacket([]byte{pnet.ShaCommand, pnet.FastAuthSuccess}, true)
+	}
+	return nil
+}
+
+// writeAccessDenied sends ER_ACCESS_DENIED_ERROR to the client and returns it as a client auth failure.
+func writeAccessDenied(clientIO pnet.PacketIO, user string, usingPassword bool) error {
+	host := clientIO.RemoteAddr().String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	using := "NO"
+	if usingPassword {
+		using = "YES"
+	}
+	myErr := mysql.NewDefaultError(mysql.ER_ACCESS_DENIED_ERROR, user, host, using)
+	if err := clientIO.WritePacket(pnet.MakeErrPacket(myErr), true); err != nil {
+		return err
+	}
+	return errors.Wrap(myErr, ErrClientAuthFail)
+}
+
+// loginBackend logs in to the backend with the password of the mapped user and returns the final OK or ERR packet.
+func (auth *Authenticator) loginBackend(backendIO pnet.PacketIO, initialPkt []byte, backendTLSConfig *tls.Config,
+	backendCapability pnet.Capability, password string) ([]byte, error) {
+	initialHandshake := pnet.ParseInitialHandshake(initialPkt)
+	authPlugin, salt := initialHandshake.AuthPlugin, initialHandshake.Salt[:]
+	authData, err := pnet.GenerateAuthResp(password, authPlugin, salt)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrBackendHandshake)
+	}
+	if err = auth.writeAuthHandshake(backendIO, backendTLSConfig, backendCapability, authPlugin, authData, 0); err != nil {
+		return nil, err
+	}
+	for {
+		pkt, err := backendIO.ReadPacket()
+		if err != nil {
+			return nil, errors.Wrap(err, ErrBackendHandshake)
+		}
+		switch pkt[0] {
+		case pnet.OKHeader.Byte(), pnet.ErrHeader.Byte():
+			return pkt, nil
+		case pnet.AuthSwitchHeader.Byte():
+			idx := bytes.IndexByte(pkt[1:], 0)
+			if idx < 0 {
+				return nil, errors.Wrap(mysql.ErrMalformPacket, ErrBackendHandshake)
+			}
+			authPlugin, salt = string(pkt[1:idx+1]), bytes.TrimSuffix(pkt[idx+2:], []byte{0})
+			if authData, err = pnet.GenerateAuthResp(password, authPlugin, salt); err != nil {
+				return nil, errors.Wrap(err, ErrBackendHandshake)
+			}
+		case pnet.ShaCommand:
+			// An OK packet follows the fast authentication.
+			if len(pkt) == 2 && pkt[1] == pnet.FastAuthSuccess {
+				continue
+			}
+			// The full authentication of caching_sha2_password requires the password in plain text.
+			if !backendIO.TLSConnectionState().HandshakeComplete {
+				return nil, errors.Wrapf(ErrBackendHandshake, "the full authentication of %s requires TLS", authPlugin)
+			}
+			authData = append([]byte(password), 0)
+		default:
+			return nil, errors.Wrapf(ErrBackendHandshake, "read unexpected command: %#x", pkt[0])
+		}
+		if err = backendIO.WritePacket(authData, true); err != nil {
+			return nil, errors.Wrap(err, ErrBackendHandshake)
+		}
+	}
+}
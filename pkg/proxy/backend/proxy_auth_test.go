@@ -0,0 +1,169 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func writeProxyAuthUsers(t *testing.T, file string, modTime time.Time, users ...string) {
+	var content string
+	for _, user := range users {
+		authString, err := pnet.HashPassword(pnet.AuthNativePassword, user+"_pwd")
+		require.NoError(t, err)
+		content += fmt.Sprintf("[[users]]\nuser = %q\nauth-plugin = %q\nauth-string = %q\nbackend-user = \"svc\"\nbackend-password = \"svc_pwd\"\n",
+			user, pnet.AuthNativePassword, authString)
+	}
+	require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+}
+
+func TestProxyAuthUsersFile(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	file := filepath.Join(t.TempDir(), "users.toml")
+	now := time.Now()
+	writeProxyAuthUsers(t, file, now, "app1", "app2")
+
+	pa := NewProxyAuth(lg, config.ProxyAuth{Enable: true, UsersFile: file})
+	require.NotNil(t, pa.lookup("app1"))
+	require.NotNil(t, pa.lookup("app2"))
+	require.Nil(t, pa.lookup("svc"))
+
+	// The modified file is reloaded.
+	writeProxyAuthUsers(t, file, now.Add(time.Second), "app1")
+	pa.checkTime = time.Time{}
+	require.NotNil(t, pa.lookup("app1"))
+	require.Nil(t, pa.lookup("app2"))
+
+	// The previous users are kept if the file is invalid.
+	invalid := []string{
+		"[[users]]\nuser = \"app3\"\nauth-plugin = \"mysql_native_password\"\n",
+		"[[users]]\nuser = \"app3\"\nbackend-user = \"svc\"\nauth-plugin = \"mysql_native_password\"\nauth-string = \"abc\"\n",
+		"[[users]]\nuser = \"app3\"\nbackend-user = \"svc\"\nauth-plugin = \"unknown\"\n",
+		"[[users]]\nuser = \"app3\"\nbackend-user = \"svc\"\n[[users]]\nuser = \"app3\"\nbackend-user = \"svc\"\n",
+		"users = 1",
+	}
+	for i, content := range invalid {
+		require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+		modTime := now.Add(time.Duration(i+2) * time.Second)
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+		pa.checkTime = time.Time{}
+		require.NotNil(t, pa.lookup("app1"), "case %d", i)
+		require.Nil(t, pa.lookup("app3"), "case %d", i)
+	}
+
+	// No user is authenticated by the proxy after it's disabled.
+	pa.SetConfig(config.ProxyAuth{UsersFile: file})
+	require.Nil(t, pa.lookup("app1"))
+}
+
+func TestProxyAuthenticate(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	file := filepath.Join(t.TempDir(), "users.toml")
+	writeProxyAuthUsers(t, file, time.Now(), "app1")
+	pa := NewProxyAuth(lg, config.ProxyAuth{Enable: true, UsersFile: file})
+	authString, err := pnet.HashPassword(pnet.AuthCachingSha2Password, "app2_pwd")
+	require.NoError(t, err)
+	pa.users["app2"] = &proxyAuthUser{User: "app2", AuthPlugin: pnet.AuthCachingSha2Password, BackendUser: "svc", BackendPassword: "svc_pwd"}
+	pa.users["app2"].hash, err = pnet.ParseAuthString(pnet.AuthCachingSha2Password, authString)
+	require.NoError(t, err)
+
+	tc := newTCPConnSuite(t)
+	tests := []struct {
+		user       string
+		password   string
+		authPlugin string
+		succeed    bool
+	}{
+		{user: "app1", password: "app1_pwd", authPlugin: pnet.AuthNativePassword, succeed: true},
+		// switch the auth plugin
+		{user: "app1", password: "app1_pwd", authPlugin: pnet.AuthCachingSha2Password, succeed: true},
+		{user: "app2", password: "app2_pwd", authPlugin: pnet.AuthCachingSha2Password, succeed: true},
+		{user: "app2", password: "app2_pwd", authPlugin: pnet.AuthNativePassword, succeed: true},
+		{user: "app1", password: "wrong", authPlugin: pnet.AuthNativePassword},
+		{user: "app2", password: "wrong", authPlugin: pnet.AuthCachingSha2Password},
+	}
+	for i, test := range tests {
+		ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+			cfg.proxyConfig.bcConfig.ProxyAuth = pa
+			cfg.clientConfig.username = test.user
+			cfg.clientConfig.password = test.password
+			cfg.clientConfig.authPlugin = test.authPlugin
+			cfg.backendConfig.authPlugin = pnet.AuthNativePassword
+		})
+		msg := fmt.Sprintf("case %d", i)
+		if test.succeed {
+			ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+				require.NoError(t, ts.mc.err, msg)
+				require.NoError(t, ts.mp.err, msg)
+				require.NoError(t, ts.mb.err, msg)
+				require.True(t, ts.mc.authSucceed, msg)
+				// The backend only sees the mapped user.
+				require.Equal(t, "svc", ts.mb.username, msg)
+				authData, err := pnet.GenerateAuthResp("svc_pwd", pnet.AuthNativePassword, ts.mb.salt[:])
+				require.NoError(t, err)
+				require.Equal(t, authData, ts.mb.authData, msg)
+				require.Equal(t, test.user, ts.mp.authenticator.user, msg)
+				require.Equal(t, "svc", ts.mp.authenticator.backendUser, msg)
+			}, ts.mc.authenticate, ts.mb.authenticate, ts.mp.authenticateFirstTime)
+
+			// The session migrates as the mapped user.
+			ts.authenticateSecondTime(t, func(t *testing.T, ts *testSuite) {
+				require.NoError(t, ts.mp.err, msg)
+				require.Equal(t, "svc", ts.mb.username, msg)
+			})
+		} else {
+			// The proxy rejects the client without connecting to the backend.
+			ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+				require.NoError(t, ts.mc.err, msg)
+				require.False(t, ts.mc.authSucceed, msg)
+				var myErr *mysql.MyError
+				require.ErrorAs(t, ts.mc.mysqlErr, &myErr, msg)
+				require.EqualValues(t, mysql.ER_ACCESS_DENIED_ERROR, myErr.Code, msg)
+				require.ErrorIs(t, ts.mp.err, ErrClientAuthFail, msg)
+				require.Equal(t, SrcClientAuthFail, Error2Source(ts.mp.err), msg)
+				require.Nil(t, ErrToClient(ts.mp.err), msg)
+			}, ts.mc.authenticate, nil, ts.mp.authenticateFirstTime)
+		}
+		clean()
+	}
+}
+
+func TestProxyAuthBackendRejects(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	file := filepath.Join(t.TempDir(), "users.toml")
+	writeProxyAuthUsers(t, file, time.Now(), "app1")
+	pa := NewProxyAuth(lg, config.ProxyAuth{Enable: true, UsersFile: file})
+
+	tc := newTCPConnSuite(t)
+	ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+		cfg.proxyConfig.bcConfig.ProxyAuth = pa
+		cfg.clientConfig.username = "app1"
+		cfg.clientConfig.password = "app1_pwd"
+		cfg.clientConfig.authPlugin = pnet.AuthNativePassword
+		cfg.backendConfig.authPlugin = pnet.AuthNativePassword
+		cfg.backendConfig.authSucceed = false
+	})
+	ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+		require.NoError(t, ts.mc.err)
+		require.False(t, ts.mc.authSucceed)
+		var myErr *mysql.MyError
+		require.ErrorAs(t, ts.mc.mysqlErr, &myErr)
+		require.EqualValues(t, mysql.ER_ACCESS_DENIED_ERROR, myErr.Code)
+		require.ErrorIs(t, ts.mp.err, ErrBackendHandshake)
+		// The error is already sent to the client.
+		require.Nil(t, ErrToClient(ts.mp.err))
+	}, ts.mc.authenticate, ts.mb.authenticate, ts.mp.authenticateFirstTime)
+	clean()
+}
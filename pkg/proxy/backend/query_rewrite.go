@@ -0,0 +1,123 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"sync"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/metrics"
+	"github.com/pingcap/tiproxy/pkg/util/lex"
+	"go.uber.org/zap"
+)
+
+const (
+	rewriteTypeRewrite = "rewrite"
+	rewriteTypeDryRun  = "dry_run"
+	// The literals can't be mapped to the placeholders.
+	rewriteTypeSkip = "skip"
+)
+
+type rewriteRule struct {
+	name        string
+	replacement string
+	dryRun      bool
+}
+
+// QueryRewriter replaces the statements matched by digests before they are sent to the backends.
+// The literals of the original statement are filled into the placeholders of the replacement.
+type QueryRewriter struct {
+	sync.Mutex
+	rules map[string]*rewriteRule
+}
+
+// NewQueryRewriter creates a QueryRewriter.
+func NewQueryRewriter(cfg config.QueryRewrite) *QueryRewriter {
+	qr := &QueryRewriter{}
+	qr.SetConfig(cfg)
+	return qr
+}
+
+// SetConfig indexes the rules by the digest. Check rejects duplicated digests, so no rule is overwritten.
+func (qr *QueryRewriter) SetConfig(cfg config.QueryRewrite) {
+	var rules map[string]*rewriteRule
+	if cfg.Enable && len(cfg.Rules) > 0 {
+		rules = make(map[string]*rewriteRule, len(cfg.Rules))
+		for _, r := range cfg.Rules {
+			name := r.Name
+			if len(name) == 0 {
+				name = r.Digest
+			}
+			rules[r.Digest] = &rewriteRule{name: name, replacement: r.Replacement, dryRun: r.DryRun}
+		}
+	}
+	qr.Lock()
+	defer qr.Unlock()
+	qr.rules = rules
+}
+
+// Enabled returns whether any statement may be rewritten.
+func (qr *QueryRewriter) Enabled() bool {
+	if qr == nil {
+		return false
+	}
+	qr.Lock()
+	defer qr.Unlock()
+	return len(qr.rules) > 0
+}
+
+// rewrite returns the matched rule and the rewritten SQL. The SQL is empty if it can't be rewritten.
+func (qr *QueryRewriter) rewrite(stmt *sqlStmt) (*rewriteRule, string) {
+	qr.Lock()
+	rules := qr.rules
+	qr.Unlock()
+	normalized, digest := stmt.normalize()
+	rule, ok := rules[digest]
+	if !ok {
+		return nil, ""
+	}
+	// Verify the extracted literals by normalizing again, because the lexer is not as strict as the parser.
+	params, stripped := lex.ExtractParams(stmt.sql)
+	if parser.Normalize(stripped, "ON") != normalized {
+		metrics.QueryRewriteCounter.WithLabelValues(rule.name, rewriteTypeSkip).Inc()
+		return rule, ""
+	}
+	newSQL, ok := lex.FillParams(rule.replacement, params)
+	if !ok {
+		metrics.QueryRewriteCounter.WithLabelValues(rule.name, rewriteTypeSkip).Inc()
+		return rule, ""
+	}
+	if rule.dryRun {
+		metrics.QueryRewriteCounter.WithLabelValues(rule.name, rewriteTypeDryRun).Inc()
+	} else {
+		metrics.QueryRewriteCounter.WithLabelValues(rule.name, rewriteTypeRewrite).Inc()
+	}
+	return rule, newSQL
+}
+
+// rewriteQuery returns the rewritten request, or the original one if it's not rewritten.
+// The statement is also updated so that the following checks see the rewritten one.
+func (mgr *BackendConnManager) rewriteQuery(request []byte, stmt *sqlStmt) ([]byte, bool) {
+	if stmt == nil {
+		return request, false
+	}
+	rule, newSQL := mgr.config.QueryRewriter.rewrite(stmt)
+	if rule == nil {
+		return request, false
+	}
+	if len(newSQL) == 0 {
+		mgr.logger.Debug("statement matches rewrite rule but can't be rewritten", zap.String("rule", rule.name))
+		return request, false
+	}
+	if rule.dryRun {
+		mgr.logger.Info("statement would be rewritten", zap.String("rule", rule.name), zap.String("query", stmt.sql),
+			zap.String("rewritten", newSQL))
+		return request, false
+	}
+	stmt.setSQL(newSQL)
+	newRequest := make([]byte, 0, len(newSQL)+1)
+	newRequest = append(newRequest, request[0])
+	return append(newRequest, newSQL...), true
+}
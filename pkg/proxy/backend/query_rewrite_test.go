@@ -0,0 +1,171 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tiproxy/lib/config"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func sqlDigest(sql string) string {
+	_, digest := parser.NormalizeDigest(sql)
+	return digest.String()
+}
+
+func TestQueryRewrite(t *testing.T) {
+	qr := NewQueryRewriter(config.QueryRewrite{
+		Enable: true,
+		Rules: []config.QueryRewriteRule{
+			{Name: "hint", Digest: sqlDigest("select * from t where id = 1"), Replacement: "select /*+ use_index(t, idx) */ * from t where id = ?"},
+			{Name: "rename", Digest: sqlDigest("select a from old_t where b = 'x' and c > 1"), Replacement: "select a from new_t where b = ? and c > ?"},
+			{Name: "limit", Digest: sqlDigest("select * from big_t"), Replacement: "select * from big_t limit 1000"},
+			{Name: "in", Digest: sqlDigest("select * from t where id in (1, 2)"), Replacement: "select * from t where id in (?, ?)"},
+			{Name: "dry-run", Digest: sqlDigest("delete from t"), Replacement: "delete from t limit 1000", DryRun: true},
+		},
+	})
+	require.True(t, qr.Enabled())
+
+	tests := []struct {
+		sql    string
+		rule   string
+		newSQL string
+	}{
+		{"SELECT * FROM t WHERE id = -10", "hint", "select /*+ use_index(t, idx) */ * from t where id = -10"},
+		{"select * from t where id = ?", "hint", "select /*+ use_index(t, idx) */ * from t where id = ?"},
+		{"select a from old_t where b = 'it''s' and c > 2.5", "rename", "select a from new_t where b = 'it''s' and c > 2.5"},
+		{"select * from big_t", "limit", "select * from big_t limit 1000"},
+		// the number of literals doesn't match
+		{"select * from t where id in (1, 2, 3)", "in", ""},
+		{"select * from t where id in (4, 5)", "in", "select * from t where id in (4, 5)"},
+		{"delete from t", "dry-run", "delete from t limit 1000"},
+		{"select * from t2", "", ""},
+	}
+	for i, test := range tests {
+		rule, newSQL := qr.rewrite(&sqlStmt{sql: test.sql})
+		if len(test.rule) == 0 {
+			require.Nil(t, rule, "case %d", i)
+			continue
+		}
+		require.NotNil(t, rule, "case %d", i)
+		require.Equal(t, test.rule, rule.name, "case %d", i)
+		require.Equal(t, test.newSQL, newSQL, "case %d", i)
+	}
+
+	qr.SetConfig(config.QueryRewrite{Rules: []config.QueryRewriteRule{{Digest: "abc", Replacement: "select 1"}}})
+	require.False(t, qr.Enabled())
+	var nilRewriter *QueryRewriter
+	require.False(t, nilRewriter.Enabled())
+}
+
+func TestRewriteCmd(t *testing.T) {
+	qr := NewQueryRewriter(config.QueryRewrite{
+		Enable: true,
+		Rules: []config.QueryRewriteRule{
+			{Digest: sqlDigest("select * from t where id = 1"), Replacement: "select * from t use index(idx) where id = ?"},
+			{Digest: sqlDigest("select * from t2"), Replacement: "select * from t2 limit 10", DryRun: true},
+		},
+	})
+	ts := newBackendMgrTester(t, func(config *testConfig) {
+		config.proxyConfig.bcConfig.QueryRewriter = qr
+	})
+	var received string
+	receive := func(packetIO pnet.PacketIO) error {
+		packetIO.ResetSequence()
+		pkt, err := packetIO.ReadPacket()
+		require.NoError(t, err)
+		received = pnet.ParseQueryPacket(pkt[1:])
+		return ts.mb.respondOK(packetIO)
+	}
+	query := func(sql string) func(packetIO pnet.PacketIO) error {
+		return func(packetIO pnet.PacketIO) error {
+			ts.mc.sql = sql
+			return ts.mc.request(packetIO)
+		}
+	}
+	runners := []runner{
+		{
+			client:  ts.mc.authenticate,
+			proxy:   ts.firstHandshake4Proxy,
+			backend: ts.handshake4Backend,
+		},
+		{
+			client:  query("select * from t where id = 100"),
+			proxy:   ts.forwardCmd4Proxy,
+			backend: receive,
+		},
+		{
+			client:  query("select * from t2"),
+			proxy:   ts.forwardCmd4Proxy,
+			backend: receive,
+		},
+	}
+	for i := range runners {
+		ts.runTests(runners[i : i+1])
+		switch i {
+		case 1:
+			require.Equal(t, "select * from t use index(idx) where id = 100", received)
+		case 2:
+			require.Equal(t, "select * from t2", received)
+		}
+	}
+}
+
+func TestFirewallBeforeRewrite(t *testing.T) {
+	qr := NewQueryRewriter(config.QueryRewrite{
+		Enable: true,
+		Rules: []config.QueryRewriteRule{
+			{Digest: sqlDigest("select * from secret where id = 1"), Replacement: "select * from t where id = ?"},
+			{Digest: sqlDigest("select * from t2 where id = 1"), Replacement: "select * from secret2 where id = ?"},
+		},
+	})
+	fw := NewFirewall(config.Firewall{
+		Enable: true,
+		Rules: []config.FirewallRule{
+			{Name: "no-secret", Action: config.FirewallActionDeny, Digest: sqlDigest("select * from secret where id = 1")},
+			{Name: "no-secret2", Action: config.FirewallActionDeny, Digest: sqlDigest("select * from secret2 where id = 1")},
+		},
+	})
+	ts := newBackendMgrTester(t, func(config *testConfig) {
+		config.proxyConfig.bcConfig.QueryRewriter = qr
+		config.proxyConfig.bcConfig.Firewall = fw
+	})
+	// The statement is denied either before or after it's rewritten, so it's never sent to the backend.
+	deny := func(sql string) runner {
+		return runner{
+			client: func(packetIO pnet.PacketIO) error {
+				ts.mc.sql = sql
+				require.NoError(t, ts.mc.request(packetIO))
+				var myErr *mysql.MyError
+				require.ErrorAs(t, ts.mc.mysqlErr, &myErr)
+				require.Equal(t, uint16(mysql.ER_SPECIFIC_ACCESS_DENIED_ERROR), myErr.Code)
+				ts.mc.mysqlErr = nil
+				return nil
+			},
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				clientIO.ResetSequence()
+				request, err := clientIO.ReadPacket()
+				require.NoError(t, err)
+				err = ts.mp.ExecuteCmd(context.Background(), request)
+				require.True(t, pnet.IsMySQLError(err))
+				return nil
+			},
+		}
+	}
+	runners := []runner{
+		{
+			client:  ts.mc.authenticate,
+			proxy:   ts.firstHandshake4Proxy,
+			backend: ts.handshake4Backend,
+		},
+		deny("select * from secret where id = 100"),
+		deny("select * from t2 where id = 100"),
+	}
+	ts.runTests(runners)
+}
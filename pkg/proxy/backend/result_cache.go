@@ -0,0 +1,212 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"container/list"
+	"reflect"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/metrics"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/util/lex"
+)
+
+const (
+	defaultResultCacheMaxMemory     = 64 * 1024 * 1024
+	defaultResultCacheMaxResultSize = 1024 * 1024
+	// resultEntryOverhead is the estimated memory of an entry besides the packets.
+	resultEntryOverhead = 128
+)
+
+const (
+	resultCacheHit  = "hit"
+	resultCacheMiss = "miss"
+)
+
+type resultCacheRule struct {
+	digest string
+	regex  *regexp.Regexp
+	ttl    time.Duration
+}
+
+// resultCacheKey identifies a cached result. It uses the original SQL instead of the normalized one because
+// the results differ for different literals. The collation and the DEPRECATE_EOF capability affect the packets.
+// The namespaces may route to different clusters, so the same user in different namespaces doesn't share results.
+type resultCacheKey struct {
+	namespace  string
+	user       string
+	db         string
+	sql        string
+	collation  uint8
+	capability pnet.Capability
+}
+
+type cachedResult struct {
+	key      resultCacheKey
+	packets  [][]byte
+	size     int
+	expireAt time.Time
+}
+
+// ResultCache caches the result-set packets of read-only queries that match the rules.
+// The least recently used results are evicted when the memory exceeds the limit.
+type ResultCache struct {
+	sync.Mutex
+	cfg           *config.ResultCache
+	enable        bool
+	rules         []resultCacheRule
+	maxMemory     int
+	maxResultSize int
+	memory        int
+	entries       map[resultCacheKey]*list.Element
+	lru           *list.List
+}
+
+// NewResultCache creates a ResultCache.
+func NewResultCache(cfg config.ResultCache) *ResultCache {
+	rc := &ResultCache{
+		entries: make(map[resultCacheKey]*list.Element),
+		lru:     list.New(),
+	}
+	rc.SetConfig(cfg)
+	return rc
+}
+
+// SetConfig updates the rules and limits. All the cached results are dropped if the config changes.
+// The config should be already checked.
+func (rc *ResultCache) SetConfig(cfg config.ResultCache) {
+	rc.Lock()
+	unchanged := rc.cfg != nil && reflect.DeepEqual(*rc.cfg, cfg)
+	rc.Unlock()
+	if unchanged {
+		return
+	}
+	rules := make([]resultCacheRule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rule := resultCacheRule{digest: r.Digest, ttl: r.TTL}
+		if len(r.Regex) > 0 {
+			regex, err := regexp.Compile(r.Regex)
+			if err != nil {
+				continue
+			}
+			rule.regex = regex
+		}
+		rules = append(rules, rule)
+	}
+	// Copy the rules so that the caller's modification won't be taken as unchanged.
+	cfg.Rules = slices.Clone(cfg.Rules)
+	rc.Lock()
+	defer rc.Unlock()
+	rc.cfg = &cfg
+	rc.enable = cfg.Enable && len(rules) > 0
+	rc.rules = rules
+	rc.maxMemory = cfg.MaxMemory * 1024 * 1024
+	if rc.maxMemory <= 0 {
+		rc.maxMemory = defaultResultCacheMaxMemory
+	}
+	rc.maxResultSize = cfg.MaxResultSize * 1024
+	if rc.maxResultSize <= 0 {
+		rc.maxResultSize = defaultResultCacheMaxResultSize
+	}
+	rc.clearLocked()
+}
+
+// Enabled returns whether any query may be cached.
+func (rc *ResultCache) Enabled() bool {
+	if rc == nil {
+		return false
+	}
+	rc.Lock()
+	defer rc.Unlock()
+	return rc.enable
+}
+
+// match returns the TTL of the first matched rule. Only read-only queries can be cached.
+func (rc *ResultCache) match(stmt *sqlStmt) (time.Duration, bool) {
+	rc.Lock()
+	rules := rc.rules
+	rc.Unlock()
+	if len(rules) == 0 || !lex.IsCacheable(stmt.sql) {
+		return 0, false
+	}
+	normalized, digest := stmt.normalize()
+	for _, rule := range rules {
+		if len(rule.digest) > 0 && rule.digest == digest {
+			return rule.ttl, true
+		}
+		if rule.regex != nil && rule.regex.MatchString(normalized) {
+			return rule.ttl, true
+		}
+	}
+	return 0, false
+}
+
+func (rc *ResultCache) get(key resultCacheKey) ([][]byte, bool) {
+	rc.Lock()
+	defer rc.Unlock()
+	elem, ok := rc.entries[key]
+	if ok {
+		result := elem.Value.(*cachedResult)
+		if time.Now().Before(result.expireAt) {
+			rc.lru.MoveToFront(elem)
+			metrics.ResultCacheCounter.WithLabelValues(resultCacheHit).Inc()
+			return result.packets, true
+		}
+		rc.removeLocked(elem)
+	}
+	metrics.ResultCacheCounter.WithLabelValues(resultCacheMiss).Inc()
+	return nil, false
+}
+
+func (rc *ResultCache) put(key resultCacheKey, packets [][]byte, ttl time.Duration) {
+	size := resultEntryOverhead + len(key.sql)
+	for _, pkt := range packets {
+		size += len(pkt)
+	}
+	rc.Lock()
+	defer rc.Unlock()
+	if !rc.enable || size > rc.maxResultSize || size > rc.maxMemory {
+		return
+	}
+	if elem, ok := rc.entries[key]; ok {
+		rc.removeLocked(elem)
+	}
+	for rc.memory+size > rc.maxMemory {
+		rc.removeLocked(rc.lru.Back())
+	}
+	rc.entries[key] = rc.lru.PushFront(&cachedResult{
+		key:      key,
+		packets:  packets,
+		size:     size,
+		expireAt: time.Now().Add(ttl),
+	})
+	rc.memory += size
+	metrics.ResultCacheBytesGauge.Set(float64(rc.memory))
+}
+
+// resultSizeLimit returns the max size of a result that can be cached.
+func (rc *ResultCache) resultSizeLimit() int {
+	rc.Lock()
+	defer rc.Unlock()
+	return rc.maxResultSize
+}
+
+func (rc *ResultCache) removeLocked(elem *list.Element) {
+	result := rc.lru.Remove(elem).(*cachedResult)
+	delete(rc.entries, result.key)
+	rc.memory -= result.size
+	metrics.ResultCacheBytesGauge.Set(float64(rc.memory))
+}
+
+func (rc *ResultCache) clearLocked() {
+	rc.entries = make(map[resultCacheKey]*list.Element)
+	rc.lru.Init()
+	rc.memory = 0
+	metrics.ResultCacheBytesGauge.Set(0)
+}
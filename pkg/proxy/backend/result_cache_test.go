@@ -0,0 +1,248 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tiproxy/lib/config"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultCacheMatch(t *testing.T) {
+	_, digest := parser.NormalizeDigest("select * from t where id = 1")
+	rc := NewResultCache(config.ResultCache{
+		Enable: true,
+		Rules: []config.ResultCacheRule{
+			{Digest: digest.String(), TTL: time.Second},
+			{Regex: "from `?t2`?", TTL: time.Minute},
+		},
+	})
+	require.True(t, rc.Enabled())
+
+	tests := []struct {
+		sql   string
+		ttl   time.Duration
+		match bool
+	}{
+		{"SELECT * FROM t WHERE id = 2", time.Second, true},
+		{"select a from t2", time.Minute, true},
+		{"select * from t3", 0, false},
+		{"select * from t2 for update", 0, false},
+		{"update t2 set a = 1", 0, false},
+		{"delete from t2", 0, false},
+	}
+	for i, test := range tests {
+		ttl, ok := rc.match(&sqlStmt{sql: test.sql})
+		require.Equal(t, test.match, ok, "case %d", i)
+		require.Equal(t, test.ttl, ttl, "case %d", i)
+	}
+
+	// no rules means disabled
+	rc.SetConfig(config.ResultCache{Enable: true})
+	require.False(t, rc.Enabled())
+	var nilCache *ResultCache
+	require.False(t, nilCache.Enabled())
+}
+
+func TestResultCacheGetPut(t *testing.T) {
+	cfg := config.ResultCache{
+		Enable:        true,
+		MaxMemory:     1,
+		MaxResultSize: 1,
+		Rules:         []config.ResultCacheRule{{Regex: ".*", TTL: time.Hour}},
+	}
+	rc := NewResultCache(cfg)
+	key := resultCacheKey{user: "u1", db: "db", sql: "select 1"}
+	packets := [][]byte{{0x01}, {0x02}}
+	_, ok := rc.get(key)
+	require.False(t, ok)
+	rc.put(key, packets, time.Hour)
+	result, ok := rc.get(key)
+	require.True(t, ok)
+	require.Equal(t, packets, result)
+
+	// different sessions don't share results
+	_, ok = rc.get(resultCacheKey{user: "u2", db: "db", sql: "select 1"})
+	require.False(t, ok)
+	_, ok = rc.get(resultCacheKey{user: "u1", db: "db2", sql: "select 1"})
+	require.False(t, ok)
+
+	// the result is too large
+	bigKey := resultCacheKey{sql: "select 2"}
+	rc.put(bigKey, [][]byte{make([]byte, 1024)}, time.Hour)
+	_, ok = rc.get(bigKey)
+	require.False(t, ok)
+
+	// the result expires
+	expiredKey := resultCacheKey{sql: "select 3"}
+	rc.put(expiredKey, packets, time.Millisecond)
+	require.Eventually(t, func() bool {
+		_, ok := rc.get(expiredKey)
+		return !ok
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// the unchanged config keeps the results
+	rc.SetConfig(cfg)
+	_, ok = rc.get(key)
+	require.True(t, ok)
+	// the changed config drops the results
+	cfg.Rules[0].TTL = time.Minute
+	rc.SetConfig(cfg)
+	_, ok = rc.get(key)
+	require.False(t, ok)
+}
+
+func TestResultCacheEvict(t *testing.T) {
+	rc := NewResultCache(config.ResultCache{
+		Enable:        true,
+		MaxMemory:     1,
+		MaxResultSize: 1024,
+		Rules:         []config.ResultCacheRule{{Regex: ".*", TTL: time.Hour}},
+	})
+	pkt := make([]byte, 400*1024)
+	keys := []resultCacheKey{{sql: "select 1"}, {sql: "select 2"}, {sql: "select 3"}}
+	rc.put(keys[0], [][]byte{pkt}, time.Hour)
+	rc.put(keys[1], [][]byte{pkt}, time.Hour)
+	// visit the first one so that the second one is the least recently used
+	_, ok := rc.get(keys[0])
+	require.True(t, ok)
+	rc.put(keys[2], [][]byte{pkt}, time.Hour)
+
+	_, ok = rc.get(keys[1])
+	require.False(t, ok)
+	for _, key := range []resultCacheKey{keys[0], keys[2]} {
+		_, ok = rc.get(key)
+		require.True(t, ok)
+	}
+	rc.Lock()
+	require.LessOrEqual(t, rc.memory, rc.maxMemory)
+	rc.Unlock()
+}
+
+func TestResultCacheQuery(t *testing.T) {
+	tc := newTCPConnSuite(t)
+	for _, capability := range []pnet.Capability{defaultTestBackendCapability &^ pnet.ClientDeprecateEOF, defaultTestBackendCapability | pnet.ClientDeprecateEOF} {
+		ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+			cfg.clientConfig.sql = "select * from t"
+			cfg.clientConfig.capability = capability
+			cfg.proxyConfig.capability = capability
+			cfg.backendConfig.capability = capability
+			cfg.backendConfig.respondType = responseTypeResultSet
+			cfg.backendConfig.columns = 2
+			cfg.backendConfig.rows = 3
+			cfg.backendConfig.status = pnet.ServerStatusAutocommit
+		})
+		ts.mp.cmdProcessor.resultCache = NewResultCache(config.ResultCache{
+			Enable: true,
+			Rules:  []config.ResultCacheRule{{Regex: "from `t`", TTL: time.Hour}},
+		})
+		// the first query is forwarded to the backend
+		inBytes := ts.tc.clientIO.InBytes()
+		ts.executeCmd(t, nil)
+		resultSize := ts.tc.clientIO.InBytes() - inBytes
+		require.EqualValues(t, 3, ts.mp.cmdProcessor.sentRows)
+
+		// the second query is served by the cache without the backend
+		inBytes = ts.tc.clientIO.InBytes()
+		ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+			require.NoError(t, ts.mc.err)
+			require.NoError(t, ts.mp.err)
+		}, ts.mc.request, nil, ts.mp.processCmd)
+		require.Equal(t, resultSize, ts.tc.clientIO.InBytes()-inBytes)
+		require.EqualValues(t, 6, ts.mp.cmdProcessor.sentRows)
+		clean()
+	}
+}
+
+func TestResultCacheMultiStmts(t *testing.T) {
+	tc := newTCPConnSuite(t)
+	for _, capability := range []pnet.Capability{defaultTestBackendCapability &^ pnet.ClientDeprecateEOF, defaultTestBackendCapability | pnet.ClientDeprecateEOF} {
+		ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+			cfg.clientConfig.sql = "select * from t; select * from t"
+			cfg.clientConfig.capability = capability
+			cfg.proxyConfig.capability = capability
+			cfg.backendConfig.capability = capability
+			cfg.backendConfig.respondType = responseTypeResultSet
+			cfg.backendConfig.columns = 2
+			cfg.backendConfig.rows = 3
+			cfg.backendConfig.stmtNum = 2
+			cfg.backendConfig.status = pnet.ServerStatusAutocommit
+		})
+		rc := NewResultCache(config.ResultCache{
+			Enable: true,
+			Rules:  []config.ResultCacheRule{{Regex: "from `t`", TTL: time.Hour}},
+		})
+		ts.mp.cmdProcessor.resultCache = rc
+		sql := "select * from t; select * from t"
+		_, _, ok := ts.mp.cmdProcessor.matchResultCache(pnet.MakeQueryPacket(sql), &sqlStmt{sql: sql})
+		require.True(t, ok)
+		// all the results are forwarded but not cached
+		ts.executeCmd(t, nil)
+		require.EqualValues(t, 6, ts.mp.cmdProcessor.sentRows)
+		rc.Lock()
+		require.Zero(t, rc.memory)
+		rc.Unlock()
+		clean()
+	}
+}
+
+func TestResultCacheSession(t *testing.T) {
+	rc := NewResultCache(config.ResultCache{
+		Enable: true,
+		Rules:  []config.ResultCacheRule{{Regex: ".*", TTL: time.Hour}},
+	})
+	request := pnet.MakeQueryPacket("select * from t")
+	stmt := &sqlStmt{sql: "select * from t"}
+	newProcessor := func(namespace string) *CmdProcessor {
+		cp := NewCmdProcessor(nil)
+		cp.resultCache, cp.namespace, cp.capability = rc, namespace, pnet.ClientMultiStatements
+		cp.setSession("u1", "db", 0)
+		return cp
+	}
+
+	// the same user in different namespaces doesn't share results
+	cp1, cp2 := newProcessor("ns1"), newProcessor("ns2")
+	key1, _, ok := cp1.matchResultCache(request, stmt)
+	require.True(t, ok)
+	rc.put(key1, [][]byte{{0x01}}, time.Hour)
+	key2, _, ok := cp2.matchResultCache(request, stmt)
+	require.True(t, ok)
+	_, ok = rc.get(key2)
+	require.False(t, ok)
+	key2, _, ok = newProcessor("ns1").matchResultCache(request, stmt)
+	require.True(t, ok)
+	_, ok = rc.get(key2)
+	require.True(t, ok)
+
+	// stop caching after the session variables change until the session is reset
+	for _, sql := range []string{"set names latin1", "set @@character_set_results = gbk", "SET collation_connection = 'utf8mb4_bin'",
+		"set @a = 1", "set time_zone = '+08:00'", "SET SESSION_STATES '{}'", "select 1; set sql_mode = ''"} {
+		cp := newProcessor("ns1")
+		cp.trackSession(pnet.MakeQueryPacket(sql))
+		_, _, ok = cp.matchResultCache(request, stmt)
+		require.False(t, ok, sql)
+		cp.setSession("u1", "db", 0)
+		_, _, ok = cp.matchResultCache(request, stmt)
+		require.True(t, ok, sql)
+	}
+
+	// stop caching when autocommit is off
+	cp := newProcessor("ns1")
+	cp.updateTxnStatus(0)
+	_, _, ok = cp.matchResultCache(request, stmt)
+	require.False(t, ok)
+	cp.updateTxnStatus(pnet.ServerStatusAutocommit)
+	_, _, ok = cp.matchResultCache(request, stmt)
+	require.True(t, ok)
+
+	// the statements that depend on the session are not cached
+	for _, sql := range []string{"select @a", "select @@sql_mode", "select last_insert_id()", "select connection_id()", "select found_rows()"} {
+		_, _, ok = cp.matchResultCache(pnet.MakeQueryPacket(sql), &sqlStmt{sql: sql})
+		require.False(t, ok, sql)
+	}
+}
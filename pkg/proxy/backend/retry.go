@@ -0,0 +1,154 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"time"
+
+	"github.com/pingcap/tiproxy/pkg/balance/router"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/util/lex"
+	"github.com/siddontang/go/hack"
+	"go.uber.org/zap"
+)
+
+const (
+	retryReadSucceeded = "succeeded"
+	retryReadFailed    = "failed"
+	// maxRetryBackends is the number of backends to try when the backend fails.
+	maxRetryBackends = 3
+)
+
+// retryableRequest returns whether the request is a read that can be retried, and whether it keeps the session states.
+func retryableRequest(request []byte) (retryable, keepStates bool) {
+	switch pnet.Command(request[0]) {
+	case pnet.ComPing:
+		return false, true
+	case pnet.ComQuery:
+		sql := pnet.ParseQueryPacket(request[1:])
+		if !lex.IsReadOnly(sql) {
+			return false, false
+		}
+		// Statements like SET and USE are retryable but they change the session states.
+		switch lex.NewLexer(sql).NextToken() {
+		case "SHOW", "DESC", "DESCRIBE":
+			return true, true
+		case "SELECT", "WITH", "TABLE":
+			return true, !lex.ChangesSessionStates(sql)
+		}
+		return true, false
+	}
+	return false, false
+}
+
+// prepareRetry returns whether the request can be retried on another backend if the backend fails.
+// It takes a snapshot if the saved one is outdated, and drops the snapshot if the request may change the states.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) prepareRetry(request []byte, backendIO pnet.PacketIO, waitingRedirect bool) bool {
+	ss := &mgr.snapshot
+	if !mgr.config.RetryReads && !ss.valid {
+		return false
+	}
+	retryable, keepStates := retryableRequest(request)
+	retryable = retryable && mgr.config.RetryReads && mgr.backendRouter != nil && !waitingRedirect &&
+		mgr.cmdProcessor.serverStatus&StatusInTrans == 0
+	if retryable && (!ss.valid || time.Since(ss.queryTime) >= sessionTokenRefreshInterval) && time.Since(ss.errTime) >= sessionTokenRefreshInterval {
+		_ = mgr.takeSnapshot(backendIO)
+	}
+	retryable = retryable && ss.valid
+	// The states may be changed by this command, so they need to be queried again.
+	if !keepStates && ss.valid {
+		mgr.releaseSnapshot()
+	}
+	return retryable
+}
+
+// failover connects to another backend and restores the session with the snapshot.
+// The failed backend is tried after the others because it may be restarting.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) failover(failedIO pnet.PacketIO) (pnet.PacketIO, error) {
+	failedAddr := failedIO.RemoteAddr().String()
+	ci := router.ClientInfo{ClientAddr: mgr.clientIO.RemoteAddr(), ProxyAddr: mgr.clientIO.ProxyAddr(), SNI: mgr.clientIO.ServerName(),
+		Group: mgr.config.RoutingGroup}
+	selector := mgr.backendRouter.GetBackendSelector(ci)
+	if err := mgr.updateAuthInfoFromSessionStates(hack.Slice(mgr.snapshot.sessionStates)); err != nil {
+		return nil, err
+	}
+	var (
+		backend      router.BackendInst
+		newBackendIO pnet.PacketIO
+		connID       uint64
+		err          error
+		skipped      bool
+	)
+	for range maxRetryBackends {
+		if backend, err = selector.Next(); err != nil {
+			break
+		}
+		if backend.Addr() == failedAddr && !skipped {
+			skipped = true
+			selector.Finish(mgr, false)
+			continue
+		}
+		if newBackendIO, connID, err = mgr.dialRetryBackend(backend); err == nil {
+			break
+		}
+		selector.Finish(mgr, false)
+	}
+	if newBackendIO == nil {
+		if err == nil {
+			err = router.ErrNoBackend
+		}
+		return nil, err
+	}
+
+	// Move the connection from the failed backend to the new one in the router.
+	if eventReceiver := mgr.getEventReceiver(); eventReceiver != nil {
+		if err := eventReceiver.OnConnClosed(failedAddr, "", mgr); err != nil {
+			mgr.logger.Warn("notify connection closed error", zap.String("backend_addr", failedAddr), zap.Error(err))
+		}
+	}
+	selector.Finish(mgr, true)
+	mgr.updateTraffic(failedIO)
+	mgr.inBytes, mgr.inPackets, mgr.outBytes, mgr.outPackets = 0, 0, 0, 0
+	mgr.updateTraffic(newBackendIO)
+	if ignoredErr := failedIO.Close(); ignoredErr != nil && !pnet.IsDisconnectError(ignoredErr) {
+		mgr.logger.Debug("close failed backend connection failed", zap.Error(ignoredErr))
+	}
+	mgr.backendConnID.Store(connID)
+	mgr.backendIO.Store(&newBackendIO)
+	mgr.curBackend.Store(&backend)
+	mgr.setKeepAlive()
+	mgr.handshakeHandler.OnHandshake(mgr, mgr.ServerAddr(), nil, SrcNone)
+	return newBackendIO, nil
+}
+
+// dialRetryBackend connects to the backend with the session token of the snapshot and restores the session states.
+func (mgr *BackendConnManager) dialRetryBackend(backend router.BackendInst) (pnet.PacketIO, uint64, error) {
+	tlsConfig, err := mgr.getBackendTLS(mgr, backend)
+	if err != nil {
+		return nil, 0, err
+	}
+	addr := backend.Addr()
+	cn, err := net.DialTimeout("tcp", addr, mgr.config.DialTimeout)
+	if err != nil {
+		mgr.handshakeHandler.OnHandshake(mgr, addr, err, SrcBackendNetwork)
+		return nil, 0, err
+	}
+	backendIO := pnet.PacketIO(pnet.NewPacketIO(cn, mgr.logger, mgr.config.ConnBufferSize, pnet.WithRemoteAddr(addr, cn.RemoteAddr()), pnet.WithWrapError(ErrBackendConn)))
+	var connID uint64
+	if connID, err = mgr.authenticator.handshakeSecondTime(mgr.logger, mgr.clientIO, backendIO, tlsConfig, mgr.snapshot.sessionToken); err == nil {
+		err = mgr.initSessionStates(backendIO, mgr.snapshot.sessionStates)
+	} else {
+		mgr.handshakeHandler.OnHandshake(mgr, addr, err, Error2Source(err))
+	}
+	if err != nil {
+		if ignoredErr := backendIO.Close(); ignoredErr != nil && !pnet.IsDisconnectError(ignoredErr) {
+			mgr.logger.Error("close new backend connection failed", zap.Error(ignoredErr))
+		}
+		return nil, 0, err
+	}
+	return backendIO, connID, nil
+}
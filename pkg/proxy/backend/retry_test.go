@@ -0,0 +1,110 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"testing"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryableRequest(t *testing.T) {
+	tests := []struct {
+		request    []byte
+		retryable  bool
+		keepStates bool
+	}{
+		{pnet.MakeQueryPacket("select 1"), true, true},
+		{pnet.MakeQueryPacket("show tables"), true, true},
+		{pnet.MakeQueryPacket("set @a = 1"), true, false},
+		{pnet.MakeQueryPacket("select @a := 1"), true, false},
+		{pnet.MakeQueryPacket("select a into @v from t"), true, false},
+		{pnet.MakeQueryPacket("select last_insert_id(10)"), true, false},
+		{pnet.MakeQueryPacket("select last_insert_id()"), true, true},
+		{pnet.MakeQueryPacket("use db"), true, false},
+		{pnet.MakeQueryPacket("select * from t for update"), false, false},
+		{pnet.MakeQueryPacket("insert into t values (1)"), false, false},
+		{[]byte{pnet.ComPing.Byte()}, false, true},
+		{[]byte{pnet.ComStmtExecute.Byte(), 1, 0, 0, 0}, false, false},
+	}
+	for i, test := range tests {
+		retryable, keepStates := retryableRequest(test.request)
+		require.Equal(t, test.retryable, retryable, "case %d", i)
+		require.Equal(t, test.keepStates, keepStates, "case %d", i)
+	}
+}
+
+func TestRetryRead(t *testing.T) {
+	ts := newBackendMgrTester(t, func(config *testConfig) {
+		config.proxyConfig.bcConfig.RetryReads = true
+	})
+	respondStates := func(packetIO pnet.PacketIO) error {
+		ts.mb.respondType = responseTypeResultSet
+		return ts.mb.respond(packetIO)
+	}
+	query := func(sql string) func(packetIO pnet.PacketIO) error {
+		return func(packetIO pnet.PacketIO) error {
+			ts.mc.sql = sql
+			return ts.mc.request(packetIO)
+		}
+	}
+	runners := []runner{
+		{
+			client:  ts.mc.authenticate,
+			proxy:   ts.firstHandshake4Proxy,
+			backend: ts.handshake4Backend,
+		},
+		// the backend fails and the read is retried on a new connection
+		{
+			client: query("select 1"),
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				backend1 := ts.mp.backendIO.Load()
+				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
+				require.NotEqual(t, backend1, ts.mp.backendIO.Load())
+				ts.mp.getEventReceiver().(*mockEventReceiver).checkEvent(t, eventClose)
+				return nil
+			},
+			backend: func(packetIO pnet.PacketIO) error {
+				require.NoError(t, respondStates(packetIO))
+				_, err := packetIO.ReadPacket()
+				require.NoError(t, err)
+				require.NoError(t, packetIO.Close())
+				require.NoError(t, ts.handshake4Backend(packetIO))
+				// SET SESSION_STATES and the client request
+				for range 2 {
+					require.NoError(t, ts.respondWithNoTxn4Backend(ts.tc.backendIO))
+				}
+				return nil
+			},
+		},
+		// the saved states are still valid
+		{
+			client:  query("select 2"),
+			proxy:   ts.forwardCmd4Proxy,
+			backend: ts.respondWithNoTxn4Backend,
+		},
+		// SET is retryable but it changes the states
+		{
+			client:  query("set @a = 1"),
+			proxy:   ts.forwardCmd4Proxy,
+			backend: ts.respondWithNoTxn4Backend,
+		},
+		{
+			client: query("select @a"),
+			proxy:  ts.forwardCmd4Proxy,
+			backend: func(packetIO pnet.PacketIO) error {
+				require.NoError(t, respondStates(packetIO))
+				return ts.respondWithNoTxn4Backend(packetIO)
+			},
+		},
+		// writes don't query the states
+		{
+			client:  query("insert into t values (1)"),
+			proxy:   ts.forwardCmd4Proxy,
+			backend: ts.respondWithNoTxn4Backend,
+		},
+	}
+	ts.runTests(runners)
+}
@@ -0,0 +1,188 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/metrics"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSnapshotInterval  = 30 * time.Second
+	defaultSnapshotMaxMemory = 128 * 1024 * 1024
+)
+
+const (
+	failoverSucceeded = "succeeded"
+	failoverFailed    = "failed"
+	// The session can't fail over because it has no valid snapshot, e.g. it's in a transaction.
+	failoverNoSnapshot = "no_snapshot"
+)
+
+// SessionFailover restores the idle sessions on other backends when their backends are lost.
+// It limits the total memory of the session snapshots kept by the connections.
+type SessionFailover struct {
+	enable    atomic.Bool
+	interval  atomic.Int64
+	maxMemory atomic.Int64
+	memory    atomic.Int64
+}
+
+// NewSessionFailover creates a SessionFailover.
+func NewSessionFailover(cfg config.SessionFailover) *SessionFailover {
+	sf := &SessionFailover{}
+	sf.SetConfig(cfg)
+	return sf
+}
+
+// SetConfig updates the config. The existing snapshots are kept even if they exceed the new memory limit.
+func (sf *SessionFailover) SetConfig(cfg config.SessionFailover) {
+	interval := cfg.SnapshotInterval
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+	maxMemory := int64(cfg.MaxMemory) * 1024 * 1024
+	if maxMemory <= 0 {
+		maxMemory = defaultSnapshotMaxMemory
+	}
+	sf.interval.Store(int64(interval))
+	sf.maxMemory.Store(maxMemory)
+	sf.enable.Store(cfg.Enable)
+}
+
+// Enabled returns whether the idle sessions should keep snapshots.
+func (sf *SessionFailover) Enabled() bool {
+	return sf != nil && sf.enable.Load()
+}
+
+func (sf *SessionFailover) snapshotInterval() time.Duration {
+	return time.Duration(sf.interval.Load())
+}
+
+// acquire reserves memory for a snapshot. It returns false if the memory exceeds the limit.
+func (sf *SessionFailover) acquire(size int) bool {
+	if sf == nil {
+		return true
+	}
+	if sf.memory.Add(int64(size)) > sf.maxMemory.Load() {
+		sf.memory.Add(-int64(size))
+		return false
+	}
+	metrics.SessionSnapshotBytesGauge.Add(float64(size))
+	return true
+}
+
+func (sf *SessionFailover) release(size int) {
+	if sf == nil || size == 0 {
+		return
+	}
+	sf.memory.Add(-int64(size))
+	metrics.SessionSnapshotBytesGauge.Sub(float64(size))
+}
+
+// sessionSnapshot keeps the session states to restore the session on another backend when the backend fails.
+type sessionSnapshot struct {
+	sessionStates string
+	sessionToken  string
+	// queryTime is the time when the states are queried and valid means no command has changed them since then.
+	queryTime time.Time
+	valid     bool
+	// errTime is the time when querying the states failed, e.g. the session has unmigratable states.
+	errTime time.Time
+	// size is the memory reserved in SessionFailover.
+	size int
+}
+
+// takeSnapshot queries the session states and saves them as the snapshot.
+// If the backend connection fails, the previous snapshot is kept because the states are not changed.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) takeSnapshot(backendIO pnet.PacketIO) error {
+	sessionStates, sessionToken, err := mgr.querySessionStates(backendIO)
+	if err != nil && errors.Is(err, ErrBackendConn) {
+		return err
+	}
+	if err == nil && len(sessionToken) == 0 {
+		err = errors.New("session token is empty")
+	}
+	mgr.releaseSnapshot()
+	ss := &mgr.snapshot
+	if err == nil {
+		size := len(sessionStates) + len(sessionToken)
+		if mgr.config.SessionFailover.acquire(size) {
+			ss.sessionStates, ss.sessionToken, ss.size = sessionStates, sessionToken, size
+			ss.queryTime, ss.valid = time.Now(), true
+			return nil
+		}
+		err = errors.New("session snapshots exceed the memory limit")
+	}
+	ss.errTime = time.Now()
+	mgr.logger.Debug("taking session snapshot failed", zap.Error(err))
+	return err
+}
+
+// releaseSnapshot drops the snapshot and releases its memory.
+func (mgr *BackendConnManager) releaseSnapshot() {
+	ss := &mgr.snapshot
+	mgr.config.SessionFailover.release(ss.size)
+	ss.sessionStates, ss.sessionToken, ss.size, ss.valid = "", "", 0, false
+}
+
+// snapshotIdleSession refreshes the snapshot of the session between commands so that the session can fail over when
+// the backend is lost. It also finds the lost backend earlier than checkBackendActive.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) snapshotIdleSession() {
+	sf := mgr.config.SessionFailover
+	if !sf.Enabled() || mgr.closeStatus.Load() >= statusNotifyClose || mgr.cmdProcessor.serverStatus&StatusInTrans > 0 {
+		return
+	}
+	// The backend connection is in the pool.
+	ptr := mgr.backendIO.Load()
+	if ptr == nil {
+		return
+	}
+	ss := &mgr.snapshot
+	if (ss.valid && time.Since(ss.queryTime) < sf.snapshotInterval()) || time.Since(ss.errTime) < sf.snapshotInterval() {
+		return
+	}
+	backendIO := *ptr
+	if !backendIO.IsPeerActive() {
+		mgr.onBackendLost(backendIO)
+		return
+	}
+	if err := mgr.takeSnapshot(backendIO); err != nil && errors.Is(err, ErrBackendConn) {
+		mgr.onBackendLost(backendIO)
+	}
+}
+
+// failoverIdleSession restores the idle session on another backend after its backend is lost.
+// It returns false if the session can't fail over.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) failoverIdleSession(lostIO pnet.PacketIO) bool {
+	if !mgr.config.SessionFailover.Enabled() || mgr.backendRouter == nil || mgr.killed.Load() {
+		return false
+	}
+	ss := &mgr.snapshot
+	// The session token expires after sessionTokenLifetime.
+	if !ss.valid || time.Since(ss.queryTime) >= sessionTokenLifetime || mgr.cmdProcessor.serverStatus&StatusInTrans > 0 ||
+		mgr.redirectInfo.Load() != nil {
+		metrics.SessionFailoverCounter.WithLabelValues(failoverNoSnapshot).Inc()
+		return false
+	}
+	lostAddr := lostIO.RemoteAddr().String()
+	if _, err := mgr.failover(lostIO); err != nil {
+		metrics.SessionFailoverCounter.WithLabelValues(failoverFailed).Inc()
+		mgr.logger.Warn("backend connection is lost and session failover failed", zap.String("backend_addr", lostAddr), zap.Error(err))
+		return false
+	}
+	metrics.SessionFailoverCounter.WithLabelValues(failoverSucceeded).Inc()
+	mgr.logger.Info("backend connection is lost, the session fails over to another backend", zap.String("from", lostAddr),
+		zap.String("to", mgr.ServerAddr()))
+	return true
+}
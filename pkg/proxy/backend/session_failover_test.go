@@ -0,0 +1,117 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotMemoryLimit(t *testing.T) {
+	sf := NewSessionFailover(config.SessionFailover{Enable: true, MaxMemory: 1})
+	require.True(t, sf.acquire(512*1024))
+	require.True(t, sf.acquire(512*1024))
+	require.False(t, sf.acquire(1))
+	sf.release(512 * 1024)
+	require.True(t, sf.acquire(1))
+	require.EqualValues(t, 512*1024+1, sf.memory.Load())
+
+	// A disabled SessionFailover still limits the snapshots for retrying reads.
+	sf.SetConfig(config.SessionFailover{MaxMemory: 1})
+	require.False(t, sf.Enabled())
+	require.False(t, sf.acquire(512*1024))
+	require.Equal(t, defaultSnapshotInterval, sf.snapshotInterval())
+}
+
+func TestSessionFailover(t *testing.T) {
+	sf := NewSessionFailover(config.SessionFailover{Enable: true})
+	ts := newBackendMgrTester(t, func(config *testConfig) {
+		config.proxyConfig.bcConfig.SessionFailover = sf
+	})
+	respondStates := func(packetIO pnet.PacketIO) error {
+		ts.mb.respondType = responseTypeResultSet
+		return ts.mb.respond(packetIO)
+	}
+	runners := []runner{
+		{
+			client:  ts.mc.authenticate,
+			proxy:   ts.firstHandshake4Proxy,
+			backend: ts.handshake4Backend,
+		},
+		// the idle session takes a snapshot
+		{
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				ts.mp.processLock.Lock()
+				ts.mp.snapshotIdleSession()
+				ts.mp.processLock.Unlock()
+				require.True(t, ts.mp.snapshot.valid)
+				require.EqualValues(t, ts.mp.snapshot.size, sf.memory.Load())
+				return nil
+			},
+			backend: respondStates,
+		},
+		// the backend is lost and the session fails over
+		{
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				backend1 := *ts.mp.backendIO.Load()
+				require.Eventually(t, func() bool {
+					return !backend1.IsPeerActive()
+				}, 3*time.Second, 10*time.Millisecond)
+				ts.mp.processLock.Lock()
+				ts.mp.lastActiveTime = time.Now().Add(-time.Hour)
+				ts.mp.processLock.Unlock()
+				ts.mp.checkBackendActive()
+				require.Equal(t, statusActive, ts.mp.closeStatus.Load())
+				require.NotEqual(t, backend1, *ts.mp.backendIO.Load())
+				ts.mp.getEventReceiver().(*mockEventReceiver).checkEvent(t, eventClose)
+				return nil
+			},
+			backend: func(packetIO pnet.PacketIO) error {
+				require.NoError(t, packetIO.Close())
+				require.NoError(t, ts.handshake4Backend(packetIO))
+				// SET SESSION_STATES
+				return ts.respondWithNoTxn4Backend(ts.tc.backendIO)
+			},
+		},
+		// the client never notices
+		{
+			client: func(packetIO pnet.PacketIO) error {
+				ts.mc.sql = "insert into t values (1)"
+				return ts.mc.request(packetIO)
+			},
+			proxy:   ts.forwardCmd4Proxy,
+			backend: ts.respondWithNoTxn4Backend,
+		},
+		// the snapshot is dropped after the command and the session can't fail over
+		{
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				require.False(t, ts.mp.snapshot.valid)
+				require.Zero(t, sf.memory.Load())
+				return nil
+			},
+		},
+		{
+			proxy: func(clientIO, backendIO pnet.PacketIO) error {
+				backend2 := *ts.mp.backendIO.Load()
+				require.Eventually(t, func() bool {
+					return !backend2.IsPeerActive()
+				}, 3*time.Second, 10*time.Millisecond)
+				ts.mp.processLock.Lock()
+				ts.mp.lastActiveTime = time.Now().Add(-time.Hour)
+				ts.mp.processLock.Unlock()
+				ts.mp.checkBackendActive()
+				return ts.checkConnClosed4Proxy(clientIO, backendIO)
+			},
+			backend: func(packetIO pnet.PacketIO) error {
+				return packetIO.Close()
+			},
+		},
+	}
+	ts.runTests(runners)
+	require.Equal(t, SrcBackendNetwork, ts.mp.QuitSource())
+}
@@ -0,0 +1,65 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"go.uber.org/zap"
+)
+
+// SlowLog writes the commands whose end-to-end latency exceeds the threshold to the slow log.
+// The latency includes the time waiting for a backend, so it may be longer than the one in the TiDB slow log.
+type SlowLog struct {
+	logger    *zap.Logger
+	threshold atomic.Int64
+}
+
+// NewSlowLog creates a SlowLog. It's disabled if the logger is nil.
+func NewSlowLog(logger *zap.Logger, cfg config.SlowLog) *SlowLog {
+	sl := &SlowLog{logger: logger}
+	sl.SetConfig(cfg)
+	return sl
+}
+
+// SetConfig updates the threshold. The config should be already checked.
+func (sl *SlowLog) SetConfig(cfg config.SlowLog) {
+	sl.threshold.Store(int64(cfg.Threshold))
+}
+
+// Enabled returns whether the slow commands are logged.
+func (sl *SlowLog) Enabled() bool {
+	return sl != nil && sl.logger != nil && sl.threshold.Load() > 0
+}
+
+func (sl *SlowLog) isSlow(queryTime time.Duration) bool {
+	if !sl.Enabled() {
+		return false
+	}
+	return queryTime >= time.Duration(sl.threshold.Load())
+}
+
+func (cp *CmdProcessor) logSlowCmd(record *cmdRecord) {
+	fields := []zap.Field{
+		zap.Uint64("conn_id", cp.connID),
+		zap.String("user", cp.user),
+		zap.String("db", cp.currentDB),
+		zap.String("backend_addr", record.backendAddr),
+		zap.Stringer("cmd", record.cmd),
+		zap.Duration("query_time", record.queryTime()),
+		zap.Duration("wait_time", record.forwardTime.Sub(record.startTime)),
+		zap.Duration("backend_time", record.endTime.Sub(record.forwardTime)),
+		zap.Uint64("result_bytes", record.outBytes),
+		zap.Uint64("result_packets", record.outPackets),
+		zap.Uint64("result_rows", record.rows),
+		zap.String("digest", record.stmt.digest),
+		zap.String("query", record.stmt.normalized),
+	}
+	if record.err != nil {
+		fields = append(fields, zap.Error(record.err))
+	}
+	cp.slowLog.logger.Info("slow command", fields...)
+}
@@ -0,0 +1,52 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowLog(t *testing.T) {
+	lg, text := logger.CreateLoggerForTest(t)
+	slowLog := NewSlowLog(lg, config.SlowLog{Threshold: 50 * time.Millisecond})
+	require.True(t, slowLog.Enabled())
+
+	tc := newTCPConnSuite(t)
+	ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+		cfg.clientConfig.cmd = pnet.ComQuery
+		cfg.clientConfig.sql = "select * from t where id = 1"
+		cfg.backendConfig.respondType = responseTypeOK
+	})
+	defer clean()
+	ts.mp.cmdProcessor.slowLog = slowLog
+	ts.mp.cmdProcessor.connID = 100
+
+	// A fast command is not logged.
+	ts.executeCmd(t, nil)
+	require.NotContains(t, text.String(), "slow command")
+
+	// A slow command is logged.
+	respond := func(packetIO pnet.PacketIO) error {
+		time.Sleep(100 * time.Millisecond)
+		return ts.mb.respond(packetIO)
+	}
+	ts.runAndCheck(t, nil, ts.mc.request, respond, ts.mp.processCmd)
+	log := text.String()
+	require.Contains(t, log, "slow command")
+	require.Contains(t, log, `"conn_id": 100`)
+	require.Contains(t, log, sqlDigest("select * from t where id = 1"))
+	require.Contains(t, log, "select * from `t` where `id` = ?")
+
+	slowLog.SetConfig(config.SlowLog{})
+	require.False(t, slowLog.Enabled())
+	require.False(t, NewSlowLog(nil, config.SlowLog{Threshold: time.Second}).Enabled())
+	var nilSlowLog *SlowLog
+	require.False(t, nilSlowLog.Enabled())
+}
@@ -0,0 +1,300 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"cmp"
+	"hash/maphash"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+// The dimensions to aggregate the SQL statistics.
+const (
+	SQLStatsGroupByDigest  = "digest"
+	SQLStatsGroupByUser    = "user"
+	SQLStatsGroupByBackend = "backend"
+	SQLStatsGroupByAll     = "all"
+)
+
+// The fields to order the SQL statistics.
+const (
+	SQLStatsOrderByTotalLatency = "total_latency"
+	SQLStatsOrderByAvgLatency   = "avg_latency"
+	SQLStatsOrderByMaxLatency   = "max_latency"
+	SQLStatsOrderByCount        = "count"
+	SQLStatsOrderByErrors       = "errors"
+	SQLStatsOrderByBytes        = "bytes"
+)
+
+var ErrInvalidSQLStatsArg = errors.New("invalid sql stats argument")
+
+type sqlStatsKey struct {
+	user    string
+	backend string
+}
+
+type sqlStatsItem struct {
+	count        uint64
+	errors       uint64
+	bytes        uint64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+}
+
+func (item *sqlStatsItem) merge(other *sqlStatsItem) {
+	item.count += other.count
+	item.errors += other.errors
+	item.bytes += other.bytes
+	item.totalLatency += other.totalLatency
+	item.maxLatency = max(item.maxLatency, other.maxLatency)
+}
+
+type digestStats struct {
+	normalized string
+	items      map[sqlStatsKey]*sqlStatsItem
+}
+
+// SQLStatsRecord is the statistics of a digest. User and Backend are empty if they are not the grouping dimensions.
+type SQLStatsRecord struct {
+	Digest       string `json:"digest"`
+	Query        string `json:"query"`
+	User         string `json:"user,omitempty"`
+	Backend      string `json:"backend,omitempty"`
+	Count        uint64 `json:"count"`
+	Errors       uint64 `json:"errors"`
+	Bytes        uint64 `json:"bytes"`
+	TotalLatency string `json:"total_latency"`
+	AvgLatency   string `json:"avg_latency"`
+	MaxLatency   string `json:"max_latency"`
+}
+
+// SQLStatsResult is the snapshot of the SQL statistics.
+type SQLStatsResult struct {
+	BeginTime string `json:"begin_time"`
+	// Dropped is the number of statements that are not tracked because of the digest limit.
+	Dropped uint64           `json:"dropped"`
+	Records []SQLStatsRecord `json:"records"`
+}
+
+// The digests are spread over the shards so that the connections seldom contend for the same lock.
+const sqlStatsShards = 32
+
+var sqlStatsSeed = maphash.MakeSeed()
+
+type sqlStatsShard struct {
+	sync.Mutex
+	digests map[string]*digestStats
+}
+
+// sqlStatsRound is the statistics since beginTime. A new round replaces the old one when the statistics are reset.
+type sqlStatsRound struct {
+	beginTime  time.Time
+	shards     [sqlStatsShards]sqlStatsShard
+	numDigests atomic.Int64
+	dropped    atomic.Uint64
+}
+
+func newSQLStatsRound(now time.Time) *sqlStatsRound {
+	round := &sqlStatsRound{beginTime: now}
+	for i := range round.shards {
+		round.shards[i].digests = make(map[string]*digestStats)
+	}
+	return round
+}
+
+// shard returns the shard of the digest.
+func (round *sqlStatsRound) shard(digest string) *sqlStatsShard {
+	return &round.shards[maphash.String(sqlStatsSeed, digest)%sqlStatsShards]
+}
+
+// SQLStats aggregates the statistics of the forwarded statements by digest, user and backend.
+// The statistics are cleared every reset interval, and new digests are dropped once the digest limit is reached.
+type SQLStats struct {
+	// cfgMu only serializes SetConfig. The statements are tracked without it.
+	cfgMu         sync.Mutex
+	enabled       atomic.Bool
+	maxDigests    atomic.Int64
+	resetInterval atomic.Int64
+	round         atomic.Pointer[sqlStatsRound]
+}
+
+// NewSQLStats creates a SQLStats.
+func NewSQLStats(cfg config.SQLStats) *SQLStats {
+	ss := &SQLStats{}
+	ss.round.Store(newSQLStatsRound(time.Now()))
+	ss.SetConfig(cfg)
+	return ss
+}
+
+// SetConfig updates the config. The config should be already checked.
+func (ss *SQLStats) SetConfig(cfg config.SQLStats) {
+	ss.cfgMu.Lock()
+	defer ss.cfgMu.Unlock()
+	ss.maxDigests.Store(int64(cfg.MaxDigests))
+	ss.resetInterval.Store(int64(cfg.ResetInterval))
+	// Clear the statistics when it's disabled and start a new round when it's enabled.
+	if !cfg.Enable || !ss.enabled.Load() {
+		ss.round.Store(newSQLStatsRound(time.Now()))
+	}
+	ss.enabled.Store(cfg.Enable)
+}
+
+// Enabled returns whether the statements are tracked.
+func (ss *SQLStats) Enabled() bool {
+	return ss != nil && ss.enabled.Load()
+}
+
+// currentRound returns the current round and starts a new one if the reset interval elapses.
+func (ss *SQLStats) currentRound(now time.Time) *sqlStatsRound {
+	round := ss.round.Load()
+	resetInterval := time.Duration(ss.resetInterval.Load())
+	if resetInterval > 0 && now.Sub(round.beginTime) >= resetInterval {
+		// Only one of the concurrent callers starts the new round.
+		newRound := newSQLStatsRound(now)
+		if ss.round.CompareAndSwap(round, newRound) {
+			return newRound
+		}
+		round = ss.round.Load()
+	}
+	return round
+}
+
+func (ss *SQLStats) add(user string, record *cmdRecord) {
+	if !ss.enabled.Load() {
+		return
+	}
+	round := ss.currentRound(record.endTime)
+	shard := round.shard(record.stmt.digest)
+	shard.Lock()
+	defer shard.Unlock()
+	ds, ok := shard.digests[record.stmt.digest]
+	if !ok {
+		if round.numDigests.Add(1) > ss.maxDigests.Load() {
+			round.numDigests.Add(-1)
+			round.dropped.Add(1)
+			return
+		}
+		ds = &digestStats{normalized: record.stmt.normalized, items: make(map[sqlStatsKey]*sqlStatsItem)}
+		shard.digests[record.stmt.digest] = ds
+	}
+	key := sqlStatsKey{user: user, backend: record.backendAddr}
+	item, ok := ds.items[key]
+	if !ok {
+		item = &sqlStatsItem{}
+		ds.items[key] = item
+	}
+	queryTime := record.queryTime()
+	added := sqlStatsItem{count: 1, bytes: record.outBytes, totalLatency: queryTime, maxLatency: queryTime}
+	if record.err != nil {
+		added.errors = 1
+	}
+	item.merge(&added)
+}
+
+// Top returns the top statistics ordered by orderBy in descending order. limit <= 0 means no limit.
+func (ss *SQLStats) Top(groupBy, orderBy string, limit int) (SQLStatsResult, error) {
+	switch groupBy {
+	case "":
+		groupBy = SQLStatsGroupByAll
+	case SQLStatsGroupByDigest, SQLStatsGroupByUser, SQLStatsGroupByBackend, SQLStatsGroupByAll:
+	default:
+		return SQLStatsResult{}, errors.Wrapf(ErrInvalidSQLStatsArg, "unknown group-by %s", groupBy)
+	}
+	if len(orderBy) == 0 {
+		orderBy = SQLStatsOrderByTotalLatency
+	}
+	compareFn, ok := sqlStatsOrders[orderBy]
+	if !ok {
+		return SQLStatsResult{}, errors.Wrapf(ErrInvalidSQLStatsArg, "unknown order-by %s", orderBy)
+	}
+
+	type groupKey struct {
+		digest string
+		sqlStatsKey
+	}
+	type group struct {
+		groupKey
+		normalized string
+		sqlStatsItem
+	}
+	round := ss.currentRound(time.Now())
+	result := SQLStatsResult{BeginTime: round.beginTime.Format(time.RFC3339), Dropped: round.dropped.Load()}
+	groups := make(map[groupKey]*group, round.numDigests.Load())
+	for i := range round.shards {
+		shard := &round.shards[i]
+		shard.Lock()
+		for digest, ds := range shard.digests {
+			for key, item := range ds.items {
+				gk := groupKey{digest: digest}
+				switch groupBy {
+				case SQLStatsGroupByUser:
+					gk.user = key.user
+				case SQLStatsGroupByBackend:
+					gk.backend = key.backend
+				case SQLStatsGroupByAll:
+					gk.sqlStatsKey = key
+				}
+				g, ok := groups[gk]
+				if !ok {
+					g = &group{groupKey: gk, normalized: ds.normalized}
+					groups[gk] = g
+				}
+				g.merge(item)
+			}
+		}
+		shard.Unlock()
+	}
+
+	sorted := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	slices.SortFunc(sorted, func(a, b *group) int {
+		if c := compareFn(&b.sqlStatsItem, &a.sqlStatsItem); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.digest+a.user+a.backend, b.digest+b.user+b.backend)
+	})
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	result.Records = make([]SQLStatsRecord, 0, len(sorted))
+	for _, g := range sorted {
+		result.Records = append(result.Records, SQLStatsRecord{
+			Digest:       g.digest,
+			Query:        g.normalized,
+			User:         g.user,
+			Backend:      g.backend,
+			Count:        g.count,
+			Errors:       g.errors,
+			Bytes:        g.bytes,
+			TotalLatency: g.totalLatency.String(),
+			AvgLatency:   g.avgLatency().String(),
+			MaxLatency:   g.maxLatency.String(),
+		})
+	}
+	return result, nil
+}
+
+func (item *sqlStatsItem) avgLatency() time.Duration {
+	if item.count == 0 {
+		return 0
+	}
+	return item.totalLatency / time.Duration(item.count)
+}
+
+var sqlStatsOrders = map[string]func(a, b *sqlStatsItem) int{
+	SQLStatsOrderByTotalLatency: func(a, b *sqlStatsItem) int { return cmp.Compare(a.totalLatency, b.totalLatency) },
+	SQLStatsOrderByAvgLatency:   func(a, b *sqlStatsItem) int { return cmp.Compare(a.avgLatency(), b.avgLatency()) },
+	SQLStatsOrderByMaxLatency:   func(a, b *sqlStatsItem) int { return cmp.Compare(a.maxLatency, b.maxLatency) },
+	SQLStatsOrderByCount:        func(a, b *sqlStatsItem) int { return cmp.Compare(a.count, b.count) },
+	SQLStatsOrderByErrors:       func(a, b *sqlStatsItem) int { return cmp.Compare(a.errors, b.errors) },
+	SQLStatsOrderByBytes:        func(a, b *sqlStatsItem) int { return cmp.Compare(a.bytes, b.bytes) },
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLStatsTop(t *testing.T) {
+	ss := NewSQLStats(config.SQLStats{Enable: true, MaxDigests: 2})
+	require.True(t, ss.Enabled())
+	now := time.Now()
+	add := func(sql, user, backend string, latency time.Duration, bytes uint64, err error) {
+		ss.add(user, &cmdRecord{
+			stmt:        (&sqlStmt{sql: sql}).stmtDigest(),
+			backendAddr: backend,
+			startTime:   now,
+			endTime:     now.Add(latency),
+			outBytes:    bytes,
+			err:         err,
+		})
+	}
+	add("select 1", "u1", "b1", time.Second, 10, nil)
+	add("select 2", "u1", "b2", 3*time.Second, 20, errors.New("mock"))
+	add("select 3", "u2", "b1", time.Second, 100, nil)
+	add("insert into t values (1)", "u2", "b1", 2*time.Second, 5, nil)
+	// exceeds max-digests
+	add("delete from t", "u1", "b1", time.Second, 5, nil)
+
+	result, err := ss.Top("", "", 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, result.Dropped)
+	require.Len(t, result.Records, 4)
+	require.Equal(t, SQLStatsRecord{
+		Digest:       (&sqlStmt{sql: "select 1"}).stmtDigest().digest,
+		Query:        "select ?",
+		User:         "u1",
+		Backend:      "b2",
+		Count:        1,
+		Errors:       1,
+		Bytes:        20,
+		TotalLatency: "3s",
+		AvgLatency:   "3s",
+		MaxLatency:   "3s",
+	}, result.Records[0])
+
+	result, err = ss.Top(SQLStatsGroupByDigest, SQLStatsOrderByCount, 1)
+	require.NoError(t, err)
+	require.Len(t, result.Records, 1)
+	require.Equal(t, "select ?", result.Records[0].Query)
+	require.EqualValues(t, 3, result.Records[0].Count)
+	require.EqualValues(t, 130, result.Records[0].Bytes)
+	require.Equal(t, "5s", result.Records[0].TotalLatency)
+	require.Equal(t, "3s", result.Records[0].MaxLatency)
+	require.Empty(t, result.Records[0].User)
+
+	result, err = ss.Top(SQLStatsGroupByUser, SQLStatsOrderByBytes, 0)
+	require.NoError(t, err)
+	require.Len(t, result.Records, 3)
+	require.Equal(t, "u2", result.Records[0].User)
+	require.EqualValues(t, 100, result.Records[0].Bytes)
+	require.Empty(t, result.Records[0].Backend)
+
+	result, err = ss.Top(SQLStatsGroupByBackend, SQLStatsOrderByErrors, 0)
+	require.NoError(t, err)
+	require.Equal(t, "b2", result.Records[0].Backend)
+
+	_, err = ss.Top("unknown", "", 0)
+	require.ErrorIs(t, err, ErrInvalidSQLStatsArg)
+	_, err = ss.Top("", "unknown", 0)
+	require.ErrorIs(t, err, ErrInvalidSQLStatsArg)
+
+	// The statistics are cleared after the reset interval.
+	ss.SetConfig(config.SQLStats{Enable: true, MaxDigests: 2, ResetInterval: time.Millisecond})
+	time.Sleep(10 * time.Millisecond)
+	result, err = ss.Top("", "", 0)
+	require.NoError(t, err)
+	require.Empty(t, result.Records)
+	require.Zero(t, result.Dropped)
+
+	ss.SetConfig(config.SQLStats{})
+	require.False(t, ss.Enabled())
+	var nilStats *SQLStats
+	require.False(t, nilStats.Enabled())
+}
+
+func TestSQLStatsCmd(t *testing.T) {
+	ss := NewSQLStats(config.SQLStats{Enable: true, MaxDigests: 10})
+	tc := newTCPConnSuite(t)
+	ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+		cfg.clientConfig.cmd = pnet.ComQuery
+		cfg.clientConfig.sql = "select * from t where id = 1"
+		cfg.backendConfig.respondType = responseTypeResultSet
+		cfg.backendConfig.columns = 2
+		cfg.backendConfig.rows = 5
+	})
+	defer clean()
+	ts.mp.cmdProcessor.sqlStats = ss
+	ts.mp.cmdProcessor.user = "root"
+	ts.executeCmd(t, nil)
+	ts.executeCmd(t, nil)
+
+	result, err := ss.Top("", "", 0)
+	require.NoError(t, err)
+	require.Len(t, result.Records, 1)
+	record := result.Records[0]
+	require.Equal(t, sqlDigest("select * from t where id = 1"), record.Digest)
+	require.Equal(t, "root", record.User)
+	require.Equal(t, ts.tc.proxyBIO.RemoteAddr().String(), record.Backend)
+	require.EqualValues(t, 2, record.Count)
+	require.Positive(t, record.Bytes)
+
+	// The prepared statements are recorded by the digests of the prepared SQL.
+	ts.setConfig(func(cfg *testConfig) {
+		cfg.clientConfig.cmd = pnet.ComStmtPrepare
+		cfg.clientConfig.sql = "select * from t where id = ?"
+		cfg.backendConfig.respondType = responseTypePrepareOK
+	})
+	ts.executeCmd(t, nil)
+	ts.setConfig(func(cfg *testConfig) {
+		cfg.clientConfig.cmd = pnet.ComStmtExecute
+		cfg.clientConfig.prepStmtID = mockCmdInt
+		cfg.backendConfig.respondType = responseTypeOK
+	})
+	ts.executeCmd(t, nil)
+	result, err = ss.Top(SQLStatsGroupByDigest, SQLStatsOrderByCount, 0)
+	require.NoError(t, err)
+	require.Len(t, result.Records, 1)
+	require.EqualValues(t, 4, result.Records[0].Count)
+}
@@ -0,0 +1,54 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"github.com/pingcap/tidb/pkg/parser"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+)
+
+// sqlStmt is the statement of COM_QUERY or COM_STMT_PREPARE. The normalized SQL and the digest are computed lazily
+// and at most once for each command, and they are shared by the query rewriter, the firewall, the result cache and
+// the command records.
+type sqlStmt struct {
+	sql        string
+	normalized string
+	digest     string
+	hasDigest  bool
+}
+
+// newSQLStmt returns the statement of the request, or nil if the command doesn't carry a statement.
+func newSQLStmt(request []byte) *sqlStmt {
+	switch pnet.Command(request[0]) {
+	case pnet.ComQuery:
+		return &sqlStmt{sql: pnet.ParseQueryPacket(request[1:])}
+	case pnet.ComStmtPrepare:
+		return &sqlStmt{sql: string(request[1:])}
+	}
+	return nil
+}
+
+func (s *sqlStmt) normalize() (normalized, digest string) {
+	if !s.hasDigest {
+		normalized, digest := parser.NormalizeDigest(s.sql)
+		s.normalized, s.digest, s.hasDigest = normalized, digest.String(), true
+	}
+	return s.normalized, s.digest
+}
+
+// setSQL replaces the statement, e.g. after it's rewritten, and the digest will be computed again if it's needed.
+func (s *sqlStmt) setSQL(sql string) {
+	if sql != s.sql {
+		s.sql, s.normalized, s.digest, s.hasDigest = sql, "", "", false
+	}
+}
+
+// stmtDigest returns the digest for the command records, in which the normalized SQL is truncated.
+func (s *sqlStmt) stmtDigest() stmtDigest {
+	normalized, digest := s.normalize()
+	if len(normalized) > maxRecordQueryLen {
+		normalized = normalized[:maxRecordQueryLen]
+	}
+	return stmtDigest{normalized: normalized, digest: digest}
+}
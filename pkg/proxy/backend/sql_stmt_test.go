@@ -0,0 +1,36 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"strings"
+	"testing"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLStmt(t *testing.T) {
+	require.Nil(t, newSQLStmt([]byte{pnet.ComPing.Byte()}))
+	prepare := newSQLStmt(append([]byte{pnet.ComStmtPrepare.Byte()}, "select ?"...))
+	require.Equal(t, "select ?", prepare.sql)
+
+	stmt := newSQLStmt(pnet.MakeQueryPacket("select * from t where id = 1"))
+	normalized, digest := stmt.normalize()
+	require.Equal(t, "select * from `t` where `id` = ?", normalized)
+	require.Equal(t, sqlDigest("select * from t where id = 2"), digest)
+
+	// the digest is kept if the SQL is unchanged
+	stmt.setSQL(stmt.sql)
+	require.True(t, stmt.hasDigest)
+	// the digest is computed again after rewriting
+	stmt.setSQL("select * from t where id = 1 limit 10")
+	require.False(t, stmt.hasDigest)
+	_, newDigest := stmt.normalize()
+	require.NotEqual(t, digest, newDigest)
+
+	// the normalized SQL is truncated in the records
+	long := newSQLStmt(pnet.MakeQueryPacket("select " + strings.Repeat("a, ", maxRecordQueryLen) + "b from t"))
+	require.Len(t, long.stmtDigest().normalized, maxRecordQueryLen)
+}
@@ -0,0 +1,229 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/balance/router"
+	"github.com/pingcap/tiproxy/pkg/metrics"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"go.uber.org/zap"
+)
+
+const (
+	// errCodeQueryTimeout is ER_QUERY_TIMEOUT, which is also returned by TiDB when max_execution_time is exceeded.
+	errCodeQueryTimeout = 3024
+	// sessionTokenLifetime is the lifetime of the session token signed by TiDB.
+	sessionTokenLifetime = time.Minute
+)
+
+const (
+	stmtTimeoutKilled     = "killed"
+	stmtTimeoutKillFailed = "kill_failed"
+)
+
+// timeoutClientIO replaces the interrupted error with a timeout error after the statement is killed by the proxy.
+type timeoutClientIO struct {
+	pnet.PacketIO
+	killed  atomic.Bool
+	timeout time.Duration
+}
+
+func (tio *timeoutClientIO) Unwrap() pnet.PacketIO {
+	return tio.PacketIO
+}
+
+func (tio *timeoutClientIO) WritePacket(data []byte, flush bool) error {
+	if tio.killed.Load() && pnet.IsErrorPacket(data[0]) && pnet.ParseErrorPacket(data).Code == mysql.ER_QUERY_INTERRUPTED {
+		myErr := mysql.NewError(errCodeQueryTimeout, fmt.Sprintf("Query execution was interrupted, statement timeout %s exceeded", tio.timeout))
+		data = pnet.MakeErrPacket(myErr)
+	}
+	return tio.PacketIO.WritePacket(data, flush)
+}
+
+// needStmtTimer returns whether the request runs a statement that should be killed after the timeout.
+func (cp *CmdProcessor) needStmtTimer(request []byte) bool {
+	if cp.stmtTimeout <= 0 {
+		return false
+	}
+	switch pnet.Command(request[0]) {
+	case pnet.ComQuery, pnet.ComStmtExecute:
+		return true
+	}
+	return false
+}
+
+// startStmtTimer kills the statement when it exceeds the timeout. The returned clientIO should be used to forward
+// the results and the returned function should be called after the statement finishes.
+func (cp *CmdProcessor) startStmtTimer(clientIO pnet.PacketIO) (pnet.PacketIO, func()) {
+	killQuery := cp.killQuery
+	tio := &timeoutClientIO{PacketIO: clientIO, timeout: cp.stmtTimeout}
+	killDone := make(chan struct{})
+	timer := time.AfterFunc(cp.stmtTimeout, func() {
+		defer close(killDone)
+		tio.killed.Store(true)
+		if err := killQuery(); err != nil {
+			metrics.StmtTimeoutCounter.WithLabelValues(stmtTimeoutKillFailed).Inc()
+			cp.logger.Warn("killing the timeout statement failed", zap.Duration("timeout", cp.stmtTimeout), zap.Error(err))
+			return
+		}
+		metrics.StmtTimeoutCounter.WithLabelValues(stmtTimeoutKilled).Inc()
+	})
+	return tio, func() {
+		// If the timer has fired, wait for the kill to finish. Otherwise, it may kill the next statement.
+		if !timer.Stop() {
+			<-killDone
+		}
+	}
+}
+
+// stmtKiller keeps the latest session token, which is also queried for other purposes, such as session migration and
+// snapshots. It's read when the statement timer fires, so it's refreshed before the statements only when it's stale.
+type stmtKiller struct {
+	token atomic.Pointer[killToken]
+	// errTime is the last time querying the token failed. It's only accessed with processLock held.
+	errTime time.Time
+}
+
+type killToken struct {
+	sessionToken string
+	queryTime    time.Time
+}
+
+func (killer *stmtKiller) setToken(sessionToken string, queryTime time.Time) {
+	if len(sessionToken) > 0 {
+		killer.token.Store(&killToken{sessionToken: sessionToken, queryTime: queryTime})
+	}
+}
+
+// validToken returns the session token if it hasn't expired.
+func (killer *stmtKiller) validToken() (string, bool) {
+	token := killer.token.Load()
+	if token == nil || time.Since(token.queryTime) >= sessionTokenLifetime {
+		return "", false
+	}
+	return token.sessionToken, true
+}
+
+// prepareStmtKiller prepares for killing the statement if it exceeds the timeout.
+// The statement is killed by the backend connection ID of the handshake.
+func (mgr *BackendConnManager) prepareStmtKiller(request []byte, backendIO pnet.PacketIO) {
+	mgr.cmdProcessor.killQuery = nil
+	if !mgr.cmdProcessor.needStmtTimer(request) {
+		return
+	}
+	// The connection ID is unknown for the connections of traffic replay.
+	connID := mgr.backendConnID.Load()
+	if connID == 0 {
+		return
+	}
+	mgr.refreshKillToken(backendIO)
+	key := mgr.poolKey(backendIO.RemoteAddr().String())
+	backend := mgr.currentBackend()
+	mgr.cmdProcessor.killQuery = func() error {
+		return mgr.killQuery(key, backend, connID)
+	}
+}
+
+// refreshKillToken queries a new session token if no other feature has queried it recently.
+// The session states can't be queried in a transaction, so the statements in a transaction use the token queried
+// before the transaction. Querying is paused for a while after it fails, e.g. the backend doesn't support it.
+func (mgr *BackendConnManager) refreshKillToken(backendIO pnet.PacketIO) {
+	killer := &mgr.stmtKiller
+	if mgr.cmdProcessor.serverStatus&StatusInTrans > 0 || time.Since(killer.errTime) < sessionTokenRefreshInterval {
+		return
+	}
+	if token := killer.token.Load(); token != nil && time.Since(token.queryTime) < sessionTokenRefreshInterval {
+		return
+	}
+	_, sessionToken, err := mgr.querySessionStates(backendIO)
+	if err == nil && len(sessionToken) == 0 {
+		err = errors.New("the backend returns an empty session token")
+	}
+	if err != nil {
+		killer.errTime = time.Now()
+		mgr.logger.Debug("querying session token for statement timeout failed", zap.Error(err))
+	}
+}
+
+// killQuery kills the running statement from another connection of the same user.
+// It's called in another goroutine, so it should not change the states of the connection.
+func (mgr *BackendConnManager) killQuery(key poolKey, backend router.BackendInst, connID uint64) error {
+	sql := fmt.Sprintf("KILL QUERY %d", connID)
+	if sessionToken, ok := mgr.stmtKiller.validToken(); ok {
+		tlsConfig, err := mgr.getBackendTLS(mgr, backend)
+		if err != nil {
+			return err
+		}
+		return mgr.runOnBackend(key.addr, tlsConfig, sessionToken, sql)
+	}
+	// An idle connection of the same user on the same backend is also allowed to kill the statement.
+	if pool := mgr.config.ConnPool; pool != nil {
+		if pc := pool.Get(key, 0); pc != nil {
+			err := runStmt(pc.backendIO, sql)
+			if err == nil || pnet.IsMySQLError(err) {
+				pool.Put(key, pc)
+			} else {
+				_ = pc.backendIO.Close()
+			}
+			return err
+		}
+	}
+	return errors.New("no valid session token or idle connection to kill the statement")
+}
+
+// runOnBackend connects to the backend with the session token and runs a statement that returns OK or ERR.
+// It doesn't change the states of the connection.
+func (mgr *BackendConnManager) runOnBackend(addr string, tlsConfig *tls.Config, sessionToken, sql string) error {
+	cn, err := net.DialTimeout("tcp", addr, mgr.config.DialTimeout)
+	if err != nil {
+		return errors.Wrap(err, ErrBackendConn)
+	}
+	if err = cn.SetDeadline(time.Now().Add(mgr.config.ConnectTimeout)); err != nil {
+		_ = cn.Close()
+		return errors.Wrap(err, ErrBackendConn)
+	}
+	backendIO := pnet.NewPacketIO(cn, mgr.logger, pnet.DefaultConnBufferSize, pnet.WithRemoteAddr(addr, cn.RemoteAddr()), pnet.WithWrapError(ErrBackendConn))
+	defer func() {
+		_ = backendIO.Close()
+	}()
+	if _, err = mgr.authenticator.handshakeSecondTime(mgr.logger, mgr.clientIO, backendIO, tlsConfig, sessionToken); err != nil {
+		return err
+	}
+	return runStmt(backendIO, sql)
+}
+
+// runStmt runs a statement that returns OK or ERR on the backend connection.
+func runStmt(backendIO pnet.PacketIO, sql string) error {
+	backendIO.ResetSequence()
+	if err := backendIO.WritePacket(pnet.MakeQueryPacket(sql), true); err != nil {
+		return err
+	}
+	response, err := backendIO.ReadPacket()
+	if err != nil {
+		return err
+	}
+	if pnet.IsErrorPacket(response[0]) {
+		return pnet.ParseErrorPacket(response)
+	}
+	return nil
+}
+
+// statementTimeout returns the statement timeout of the user, the namespace, or the default one in order.
+func (mgr *BackendConnManager) statementTimeout(user string) time.Duration {
+	if timeout, ok := mgr.config.UserStatementTimeouts[user]; ok {
+		return timeout
+	}
+	if timeout, ok := mgr.Value(ConnContextKeyStatementTimeout).(time.Duration); ok {
+		return timeout
+	}
+	return mgr.config.StatementTimeout
+}
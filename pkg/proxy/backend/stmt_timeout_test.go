@@ -0,0 +1,193 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStmtTimeout(t *testing.T) {
+	tc := newTCPConnSuite(t)
+	cfg := func(cfg *testConfig) {
+		cfg.clientConfig.cmd = pnet.ComQuery
+		cfg.backendConfig.respondType = responseTypeOK
+	}
+	notKill := func() error {
+		t.Fatal("the statement should not be killed")
+		return nil
+	}
+
+	// The statement finishes in time and is not killed.
+	ts, clean := newTestSuite(t, tc, cfg)
+	ts.mp.cmdProcessor.stmtTimeout = 100 * time.Millisecond
+	ts.mp.cmdProcessor.killQuery = notKill
+	ts.executeCmd(t, nil)
+	clean()
+
+	// The backend returns the interrupted error after the statement is killed.
+	interrupt := func(killed chan struct{}) func(packetIO pnet.PacketIO) error {
+		return func(packetIO pnet.PacketIO) error {
+			packetIO.ResetSequence()
+			if _, err := packetIO.ReadPacket(); err != nil {
+				return err
+			}
+			<-killed
+			return packetIO.WritePacket(pnet.MakeErrPacket(mysql.NewError(mysql.ER_QUERY_INTERRUPTED, "Query execution was interrupted")), true)
+		}
+	}
+
+	// The statement is killed after the timeout and the client receives the timeout error.
+	ts, clean = newTestSuite(t, tc, cfg)
+	ts.mp.cmdProcessor.stmtTimeout = 100 * time.Millisecond
+	killed := make(chan struct{})
+	ts.mp.cmdProcessor.killQuery = func() error {
+		close(killed)
+		return nil
+	}
+	ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+		require.NoError(t, ts.mc.err)
+		var myErr *mysql.MyError
+		require.ErrorAs(t, ts.mc.mysqlErr, &myErr)
+		require.EqualValues(t, errCodeQueryTimeout, myErr.Code)
+		require.True(t, pnet.IsMySQLError(ts.mp.err))
+	}, ts.mc.request, interrupt(killed), ts.mp.processCmd)
+	clean()
+
+	// The interrupted error is forwarded as it is if the proxy doesn't kill the statement.
+	ts, clean = newTestSuite(t, tc, cfg)
+	ts.mp.cmdProcessor.stmtTimeout = time.Minute
+	ts.mp.cmdProcessor.killQuery = notKill
+	killed = make(chan struct{})
+	close(killed)
+	ts.runAndCheck(t, func(t *testing.T, ts *testSuite) {
+		var myErr *mysql.MyError
+		require.ErrorAs(t, ts.mc.mysqlErr, &myErr)
+		require.EqualValues(t, mysql.ER_QUERY_INTERRUPTED, myErr.Code)
+	}, ts.mc.request, interrupt(killed), ts.mp.processCmd)
+	clean()
+}
+
+func TestStatementTimeoutPriority(t *testing.T) {
+	mgr := &BackendConnManager{config: &BCConfig{
+		StatementTimeout:      time.Minute,
+		UserStatementTimeouts: map[string]time.Duration{"report": time.Hour, "etl": 0},
+	}}
+	mgr.ctxmap.m = make(map[any]any)
+	require.Equal(t, time.Minute, mgr.statementTimeout("app"))
+	mgr.SetValue(ConnContextKeyStatementTimeout, time.Second)
+	require.Equal(t, time.Second, mgr.statementTimeout("app"))
+	require.Equal(t, time.Hour, mgr.statementTimeout("report"))
+	// 0 disables the timeout for the user.
+	require.Equal(t, time.Duration(0), mgr.statementTimeout("etl"))
+}
+
+func TestStmtKiller(t *testing.T) {
+	ts, clean := newTestSuite(t, newTCPConnSuite(t), func(cfg *testConfig) {
+		cfg.clientConfig.cmd = pnet.ComQuery
+	})
+	defer clean()
+	mp := ts.mp
+	mp.cmdProcessor.stmtTimeout = time.Second
+	request := pnet.MakeQueryPacket("select sleep(10)")
+	backendIO := newPooledConn(t, 1).backendIO
+
+	// the connection ID is unknown
+	mp.prepareStmtKiller(request, backendIO)
+	require.Nil(t, mp.cmdProcessor.killQuery)
+
+	// the statement can't be killed without a token or an idle connection
+	mp.backendConnID.Store(100)
+	mp.stmtKiller.errTime = time.Now()
+	mp.prepareStmtKiller(request, backendIO)
+	require.NotNil(t, mp.cmdProcessor.killQuery)
+	require.ErrorContains(t, mp.cmdProcessor.killQuery(), "no valid session token")
+
+	// an idle connection of the same user kills the statement and is returned to the pool
+	pool := NewConnPool(config.ConnPool{Enable: true})
+	t.Cleanup(pool.Close)
+	mp.config.ConnPool = pool
+	lg, _ := logger.CreateLoggerForTest(t)
+	cli, srv := net.Pipe()
+	pool.Put(mp.poolKey(backendIO.RemoteAddr().String()), &pooledConn{backendIO: pnet.NewPacketIO(cli, lg, pnet.DefaultConnBufferSize), owner: 1})
+	go func() {
+		srvIO := pnet.NewPacketIO(srv, lg, pnet.DefaultConnBufferSize)
+		pkt, err := srvIO.ReadPacket()
+		require.NoError(t, err)
+		require.Equal(t, "KILL QUERY 100", string(pkt[1:]))
+		require.NoError(t, srvIO.WritePacket(pnet.MakeOKPacket(0, pnet.OKHeader), true))
+	}()
+	require.NoError(t, mp.cmdProcessor.killQuery())
+	require.Equal(t, 1, pool.IdleCount())
+	mp.config.ConnPool = nil
+
+	// the token queried for other purposes is reused until it expires
+	mp.stmtKiller.setToken("token", time.Now().Add(-sessionTokenLifetime))
+	_, ok := mp.stmtKiller.validToken()
+	require.False(t, ok)
+	mp.stmtKiller.setToken("token", time.Now())
+	token, ok := mp.stmtKiller.validToken()
+	require.True(t, ok)
+	require.Equal(t, "token", token)
+}
+
+func TestStmtKillerRefreshToken(t *testing.T) {
+	tc := newTCPConnSuite(t)
+	ts, clean := newTestSuite(t, tc, func(cfg *testConfig) {
+		cfg.backendConfig.respondType = responseTypeResultSet
+	})
+	defer clean()
+	mp := ts.mp
+	mp.cmdProcessor.stmtTimeout = time.Second
+	mp.backendConnID.Store(100)
+	request := pnet.MakeQueryPacket("select sleep(10)")
+	prepare := func(query bool) {
+		outBytes := tc.proxyBIO.OutBytes()
+		var wg waitgroup.WaitGroup
+		if query {
+			wg.Run(func() {
+				require.NoError(t, ts.mb.respondOnce(tc.backendIO))
+			})
+		}
+		mp.prepareStmtKiller(request, tc.proxyBIO)
+		wg.Wait()
+		require.Equal(t, query, tc.proxyBIO.OutBytes() > outBytes)
+		require.NotNil(t, mp.cmdProcessor.killQuery)
+	}
+
+	// only the statement timeout is enabled, so the token is queried before the statement
+	prepare(true)
+	token, ok := mp.stmtKiller.validToken()
+	require.True(t, ok)
+	require.Equal(t, mockToken, token)
+	// the token is still fresh
+	prepare(false)
+
+	// the stale token is not refreshed in a transaction, but it's still valid
+	mp.stmtKiller.setToken(mockToken, time.Now().Add(-sessionTokenRefreshInterval))
+	mp.cmdProcessor.serverStatus |= StatusInTrans
+	prepare(false)
+	_, ok = mp.stmtKiller.validToken()
+	require.True(t, ok)
+	mp.cmdProcessor.serverStatus &^= StatusInTrans
+
+	// querying is paused after it fails
+	ts.mb.sessionToken = ""
+	prepare(true)
+	prepare(false)
+	mp.stmtKiller.errTime = time.Now().Add(-sessionTokenRefreshInterval)
+	ts.mb.sessionToken = mockToken
+	prepare(true)
+	token, ok = mp.stmtKiller.validToken()
+	require.True(t, ok)
+	require.Equal(t, mockToken, token)
+}
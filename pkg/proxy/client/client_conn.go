@@ -28,9 +28,12 @@ func NewClientConnection(logger *zap.Logger, conn net.Conn, frontendTLSConfig *t
 	hsHandler backend.HandshakeHandler, cpt capture.Capture, connID uint64, addr string, bcConfig *backend.BCConfig, m backend.Meter) *ClientConnection {
 	bemgr := backend.NewBackendConnManager(logger.Named("be"), hsHandler, cpt, connID, bcConfig, m)
 	bemgr.SetValue(backend.ConnContextKeyConnAddr, addr)
+	if len(bcConfig.DefaultNamespace) > 0 {
+		bemgr.SetValue(backend.ConnContextKeyDefaultNamespace, bcConfig.DefaultNamespace)
+	}
 	opts := make([]pnet.PacketIOption, 0, 2)
 	opts = append(opts, pnet.WithWrapError(backend.ErrClientConn))
-	if bcConfig.ProxyProtocol {
+	if bcConfig.ClientProxyProtocol {
 		opts = append(opts, pnet.WithProxy)
 	}
 	pkt := pnet.NewPacketIO(conn, logger, bcConfig.ConnBufferSize, opts...)
@@ -85,6 +88,11 @@ func (cc *ClientConnection) processMsg(ctx context.Context) error {
 	}
 }
 
+// ConnMgr returns the BackendConnManager of the connection.
+func (cc *ClientConnection) ConnMgr() *backend.BackendConnManager {
+	return cc.connMgr
+}
+
 func (cc *ClientConnection) GracefulClose() {
 	cc.connMgr.GracefulClose()
 }
@@ -0,0 +1,66 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"net"
+	"os"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+// listen listens on a TCP address or a Unix domain socket, such as unix:///var/run/tiproxy.sock.
+func listen(addr, perm string) (net.Listener, error) {
+	network, address := config.ParseListenAddr(addr)
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+
+	// The socket file may be left if TiProxy exited abnormally last time.
+	if fi, err := os.Stat(address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(address); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if len(perm) > 0 {
+		mode, err := config.ParseUnixSocketPermission(perm)
+		if err == nil {
+			err = os.Chmod(address, mode)
+		}
+		if err != nil {
+			_ = ln.Close()
+			return nil, errors.WithStack(err)
+		}
+	}
+	return &unixListener{Listener: ln, addr: &net.UnixAddr{Name: address, Net: network}}, nil
+}
+
+// unixListener wraps the accepted connections because the remote address of a Unix socket is always empty.
+type unixListener struct {
+	net.Listener
+	addr *net.UnixAddr
+}
+
+func (ul *unixListener) Accept() (net.Conn, error) {
+	conn, err := ul.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &unixConn{Conn: conn, addr: ul.addr}, nil
+}
+
+// unixConn reports the socket path as the client address so that the logs are readable.
+type unixConn struct {
+	net.Conn
+	addr *net.UnixAddr
+}
+
+func (uc *unixConn) RemoteAddr() net.Addr {
+	return uc.addr
+}
@@ -4,9 +4,13 @@
 package net
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
 
 	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/siddontang/go/hack"
@@ -121,3 +125,89 @@ func scrambleNativePassword(scramble []byte, password string) ([]byte, error) {
 	}
 	return scramble, nil
 }
+
+// HashPassword returns the authentication string of the password, which is stored by the proxy to authenticate clients.
+// For mysql_native_password, it's the same as the authentication string in TiDB: `*` + hex(SHA1(SHA1(password))).
+// For caching_sha2_password, it's hex(SHA256(SHA256(password))), which is enough to verify the fast authentication.
+// The authentication string of an empty password is empty.
+func HashPassword(authPlugin, password string) (string, error) {
+	if len(password) == 0 {
+		return "", nil
+	}
+	switch authPlugin {
+	case AuthNativePassword:
+		stage1 := sha1.Sum(hack.Slice(password))
+		hash := sha1.Sum(stage1[:])
+		return "*" + strings.ToUpper(hex.EncodeToString(hash[:])), nil
+	case AuthCachingSha2Password:
+		stage1 := sha256.Sum256(hack.Slice(password))
+		hash := sha256.Sum256(stage1[:])
+		return strings.ToUpper(hex.EncodeToString(hash[:])), nil
+	default:
+		return "", errors.Errorf("unsupported auth plugin %s", authPlugin)
+	}
+}
+
+// ParseAuthString decodes the authentication string generated by HashPassword.
+func ParseAuthString(authPlugin, authString string) ([]byte, error) {
+	var size int
+	switch authPlugin {
+	case AuthNativePassword:
+		size = sha1.Size
+	case AuthCachingSha2Password:
+		size = sha256.Size
+	default:
+		return nil, errors.Errorf("unsupported auth plugin %s", authPlugin)
+	}
+	if len(authString) == 0 {
+		return nil, nil
+	}
+	if authPlugin == AuthNativePassword {
+		var ok bool
+		if authString, ok = strings.CutPrefix(authString, "*"); !ok {
+			return nil, errors.Errorf("the authentication string of %s must start with *", authPlugin)
+		}
+	}
+	hash, err := hex.DecodeString(authString)
+	if err != nil || len(hash) != size {
+		return nil, errors.Errorf("invalid authentication string of %s", authPlugin)
+	}
+	return hash, nil
+}
+
+// VerifyAuthResp checks the auth response of the client with the hash returned by ParseAuthString.
+func VerifyAuthResp(authPlugin string, hash, salt, authResp []byte) bool {
+	// Some clients send a NUL as the empty auth response.
+	if len(authResp) == 1 && authResp[0] == 0 {
+		authResp = nil
+	}
+	if len(hash) == 0 || len(authResp) == 0 {
+		return len(hash) == 0 && len(authResp) == 0
+	}
+	switch authPlugin {
+	case AuthNativePassword:
+		// authResp = SHA1(password) XOR SHA1(salt + SHA1(SHA1(password)))
+		if len(authResp) != sha1.Size {
+			return false
+		}
+		stage1 := sha1.Sum(append(bytes.Clone(salt), hash...))
+		for i := range stage1 {
+			stage1[i] ^= authResp[i]
+		}
+		actual := sha1.Sum(stage1[:])
+		return subtle.ConstantTimeCompare(actual[:], hash) == 1
+	case AuthCachingSha2Password:
+		// authResp = SHA256(password) XOR SHA256(SHA256(SHA256(password)) + salt)
+		if len(authResp) != sha256.Size {
+			return false
+		}
+		stage1 := sha256.Sum256(append(bytes.Clone(hash), salt...))
+		for i := range stage1 {
+			stage1[i] ^= authResp[i]
+		}
+		actual := sha256.Sum256(stage1[:])
+		return subtle.ConstantTimeCompare(actual[:], hash) == 1
+	default:
+		return false
+	}
+}
@@ -39,3 +39,46 @@ func TestGenerateAuthResp(t *testing.T) {
 		require.NotEmpty(t, resp)
 	}
 }
+
+func TestVerifyAuthResp(t *testing.T) {
+	plugins := []string{
+		AuthNativePassword,
+		AuthCachingSha2Password,
+	}
+	for _, plugin := range plugins {
+		var salt [20]byte
+		require.NoError(t, GenerateSalt(&salt))
+		authString, err := HashPassword(plugin, "test")
+		require.NoError(t, err)
+		hash, err := ParseAuthString(plugin, authString)
+		require.NoError(t, err)
+		resp, err := GenerateAuthResp("test", plugin, salt[:])
+		require.NoError(t, err)
+		require.True(t, VerifyAuthResp(plugin, hash, salt[:], resp), plugin)
+		resp, err = GenerateAuthResp("wrong", plugin, salt[:])
+		require.NoError(t, err)
+		require.False(t, VerifyAuthResp(plugin, hash, salt[:], resp), plugin)
+		require.False(t, VerifyAuthResp(plugin, hash, salt[:], nil), plugin)
+
+		// empty password
+		authString, err = HashPassword(plugin, "")
+		require.NoError(t, err)
+		require.Empty(t, authString)
+		hash, err = ParseAuthString(plugin, authString)
+		require.NoError(t, err)
+		require.True(t, VerifyAuthResp(plugin, hash, salt[:], []byte{0}), plugin)
+		require.False(t, VerifyAuthResp(plugin, hash, salt[:], resp), plugin)
+	}
+
+	// The same as `SELECT authentication_string FROM mysql.user` in TiDB.
+	authString, err := HashPassword(AuthNativePassword, "123456")
+	require.NoError(t, err)
+	require.Equal(t, "*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9", authString)
+
+	for _, authString := range []string{"6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9", "*6BB4", "*XYZ4837EB74329105EE4568DDA7DC67ED2CA2AD9"} {
+		_, err = ParseAuthString(AuthNativePassword, authString)
+		require.Error(t, err)
+	}
+	_, err = ParseAuthString(AuthTiDBSM3Password, "abc")
+	require.Error(t, err)
+}
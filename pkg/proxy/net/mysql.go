@@ -19,8 +19,9 @@ import (
 )
 
 const (
-	ShaCommand   = 1
-	FastAuthFail = 4
+	ShaCommand      = 1
+	FastAuthSuccess = 3
+	FastAuthFail    = 4
 )
 
 var (
@@ -63,7 +64,7 @@ func MakeErrPacket(merr *gomysql.MyError) []byte {
 	return data
 }
 
-// WriteOKPacket writes an OK packet. It's only for testing.
+// MakeOKPacket makes an OK packet without affected rows or info.
 func MakeOKPacket(status uint16, header Header) []byte {
 	data := make([]byte, 0, 7)
 	data = append(data, header.Byte())
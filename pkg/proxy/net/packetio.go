@@ -192,6 +192,17 @@ func ReadFull(prw packetReadWriter, b []byte) error {
 	return nil
 }
 
+// PacketIOWrapper wraps a PacketIO to intercept the packets written by WritePacket.
+type PacketIOWrapper interface {
+	Unwrap() PacketIO
+}
+
+// ReadTimer is implemented by the PacketIO that records when the packets are received.
+type ReadTimer interface {
+	// LastReadTime returns the time when the header of the last packet read by ReadPacket is received.
+	LastReadTime() time.Time
+}
+
 type PacketIO interface {
 	ApplyOpts(opts ...PacketIOption)
 	LocalAddr() net.Addr
@@ -223,6 +234,8 @@ type PacketIO interface {
 	ServerTLSHandshake(tlsConfig *tls.Config) (tls.ConnectionState, error)
 	ClientTLSHandshake(tlsConfig *tls.Config) error
 	TLSConnectionState() tls.ConnectionState
+	// ServerName returns the SNI hostname sent by the client in the TLS handshake. It's empty without TLS.
+	ServerName() string
 
 	// compression
 	SetCompressionAlgorithm(algorithm CompressAlgorithm, zstdLevel int) error
@@ -240,6 +253,7 @@ type packetIO struct {
 	header        [4]byte // reuse memory to reduce allocation
 	inPackets     uint64
 	outPackets    uint64
+	lastReadTime  time.Time
 }
 
 func NewPacketIO(conn net.Conn, lg *zap.Logger, bufferSize int, opts ...PacketIOption) *packetIO {
@@ -286,10 +300,13 @@ func (p *packetIO) GetSequence() uint8 {
 	return p.readWriter.Sequence()
 }
 
-func (p *packetIO) readOnePacket() ([]byte, bool, error) {
+func (p *packetIO) readOnePacket(first bool) ([]byte, bool, error) {
 	if err := ReadFull(p.readWriter, p.header[:]); err != nil {
 		return nil, false, errors.Wrap(err, ErrReadConn)
 	}
+	if first {
+		p.lastReadTime = time.Now()
+	}
 	sequence, pktSequence := p.header[3], p.readWriter.Sequence()
 	if sequence != pktSequence {
 		p.logger.Warn("sequence mismatch", zap.Uint8("expected", pktSequence), zap.Uint8("actual", sequence))
@@ -310,7 +327,7 @@ func (p *packetIO) ReadPacket() (data []byte, err error) {
 	p.readWriter.BeginRW(rwRead)
 	for more := true; more; {
 		var buf []byte
-		buf, more, err = p.readOnePacket()
+		buf, more, err = p.readOnePacket(data == nil)
 		if err != nil {
 			err = p.wrapErr(err)
 			return
@@ -375,6 +392,10 @@ func (p *packetIO) ForwardUntil(destIO PacketIO, isEnd func(firstByte byte, firs
 	process func(response []byte) error) error {
 	p.readWriter.BeginRW(rwRead)
 	dest, _ := destIO.(*packetIO)
+	// The wrapper only intercepts the packets that are read with data, others are copied directly.
+	if wrapper, ok := destIO.(PacketIOWrapper); ok {
+		dest, _ = wrapper.Unwrap().(*packetIO)
+	}
 	// destIO is not packetIO in traffic replay.
 	if dest != nil {
 		dest.readWriter.BeginRW(rwWrite)
@@ -453,6 +474,10 @@ func (p *packetIO) InPackets() uint64 {
 	return p.inPackets
 }
 
+func (p *packetIO) LastReadTime() time.Time {
+	return p.lastReadTime
+}
+
 func (p *packetIO) OutPackets() uint64 {
 	return p.outPackets
 }
@@ -416,6 +416,28 @@ func TestPacketSequence(t *testing.T) {
 	)
 }
 
+func TestLastReadTime(t *testing.T) {
+	testPipeConn(t,
+		func(t *testing.T, cli *packetIO) {
+			require.NoError(t, cli.WritePacket([]byte{0}, true))
+			require.NoError(t, cli.WritePacket(make([]byte, MaxPayloadLen+1), true))
+		},
+		func(t *testing.T, srv *packetIO) {
+			require.True(t, srv.LastReadTime().IsZero())
+			before := time.Now()
+			_, err := srv.ReadPacket()
+			require.NoError(t, err)
+			readTime := srv.LastReadTime()
+			require.False(t, readTime.Before(before))
+			data, err := srv.ReadPacket()
+			require.NoError(t, err)
+			require.Len(t, data, MaxPayloadLen+1)
+			require.True(t, srv.LastReadTime().After(readTime))
+		},
+		1,
+	)
+}
+
 func TestForwardUntil(t *testing.T) {
 	stls, ctls, err := security.CreateTLSConfigForTest()
 	require.NoError(t, err)
@@ -76,6 +76,14 @@ func (prw *proxyReadWriter) readProxy() error {
 			if proxyHeader != nil {
 				prw.proxy = proxyHeader
 			}
+		} else if bytes.Equal(header[:], proxyprotocol.MagicV1[:4]) {
+			proxyHeader, err := prw.parseProxyV1()
+			if err != nil {
+				return errors.Wrap(err, ErrReadConn)
+			}
+			if proxyHeader != nil {
+				prw.proxy = proxyHeader
+			}
 		}
 		prw.proxyInited.Store(true)
 	}
@@ -146,6 +154,32 @@ func (prw *proxyReadWriter) parseProxyV2() (*proxyprotocol.Proxy, error) {
 	return m, err
 }
 
+func (prw *proxyReadWriter) parseProxyV1() (*proxyprotocol.Proxy, error) {
+	rem, err := prw.packetReadWriter.Peek(len(proxyprotocol.MagicV1))
+	if err != nil {
+		return nil, errors.WithStack(errors.Wrap(err, ErrReadConn))
+	}
+	if !bytes.Equal(rem, proxyprotocol.MagicV1) {
+		return nil, nil
+	}
+
+	_, err = prw.packetReadWriter.Discard(len(proxyprotocol.MagicV1))
+	if err != nil {
+		return nil, errors.WithStack(errors.Wrap(err, ErrReadConn))
+	}
+
+	m, _, err := proxyprotocol.ParseProxyV1(prw.packetReadWriter)
+	if err != nil {
+		return nil, err
+	}
+	// UNKNOWN means the real connection addresses should be used.
+	if m.Command == proxyprotocol.ProxyCommandLocal {
+		return nil, nil
+	}
+	prw.addr = m.SrcAddress
+	return m, nil
+}
+
 func (prw *proxyReadWriter) RemoteAddr() net.Addr {
 	if prw.addr != nil {
 		return prw.addr
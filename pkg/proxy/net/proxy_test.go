@@ -36,6 +36,31 @@ func TestProxyParse(t *testing.T) {
 	)
 }
 
+func TestProxyParseV1(t *testing.T) {
+	tcpaddr, p := mockProxy(t)
+	p.Version = proxyprotocol.ProxyVersion1
+	p.Command = proxyprotocol.ProxyCommandProxy
+	testPipeConn(t,
+		func(t *testing.T, cli *packetIO) {
+			b, err := p.ToBytes()
+			require.NoError(t, err)
+			_, err = io.Copy(cli.readWriter, bytes.NewReader(b))
+			require.NoError(t, err)
+			err = cli.WritePacket([]byte("hello"), true)
+			require.NoError(t, err)
+		},
+		func(t *testing.T, srv *packetIO) {
+			srv.ApplyOpts(WithProxy)
+			b, err := srv.ReadPacket()
+			require.NoError(t, err)
+			require.Equal(t, "hello", string(b))
+			require.Equal(t, tcpaddr.String(), srv.RemoteAddr().String())
+			require.Equal(t, proxyprotocol.ProxyVersion1, srv.Proxy().Version)
+		},
+		1,
+	)
+}
+
 func TestProxyReadWrite(t *testing.T) {
 	addr, p := mockProxy(t)
 	message := []byte("hello world")
@@ -49,6 +49,10 @@ func (p *packetIO) TLSConnectionState() tls.ConnectionState {
 	return p.readWriter.TLSConnectionState()
 }
 
+func (p *packetIO) ServerName() string {
+	return p.TLSConnectionState().ServerName
+}
+
 var _ packetReadWriter = (*tlsReadWriter)(nil)
 
 type tlsReadWriter struct {
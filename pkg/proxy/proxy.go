@@ -20,6 +20,7 @@ import (
 	"github.com/pingcap/tiproxy/pkg/proxy/client"
 	"github.com/pingcap/tiproxy/pkg/proxy/keepalive"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
 	"github.com/pingcap/tiproxy/pkg/util/netutil"
 	"github.com/pingcap/tiproxy/pkg/util/waitgroup"
@@ -31,14 +32,24 @@ type serverState struct {
 	healthyKeepAlive   config.KeepAlive
 	unhealthyKeepAlive config.KeepAlive
 	clients            map[uint64]*client.ClientConnection
+	listenerCfgs       map[string]config.ProxyListener // listener address -> per-listener settings
+	listenerConns      map[string]uint64               // listener address -> connection count
 	publicEndpoints    []*net.IPNet
 	maxConnections     uint64
 	connBufferSize     int
 	requireBackendTLS  bool
 	tcpKeepAlive       bool
 	proxyProtocol      bool
+	proxyVersion       proxyprotocol.ProxyVersion
 	gracefulWait       int // graceful-wait-before-shutdown
 	gracefulClose      int // graceful-close-conn-timeout
+	stmtTimeout        time.Duration
+	userStmtTimeouts   map[string]time.Duration // user -> statement timeout
+	loadDataLocal      config.LoadDataPolicy
+	userLoadDataLocal  map[string]config.LoadDataPolicy // user -> LOAD DATA LOCAL INFILE policy
+	retryReads         bool
+	certUserMapper     *backend.CertUserMapper
+	listenerMappers    map[string]*backend.CertUserMapper // listener address -> cert user mapper of its server-tls
 }
 
 type SQLServer struct {
@@ -50,16 +61,43 @@ type SQLServer struct {
 	hsHandler  backend.HandshakeHandler
 	cpt        capture.Capture
 	meter      backend.Meter
+	connPool   *backend.ConnPool
+	failover   *backend.SessionFailover
+	loadData   *backend.LoadDataMemory
+	cache      *backend.ResultCache
+	firewall   *backend.Firewall
+	rewriter   *backend.QueryRewriter
+	slowLog    *backend.SlowLog
+	sqlStats   *backend.SQLStats
+	proxyAuth  *backend.ProxyAuth
 	wg         waitgroup.WaitGroup
 	cancelFunc context.CancelFunc
 
 	mu serverState
 }
 
+type sqlServerOptions struct {
+	slowLogger *zap.Logger
+}
+
+// SQLServerOption configures the optional components of the SQLServer.
+type SQLServerOption = func(*sqlServerOptions)
+
+// WithSlowLogger sets the logger of the slow log. The slow log is disabled without it.
+func WithSlowLogger(lg *zap.Logger) SQLServerOption {
+	return func(opts *sqlServerOptions) {
+		opts.slowLogger = lg
+	}
+}
+
 // NewSQLServer creates a new SQLServer.
 func NewSQLServer(logger *zap.Logger, cfg *config.Config, certMgr *cert.CertManager, idMgr *id.IDManager, cpt capture.Capture,
-	meter backend.Meter, hsHandler backend.HandshakeHandler) (*SQLServer, error) {
+	meter backend.Meter, hsHandler backend.HandshakeHandler, opts ...SQLServerOption) (*SQLServer, error) {
 	var err error
+	var options sqlServerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 	s := &SQLServer{
 		logger:    logger,
 		certMgr:   certMgr,
@@ -67,17 +105,30 @@ func NewSQLServer(logger *zap.Logger, cfg *config.Config, certMgr *cert.CertMana
 		hsHandler: hsHandler,
 		cpt:       cpt,
 		meter:     meter,
+		connPool:  backend.NewConnPool(cfg.Proxy.ConnPool),
+		failover:  backend.NewSessionFailover(cfg.Proxy.SessionFailover),
+		loadData:  backend.NewLoadDataMemory(cfg.Proxy.LoadDataLocal),
+		cache:     backend.NewResultCache(cfg.ResultCache),
+		firewall:  backend.NewFirewall(cfg.Firewall),
+		rewriter:  backend.NewQueryRewriter(cfg.QueryRewrite),
+		slowLog:   backend.NewSlowLog(options.slowLogger, cfg.Log.SlowLog),
+		sqlStats:  backend.NewSQLStats(cfg.SQLStats),
+		proxyAuth: backend.NewProxyAuth(logger.Named("proxy_auth"), cfg.Security.ProxyAuth),
 		mu: serverState{
-			clients: make(map[uint64]*client.ClientConnection),
+			clients:       make(map[uint64]*client.ClientConnection),
+			listenerConns: make(map[string]uint64),
 		},
 	}
 
 	s.reset(cfg)
 
 	s.addrs = strings.Split(cfg.Proxy.Addr, ",")
+	for _, listener := range cfg.Proxy.Listeners {
+		s.addrs = append(s.addrs, listener.Addr)
+	}
 	s.listeners = make([]net.Listener, len(s.addrs))
 	for i, addr := range s.addrs {
-		s.listeners[i], err = net.Listen("tcp", addr)
+		s.listeners[i], err = listen(addr, cfg.Proxy.UnixSocketPermission)
 		if err != nil {
 			return nil, err
 		}
@@ -91,18 +142,61 @@ func (s *SQLServer) reset(cfg *config.Config) {
 	if parseErr != nil {
 		s.logger.Warn("failed to parse public endpoints", zap.Error(parseErr))
 	}
+	certUserMapper, err := backend.NewCertUserMapper(cfg.Security.ServerSQLTLS)
+	if err != nil {
+		s.logger.Warn("failed to parse cert user mapping", zap.Error(err))
+	}
+	listenerMappers := make(map[string]*backend.CertUserMapper, len(cfg.Proxy.Listeners))
+	for _, listener := range cfg.Proxy.Listeners {
+		// The listener uses the global server-tls if it doesn't override it.
+		if !listener.HasServerTLS() {
+			continue
+		}
+		mapper, err := backend.NewCertUserMapper(listener.ServerTLS)
+		if err != nil {
+			s.logger.Warn("failed to parse cert user mapping", zap.String("addr", listener.Addr), zap.Error(err))
+		}
+		listenerMappers[listener.Addr] = mapper
+	}
 	s.mu.Lock()
 	s.mu.tcpKeepAlive = cfg.Proxy.FrontendKeepalive.Enabled
 	s.mu.maxConnections = cfg.Proxy.MaxConnections
 	s.mu.requireBackendTLS = cfg.Security.RequireBackendTLS
 	s.mu.proxyProtocol = cfg.Proxy.ProxyProtocol != ""
+	s.mu.proxyVersion = proxyprotocol.ParseProxyVersion(cfg.Proxy.ProxyProtocol)
 	s.mu.gracefulWait = cfg.Proxy.GracefulWaitBeforeShutdown
 	s.mu.gracefulClose = cfg.Proxy.GracefulCloseConnTimeout
 	s.mu.healthyKeepAlive = cfg.Proxy.BackendHealthyKeepalive
 	s.mu.unhealthyKeepAlive = cfg.Proxy.BackendUnhealthyKeepalive
 	s.mu.connBufferSize = cfg.Proxy.ConnBufferSize
 	s.mu.publicEndpoints = cidrList
+	s.mu.stmtTimeout = cfg.Proxy.StatementTimeout.Default
+	s.mu.userStmtTimeouts = make(map[string]time.Duration, len(cfg.Proxy.StatementTimeout.Users))
+	for _, ut := range cfg.Proxy.StatementTimeout.Users {
+		s.mu.userStmtTimeouts[ut.User] = ut.Timeout
+	}
+	s.mu.loadDataLocal = cfg.Proxy.LoadDataLocal.Default
+	s.mu.userLoadDataLocal = make(map[string]config.LoadDataPolicy, len(cfg.Proxy.LoadDataLocal.Users))
+	for _, up := range cfg.Proxy.LoadDataLocal.Users {
+		s.mu.userLoadDataLocal[up.User] = up.LoadDataPolicy
+	}
+	s.mu.retryReads = cfg.Proxy.RetryReads
+	s.mu.listenerCfgs = make(map[string]config.ProxyListener, len(cfg.Proxy.Listeners))
+	for _, listener := range cfg.Proxy.Listeners {
+		s.mu.listenerCfgs[listener.Addr] = listener
+	}
+	s.mu.certUserMapper = certUserMapper
+	s.mu.listenerMappers = listenerMappers
 	s.mu.Unlock()
+	s.connPool.SetConfig(cfg.Proxy.ConnPool)
+	s.failover.SetConfig(cfg.Proxy.SessionFailover)
+	s.loadData.SetConfig(cfg.Proxy.LoadDataLocal)
+	s.cache.SetConfig(cfg.ResultCache)
+	s.firewall.SetConfig(cfg.Firewall)
+	s.rewriter.SetConfig(cfg.QueryRewrite)
+	s.slowLog.SetConfig(cfg.Log.SlowLog)
+	s.sqlStats.SetConfig(cfg.SQLStats)
+	s.proxyAuth.SetConfig(cfg.Security.ProxyAuth)
 }
 
 func (s *SQLServer) Run(ctx context.Context, cfgch <-chan *config.Config) {
@@ -161,21 +255,65 @@ func (s *SQLServer) onConn(ctx context.Context, conn net.Conn, addr string) {
 			s.logger.Warn("too many connections", zap.Uint64("max connections", maxConns), zap.Stringer("client_addr", conn.RemoteAddr()), zap.Error(conn.Close()))
 			return false, nil, 0, nil
 		}
+		// The listener only decides whether to parse the PROXY header from clients. Whether to send it to backends
+		// depends on the backends, so it's decided by the global setting.
+		clientProxyProtocol := s.mu.proxyProtocol
+		listenerCfg, ok := s.mu.listenerCfgs[addr]
+		if ok {
+			if listenerCfg.MaxConnections != 0 && s.mu.listenerConns[addr] >= listenerCfg.MaxConnections {
+				s.logger.Warn("too many connections on the listener", zap.String("addr", addr), zap.Uint64("max connections", listenerCfg.MaxConnections),
+					zap.Stringer("client_addr", conn.RemoteAddr()), zap.Error(conn.Close()))
+				return false, nil, 0, nil
+			}
+			clientProxyProtocol = listenerCfg.ProxyProtocol != ""
+		}
+
+		certUserMapper, ok := s.mu.listenerMappers[addr]
+		if !ok {
+			certUserMapper = s.mu.certUserMapper
+		}
 
 		connID := s.idMgr.NewID()
 		logger := s.logger.With(zap.Uint64("connID", connID), zap.String("client_addr", conn.RemoteAddr().String()),
 			zap.String("addr", addr))
-		clientConn := client.NewClientConnection(logger.Named("conn"), conn, s.certMgr.ServerSQLTLS(), s.certMgr.SQLTLS(),
+		var connPool *backend.ConnPool
+		// Enabling the pool only affects new connections.
+		if s.connPool.Enabled() {
+			connPool = s.connPool
+		}
+		clientConn := client.NewClientConnection(logger.Named("conn"), conn, s.certMgr.ListenerSQLTLS(addr), s.certMgr.SQLTLS(),
 			s.hsHandler, s.cpt, connID, addr, &backend.BCConfig{
-				ProxyProtocol:       s.mu.proxyProtocol,
-				RequireBackendTLS:   s.mu.requireBackendTLS,
-				HealthyKeepAlive:    s.mu.healthyKeepAlive,
-				UnhealthyKeepAlive:  s.mu.unhealthyKeepAlive,
-				ConnBufferSize:      s.mu.connBufferSize,
-				FromPublicEndpoints: s.fromPublicEndpoint,
+				ProxyProtocol:         s.mu.proxyProtocol,
+				ProxyProtocolVersion:  s.mu.proxyVersion,
+				ClientProxyProtocol:   clientProxyProtocol,
+				DefaultNamespace:      listenerCfg.Namespace,
+				RoutingGroup:          listenerCfg.RoutingGroup,
+				RequireBackendTLS:     s.mu.requireBackendTLS,
+				HealthyKeepAlive:      s.mu.healthyKeepAlive,
+				UnhealthyKeepAlive:    s.mu.unhealthyKeepAlive,
+				ConnBufferSize:        s.mu.connBufferSize,
+				FromPublicEndpoints:   s.fromPublicEndpoint,
+				ConnPool:              connPool,
+				ResultCache:           s.cache,
+				Firewall:              s.firewall,
+				QueryRewriter:         s.rewriter,
+				StatementTimeout:      s.mu.stmtTimeout,
+				UserStatementTimeouts: s.mu.userStmtTimeouts,
+				LoadDataLocal:         s.mu.loadDataLocal,
+				UserLoadDataLocal:     s.mu.userLoadDataLocal,
+				RetryReads:            s.mu.retryReads,
+				SessionFailover:       s.failover,
+				LoadDataMemory:        s.loadData,
+				FindConn:              s.findConn,
+				SlowLog:               s.slowLog,
+				SQLStats:              s.sqlStats,
+				BackendTLS:            s.certMgr,
+				ProxyAuth:             s.proxyAuth,
+				CertUserMapper:        certUserMapper,
 			}, s.meter)
 		s.mu.clients[connID] = clientConn
-		logger.Debug("new connection", zap.Bool("proxy-protocol", s.mu.proxyProtocol), zap.Bool("require_backend_tls", s.mu.requireBackendTLS))
+		s.mu.listenerConns[addr]++
+		logger.Debug("new connection", zap.Bool("proxy-protocol", clientProxyProtocol), zap.Bool("require_backend_tls", s.mu.requireBackendTLS))
 		return s.mu.tcpKeepAlive, logger, connID, clientConn
 	}()
 
@@ -189,6 +327,7 @@ func (s *SQLServer) onConn(ctx context.Context, conn net.Conn, addr string) {
 	defer func() {
 		s.mu.Lock()
 		delete(s.mu.clients, connID)
+		s.mu.listenerConns[addr]--
 		s.mu.Unlock()
 
 		if err := clientConn.Close(); err != nil && !pnet.IsDisconnectError(err) {
@@ -199,8 +338,11 @@ func (s *SQLServer) onConn(ctx context.Context, conn net.Conn, addr string) {
 		metrics.ConnGauge.Dec()
 	}()
 
-	if err := keepalive.SetKeepalive(conn, config.KeepAlive{Enabled: tcpKeepAlive}); err != nil {
-		logger.Warn("failed to set tcp keep alive option", zap.Error(err))
+	// Unix socket connections are local and don't need keepalive.
+	if _, ok := conn.(*unixConn); !ok {
+		if err := keepalive.SetKeepalive(conn, config.KeepAlive{Enabled: tcpKeepAlive}); err != nil {
+			logger.Warn("failed to set tcp keep alive option", zap.Error(err))
+		}
 	}
 
 	clientConn.Run(ctx)
@@ -210,6 +352,10 @@ func (s *SQLServer) fromPublicEndpoint(addr net.Addr) bool {
 	if addr == nil || reflect.ValueOf(addr).IsNil() {
 		return false
 	}
+	// Unix socket clients are on the same host.
+	if _, ok := addr.(*net.UnixAddr); ok {
+		return false
+	}
 	s.mu.RLock()
 	publicEndpoints := s.mu.publicEndpoints
 	s.mu.RUnlock()
@@ -231,6 +377,21 @@ func (s *SQLServer) fromPublicEndpoint(addr net.Addr) bool {
 	return !netutil.IsPrivate(ip)
 }
 
+// findConn returns the connection of the connection ID, which is used to translate KILL statements.
+func (s *SQLServer) findConn(connID uint64) *backend.BackendConnManager {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if clientConn, ok := s.mu.clients[connID]; ok {
+		return clientConn.ConnMgr()
+	}
+	return nil
+}
+
+// SQLStats returns the statistics of the forwarded statements.
+func (s *SQLServer) SQLStats() *backend.SQLStats {
+	return s.sqlStats
+}
+
 func (s *SQLServer) PreClose() {
 	// Step 1: HTTP status returns unhealthy so that NLB takes this instance offline and then new connections won't come.
 	s.mu.Lock()
@@ -295,5 +456,6 @@ func (s *SQLServer) Close() error {
 	s.mu.RUnlock()
 
 	s.wg.Wait()
+	s.connPool.Close()
 	return nil
 }
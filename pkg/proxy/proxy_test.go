@@ -7,7 +7,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -219,6 +222,118 @@ func TestMultiAddr(t *testing.T) {
 	certManager.Close()
 }
 
+func TestListeners(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	cfg := &config.Config{
+		Proxy: config.ProxyServer{
+			Addr: "127.0.0.1:0",
+			Listeners: []config.ProxyListener{
+				{
+					Addr:           "localhost:0",
+					ProxyProtocol:  "v2",
+					MaxConnections: 1,
+					Namespace:      "ns",
+				},
+			},
+		},
+	}
+	certManager := cert.NewCertManager()
+	require.NoError(t, certManager.Init(cfg, lg, nil))
+	server, err := NewSQLServer(lg, cfg, certManager, id.NewIDManager(), nil, nil, &mockHsHandler{})
+	require.NoError(t, err)
+	server.Run(context.Background(), nil)
+	require.Len(t, server.listeners, 2)
+
+	listenerConns := func(addr string) uint64 {
+		server.mu.RLock()
+		defer server.mu.RUnlock()
+		return server.mu.listenerConns[addr]
+	}
+	// the listener accepts only 1 connection
+	conn1, err := net.Dial("tcp", server.listeners[1].Addr().String())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return listenerConns("localhost:0") == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	conn2, err := net.Dial("tcp", server.listeners[1].Addr().String())
+	require.NoError(t, err)
+	_, err = conn2.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+	require.NoError(t, conn2.Close())
+	// the other listener is not limited
+	conn3, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return listenerConns("127.0.0.1:0") == 1
+	}, 3*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, conn1.Close())
+	require.NoError(t, conn3.Close())
+	require.Eventually(t, func() bool {
+		return listenerConns("localhost:0") == 0 && listenerConns("127.0.0.1:0") == 0
+	}, 3*time.Second, 10*time.Millisecond)
+	server.PreClose()
+	require.NoError(t, server.Close())
+	certManager.Close()
+}
+
+func TestUnixSocket(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	certManager := cert.NewCertManager()
+	err := certManager.Init(&config.Config{}, lg, nil)
+	require.NoError(t, err)
+
+	// the stale socket file left by the last process is removed
+	sockPath := filepath.Join(t.TempDir(), "tiproxy.sock")
+	ln, err := net.Listen("unix", sockPath)
+	require.NoError(t, err)
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, ln.Close())
+
+	server, err := NewSQLServer(lg, &config.Config{
+		Proxy: config.ProxyServer{
+			Addr:                 "0.0.0.0:0," + config.UnixSocketPrefix + sockPath,
+			UnixSocketPermission: "0660",
+		},
+	}, certManager, id.NewIDManager(), nil, nil, &mockHsHandler{})
+	require.NoError(t, err)
+	server.Run(context.Background(), nil)
+
+	require.Len(t, server.listeners, 2)
+	fi, err := os.Stat(sockPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0660), fi.Mode().Perm())
+	conn, err := net.Dial("unix", sockPath)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.False(t, server.fromPublicEndpoint(&net.UnixAddr{Name: sockPath, Net: "unix"}))
+
+	server.PreClose()
+	require.NoError(t, server.Close())
+	certManager.Close()
+	// the socket file is removed after closing
+	_, err = os.Stat(sockPath)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestUnixSocketRemoteAddr(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "tiproxy.sock")
+	ln, err := listen(config.UnixSocketPrefix+sockPath, "")
+	require.NoError(t, err)
+	var wg waitgroup.WaitGroup
+	wg.Run(func() {
+		conn, err := net.Dial("unix", sockPath)
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+	})
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	require.Equal(t, sockPath, conn.RemoteAddr().String())
+	require.NoError(t, conn.Close())
+	wg.Wait()
+	require.NoError(t, ln.Close())
+}
+
 func TestWatchCfg(t *testing.T) {
 	lg, _ := logger.CreateLoggerForTest(t)
 	hsHandler := backend.NewDefaultHandshakeHandler(nil)
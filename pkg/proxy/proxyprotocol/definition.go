@@ -8,9 +8,18 @@ import "net"
 type ProxyVersion int
 
 const (
-	ProxyVersion2 ProxyVersion = iota + 2
+	ProxyVersion1 ProxyVersion = iota + 1
+	ProxyVersion2
 )
 
+// ParseProxyVersion converts the config value to the version. It returns v2 by default.
+func ParseProxyVersion(version string) ProxyVersion {
+	if version == "v1" {
+		return ProxyVersion1
+	}
+	return ProxyVersion2
+}
+
 type ProxyCommand int
 
 const (
@@ -43,12 +52,26 @@ const (
 	ProxyTlvCRC32C
 	ProxyTlvNoop
 	ProxyTlvUniqueID
+)
+
+const (
 	ProxyTlvSSL ProxyTlvType = iota + 0x20
+	ProxyTlvSSLVersion
 	ProxyTlvSSLCN
 	ProxyTlvSSLCipher
 	ProxyTlvSSLSignALG
 	ProxyTlvSSLKeyALG
-	ProxyTlvNetns ProxyTlvType = iota + 0x30
+)
+
+const (
+	ProxyTlvNetns ProxyTlvType = 0x30
+	// ProxyTlvAWS is the vendor-specific type used by AWS NLB.
+	ProxyTlvAWS ProxyTlvType = 0xEA
+)
+
+const (
+	// ProxyTlvAWSSubtypeVPCEndpointID is the first byte of the AWS TLV that carries the VPC endpoint ID.
+	ProxyTlvAWSSubtypeVPCEndpointID byte = 0x01
 )
 
 type ProxyTlv struct {
@@ -7,4 +7,5 @@ import "github.com/pingcap/tiproxy/lib/util/errors"
 
 var (
 	ErrAddressFamilyMismatch = errors.New("address family between source and target mismatched")
+	ErrInvalidV1Header       = errors.New("invalid proxy protocol v1 header")
 )
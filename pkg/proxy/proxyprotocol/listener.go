@@ -50,6 +50,14 @@ func (c *proxyConn) Read(b []byte) (n int, err error) {
 			if err != nil {
 				return 0, err
 			}
+		} else if bytes.HasPrefix(c.buf.Bytes(), MagicV1) {
+			// the header may have been partially read into the buffer
+			rd := io.MultiReader(bytes.NewReader(c.buf.Bytes()[len(MagicV1):]), c.Conn)
+			c.buf.Reset()
+			c.proxy, _, err = ParseProxyV1(rd)
+			if err != nil {
+				return 0, err
+			}
 		}
 		// prefixes mismatched, or we have parsed PP header
 		c.inited = true
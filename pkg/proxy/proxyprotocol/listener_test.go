@@ -55,6 +55,33 @@ func TestProxyListener(t *testing.T) {
 			require.Equal(t, tcpaddr.String(), c.RemoteAddr().String())
 		}, 1)
 
+	testkit.TestTCPConnWithListener(t,
+		func(t *testing.T, network, addr string) net.Listener {
+			ln, err := net.Listen(network, addr)
+			require.NoError(t, err)
+			return NewListener(ln)
+		},
+		func(t *testing.T, c net.Conn) {
+			p := &Proxy{
+				Version:    ProxyVersion1,
+				Command:    ProxyCommandProxy,
+				SrcAddress: tcpaddr,
+				DstAddress: tcpaddr,
+			}
+			b, err := p.ToBytes()
+			require.NoError(t, err)
+			_, err = io.Copy(c, bytes.NewReader(b))
+			require.NoError(t, err)
+			_, err = io.Copy(c, strings.NewReader("test"))
+			require.NoError(t, err)
+		},
+		func(t *testing.T, c net.Conn) {
+			all, err := io.ReadAll(c)
+			require.NoError(t, err)
+			require.Equal(t, []byte("test"), all)
+			require.Equal(t, tcpaddr.String(), c.RemoteAddr().String())
+		}, 1)
+
 	testkit.TestTCPConnWithListener(t,
 		func(t *testing.T, network, addr string) net.Listener {
 			ln, err := net.Listen(network, addr)
@@ -4,14 +4,26 @@
 package proxyprotocol
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
 )
 
 var (
+	MagicV1 = []byte("PROXY ")
 	MagicV2 = []byte{0xD, 0xA, 0xD, 0xA, 0x0, 0xD, 0xA, 0x51, 0x55, 0x49, 0x54, 0xA}
 )
 
+const (
+	// maxV1HeaderLen is the max length of a v1 header, including the magic and CRLF.
+	maxV1HeaderLen = 107
+)
+
 func unwrapOriginAddr(addr net.Addr) net.Addr {
 	for {
 		v, ok := addr.(AddressWrapper)
@@ -22,7 +34,38 @@ func unwrapOriginAddr(addr net.Addr) net.Addr {
 	}
 }
 
+// ToBytes encodes the header in the format of p.Version.
 func (p *Proxy) ToBytes() ([]byte, error) {
+	if p.Version == ProxyVersion1 {
+		return p.toBytesV1(), nil
+	}
+	return p.toBytesV2()
+}
+
+// toBytesV1 encodes the header in the text format. Only TCP addresses can be encoded in v1,
+// so other addresses are sent as UNKNOWN and the receiver uses the real connection addresses.
+func (p *Proxy) toBytesV1() []byte {
+	sadd, ok1 := unwrapOriginAddr(p.SrcAddress).(*net.TCPAddr)
+	dadd, ok2 := unwrapOriginAddr(p.DstAddress).(*net.TCPAddr)
+	if p.Command == ProxyCommandLocal || !ok1 || !ok2 {
+		return append(bytes.Clone(MagicV1), "UNKNOWN\r\n"...)
+	}
+	saddUnifiedIP, daddUnifiedIP := unifyIPFamily(sadd.IP, dadd.IP)
+	if len(saddUnifiedIP) == net.IPv4len {
+		return fmt.Appendf(bytes.Clone(MagicV1), "TCP4 %s %s %d %d\r\n", saddUnifiedIP, daddUnifiedIP, sadd.Port, dadd.Port)
+	}
+	return fmt.Appendf(bytes.Clone(MagicV1), "TCP6 %s %s %d %d\r\n", formatIPv6(saddUnifiedIP), formatIPv6(daddUnifiedIP), sadd.Port, dadd.Port)
+}
+
+// formatIPv6 formats IPv4-mapped addresses in IPv6 notation, which net.IP.String() doesn't do.
+func formatIPv6(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return "::ffff:" + ip4.String()
+	}
+	return ip.String()
+}
+
+func (p *Proxy) toBytesV2() ([]byte, error) {
 	magicLen := len(MagicV2)
 	buf := make([]byte, magicLen+4)
 	_ = copy(buf, MagicV2)
@@ -33,6 +76,14 @@ func (p *Proxy) ToBytes() ([]byte, error) {
 
 	srcAddr := unwrapOriginAddr(p.SrcAddress)
 	dstAddr := unwrapOriginAddr(p.DstAddress)
+	// A Unix socket client is forwarded to a TCP backend, so the addresses can't be encoded together.
+	// Send LOCAL with the UNSPEC family, just like UNKNOWN in v1, and the receiver uses the real connection addresses.
+	if _, ok := srcAddr.(*net.UnixAddr); ok {
+		if _, ok := dstAddr.(*net.UnixAddr); !ok {
+			buf[magicLen] = byte(p.Version<<4) | byte(ProxyCommandLocal&0xF)
+			srcAddr, dstAddr = nil, nil
+		}
+	}
 
 	switch sadd := srcAddr.(type) {
 	case *net.TCPAddr:
@@ -111,6 +162,72 @@ func unifyIPFamily(ip1 net.IP, ip2 net.IP) (net.IP, net.IP) {
 	return ip1.To16(), ip2.To16()
 }
 
+// ParseProxyV1 parses the v1 header in text format. MagicV1 must be consumed before calling it.
+// It reads byte by byte so that it never reads beyond the header.
+func ParseProxyV1(rd io.Reader) (m *Proxy, n int, err error) {
+	line := make([]byte, 0, maxV1HeaderLen-len(MagicV1))
+	var b [1]byte
+	for {
+		if len(line) >= maxV1HeaderLen-len(MagicV1) {
+			return nil, n, errors.Wrapf(ErrInvalidV1Header, "header is too long")
+		}
+		if _, err = io.ReadFull(rd, b[:]); err != nil {
+			return nil, n, err
+		}
+		n++
+		if b[0] == '\n' {
+			break
+		}
+		line = append(line, b[0])
+	}
+	if len(line) == 0 || line[len(line)-1] != '\r' {
+		return nil, n, errors.Wrapf(ErrInvalidV1Header, "header doesn't end with CRLF")
+	}
+
+	m = &Proxy{
+		Version: ProxyVersion1,
+		Command: ProxyCommandProxy,
+	}
+	fields := strings.Split(string(line[:len(line)-1]), " ")
+	switch fields[0] {
+	case "UNKNOWN":
+		// The receiver must ignore the rest of the line and use the real connection addresses.
+		m.Command = ProxyCommandLocal
+		return m, n, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, n, errors.Wrapf(ErrInvalidV1Header, "unknown protocol %s", fields[0])
+	}
+	if len(fields) != 5 {
+		return nil, n, errors.Wrapf(ErrInvalidV1Header, "expect 5 fields but got %d", len(fields))
+	}
+	saddr, daddr := net.ParseIP(fields[1]), net.ParseIP(fields[2])
+	if saddr == nil || daddr == nil {
+		return nil, n, errors.Wrapf(ErrInvalidV1Header, "invalid address %s or %s", fields[1], fields[2])
+	}
+	// IPv4-mapped addresses are valid for TCP6, so check the notation instead of the parsed IP.
+	if isV6 := fields[0] == "TCP6"; isV6 != strings.Contains(fields[1], ":") || isV6 != strings.Contains(fields[2], ":") {
+		return nil, n, errors.Wrapf(ErrAddressFamilyMismatch, "%s %s %s", fields[0], fields[1], fields[2])
+	}
+	sport, err := strconv.ParseUint(fields[3], 10, 16)
+	if err != nil {
+		return nil, n, errors.Wrapf(ErrInvalidV1Header, "invalid port %s", fields[3])
+	}
+	dport, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, n, errors.Wrapf(ErrInvalidV1Header, "invalid port %s", fields[4])
+	}
+	m.SrcAddress = &net.TCPAddr{
+		IP:   saddr,
+		Port: int(sport),
+	}
+	m.DstAddress = &net.TCPAddr{
+		IP:   daddr,
+		Port: int(dport),
+	}
+	return m, n, nil
+}
+
 func ParseProxyV2(rd io.Reader) (m *Proxy, n int, err error) {
 	var hdr [4]byte
 
@@ -200,6 +317,8 @@ func ParseProxyV2(rd io.Reader) (m *Proxy, n int, err error) {
 			// TODO: logging
 		}
 		buf = buf[216:]
+	case ProxyAFUnspec:
+		// no addresses, but the TLVs still follow
 	default:
 		buf = buf[len(buf):]
 	}
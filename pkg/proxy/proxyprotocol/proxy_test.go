@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"io"
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/pingcap/tiproxy/pkg/testkit"
@@ -98,6 +99,19 @@ func TestProxyToBytes(t *testing.T) {
 	hdr.DstAddress = &originAddr{Addr: &net.TCPAddr{IP: make(net.IP, net.IPv6len), Port: 0}}
 	_, err = hdr.ToBytes()
 	require.NoError(t, err)
+
+	// a Unix socket client is sent as LOCAL with the UNSPEC family
+	hdr.Command = ProxyCommandProxy
+	hdr.SrcAddress = &net.UnixAddr{Name: "/tmp/test.sock", Net: "unix"}
+	hdr.TLV = []ProxyTlv{{Typ: ProxyTlvALPN, Content: []byte("test")}}
+	hdrBytes, err = hdr.ToBytes()
+	require.NoError(t, err)
+	p, _, err := ParseProxyV2(bytes.NewReader(hdrBytes[len(MagicV2):]))
+	require.NoError(t, err)
+	require.Equal(t, ProxyCommandLocal, p.Command)
+	require.Nil(t, p.SrcAddress)
+	require.Nil(t, p.DstAddress)
+	require.Equal(t, hdr.TLV, p.TLV)
 }
 
 func TestMixIPv4AndIPv6ProxyToBytes(t *testing.T) {
@@ -181,3 +195,99 @@ func TestMixIPv4AndIPv6ProxyToBytes(t *testing.T) {
 		require.Equal(t, expectedPayloadSize, length)
 	}
 }
+
+func TestProxyV1(t *testing.T) {
+	tests := []struct {
+		src     *net.TCPAddr
+		dst     *net.TCPAddr
+		command ProxyCommand
+		header  string
+	}{
+		{
+			src:     &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 1234},
+			dst:     &net.TCPAddr{IP: net.ParseIP("192.168.1.2"), Port: 5678},
+			command: ProxyCommandProxy,
+			header:  "PROXY TCP4 192.168.1.1 192.168.1.2 1234 5678\r\n",
+		},
+		{
+			src:     &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1234},
+			dst:     &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 5678},
+			command: ProxyCommandProxy,
+			header:  "PROXY TCP6 2001:db8::1 2001:db8::2 1234 5678\r\n",
+		},
+		{
+			src:     &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 1234},
+			dst:     &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 5678},
+			command: ProxyCommandProxy,
+			header:  "PROXY TCP6 ::ffff:192.168.1.1 2001:db8::2 1234 5678\r\n",
+		},
+		{
+			command: ProxyCommandLocal,
+			header:  "PROXY UNKNOWN\r\n",
+		},
+	}
+
+	for i, test := range tests {
+		hdr := &Proxy{
+			Version: ProxyVersion1,
+			Command: test.command,
+		}
+		if test.src != nil {
+			hdr.SrcAddress, hdr.DstAddress = test.src, test.dst
+		}
+		b, err := hdr.ToBytes()
+		require.NoError(t, err, "case %d", i)
+		require.Equal(t, test.header, string(b), "case %d", i)
+
+		// the bytes after the header must not be consumed
+		rd := bytes.NewReader(append(b[len(MagicV1):], "test"...))
+		p, n, err := ParseProxyV1(rd)
+		require.NoError(t, err, "case %d", i)
+		require.Equal(t, len(b)-len(MagicV1), n, "case %d", i)
+		require.Equal(t, ProxyVersion1, p.Version, "case %d", i)
+		require.Equal(t, test.command, p.Command, "case %d", i)
+		if test.src != nil {
+			require.Equal(t, test.src.Port, p.SrcAddress.(*net.TCPAddr).Port, "case %d", i)
+			require.True(t, test.src.IP.Equal(p.SrcAddress.(*net.TCPAddr).IP), "case %d", i)
+			require.Equal(t, test.dst.Port, p.DstAddress.(*net.TCPAddr).Port, "case %d", i)
+			require.True(t, test.dst.IP.Equal(p.DstAddress.(*net.TCPAddr).IP), "case %d", i)
+		}
+		remain, err := io.ReadAll(rd)
+		require.NoError(t, err)
+		require.Equal(t, "test", string(remain), "case %d", i)
+	}
+
+	// non-TCP addresses are sent as UNKNOWN
+	hdr := &Proxy{
+		Version:    ProxyVersion1,
+		Command:    ProxyCommandProxy,
+		SrcAddress: &net.UnixAddr{Name: "/tmp/test.sock", Net: "unix"},
+		DstAddress: &originAddr{Addr: &net.TCPAddr{IP: net.ParseIP("192.168.1.2"), Port: 5678}},
+	}
+	b, err := hdr.ToBytes()
+	require.NoError(t, err)
+	require.Equal(t, "PROXY UNKNOWN\r\n", string(b))
+}
+
+func TestInvalidProxyV1(t *testing.T) {
+	headers := []string{
+		"TCP4 192.168.1.1 192.168.1.2 1234 5678\n",
+		"TCP4 192.168.1.1 192.168.1.2 1234\r\n",
+		"TCP5 192.168.1.1 192.168.1.2 1234 5678\r\n",
+		"TCP4 192.168.1.1 192.168.1.2 1234 65536\r\n",
+		"TCP4 192.168.1.1 192.168.1.2 -1 5678\r\n",
+		"TCP4 192.168.1 192.168.1.2 1234 5678\r\n",
+		"TCP4 2001:db8::1 192.168.1.2 1234 5678\r\n",
+		"TCP6 192.168.1.1 192.168.1.2 1234 5678\r\n",
+		"TCP4  192.168.1.1 192.168.1.2 1234 5678\r\n",
+		"TCP4 192.168.1.1 192.168.1.2 1234 5678" + strings.Repeat(" ", 100) + "\r\n",
+	}
+	for i, header := range headers {
+		_, _, err := ParseProxyV1(strings.NewReader(header))
+		require.Error(t, err, "case %d", i)
+	}
+
+	// EOF before the header ends
+	_, _, err := ParseProxyV1(strings.NewReader("TCP4 192.168.1.1"))
+	require.ErrorIs(t, err, io.EOF)
+}
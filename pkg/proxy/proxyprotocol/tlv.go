@@ -0,0 +1,96 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package proxyprotocol
+
+import (
+	"encoding/binary"
+)
+
+const (
+	// ProxySSLClientSSL means the client connected over SSL/TLS.
+	ProxySSLClientSSL uint8 = 0x01
+	// ProxySSLClientCertConn means the client provided a certificate over the current connection.
+	ProxySSLClientCertConn uint8 = 0x02
+	// ProxySSLClientCertSess means the client provided a certificate at least once over the TLS session.
+	ProxySSLClientCertSess uint8 = 0x04
+)
+
+// ProxySSL is the parsed content of the PP2_TYPE_SSL TLV.
+type ProxySSL struct {
+	Version string
+	CN      string
+	Cipher  string
+	SignAlg string
+	KeyAlg  string
+	// Verify is 0 if the client certificate is verified successfully.
+	Verify uint32
+	Client uint8
+}
+
+// TLVValue returns the content of the first TLV of the type.
+func (p *Proxy) TLVValue(typ ProxyTlvType) ([]byte, bool) {
+	for _, tlv := range p.TLV {
+		if tlv.Typ == typ {
+			return tlv.Content, true
+		}
+	}
+	return nil, false
+}
+
+// Authority returns the host name that the client used, which is typically the SNI.
+func (p *Proxy) Authority() string {
+	content, _ := p.TLVValue(ProxyTlvAuthority)
+	return string(content)
+}
+
+// UniqueID returns the opaque connection ID generated by the upstream proxy.
+func (p *Proxy) UniqueID() []byte {
+	content, _ := p.TLVValue(ProxyTlvUniqueID)
+	return content
+}
+
+// AWSVPCEndpointID returns the VPC endpoint ID that the client came through when the upstream is AWS NLB.
+func (p *Proxy) AWSVPCEndpointID() string {
+	for _, tlv := range p.TLV {
+		if tlv.Typ == ProxyTlvAWS && len(tlv.Content) > 1 && tlv.Content[0] == ProxyTlvAWSSubtypeVPCEndpointID {
+			return string(tlv.Content[1:])
+		}
+	}
+	return ""
+}
+
+// SSL parses the SSL TLV. It returns nil if the TLV doesn't exist or is malformed.
+func (p *Proxy) SSL() *ProxySSL {
+	content, ok := p.TLVValue(ProxyTlvSSL)
+	if !ok || len(content) < 5 {
+		return nil
+	}
+	ssl := &ProxySSL{
+		Client: content[0],
+		Verify: binary.BigEndian.Uint32(content[1:5]),
+	}
+	buf := content[5:]
+	for len(buf) >= 3 {
+		typ := ProxyTlvType(buf[0])
+		length := int(buf[1])<<8 | int(buf[2])
+		if len(buf) < length+3 {
+			return nil
+		}
+		value := string(buf[3 : 3+length])
+		switch typ {
+		case ProxyTlvSSLVersion:
+			ssl.Version = value
+		case ProxyTlvSSLCN:
+			ssl.CN = value
+		case ProxyTlvSSLCipher:
+			ssl.Cipher = value
+		case ProxyTlvSSLSignALG:
+			ssl.SignAlg = value
+		case ProxyTlvSSLKeyALG:
+			ssl.KeyAlg = value
+		}
+		buf = buf[3+length:]
+	}
+	return ssl
+}
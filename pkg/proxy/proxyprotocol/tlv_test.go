@@ -0,0 +1,63 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package proxyprotocol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLVAccessors(t *testing.T) {
+	sslContent := []byte{ProxySSLClientSSL | ProxySSLClientCertConn, 0, 0, 0, 0}
+	for _, tlv := range []ProxyTlv{
+		{Typ: ProxyTlvSSLVersion, Content: []byte("TLSv1.3")},
+		{Typ: ProxyTlvSSLCN, Content: []byte("tenant1")},
+		{Typ: ProxyTlvSSLCipher, Content: []byte("TLS_AES_128_GCM_SHA256")},
+	} {
+		sslContent = append(sslContent, byte(tlv.Typ), byte(len(tlv.Content)>>8), byte(len(tlv.Content)))
+		sslContent = append(sslContent, tlv.Content...)
+	}
+	hdr := &Proxy{
+		Version:    ProxyVersion2,
+		Command:    ProxyCommandProxy,
+		SrcAddress: &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 1234},
+		DstAddress: &net.TCPAddr{IP: net.ParseIP("192.168.1.2"), Port: 5678},
+		TLV: []ProxyTlv{
+			{Typ: ProxyTlvAuthority, Content: []byte("tidb.example.com")},
+			{Typ: ProxyTlvUniqueID, Content: []byte{0x01, 0x02}},
+			{Typ: ProxyTlvSSL, Content: sslContent},
+			{Typ: ProxyTlvAWS, Content: append([]byte{ProxyTlvAWSSubtypeVPCEndpointID}, "vpce-08d2bf15fac5001c9"...)},
+		},
+	}
+	b, err := hdr.ToBytes()
+	require.NoError(t, err)
+	p, _, err := ParseProxyV2(bytes.NewReader(b[len(MagicV2):]))
+	require.NoError(t, err)
+
+	require.Equal(t, "tidb.example.com", p.Authority())
+	require.Equal(t, []byte{0x01, 0x02}, p.UniqueID())
+	require.Equal(t, "vpce-08d2bf15fac5001c9", p.AWSVPCEndpointID())
+	ssl := p.SSL()
+	require.NotNil(t, ssl)
+	require.Equal(t, ProxySSLClientSSL|ProxySSLClientCertConn, ssl.Client)
+	require.Equal(t, uint32(0), ssl.Verify)
+	require.Equal(t, "TLSv1.3", ssl.Version)
+	require.Equal(t, "tenant1", ssl.CN)
+	require.Equal(t, "TLS_AES_128_GCM_SHA256", ssl.Cipher)
+	_, ok := p.TLVValue(ProxyTlvNetns)
+	require.False(t, ok)
+
+	// absent or malformed TLVs
+	p = &Proxy{TLV: []ProxyTlv{
+		{Typ: ProxyTlvSSL, Content: []byte{ProxySSLClientSSL, 0, 0}},
+		{Typ: ProxyTlvAWS, Content: []byte{0x02, 'a'}},
+	}}
+	require.Empty(t, p.Authority())
+	require.Empty(t, p.UniqueID())
+	require.Empty(t, p.AWSVPCEndpointID())
+	require.Nil(t, p.SSL())
+}
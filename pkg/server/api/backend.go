@@ -4,6 +4,7 @@
 package api
 
 import (
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +24,32 @@ func (h *Server) BackendMetrics(c *gin.Context) {
 	}
 }
 
+// BackendEvents streams the backend changes of all namespaces as Server-Sent Events until the client disconnects.
+func (h *Server) BackendEvents(c *gin.Context) {
+	hub := h.mgr.NsMgr.BackendEventHub()
+	id, ch := hub.Subscribe()
+	defer hub.Unsubscribe(id)
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.WriteHeader(http.StatusOK)
+	// Flush the header so that the client knows the subscription is established.
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent("backend", event)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
 func (h *Server) registerBackend(group *gin.RouterGroup) {
 	group.GET("/metrics", h.BackendMetrics)
+	group.GET("/events", h.BackendEvents)
 }
@@ -4,10 +4,14 @@
 package api
 
 import (
+	"bufio"
+	"encoding/json"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/pingcap/tiproxy/pkg/balance/observer"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 )
@@ -44,6 +48,38 @@ func TestBackendMetrics(t *testing.T) {
 	}
 }
 
+func TestBackendEvents(t *testing.T) {
+	server, doHTTP := createServer(t)
+	hub := server.mgr.NsMgr.BackendEventHub()
+	events := []observer.BackendEvent{
+		{Type: observer.BackendEventAdded, Namespace: "default", Addr: "127.0.0.1:4000", Healthy: true},
+		{Type: observer.BackendEventUnhealthy, Namespace: "default", Addr: "127.0.0.1:4000", Err: "mock error"},
+	}
+	doHTTP(t, http.MethodGet, "/api/backend/events", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		require.Equal(t, "text/event-stream", r.Header.Get("Content-Type"))
+		hub.Publish(events)
+		reader := bufio.NewReader(r.Body)
+		for _, expected := range events {
+			var data string
+			for {
+				line, err := reader.ReadString('\n')
+				require.NoError(t, err)
+				if strings.HasPrefix(line, "data:") {
+					data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+					break
+				}
+			}
+			var event observer.BackendEvent
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			require.Equal(t, expected.Type, event.Type)
+			require.Equal(t, expected.Addr, event.Addr)
+			require.Equal(t, expected.Healthy, event.Healthy)
+			require.Equal(t, expected.Err, event.Err)
+		}
+	})
+}
+
 type mockBackendReader struct {
 	data atomic.String
 }
@@ -0,0 +1,19 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CertStatus returns the expiration time of the local certs.
+func (h *Server) CertStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.mgr.CertMgr.CertStatus())
+}
+
+func (h *Server) registerCert(group *gin.RouterGroup) {
+	group.GET("/", h.CertStatus)
+}
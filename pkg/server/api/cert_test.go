@@ -0,0 +1,27 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	mgrcrt "github.com/pingcap/tiproxy/pkg/manager/cert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertStatus(t *testing.T) {
+	_, doHTTP := createServer(t)
+
+	doHTTP(t, http.MethodGet, "/api/cert/", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		all, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var status []mgrcrt.CertStatus
+		require.NoError(t, json.Unmarshal(all, &status))
+		require.Empty(t, status)
+	})
+}
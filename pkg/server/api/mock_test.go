@@ -20,11 +20,14 @@ import (
 var _ namespace.NamespaceManager = (*mockNamespaceManager)(nil)
 
 type mockNamespaceManager struct {
-	success atomic.Bool
+	success  atomic.Bool
+	eventHub *observer.EventHub
 }
 
 func newMockNamespaceManager() *mockNamespaceManager {
-	mgr := &mockNamespaceManager{}
+	mgr := &mockNamespaceManager{
+		eventHub: observer.NewEventHub(),
+	}
 	mgr.success.Store(true)
 	return mgr
 }
@@ -42,6 +45,14 @@ func (m *mockNamespaceManager) GetNamespaceByUser(_ string) (*namespace.Namespac
 	return nil, false
 }
 
+func (m *mockNamespaceManager) GetNamespaceByVPCEndpoint(_ string) (*namespace.Namespace, bool) {
+	return nil, false
+}
+
+func (m *mockNamespaceManager) GetNamespaceBySNI(_ string) (*namespace.Namespace, bool) {
+	return nil, false
+}
+
 func (m *mockNamespaceManager) SetNamespace(_ context.Context, _ string, _ *config.Namespace) error {
 	if m.success.Load() {
 		return nil
@@ -64,6 +75,10 @@ func (m *mockNamespaceManager) RedirectConnections() []error {
 	return []error{errors.New("mock error")}
 }
 
+func (m *mockNamespaceManager) BackendEventHub() *observer.EventHub {
+	return m.eventHub
+}
+
 func (m *mockNamespaceManager) Close() error {
 	return nil
 }
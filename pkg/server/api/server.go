@@ -58,6 +58,7 @@ type Managers struct {
 	CertMgr       *mgrcrt.CertManager
 	BackendReader BackendReader
 	ReplayJobMgr  mgrrp.JobManager
+	SQLStats      SQLStatsReader
 }
 
 type Server struct {
@@ -100,7 +101,7 @@ func NewServer(cfg config.API, lg *zap.Logger, mgr Managers, handler HTTPHandler
 		return nil, err
 	}
 	switch cfg.ProxyProtocol {
-	case "v2":
+	case "v1", "v2":
 		h.listener = proxyprotocol.NewListener(h.listener)
 	}
 
@@ -209,6 +210,8 @@ func (h *Server) registerAPI(g *gin.RouterGroup) {
 	h.registerDebug(g.Group("debug"))
 	h.registerBackend(g.Group("backend"))
 	h.registerTraffic(g.Group("traffic"))
+	h.registerSQL(g.Group("sql"))
+	h.registerCert(g.Group("cert"))
 }
 
 func (h *Server) PreClose() {
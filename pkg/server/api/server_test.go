@@ -14,6 +14,7 @@ import (
 	"github.com/pingcap/tiproxy/lib/util/logger"
 	mgrcrt "github.com/pingcap/tiproxy/pkg/manager/cert"
 	mgrcfg "github.com/pingcap/tiproxy/pkg/manager/config"
+	"github.com/pingcap/tiproxy/pkg/proxy/backend"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 	"google.golang.org/grpc"
@@ -43,6 +44,7 @@ func createServer(t *testing.T) (*Server, doHTTPFunc) {
 		CertMgr:       crtmgr,
 		BackendReader: &mockBackendReader{},
 		ReplayJobMgr:  &mockReplayJobManager{},
+		SQLStats:      backend.NewSQLStats(cfgmgr.GetConfig().SQLStats),
 	}, nil, ready)
 	require.NoError(t, err)
 	t.Cleanup(func() {
@@ -0,0 +1,46 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/proxy/backend"
+)
+
+type SQLStatsReader interface {
+	Top(groupBy, orderBy string, limit int) (backend.SQLStatsResult, error)
+}
+
+func (h *Server) SQLStats(c *gin.Context) {
+	if !h.mgr.CfgMgr.GetConfig().SQLStats.Enable {
+		c.String(http.StatusBadRequest, "sql stats is disabled")
+		return
+	}
+	var limit int
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	result, err := h.mgr.SQLStats.Top(c.Query("group-by"), c.Query("order-by"), limit)
+	if err != nil {
+		if errors.Is(err, backend.ErrInvalidSQLStatsArg) {
+			c.String(http.StatusBadRequest, err.Error())
+		} else {
+			c.String(http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Server) registerSQL(group *gin.RouterGroup) {
+	group.GET("/stats", h.SQLStats)
+}
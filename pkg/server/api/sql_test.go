@@ -0,0 +1,64 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/pingcap/tiproxy/pkg/proxy/backend"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSQLStatsReader struct {
+	groupBy, orderBy string
+	limit            int
+	result           backend.SQLStatsResult
+}
+
+func (m *mockSQLStatsReader) Top(groupBy, orderBy string, limit int) (backend.SQLStatsResult, error) {
+	m.groupBy, m.orderBy, m.limit = groupBy, orderBy, limit
+	return m.result, nil
+}
+
+func TestSQLStats(t *testing.T) {
+	server, doHTTP := createServer(t)
+	doHTTP(t, http.MethodGet, "/api/sql/stats", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusBadRequest, r.StatusCode)
+		all, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "sql stats is disabled", string(all))
+	})
+
+	server.mgr.CfgMgr.GetConfig().SQLStats.Enable = true
+	server.mgr.SQLStats.(*backend.SQLStats).SetConfig(server.mgr.CfgMgr.GetConfig().SQLStats)
+	doHTTP(t, http.MethodGet, "/api/sql/stats?order-by=unknown", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusBadRequest, r.StatusCode)
+	})
+	doHTTP(t, http.MethodGet, "/api/sql/stats?limit=abc", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusBadRequest, r.StatusCode)
+	})
+	doHTTP(t, http.MethodGet, "/api/sql/stats", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		var result backend.SQLStatsResult
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+		require.Empty(t, result.Records)
+	})
+
+	reader := &mockSQLStatsReader{result: backend.SQLStatsResult{
+		Records: []backend.SQLStatsRecord{{Digest: "abc", Query: "select ?", Count: 10}},
+	}}
+	server.mgr.SQLStats = reader
+	doHTTP(t, http.MethodGet, "/api/sql/stats?group-by=user&order-by=count&limit=5", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		var result backend.SQLStatsResult
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+		require.Equal(t, reader.result, result)
+	})
+	require.Equal(t, "user", reader.groupBy)
+	require.Equal(t, "count", reader.orderBy)
+	require.Equal(t, 5, reader.limit)
+}
@@ -60,12 +60,14 @@ type Server struct {
 }
 
 func NewServer(ctx context.Context, sctx *sctx.Context) (srv *Server, err error) {
+	nsMgr := mgrns.NewNamespaceManager()
 	srv = &Server{
 		configManager:    mgrcfg.NewConfigManager(),
 		metricsManager:   metrics.NewMetricsManager(),
-		namespaceManager: mgrns.NewNamespaceManager(),
+		namespaceManager: nsMgr,
 		certManager:      cert.NewCertManager(),
 	}
+	nsMgr.SetBackendTLSRemover(srv.certManager.RemoveNamespaceTLS)
 
 	handler := sctx.Handler
 	ready := atomic.NewBool(false)
@@ -188,7 +190,8 @@ func NewServer(ctx context.Context, sctx *sctx.Context) (srv *Server, err error)
 
 	// setup proxy server
 	{
-		srv.proxy, err = proxy.NewSQLServer(lg.Named("proxy"), cfg, srv.certManager, idMgr, srv.replay.GetCapture(), srv.meter, hsHandler)
+		srv.proxy, err = proxy.NewSQLServer(lg.Named("proxy"), cfg, srv.certManager, idMgr, srv.replay.GetCapture(), srv.meter, hsHandler,
+			proxy.WithSlowLogger(srv.loggerManager.SlowLogger()))
 		if err != nil {
 			return
 		}
@@ -202,6 +205,7 @@ func NewServer(ctx context.Context, sctx *sctx.Context) (srv *Server, err error)
 		CertMgr:       srv.certManager,
 		BackendReader: srv.metricsReader,
 		ReplayJobMgr:  srv.replay,
+		SQLStats:      srv.proxy.SQLStats(),
 	}
 	if srv.apiServer, err = api.NewServer(cfg.API, lg.Named("api"), mgrs, handler, ready); err != nil {
 		return
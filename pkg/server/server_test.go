@@ -6,6 +6,7 @@ package server
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/pelletier/go-toml/v2"
@@ -23,6 +24,8 @@ func TestServer(t *testing.T) {
 	configFile := dir + "/config.toml"
 	endpoint := etcdServer.Clients[0].Addr().String()
 	cfg := etcd.ConfigForEtcdTest(endpoint)
+	// The default workdir is under the source tree.
+	cfg.Workdir = filepath.Join(dir, "work")
 	b, err := toml.Marshal(cfg)
 	require.NoError(t, err)
 	require.NoError(t, os.WriteFile(configFile, b, 0o644))
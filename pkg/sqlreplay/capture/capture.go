@@ -44,8 +44,8 @@ type Capture interface {
 	// Stop stops the capture.
 	// err means the error that caused the capture to stop. nil means the capture stopped manually.
 	Stop(err error)
-	// InitConn is called when a new connection is created.
-	InitConn(startTime time.Time, connID uint64, db string)
+	// InitConn is called when a new connection is created. sni is the SNI hostname of the client, which may be empty.
+	InitConn(startTime time.Time, connID uint64, db, sni string)
 	// Capture captures traffic
 	Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, error))
 	// Progress returns the progress of the capture job
@@ -119,7 +119,7 @@ var _ Capture = (*capture)(nil)
 type capture struct {
 	sync.Mutex
 	cfg          CaptureConfig
-	conns        map[uint64]struct{}
+	conns        map[uint64]string // connID -> SNI that is not recorded yet
 	wg           waitgroup.WaitGroup
 	cancel       context.CancelFunc
 	storage      storage.ExternalStorage
@@ -161,7 +161,7 @@ func (c *capture) Start(cfg CaptureConfig) error {
 	c.filteredCmds = 0
 	c.status = statusRunning
 	c.err = nil
-	c.conns = make(map[uint64]struct{})
+	c.conns = make(map[uint64]string)
 	childCtx, cancel := context.WithTimeout(context.Background(), c.cfg.Duration)
 	c.cancel = cancel
 	bufCh := make(chan *bytes.Buffer, cfg.maxBuffers)
@@ -284,7 +284,7 @@ func (c *capture) flushBuffer(bufCh <-chan *bytes.Buffer) {
 	c.writeMeta(storage, time.Since(startTime), capturedCmds, filteredCmds)
 }
 
-func (c *capture) InitConn(startTime time.Time, connID uint64, db string) {
+func (c *capture) InitConn(startTime time.Time, connID uint64, db, sni string) {
 	c.Lock()
 	defer c.Unlock()
 	if c.status != statusRunning {
@@ -298,9 +298,12 @@ func (c *capture) InitConn(startTime time.Time, connID uint64, db string) {
 		if command == nil {
 			return
 		}
+		command.SNI = sni
 		c.putCommand(command)
+		sni = ""
 	}
-	c.conns[connID] = struct{}{}
+	// The SNI is recorded in the first command of the connection.
+	c.conns[connID] = sni
 }
 
 func (c *capture) Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, error)) {
@@ -331,7 +334,7 @@ func (c *capture) Capture(packet []byte, startTime time.Time, connID uint64, ini
 		command := cmd.NewCommand(initPacket, startTime, connID)
 		c.Lock()
 		if c.putCommand(command) {
-			c.conns[connID] = struct{}{}
+			c.conns[connID] = ""
 		}
 		c.Unlock()
 	}
@@ -342,6 +345,10 @@ func (c *capture) Capture(packet []byte, startTime time.Time, connID uint64, ini
 	}
 	c.Lock()
 	defer c.Unlock()
+	if sni := c.conns[connID]; len(sni) > 0 {
+		command.SNI = sni
+		c.conns[connID] = ""
+	}
 	c.putCommand(command)
 }
 
@@ -412,7 +419,7 @@ func (c *capture) stopNoLock(err error) {
 	if c.err == nil {
 		c.err = err
 	}
-	c.conns = map[uint64]struct{}{}
+	c.conns = map[uint64]string{}
 }
 
 func (c *capture) stop(err error) {
@@ -99,7 +99,7 @@ func TestConcurrency(t *testing.T) {
 			case <-ctx.Done():
 				return
 			case <-time.After(100 * time.Microsecond):
-				cpt.InitConn(time.Now(), uint64(i), "abc")
+				cpt.InitConn(time.Now(), uint64(i), "abc", "")
 			}
 		}
 	})
@@ -253,7 +253,7 @@ func TestInitConn(t *testing.T) {
 	}
 
 	require.NoError(t, cpt.Start(cfg))
-	cpt.InitConn(time.Now(), 100, "mockDB")
+	cpt.InitConn(time.Now(), 100, "mockDB", "")
 	cpt.Capture(packet, time.Now(), 100, func() (string, error) {
 		return "init session 100", nil
 	})
@@ -273,6 +273,35 @@ func TestInitConn(t *testing.T) {
 	require.Equal(t, uint64(4), cpt.capturedCmds)
 }
 
+func TestCaptureSNI(t *testing.T) {
+	cpt := NewCapture(zap.NewNop())
+	defer cpt.Close()
+
+	packet := append([]byte{pnet.ComQuery.Byte()}, []byte("select 1")...)
+	writer := newMockWriter(store.WriterCfg{})
+	cfg := CaptureConfig{
+		Output:    t.TempDir(),
+		Duration:  10 * time.Second,
+		cmdLogger: writer,
+		StartTime: time.Now(),
+	}
+
+	require.NoError(t, cpt.Start(cfg))
+	// The SNI is recorded in the first command of the connection.
+	cpt.InitConn(time.Now(), 100, "mockDB", "tenant1.example.com")
+	cpt.InitConn(time.Now(), 101, "", "tenant2.example.com")
+	for _, connID := range []uint64{100, 101} {
+		for range 2 {
+			cpt.Capture(packet, time.Now(), connID, nil)
+		}
+	}
+	cpt.Stop(errors.Errorf("mock error"))
+	data := string(writer.getData())
+	require.Equal(t, 1, strings.Count(data, "# SNI: tenant1.example.com\n# Payload_len: 6\nmockDB"))
+	require.Equal(t, 1, strings.Count(data, "# SNI: tenant2.example.com\n# Payload_len: 8\nselect 1"))
+	require.Equal(t, 2, strings.Count(data, "# SNI: "))
+}
+
 func TestQuit(t *testing.T) {
 	cpt := NewCapture(zap.NewNop())
 	defer cpt.Close()
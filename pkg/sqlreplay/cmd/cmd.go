@@ -77,6 +77,8 @@ type Command struct {
 	kvs      map[string]string
 	// Logged only in native log.
 	Success bool
+	// SNI is the SNI hostname of the client. It's only recorded in the first command of a connection.
+	SNI string
 }
 
 func NewCommand(packet []byte, startTs time.Time, connID uint64) *Command {
@@ -101,6 +103,7 @@ func (c *Command) Equal(that *Command) bool {
 		c.ConnID == that.ConnID &&
 		c.Type == that.Type &&
 		c.Success == that.Success &&
+		c.SNI == that.SNI &&
 		bytes.Equal(c.Payload, that.Payload)
 }
 
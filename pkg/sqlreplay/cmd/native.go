@@ -22,6 +22,7 @@ const (
 	nativeKeyConnID       = "# Conn_ID: "
 	nativeKeyType         = "# Cmd_type: "
 	nativeKeySuccess      = "# Success: "
+	nativeKeySNI          = "# SNI: "
 	nativeKeyPayloadLen   = "# Payload_len: "
 )
 
@@ -53,6 +54,11 @@ func (rw *NativeEncoder) Encode(c *Command, writer *bytes.Buffer) error {
 			return err
 		}
 	}
+	if len(c.SNI) > 0 {
+		if err = writeString(nativeKeySNI, c.SNI, writer); err != nil {
+			return err
+		}
+	}
 	// `Payload_len` doesn't include the command type.
 	if err = writeString(nativeKeyPayloadLen, strconv.Itoa(len(c.Payload[1:])), writer); err != nil {
 		return err
@@ -132,6 +138,8 @@ func (rw *NativeDecoder) Decode(reader LineReader) (c *Command, err error) {
 			c.Type = pnet.CommandFromString(value)
 		case nativeKeySuccess:
 			c.Success = value == "true"
+		case nativeKeySNI:
+			c.SNI = value
 		case nativeKeyPayloadLen:
 			var payloadLen int
 			if payloadLen, err = strconv.Atoi(value); err != nil {
@@ -16,10 +16,12 @@ func TestEncode(t *testing.T) {
 	tests := []struct {
 		payload []byte
 		cmd     pnet.Command
+		sni     string
 	}{
 		{
 			cmd:     pnet.ComQuery,
 			payload: []byte("select 1"),
+			sni:     "tenant1.example.com",
 		},
 		{
 			cmd:     pnet.ComStmtSendLongData,
@@ -45,6 +47,7 @@ func TestEncode(t *testing.T) {
 		packet := append([]byte{byte(test.cmd)}, test.payload...)
 		now := time.Now()
 		cmd := NewCommand(packet, now, 100)
+		cmd.SNI = test.sni
 		require.NoError(t, encoder.Encode(cmd, &buf), "case %d", i)
 		cmds = append(cmds, cmd)
 	}
@@ -164,5 +164,10 @@ func (p *packetIO) TLSConnectionState() tls.ConnectionState {
 	return tls.ConnectionState{}
 }
 
+// ServerName implements net.PacketIO.
+func (p *packetIO) ServerName() string {
+	return ""
+}
+
 func (p *packetIO) ApplyOpts(opts ...pnet.PacketIOption) {
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/pingcap/tiproxy/pkg/manager/id"
 	"github.com/pingcap/tiproxy/pkg/proxy/backend"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/replay"
 	"github.com/siddontang/go/hack"
@@ -152,13 +153,14 @@ func (jm *jobManager) StartReplay(cfg replay.ReplayConfig) error {
 	}
 	// TODO: support update configs online
 	err := jm.replay.Start(cfg, jm.certManager.SQLTLS(), jm.hsHandler, &backend.BCConfig{
-		ProxyProtocol:      jm.cfg.Proxy.ProxyProtocol != "",
-		RequireBackendTLS:  jm.cfg.Security.RequireBackendTLS,
-		HealthyKeepAlive:   jm.cfg.Proxy.BackendHealthyKeepalive,
-		UnhealthyKeepAlive: jm.cfg.Proxy.BackendUnhealthyKeepalive,
-		ConnBufferSize:     jm.cfg.Proxy.ConnBufferSize,
-		DialTimeout:        dialTimeout,
-		ConnectTimeout:     connectTimeout,
+		ProxyProtocol:        jm.cfg.Proxy.ProxyProtocol != "",
+		ProxyProtocolVersion: proxyprotocol.ParseProxyVersion(jm.cfg.Proxy.ProxyProtocol),
+		RequireBackendTLS:    jm.cfg.Security.RequireBackendTLS,
+		HealthyKeepAlive:     jm.cfg.Proxy.BackendHealthyKeepalive,
+		UnhealthyKeepAlive:   jm.cfg.Proxy.BackendUnhealthyKeepalive,
+		ConnBufferSize:       jm.cfg.Proxy.ConnBufferSize,
+		DialTimeout:          dialTimeout,
+		ConnectTimeout:       connectTimeout,
 	})
 	if err != nil {
 		jm.lg.Warn("start replay failed", zap.String("job", newJob.String()), zap.Error(err))
@@ -29,7 +29,7 @@ type mockCapture struct {
 	done     bool
 }
 
-func (m *mockCapture) InitConn(startTime time.Time, connID uint64, db string) {
+func (m *mockCapture) InitConn(startTime time.Time, connID uint64, db, sni string) {
 }
 
 func (m *mockCapture) Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, error)) {
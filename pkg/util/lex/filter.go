@@ -108,3 +108,130 @@ var startTxnKeywords = [][]string{
 func IsStartTxn(sql string) bool {
 	return startsWithKeyword(sql, startTxnKeywords)
 }
+
+// sessionFuncs are the functions whose results depend on the session. The value is whether the function can be
+// called without parentheses.
+var sessionFuncs = map[string]bool{
+	"LAST_INSERT_ID": false,
+	"CONNECTION_ID":  false,
+	"FOUND_ROWS":     false,
+	"ROW_COUNT":      false,
+	"USER":           false,
+	"SESSION_USER":   false,
+	"SYSTEM_USER":    false,
+	"CURRENT_USER":   true,
+	"CURRENT_ROLE":   true,
+}
+
+// IsCacheable returns true if the statement only reads data and returns a result set, so its result can be cached.
+// It excludes SELECT FOR UPDATE, SELECT LOCK IN SHARE MODE, and SELECT INTO, which have side effects, and the
+// statements that use variables or call the functions in sessionFuncs, whose results differ among sessions.
+func IsCacheable(sql string) bool {
+	lexer := NewLexer(sql)
+	switch lexer.NextToken() {
+	case "SELECT", "WITH", "TABLE":
+	default:
+		return false
+	}
+	for {
+		token := lexer.NextToken()
+		if lexer.assigned || lexer.variable {
+			return false
+		}
+		switch token {
+		case "":
+			return true
+		case "FOR":
+			switch lexer.NextToken() {
+			case "UPDATE", "SHARE":
+				return false
+			}
+		case "LOCK", "INTO":
+			return false
+		default:
+			if noParen, ok := sessionFuncs[token]; ok && (noParen || lexer.followedByParen()) {
+				return false
+			}
+		}
+	}
+}
+
+// UseDB returns the database name if the statement is `USE db`.
+func UseDB(sql string) (string, bool) {
+	lexer := NewLexer(sql)
+	if lexer.NextToken() != "USE" || lexer.curIdx >= len(sql) {
+		return "", false
+	}
+	// The lexer has skipped the delimiter after USE, which may be a backtick.
+	db := strings.TrimSpace(sql[lexer.curIdx-1:])
+	db = strings.TrimSpace(strings.TrimRight(db, ";"))
+	if len(db) >= 2 && db[0] == '`' && db[len(db)-1] == '`' {
+		db = strings.ReplaceAll(db[1:len(db)-1], "``", "`")
+	}
+	return db, len(db) > 0
+}
+
+// ChangesSessionVars returns true if the statement is a SET statement, which may change the session variables,
+// the character set, the role, or the whole session states with SET SESSION_STATES.
+func ChangesSessionVars(sql string) bool {
+	return NewLexer(sql).NextToken() == "SET"
+}
+
+// ChangesSessionStates returns true if a read-only statement changes the session states, such as assigning user
+// variables with := or INTO, or setting the value of LAST_INSERT_ID() with LAST_INSERT_ID(expr).
+func ChangesSessionStates(sql string) bool {
+	lexer := NewLexer(sql)
+	for {
+		token := lexer.NextToken()
+		if lexer.assigned {
+			return true
+		}
+		switch token {
+		case "":
+			return false
+		case "INTO":
+			return true
+		case "LAST_INSERT_ID":
+			// The lexer has skipped the delimiter after the function name, which may be the parenthesis.
+			args := strings.TrimLeft(sql[lexer.curIdx-1:], " \t\r\n")
+			if strings.HasPrefix(args, "(") && !strings.HasPrefix(strings.TrimLeft(args[1:], " \t\r\n"), ")") {
+				return true
+			}
+		}
+	}
+}
+
+// LoadsLocalFile returns true if any statement is LOAD DATA LOCAL INFILE. followed is true if other statements follow
+// the first LOAD DATA LOCAL INFILE in the multi-statement.
+func LoadsLocalFile(sql string) (loads, followed bool) {
+	lexer := NewLexer(sql)
+	var prev string
+	separators := 0
+	for {
+		token := lexer.NextToken()
+		switch {
+		case token == "":
+			return
+		case loads:
+			if lexer.separators > separators {
+				return true, true
+			}
+		case token == "INFILE" && prev == "LOCAL":
+			loads, separators = true, lexer.separators
+		}
+		prev = token
+	}
+}
+
+// ContainsKeyword returns true if the SQL contains the keyword outside of comments and quotes.
+func ContainsKeyword(sql, keyword string) bool {
+	lexer := NewLexer(sql)
+	for {
+		switch lexer.NextToken() {
+		case "":
+			return false
+		case keyword:
+			return true
+		}
+	}
+}
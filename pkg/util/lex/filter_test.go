@@ -123,3 +123,128 @@ func TestStartTxn(t *testing.T) {
 		require.Equal(t, test.isBegin, IsStartTxn(test.stmt), test.stmt)
 	}
 }
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		sql       string
+		cacheable bool
+	}{
+		{`SELECT ? FROM table_name`, true},
+		{`/* dashboard */ select count(*) from t where a = 'for update'`, true},
+		{`WITH cte AS (SELECT 1, 2) SELECT * FROM cte`, true},
+		{`TABLE t1`, true},
+		{`select * from t for update`, false},
+		{`select * from t for share`, false},
+		{`select * from t lock in share mode`, false},
+		{`select a into @a from t`, false},
+		{`select @a`, false},
+		{`select @@sql_mode`, false},
+		{`select a := 1 from t`, false},
+		{`select last_insert_id()`, false},
+		{`SELECT CONNECTION_ID ()`, false},
+		{`select found_rows()`, false},
+		{`select current_user`, false},
+		{`select user()`, false},
+		{`select user, host from mysql.user`, true},
+		{`select '@a', 'found_rows()' from t`, true},
+		{`insert into t select * from t2`, false},
+		{`show tables`, false},
+		{`begin`, false},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.cacheable, IsCacheable(test.sql), test.sql)
+	}
+}
+
+func TestUseDB(t *testing.T) {
+	tests := []struct {
+		sql string
+		db  string
+		ok  bool
+	}{
+		{`use db1`, "db1", true},
+		{`USE  db_1 ;`, "db_1", true},
+		{"use`my db`", "my db", true},
+		{"/* c */ use `a``b`", "a`b", true},
+		{`use`, "", false},
+		{`use `, "", false},
+		{`select 1`, "", false},
+	}
+
+	for _, test := range tests {
+		db, ok := UseDB(test.sql)
+		require.Equal(t, test.ok, ok, test.sql)
+		require.Equal(t, test.db, db, test.sql)
+	}
+}
+
+func TestChangesSessionVars(t *testing.T) {
+	tests := []struct {
+		sql     string
+		changed bool
+	}{
+		{`set names utf8mb4`, true},
+		{`SET CHARACTER SET gbk`, true},
+		{`set @@character_set_results = latin1`, true},
+		{`set @a = 1, autocommit = 0`, true},
+		{`/* c */ SET SESSION_STATES '{}'`, true},
+		{`set role r1`, true},
+		{`select @@character_set_results`, false},
+		{`select 'set names utf8'`, false},
+		{`update t set a = 1`, false},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.changed, ChangesSessionVars(test.sql), test.sql)
+	}
+}
+
+func TestChangesSessionStates(t *testing.T) {
+	tests := []struct {
+		sql     string
+		changed bool
+	}{
+		{`select @a := 1`, true},
+		{`SELECT a INTO @v FROM t`, true},
+		{`select * from t into outfile '/tmp/a'`, true},
+		{`select last_insert_id(10)`, true},
+		{`SELECT LAST_INSERT_ID ( id + 1 ) FROM t`, true},
+		{`select last_insert_id()`, false},
+		{`select last_insert_id( )`, false},
+		{`select @a, a = 1 from t`, false},
+		{`select ':=', 'into' /* := */`, false},
+		{`select last_insert_id`, false},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.changed, ChangesSessionStates(test.sql), test.sql)
+	}
+}
+
+func TestLoadsLocalFile(t *testing.T) {
+	tests := []struct {
+		sql      string
+		loads    bool
+		followed bool
+	}{
+		{`LOAD DATA LOCAL INFILE '/tmp/a' INTO TABLE t`, true, false},
+		{`load data local infile '/tmp/a' into table t;`, true, false},
+		{`select 1; load data local infile '/tmp/a' into table t`, true, false},
+		{`load data local infile '/tmp/a; b' into table t; insert into t values (1)`, true, true},
+		{`load data local infile '/tmp/a' into table t /* ; */ -- ;`, true, false},
+		{`load data infile '/tmp/a' into table t; select 1`, false, false},
+		{`select 'local infile'`, false, false},
+	}
+
+	for _, test := range tests {
+		loads, followed := LoadsLocalFile(test.sql)
+		require.Equal(t, test.loads, loads, test.sql)
+		require.Equal(t, test.followed, followed, test.sql)
+	}
+}
+
+func TestContainsKeyword(t *testing.T) {
+	require.True(t, ContainsKeyword("select 1; use db", "USE"))
+	require.False(t, ContainsKeyword("select 'use'; select user from t", "USE"))
+}
@@ -3,10 +3,20 @@
 
 package lex
 
+import "strings"
+
 type Lexer struct {
 	sql      string
 	curToken []byte
 	curIdx   int
+	// assigned is whether := is found outside of comments and quotes so far.
+	assigned bool
+	// variable is whether @ is found outside of comments and quotes so far.
+	variable bool
+	// separators is the number of `;` found outside of comments and quotes before the current token.
+	separators int
+	// endsWithSeparator is whether the current token is ended by `;`, which is counted before the next token.
+	endsWithSeparator bool
 }
 
 func NewLexer(sql string) *Lexer {
@@ -20,6 +30,10 @@ func NewLexer(sql string) *Lexer {
 // It doesn't need to strict but it needs to be fast enough.
 func (l *Lexer) NextToken() string {
 	l.curToken = l.curToken[:0]
+	if l.endsWithSeparator {
+		l.separators++
+		l.endsWithSeparator = false
+	}
 	inSingleLineComment, inMultiLineComment, inSingleQuote, inDoubleQuote := false, false, false, false
 	for ; l.curIdx < len(l.sql); l.curIdx++ {
 		char := l.sql[l.curIdx]
@@ -62,6 +76,19 @@ func (l *Lexer) NextToken() string {
 		case char >= 'A' && char <= 'Z' || char == '_':
 			l.curToken = append(l.curToken, char)
 		default:
+			if char == ':' && l.curIdx+1 < len(l.sql) && l.sql[l.curIdx+1] == '=' {
+				l.assigned = true
+			}
+			if char == '@' {
+				l.variable = true
+			}
+			if char == ';' {
+				if len(l.curToken) > 0 {
+					l.endsWithSeparator = true
+				} else {
+					l.separators++
+				}
+			}
 			if len(l.curToken) > 0 {
 				l.curIdx++
 				return string(l.curToken)
@@ -74,3 +101,12 @@ func (l *Lexer) NextToken() string {
 	}
 	return ""
 }
+
+// followedByParen returns true if the current token is followed by `(`, e.g. it's a function call.
+func (l *Lexer) followedByParen() bool {
+	// The lexer has skipped the delimiter after the token, which may be the parenthesis.
+	if l.curIdx == 0 || l.curIdx > len(l.sql) {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimLeft(l.sql[l.curIdx-1:], " \t\r\n"), "(")
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package lex
+
+import (
+	"strings"
+)
+
+// After these keywords, a sign before a number is unary and belongs to the literal.
+var unaryKeywords = map[string]struct{}{
+	"SELECT": {}, "WHERE": {}, "AND": {}, "OR": {}, "NOT": {}, "XOR": {}, "CASE": {}, "WHEN": {}, "THEN": {}, "ELSE": {},
+	"BETWEEN": {}, "IN": {}, "LIKE": {}, "IS": {}, "ON": {}, "BY": {}, "SET": {}, "VALUES": {}, "VALUE": {}, "HAVING": {},
+	"LIMIT": {}, "OFFSET": {}, "INTERVAL": {}, "DIV": {}, "MOD": {}, "REGEXP": {}, "RETURN": {}, "DEFAULT": {},
+}
+
+// ExtractParams returns the literals and the `?` placeholders in the SQL in order, and the SQL in which they are
+// replaced with `?`. It's not strict, so the caller should verify the result, e.g. by comparing the normalized SQL.
+func ExtractParams(sql string) (params []string, stripped string) {
+	spans := scanParams(sql)
+	if len(spans) == 0 {
+		return nil, sql
+	}
+	var sb strings.Builder
+	sb.Grow(len(sql))
+	params = make([]string, 0, len(spans))
+	last := 0
+	for _, span := range spans {
+		params = append(params, sql[span[0]:span[1]])
+		sb.WriteString(sql[last:span[0]])
+		sb.WriteByte('?')
+		last = span[1]
+	}
+	sb.WriteString(sql[last:])
+	return params, sb.String()
+}
+
+// FillParams replaces the `?` placeholders in the template with the params in order.
+// It returns false if the number of placeholders doesn't equal the number of params.
+func FillParams(template string, params []string) (string, bool) {
+	spans := scanParams(template)
+	var sb strings.Builder
+	sb.Grow(len(template))
+	last, idx := 0, 0
+	for _, span := range spans {
+		if template[span[0]:span[1]] != "?" {
+			continue
+		}
+		if idx >= len(params) {
+			return "", false
+		}
+		sb.WriteString(template[last:span[0]])
+		sb.WriteString(params[idx])
+		last = span[1]
+		idx++
+	}
+	if idx != len(params) {
+		return "", false
+	}
+	sb.WriteString(template[last:])
+	return sb.String(), true
+}
+
+// scanParams returns the positions of string literals, numeric literals and `?` placeholders.
+func scanParams(sql string) (spans [][2]int) {
+	// operand indicates whether the previous token is an operand, in which case a following sign is a binary operator.
+	operand := false
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == '#' || (c == '-' && i+1 < len(sql) && sql[i+1] == '-' && (i+2 == len(sql) || isSpace(sql[i+2]))):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+		case c == '\'' || c == '"':
+			end := skipQuote(sql, i)
+			spans = append(spans, [2]int{i, end})
+			i, operand = end, true
+		case c == '`':
+			i, operand = skipQuote(sql, i), true
+		case c == '?':
+			spans = append(spans, [2]int{i, i + 1})
+			i, operand = i+1, true
+		case isNumberStart(sql, i):
+			end := scanNumber(sql, i)
+			spans = append(spans, [2]int{i, end})
+			i, operand = end, true
+		case (c == '-' || c == '+') && !operand:
+			j := i + 1
+			for j < len(sql) && isSpace(sql[j]) {
+				j++
+			}
+			if isNumberStart(sql, j) {
+				end := scanNumber(sql, j)
+				spans = append(spans, [2]int{i, end})
+				i, operand = end, true
+			} else {
+				i++
+			}
+		case isIdentChar(c):
+			j := i + 1
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			word := sql[i:j]
+			// x'1F' and b'01'
+			if j < len(sql) && sql[j] == '\'' && (word == "x" || word == "X" || word == "b" || word == "B") {
+				end := skipQuote(sql, j)
+				spans = append(spans, [2]int{i, end})
+				i, operand = end, true
+				break
+			}
+			_, keyword := unaryKeywords[strings.ToUpper(word)]
+			i, operand = j, !keyword
+		default:
+			i, operand = i+1, c == ')'
+		}
+	}
+	return
+}
+
+func skipQuote(sql string, i int) int {
+	quote := sql[i]
+	for j := i + 1; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			if quote != '`' {
+				j++
+			}
+		case quote:
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+func isNumberStart(sql string, i int) bool {
+	if i >= len(sql) {
+		return false
+	}
+	if isDigit(sql[i]) {
+		return true
+	}
+	return sql[i] == '.' && i+1 < len(sql) && isDigit(sql[i+1]) && (i == 0 || !isIdentChar(sql[i-1]))
+}
+
+func scanNumber(sql string, i int) int {
+	j := i
+	// 0x1F and 0b01
+	if sql[j] == '0' && j+2 < len(sql) && (sql[j+1] == 'x' || sql[j+1] == 'b') {
+		j += 2
+		for j < len(sql) && isIdentChar(sql[j]) {
+			j++
+		}
+		return j
+	}
+	for j < len(sql) && isDigit(sql[j]) {
+		j++
+	}
+	if j < len(sql) && sql[j] == '.' {
+		j++
+		for j < len(sql) && isDigit(sql[j]) {
+			j++
+		}
+	}
+	if j+1 < len(sql) && (sql[j] == 'e' || sql[j] == 'E') {
+		k := j + 1
+		if sql[k] == '+' || sql[k] == '-' {
+			k++
+		}
+		if k < len(sql) && isDigit(sql[k]) {
+			j = k
+			for j < len(sql) && isDigit(sql[j]) {
+				j++
+			}
+		}
+	}
+	// Identifiers may start with digits, such as `1a`.
+	for j < len(sql) && isIdentChar(sql[j]) {
+		j++
+	}
+	return j
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c == '_' || c == '$' || c == '@' || c >= 0x80
+}
@@ -0,0 +1,82 @@
+// Copyright 2025 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package lex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractParams(t *testing.T) {
+	tests := []struct {
+		sql      string
+		params   []string
+		stripped string
+	}{
+		{
+			sql:      "select * from t where id = 1",
+			params:   []string{"1"},
+			stripped: "select * from t where id = ?",
+		},
+		{
+			sql:      "select a - 1, -2.5e3, b*-3 from t where c = 'it''s' and d in (\"x\", 0x1F, x'1f', b'01')",
+			params:   []string{"1", "-2.5e3", "-3", "'it''s'", `"x"`, "0x1F", "x'1f'", "b'01'"},
+			stripped: "select a - ?, ?, b*? from t where c = ? and d in (?, ?, ?, ?)",
+		},
+		{
+			sql:      "select `a1`, t1.c, @v2 from t1 -- 1\n /* 2 */ where e = '\\'' limit 10, 20",
+			params:   []string{"'\\''", "10", "20"},
+			stripped: "select `a1`, t1.c, @v2 from t1 -- 1\n /* 2 */ where e = ? limit ?, ?",
+		},
+		{
+			sql:      "select * from t where id = ? and name = 'a?'",
+			params:   []string{"?", "'a?'"},
+			stripped: "select * from t where id = ? and name = ?",
+		},
+		{
+			sql:      "select null from dual",
+			stripped: "select null from dual",
+		},
+	}
+	for i, test := range tests {
+		params, stripped := ExtractParams(test.sql)
+		require.Equal(t, test.params, params, "case %d", i)
+		require.Equal(t, test.stripped, stripped, "case %d", i)
+	}
+}
+
+func TestFillParams(t *testing.T) {
+	tests := []struct {
+		template string
+		params   []string
+		sql      string
+		ok       bool
+	}{
+		{
+			template: "select /*+ use_index(t, idx) */ * from t where id = ? and name = 'a?'",
+			params:   []string{"-1"},
+			sql:      "select /*+ use_index(t, idx) */ * from t where id = -1 and name = 'a?'",
+			ok:       true,
+		},
+		{
+			template: "select * from t limit 100",
+			ok:       true,
+			sql:      "select * from t limit 100",
+		},
+		{
+			template: "select * from t where a = ? and b = ?",
+			params:   []string{"1"},
+		},
+		{
+			template: "select * from t where a = ?",
+			params:   []string{"1", "2"},
+		},
+	}
+	for i, test := range tests {
+		sql, ok := FillParams(test.template, test.params)
+		require.Equal(t, test.ok, ok, "case %d", i)
+		require.Equal(t, test.sql, sql, "case %d", i)
+	}
+}